@@ -14,14 +14,15 @@ import (
 
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/service"
 )
 
 var (
 	dryRun         = flag.Bool("dry-run", true, "Dry run mode, don't actually delete files")
-	uploadExpire   = flag.Int("upload-expire", 24, "Hours to keep uploaded source files")
+	uploadExpire   = flag.Int("upload-expire", 24, "Hours to keep untracked upload dirs and chunk sessions")
 	diagramExpire  = flag.Int("diagram-expire", 7, "Days to keep local diagram files")
 	cleanUploads   = flag.Bool("clean-uploads", true, "Clean expired upload files")
-	cleanDiagrams  = flag.Bool("clean-diagrams", true, "Clean diagrams migrated to OSS")
+	cleanDiagrams  = flag.Bool("clean-diagrams", true, "Clean local diagrams migrated to remote storage")
 )
 
 func main() {
@@ -54,15 +55,30 @@ func main() {
 
 	// 1. 清理过期的上传文件
 	if *cleanUploads {
-		log.Printf("\n📦 Cleaning expired upload files (older than %d hours)...", *uploadExpire)
-		size, count := cleanExpiredUploads(uploadDir, *uploadExpire, *dryRun)
+		log.Printf("\n📦 Cleaning uploads past their expires_at...")
+		size, count := cleanExpiredUploads(db, uploadDir, *dryRun)
 		deletedSize += size
 		deletedFiles += count
+
+		// 没有 active 记录的目录（历史遗留或记录写入失败）按修改时间清理
+		log.Printf("\n🗂  Cleaning untracked upload directories (older than %d hours)...", *uploadExpire)
+		size, count = cleanUntrackedUploads(db, uploadDir, *uploadExpire, *dryRun)
+		deletedSize += size
+		deletedFiles += count
+
+		// 分片上传会话位于 chunks 子目录下，按同样的过期时间清理
+		chunkDir := filepath.Join(uploadDir, service.ChunkSessionDir)
+		if _, err := os.Stat(chunkDir); err == nil {
+			log.Printf("\n🧩 Cleaning expired chunked upload sessions (older than %d hours)...", *uploadExpire)
+			size, count := cleanStaleDirs(chunkDir, *uploadExpire, nil, *dryRun)
+			deletedSize += size
+			deletedFiles += count
+		}
 	}
 
-	// 2. 清理已迁移到OSS的diagram文件
+	// 2. 清理已同步到远程存储的diagram文件
 	if *cleanDiagrams {
-		log.Printf("\n📊 Cleaning diagrams migrated to OSS...")
+		log.Printf("\n📊 Cleaning diagrams migrated to remote storage...")
 		size, count := cleanMigratedDiagrams(db, uploadDir, *diagramExpire, *dryRun)
 		deletedSize += size
 		deletedFiles += count
@@ -95,29 +111,88 @@ func main() {
 	log.Println(strings.Repeat("=", 60))
 }
 
-// cleanExpiredUploads 清理过期的上传文件
-func cleanExpiredUploads(uploadDir string, expireHours int, dryRun bool) (int64, int) {
-	expireTime := time.Now().Add(-time.Duration(expireHours) * time.Hour)
+// cleanExpiredUploads 根据 uploads 表清理已到期的上传目录，并标记为 expired
+func cleanExpiredUploads(db *gorm.DB, uploadDir string, dryRun bool) (int64, int) {
 	var totalSize int64
 	var count int
 
-	entries, err := os.ReadDir(uploadDir)
+	var uploads []model.Upload
+	err := db.Where("status = ? AND expires_at < ?", "active", time.Now()).
+		Find(&uploads).Error
 	if err != nil {
-		log.Printf("Failed to read upload dir: %v", err)
+		log.Printf("Failed to query expired uploads: %v", err)
 		return 0, 0
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+	for _, upload := range uploads {
+		dirPath := filepath.Join(uploadDir, upload.ID)
+		size := getDirSize(dirPath)
+		totalSize += size
+
+		log.Printf("  - %s (user %d, %.2f MB, expired %s ago)",
+			upload.ID,
+			upload.UserID,
+			float64(size)/1024/1024,
+			time.Since(upload.ExpiresAt).Round(time.Minute))
+
+		if !dryRun {
+			if err := os.RemoveAll(dirPath); err != nil {
+				log.Printf("    ❌ Failed to delete: %v", err)
+				continue
+			}
+			if err := db.Model(&model.Upload{}).Where("id = ?", upload.ID).
+				Update("status", "expired").Error; err != nil {
+				log.Printf("    ❌ Failed to mark expired: %v", err)
+				continue
+			}
 		}
+		count++
+	}
+
+	log.Printf("Found %d expired uploads (total: %s)", count, formatSize(totalSize))
+
+	return totalSize, count
+}
+
+// cleanUntrackedUploads 清理 uploads 表中没有 active 记录的上传目录
+func cleanUntrackedUploads(db *gorm.DB, uploadDir string, expireHours int, dryRun bool) (int64, int) {
+	var activeIDs []string
+	err := db.Model(&model.Upload{}).Where("status = ?", "active").Pluck("id", &activeIDs).Error
+	if err != nil {
+		log.Printf("Failed to query active uploads: %v", err)
+		return 0, 0
+	}
+
+	// 跳过diagrams目录、分片上传会话目录和仍在有效期内的上传
+	keep := map[string]bool{
+		"diagrams":              true,
+		service.ChunkSessionDir: true,
+	}
+	for _, id := range activeIDs {
+		keep[id] = true
+	}
 
-		// 跳过diagrams目录
-		if entry.Name() == "diagrams" {
+	return cleanStaleDirs(uploadDir, expireHours, keep, dryRun)
+}
+
+// cleanStaleDirs 清理 dir 下修改时间超过 expireHours 的子目录，keep 中的目录保留
+func cleanStaleDirs(dir string, expireHours int, keep map[string]bool, dryRun bool) (int64, int) {
+	expireTime := time.Now().Add(-time.Duration(expireHours) * time.Hour)
+	var totalSize int64
+	var count int
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Failed to read dir %s: %v", dir, err)
+		return 0, 0
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || keep[entry.Name()] {
 			continue
 		}
 
-		dirPath := filepath.Join(uploadDir, entry.Name())
+		dirPath := filepath.Join(dir, entry.Name())
 		info, err := entry.Info()
 		if err != nil {
 			continue
@@ -145,56 +220,64 @@ func cleanExpiredUploads(uploadDir string, expireHours int, dryRun bool) (int64,
 		}
 	}
 
-	log.Printf("Found %d expired upload directories (total: %s)",
+	log.Printf("Found %d expired directories (total: %s)",
 		count, formatSize(totalSize))
 
 	return totalSize, count
 }
 
-// cleanMigratedDiagrams 清理已迁移到OSS的diagram文件
+// cleanMigratedDiagrams 清理已同步到远程存储的本地diagram文件（<id>.json 或 <id>/ 目录）
 func cleanMigratedDiagrams(db *gorm.DB, uploadDir string, keepDays int, dryRun bool) (int64, int) {
 	diagramDir := filepath.Join(uploadDir, "diagrams")
 	var totalSize int64
 	var count int
 
-	// 获取所有已迁移到OSS的分析记录
+	// 获取框图已在远程存储的分析记录
 	var analyses []model.Analysis
-	err := db.Where("diagram_oss_url LIKE ?", "https://%").
+	err := db.Where("diagram_backend <> ? AND diagram_key <> ''", "local").
 		Find(&analyses).Error
 	if err != nil {
 		log.Printf("Failed to query analyses: %v", err)
 		return 0, 0
 	}
 
-	log.Printf("Found %d analyses migrated to OSS", len(analyses))
+	log.Printf("Found %d analyses migrated to remote storage", len(analyses))
 
 	// 为了安全，只删除超过N天的旧文件
 	expireTime := time.Now().Add(-time.Duration(keepDays) * 24 * time.Hour)
 
 	for _, analysis := range analyses {
-		localPath := filepath.Join(diagramDir, fmt.Sprintf("%d.json", analysis.ID))
+		for _, name := range []string{fmt.Sprintf("%d.json", analysis.ID), fmt.Sprintf("%d", analysis.ID)} {
+			localPath := filepath.Join(diagramDir, name)
 
-		// 检查文件是否存在
-		info, err := os.Stat(localPath)
-		if os.IsNotExist(err) {
-			continue // 文件不存在，跳过
-		}
-		if err != nil {
-			log.Printf("  ⚠️  Failed to stat %d.json: %v", analysis.ID, err)
-			continue
-		}
+			// 检查文件是否存在
+			info, err := os.Stat(localPath)
+			if os.IsNotExist(err) {
+				continue // 文件不存在，跳过
+			}
+			if err != nil {
+				log.Printf("  ⚠️  Failed to stat %s: %v", name, err)
+				continue
+			}
 
-		// 只删除超过指定天数的文件（安全措施）
-		if info.ModTime().Before(expireTime) {
-			totalSize += info.Size()
+			// 只删除超过指定天数的文件（安全措施）
+			if !info.ModTime().Before(expireTime) {
+				continue
+			}
+			size := info.Size()
+			if info.IsDir() {
+				size = getDirSize(localPath)
+			}
+			totalSize += size
 
-			log.Printf("  - %d.json (%.2f KB, migrated to OSS, %s old)",
-				analysis.ID,
-				float64(info.Size())/1024,
+			log.Printf("  - %s (%.2f KB, migrated to %s, %s old)",
+				name,
+				float64(size)/1024,
+				analysis.DiagramBackend,
 				time.Since(info.ModTime()).Round(time.Hour))
 
 			if !dryRun {
-				if err := os.Remove(localPath); err != nil {
+				if err := os.RemoveAll(localPath); err != nil {
 					log.Printf("    ❌ Failed to delete: %v", err)
 				} else {
 					count++
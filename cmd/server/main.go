@@ -10,10 +10,15 @@ import (
 	"github.com/qs3c/anal_go_server/internal/api/handler"
 	"github.com/qs3c/anal_go_server/internal/database"
 	"github.com/qs3c/anal_go_server/internal/pkg/cron"
+	"github.com/qs3c/anal_go_server/internal/pkg/email"
+	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
 	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
-	"github.com/qs3c/anal_go_server/internal/pkg/oss"
 	"github.com/qs3c/anal_go_server/internal/pkg/pubsub"
 	"github.com/qs3c/anal_go_server/internal/pkg/queue"
+	"github.com/qs3c/anal_go_server/internal/pkg/ratelimit"
+	"github.com/qs3c/anal_go_server/internal/pkg/revocation"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/pkg/ws"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/service"
@@ -48,17 +53,18 @@ func main() {
 	go wsHub.Run()
 	log.Println("WebSocket hub started")
 
-	// 初始化 OSS 客户端（可选）
-	var ossClient *oss.Client
-	if cfg.OSS.Endpoint != "" {
-		var err error
-		ossClient, err = oss.NewClient(&cfg.OSS)
-		if err != nil {
-			log.Printf("Warning: Failed to initialize OSS client: %v", err)
-		} else {
-			log.Println("OSS client initialized")
-		}
+	// 初始化对象存储，主存储初始化失败时使用本地存储
+	localStore, err := storage.NewLocalFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize local storage: %v", err)
+	}
+	primaryStore, err := storage.New(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize storage: %v, using local storage", err)
+		primaryStore = localStore
 	}
+	stores := storage.NewSet(primaryStore, localStore)
+	log.Printf("Storage initialized: %s", primaryStore.Name())
 
 	// 初始化 Repository
 	userRepo := repository.NewUserRepository(db)
@@ -66,15 +72,43 @@ func main() {
 	jobRepo := repository.NewJobRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
 	commentRepo := repository.NewCommentRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	scheduleRepo := repository.NewScheduleRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	channelRepo := repository.NewChannelRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	accessTokenRepo := repository.NewAccessTokenRepository(db)
+
+	// 初始化邮件发件箱，邮件先写入 Redis，由后台按退避时间发送和重试
+	emailOutbox := email.NewOutbox(rdb, email.NewSMTPTransport(&cfg.Email), cfg.Email.MaxAttempts)
+	mailer := email.NewServiceWithTransport(&cfg.Email, emailOutbox)
+	go emailOutbox.Run(context.Background())
+	log.Println("Email outbox started")
+
+	// 初始化 JWT 密钥集，后台定期重新加载并按配置轮换
+	jwtKeys, err := jwt.LoadKeySet(&cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	go jwt.NewRotator(jwtKeys, &cfg.JWT, lock.NewLocker(rdb)).Run(context.Background())
+	log.Printf("JWT signing key: %q", jwtKeys.SigningKeyID())
 
 	// 初始化 Service
-	authService := service.NewAuthService(userRepo, cfg)
-	userService := service.NewUserService(userRepo, ossClient, cfg)
+	authService := service.NewAuthService(userRepo, passwordResetRepo, refreshTokenRepo, accessTokenRepo, mailer,
+		ratelimit.NewLimiter(rdb), revocation.NewList(rdb), jwtKeys, cfg)
+	userService := service.NewUserService(userRepo, stores.Primary, cfg)
 	quotaService := service.NewQuotaService(userRepo, cfg)
-	uploadService := service.NewUploadService(cfg)
-	analysisService := service.NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, uploadService, ossClient, jobQueue, cfg)
-	communityService := service.NewCommunityService(analysisRepo, interactionRepo, cfg)
-	commentService := service.NewCommentService(commentRepo, analysisRepo, userRepo, cfg)
+	uploadService := service.NewUploadService(uploadRepo, cfg)
+	analysisService := service.NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, uploadService, stores, jobQueue, cfg)
+	notificationService := service.NewNotificationService(notificationRepo, pubsub.NewPublisher(rdb), cfg)
+	communityService := service.NewCommunityService(analysisRepo, interactionRepo, stores, notificationService, cfg)
+	commentService := service.NewCommentService(commentRepo, analysisRepo, userRepo, notificationService, cfg)
+	scheduleService := service.NewScheduleService(scheduleRepo, analysisRepo, cfg)
+	webhookService := service.NewWebhookService(webhookRepo, analysisRepo, analysisService)
+	channelService := service.NewChannelService(channelRepo, userRepo, cfg)
+	accessTokenService := service.NewAccessTokenService(accessTokenRepo, cfg)
 
 	// 初始化 OAuth StateStore
 	stateStore := oauth.NewStateStore(rdb)
@@ -84,14 +118,21 @@ func main() {
 	userHandler := handler.NewUserHandler(userService)
 	analysisHandler := handler.NewAnalysisHandler(analysisService)
 	modelsHandler := handler.NewModelsHandler(cfg)
-	websocketHandler := handler.NewWebSocketHandler(wsHub, cfg.JWT.Secret)
+	websocketHandler := handler.NewWebSocketHandler(wsHub, jwtKeys, authService)
 	communityHandler := handler.NewCommunityHandler(communityService)
 	commentHandler := handler.NewCommentHandler(commentService)
 	quotaHandler := handler.NewQuotaHandler(quotaService)
 	uploadHandler := handler.NewUploadHandler(uploadService, cfg)
+	fileHandler := handler.NewFileHandler(localStore)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	channelHandler := handler.NewChannelHandler(channelService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	accessTokenHandler := handler.NewAccessTokenHandler(accessTokenService)
 
 	// 初始化 Cron 服务
-	cronService := cron.NewService(quotaService, analysisRepo, cfg.Upload.TempDir, cfg.Upload.ExpireHours)
+	cronService := cron.NewService(quotaService, analysisService, analysisRepo, scheduleRepo, uploadRepo,
+		lock.NewLocker(rdb), cfg.Upload.TempDir, cfg.Upload.ExpireHours)
 	cronService.Start()
 	log.Println("Cron service started")
 
@@ -106,6 +147,15 @@ func main() {
 		commentHandler,
 		quotaHandler,
 		uploadHandler,
+		fileHandler,
+		scheduleHandler,
+		webhookHandler,
+		channelHandler,
+		notificationHandler,
+		accessTokenHandler,
+		jwtKeys,
+		authService,
+		accessTokenService,
 		cfg,
 	)
 	engine := router.Setup()
@@ -139,7 +189,7 @@ func main() {
 			// 如果任务完成，获取 diagram_oss_url
 			if msg.Status == "completed" {
 				if analysis, err := analysisRepo.GetByID(msg.AnalysisID); err == nil && analysis != nil {
-					data["diagram_oss_url"] = analysis.DiagramOSSURL
+					data["diagram_oss_url"] = analysisService.DiagramURL(analysis)
 				}
 			}
 
@@ -156,6 +206,22 @@ func main() {
 	}()
 	log.Println("Redis subscriber started")
 
+	// 转发站内通知到 WebSocket，每个实例只投递给本机上的连接
+	go func() {
+		err := subscriber.SubscribeNotifications(context.Background(), func(msg *pubsub.NotificationMessage) {
+			wsHub.SendToUser(msg.UserID, &ws.Message{
+				Type: ws.MessageTypeNotification,
+				Data: ws.NotificationData{
+					Notification: msg.Notification,
+					UnreadCount:  msg.UnreadCount,
+				},
+			})
+		})
+		if err != nil {
+			log.Printf("Notification subscriber error: %v", err)
+		}
+	}()
+
 	// 启动服务器
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	log.Printf("Server starting on %s", addr)
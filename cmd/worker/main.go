@@ -10,10 +10,14 @@ import (
 
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/database"
-	"github.com/qs3c/anal_go_server/internal/pkg/oss"
+	"github.com/qs3c/anal_go_server/internal/pkg/email"
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
+	"github.com/qs3c/anal_go_server/internal/pkg/notify"
 	"github.com/qs3c/anal_go_server/internal/pkg/pubsub"
 	"github.com/qs3c/anal_go_server/internal/pkg/queue"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
 	"github.com/qs3c/anal_go_server/internal/worker"
 )
 
@@ -38,16 +42,18 @@ func main() {
 	}
 	log.Println("Redis connected")
 
-	// 初始化 OSS（可选）
-	var ossClient *oss.Client
-	if cfg.OSS.Endpoint != "" && cfg.OSS.AccessKeyID != "" {
-		ossClient, err = oss.NewClient(&cfg.OSS)
-		if err != nil {
-			log.Printf("Warning: Failed to init OSS client: %v", err)
-		} else {
-			log.Println("OSS client initialized")
-		}
+	// 初始化对象存储，主存储写入失败时降级到本地存储
+	localStore, err := storage.NewLocalFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init local storage: %v", err)
+	}
+	primaryStore, err := storage.New(cfg)
+	if err != nil {
+		log.Printf("Warning: Failed to init storage: %v, using local storage", err)
+		primaryStore = localStore
 	}
+	stores := storage.NewSet(primaryStore, localStore)
+	log.Printf("Storage initialized: %s", primaryStore.Name())
 
 	// 初始化 Queue 和 Pub/Sub
 	jobQueue := queue.NewQueue(rdb, cfg.Queue.AnalysisQueue)
@@ -56,9 +62,17 @@ func main() {
 	// 初始化 Repository
 	analysisRepo := repository.NewAnalysisRepository(db)
 	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	channelRepo := repository.NewChannelRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+
+	// 初始化通知，邮件写入发件箱，由 server 在后台发送
+	mailer := email.NewServiceWithTransport(&cfg.Email, email.NewOutbox(rdb, email.NewSMTPTransport(&cfg.Email), cfg.Email.MaxAttempts))
+	notifier := notify.NewNotifier(channelRepo, userRepo, mailer, lock.NewLocker(rdb), &cfg.Notification)
+	inbox := service.NewNotificationService(notificationRepo, publisher, cfg)
 
 	// 创建任务处理器
-	processor := worker.NewProcessor(jobRepo, analysisRepo, ossClient, publisher, cfg)
+	processor := worker.NewProcessor(jobRepo, analysisRepo, stores, publisher, notifier, inbox, cfg)
 
 	// 创建 context 用于优雅关闭
 	ctx, cancel := context.WithCancel(context.Background())
@@ -74,13 +88,16 @@ func main() {
 		cancel()
 	}()
 
-	// 启动后台重传器（仅在 OSS 可用时）
-	if ossClient != nil {
-		reuploader := worker.NewReuploader(analysisRepo, ossClient, cfg)
+	// 启动后台重传器（仅在主存储不是本地存储时）
+	if stores.Fallback != nil {
+		reuploader := worker.NewReuploader(analysisRepo, stores, cfg)
 		go reuploader.Start(ctx)
 		log.Println("Reuploader started")
 	}
 
+	// 启动通知重试
+	go notifier.RunRetries(ctx)
+
 	log.Printf("Worker started, max workers: %d", cfg.Queue.MaxWorkers)
 
 	// 启动 worker 循环
@@ -14,6 +14,7 @@ type Config struct {
 	Redis        RedisConfig        `mapstructure:"redis"`
 	JWT          JWTConfig          `mapstructure:"jwt"`
 	OSS          OSSConfig          `mapstructure:"oss"`
+	Storage      StorageConfig      `mapstructure:"storage"`
 	OAuth        OAuthConfig        `mapstructure:"oauth"`
 	Email        EmailConfig        `mapstructure:"email"`
 	Queue        QueueConfig        `mapstructure:"queue"`
@@ -22,6 +23,8 @@ type Config struct {
 	Models       []ModelConfig      `mapstructure:"models"`
 	Upload       UploadConfig       `mapstructure:"upload"`
 	Clone        CloneConfig        `mapstructure:"clone"`
+	GoProxy      GoProxyConfig      `mapstructure:"goproxy"`
+	Notification NotificationConfig `mapstructure:"notification"`
 }
 
 type CloneConfig struct {
@@ -29,6 +32,19 @@ type CloneConfig struct {
 	MaxRetries     int `mapstructure:"max_retries"`
 }
 
+// GoProxyConfig 按模块路径下载源码时使用的 GOPROXY
+type GoProxyConfig struct {
+	URL            string `mapstructure:"url"`             // 代理地址，默认 https://proxy.golang.org
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"` // 下载超时（秒）
+}
+
+// NotificationConfig 分析完成/失败时的用户通知
+type NotificationConfig struct {
+	WebhookTimeoutSeconds int  `mapstructure:"webhook_timeout_seconds"` // 单次 webhook 请求超时，默认 10 秒
+	MaxAttempts           int  `mapstructure:"max_attempts"`            // 每次投递的最大尝试次数，默认 5
+	AllowPrivateNetworks  bool `mapstructure:"allow_private_networks"`  // 是否允许 webhook 指向内网地址，仅用于本地调试
+}
+
 type ServerConfig struct {
 	Host string `mapstructure:"host"`
 	Port int    `mapstructure:"port"`
@@ -54,8 +70,30 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret      string `mapstructure:"secret"`
-	ExpireHours int    `mapstructure:"expire_hours"`
+	Secret string `mapstructure:"secret"`
+	// ExpireHours 登录会话（刷新令牌）的有效期
+	ExpireHours int `mapstructure:"expire_hours"`
+	// AccessExpireMinutes 访问令牌的有效期，默认 15 分钟
+	AccessExpireMinutes int `mapstructure:"access_expire_minutes"`
+	// SigningKey 签名使用的密钥 kid，为空时使用 key_dir 中已生效的最新密钥，都没有时使用 secret（HS256）
+	SigningKey string `mapstructure:"signing_key"`
+	// Keys 配置中的签名或验证密钥，如只用于验证的旧公钥
+	Keys []JWTKeyConfig `mapstructure:"keys"`
+	// KeyDir 密钥目录，其中每个 <kid>.pem 私钥都用于验证，多实例部署时需要共享该目录
+	KeyDir string `mapstructure:"key_dir"`
+	// RotateHours 在 key_dir 中自动生成新密钥的间隔，0 表示不自动轮换
+	RotateHours int `mapstructure:"rotate_hours"`
+	// Algorithm 自动轮换生成的密钥算法：RS256（默认）或 EdDSA
+	Algorithm string `mapstructure:"algorithm"`
+}
+
+// JWTKeyConfig 一个 JWT 密钥，私钥和公钥可以直接写 PEM 或指定文件，只有公钥时只用于验证
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"kid"`
+	PrivateKey     string `mapstructure:"private_key"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKey      string `mapstructure:"public_key"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
 }
 
 type OSSConfig struct {
@@ -66,9 +104,38 @@ type OSSConfig struct {
 	CDNDomain       string `mapstructure:"cdn_domain"`
 }
 
+// StorageConfig 对象存储配置，backend 可选 oss、s3、local
+// 为空时配置了 OSS 密钥则使用 OSS，否则使用本地存储
+type StorageConfig struct {
+	Backend string             `mapstructure:"backend"`
+	Local   LocalStorageConfig `mapstructure:"local"`
+	S3      S3StorageConfig    `mapstructure:"s3"`
+}
+
+type LocalStorageConfig struct {
+	Dir     string `mapstructure:"dir"`      // 存储目录，默认 upload.temp_dir
+	BaseURL string `mapstructure:"base_url"` // 签名 URL 前缀，默认 /api/v1/files
+	Secret  string `mapstructure:"secret"`   // 签名密钥，默认 jwt.secret，两者都为空时无法启动
+}
+
+type S3StorageConfig struct {
+	Endpoint        string `mapstructure:"endpoint"` // 如 https://s3.amazonaws.com、http://minio:9000
+	Region          string `mapstructure:"region"`
+	Bucket          string `mapstructure:"bucket"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	UsePathStyle    bool   `mapstructure:"use_path_style"`  // MinIO 等需要路径风格访问
+	PublicURL       string `mapstructure:"public_url"`      // 公共读域名或 CDN，用于头像等长期地址
+	TimeoutSeconds  int    `mapstructure:"timeout_seconds"` // 请求超时（秒）
+}
+
 type OAuthConfig struct {
+	// 第三方登录完成后允许跳转的前端来源（scheme://host[:port]），redirect_uri 不在其中时拒绝
+	AllowedRedirectOrigins []string `mapstructure:"allowed_redirect_origins"`
+
 	Github GithubOAuthConfig `mapstructure:"github"`
 	Wechat WechatOAuthConfig `mapstructure:"wechat"`
+	OIDC   OIDCConfig        `mapstructure:"oidc"`
 }
 
 type GithubOAuthConfig struct {
@@ -83,12 +150,33 @@ type WechatOAuthConfig struct {
 	RedirectURI string `mapstructure:"redirect_uri"`
 }
 
+// OIDCConfig 企业身份提供方（OpenID Connect）登录，issuer 为空时不启用
+type OIDCConfig struct {
+	Name           string   `mapstructure:"name"`   // 登录按钮上显示的名称
+	Issuer         string   `mapstructure:"issuer"` // 端点从 <issuer>/.well-known/openid-configuration 发现
+	ClientID       string   `mapstructure:"client_id"`
+	ClientSecret   string   `mapstructure:"client_secret"`
+	RedirectURI    string   `mapstructure:"redirect_uri"`
+	Scopes         []string `mapstructure:"scopes"`          // 默认 openid email profile
+	AutoProvision  bool     `mapstructure:"auto_provision"`  // 首次登录时自动创建用户
+	AllowedDomains []string `mapstructure:"allowed_domains"` // 自动创建用户时允许的邮箱域名，为空时不限制
+}
+
 type EmailConfig struct {
-	SMTPHost string `mapstructure:"smtp_host"`
-	SMTPPort int    `mapstructure:"smtp_port"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	From     string `mapstructure:"from"`
+	SMTPHost    string                         `mapstructure:"smtp_host"`
+	SMTPPort    int                            `mapstructure:"smtp_port"`
+	Username    string                         `mapstructure:"username"`
+	Password    string                         `mapstructure:"password"`
+	From        string                         `mapstructure:"from"`
+	BaseURL     string                         `mapstructure:"base_url"`     // 前端地址，用于拼接邮件中的验证、重置链接
+	MaxAttempts int                            `mapstructure:"max_attempts"` // 发件箱中每封邮件的最大发送次数，默认 5
+	Templates   map[string]EmailTemplateConfig `mapstructure:"templates"`    // 按模板名覆盖内置模板
+}
+
+// EmailTemplateConfig 覆盖内置邮件模板，subject 为 text/template，file 为 html/template 文件路径，为空时沿用内置的
+type EmailTemplateConfig struct {
+	Subject string `mapstructure:"subject"`
+	File    string `mapstructure:"file"`
 }
 
 type QueueConfig struct {
@@ -110,6 +198,11 @@ type SubscriptionLevel struct {
 	DailyQuota int     `mapstructure:"daily_quota"`
 	MaxDepth   int     `mapstructure:"max_depth"`
 	Price      float64 `mapstructure:"price"`
+
+	// 手动编辑/导入的框图限制，0 表示使用默认值
+	MaxDiagramSizeKB      int `mapstructure:"max_diagram_size_kb"`
+	MaxDiagramStructs     int `mapstructure:"max_diagram_structs"`
+	MaxDiagramConnections int `mapstructure:"max_diagram_connections"`
 }
 
 type ModelConfig struct {
@@ -122,10 +215,16 @@ type ModelConfig struct {
 }
 
 type UploadConfig struct {
-	MaxSize           int64    `mapstructure:"max_size"`           // 最大文件大小（字节）
-	TempDir           string   `mapstructure:"temp_dir"`           // 临时目录
-	ExpireHours       int      `mapstructure:"expire_hours"`       // 过期时间（小时）
-	AllowedExtensions []string `mapstructure:"allowed_extensions"` // 允许的扩展名
+	MaxSize             int64    `mapstructure:"max_size"`              // 最大文件大小（字节）
+	MaxEntrySize        int64    `mapstructure:"max_entry_size"`        // 单个文件解压后最大大小（字节）
+	MaxExtractedSize    int64    `mapstructure:"max_extracted_size"`    // 解压后总大小上限（字节）
+	MaxEntries          int      `mapstructure:"max_entries"`           // 压缩包条目数量上限
+	MaxDepth            int      `mapstructure:"max_depth"`             // 目录层级上限
+	MaxCompressionRatio int64    `mapstructure:"max_compression_ratio"` // 压缩比上限
+	ChunkSize           int64    `mapstructure:"chunk_size"`            // 分片上传的分片大小（字节）
+	TempDir             string   `mapstructure:"temp_dir"`              // 临时目录
+	ExpireHours         int      `mapstructure:"expire_hours"`          // 过期时间（小时）
+	AllowedExtensions   []string `mapstructure:"allowed_extensions"`    // 前端提示用的扩展名，服务端按文件内容识别格式
 }
 
 func Load(configPath string) (*Config, error) {
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/service"
+)
+
+type AccessTokenHandler struct {
+	tokenService *service.AccessTokenService
+}
+
+func NewAccessTokenHandler(tokenService *service.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+// List 获取个人访问令牌列表
+// GET /api/v1/user/tokens
+func (h *AccessTokenHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	items, err := h.tokenService.List(userID)
+	if err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.Success(c, items)
+}
+
+// Create 创建个人访问令牌
+// POST /api/v1/user/tokens
+func (h *AccessTokenHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var req dto.CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	item, err := h.tokenService.Create(userID, &req)
+	if err != nil {
+		accessTokenError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "令牌只显示一次，请妥善保存", item)
+}
+
+// Delete 吊销个人访问令牌
+// DELETE /api/v1/user/tokens/:id
+func (h *AccessTokenHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的令牌ID")
+		return
+	}
+
+	if err := h.tokenService.Delete(userID, tokenID); err != nil {
+		accessTokenError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "令牌已吊销", nil)
+}
+
+func accessTokenError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrAccessTokenNotFound:
+		response.NotFoundError(c, err.Error())
+	case service.ErrAccessTokenPermission:
+		response.PermissionError(c, err.Error())
+	case service.ErrInvalidAccessTokenScope, service.ErrAccessTokenLimitReached:
+		response.ParamError(c, err.Error())
+	default:
+		response.ServerError(c, "")
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestAccessTokenHandler_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	tokenService := service.NewAccessTokenService(repository.NewAccessTokenRepository(db), &config.Config{})
+	handler := NewAccessTokenHandler(tokenService)
+	user := testutil.TestUser(t, db)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/user/tokens", handler.List)
+	router.POST("/user/tokens", handler.Create)
+	router.DELETE("/user/tokens/:id", handler.Delete)
+
+	w := performRequest(router, "POST", "/user/tokens", dto.CreateAccessTokenRequest{Name: "ci"})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "POST", "/user/tokens", dto.CreateAccessTokenRequest{Name: "ci", Scopes: []string{"admin"}})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "POST", "/user/tokens", dto.CreateAccessTokenRequest{Name: "ci", Scopes: []string{model.ScopeAnalysesRead}, ExpiresInDays: 1000})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "POST", "/user/tokens", dto.CreateAccessTokenRequest{
+		Name: "ci", Scopes: []string{model.ScopeAnalysesRead}, ExpiresInDays: 90,
+	})
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.NotEmpty(t, data["token"])
+	path := fmt.Sprintf("/user/tokens/%d", int64(data["id"].(float64)))
+
+	w = performRequest(router, "GET", "/user/tokens", nil)
+	items := parseResponse(t, w).Data.([]interface{})
+	require.Len(t, items, 1)
+	assert.Nil(t, items[0].(map[string]interface{})["token"])
+
+	w = performRequest(router, "DELETE", "/user/tokens/abc", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "DELETE", path, nil)
+	assert.Equal(t, response.CodeSuccess, parseResponse(t, w).Code)
+	w = performRequest(router, "DELETE", path, nil)
+	assert.Equal(t, response.CodeResourceNotFound, parseResponse(t, w).Code)
+}
@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/qs3c/anal_go_server/internal/api/middleware"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
 	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/service"
 )
 
@@ -38,13 +45,18 @@ func (h *AnalysisHandler) Create(c *gin.Context) {
 
 	resp, err := h.analysisService.Create(userID, &req)
 	if err != nil {
+		if diagramParamError(c, err) {
+			return
+		}
 		switch err {
 		case service.ErrQuotaExceeded:
 			response.QuotaError(c, err.Error())
 		case service.ErrDepthExceeded:
 			response.ParamError(c, err.Error())
-		case service.ErrModelDenied:
+		case service.ErrModelDenied, service.ErrUploadPermission:
 			response.PermissionError(c, err.Error())
+		case service.ErrUploadNotFound, service.ErrUploadNotModule:
+			response.ParamError(c, err.Error())
 		default:
 			response.ServerError(c, "")
 		}
@@ -138,6 +150,9 @@ func (h *AnalysisHandler) Update(c *gin.Context) {
 
 	detail, err := h.analysisService.Update(userID, analysisID, &req)
 	if err != nil {
+		if diagramParamError(c, err) {
+			return
+		}
 		switch err {
 		case service.ErrAnalysisNotFound:
 			response.NotFoundError(c, err.Error())
@@ -152,6 +167,48 @@ func (h *AnalysisHandler) Update(c *gin.Context) {
 	response.SuccessWithMessage(c, "更新成功", detail)
 }
 
+// Import 从 Mermaid、PlantUML 或 Go 源码导入框图（仅限手动创建的分析）
+// POST /api/v1/analyses/:id/import
+func (h *AnalysisHandler) Import(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	var req dto.ImportDiagramRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	detail, err := h.analysisService.Import(userID, analysisID, &req)
+	if err != nil {
+		if diagramParamError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			response.NotFoundError(c, err.Error())
+		case errors.Is(err, service.ErrAnalysisPermission):
+			response.PermissionError(c, err.Error())
+		case errors.Is(err, service.ErrImportNotManual):
+			response.ParamError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "导入成功", detail)
+}
+
 // Delete 删除分析
 // DELETE /api/v1/analyses/:id
 func (h *AnalysisHandler) Delete(c *gin.Context) {
@@ -293,7 +350,7 @@ func (h *AnalysisHandler) GetDiagram(c *gin.Context) {
 		return
 	}
 
-	data, err := h.analysisService.GetDiagramData(userID, analysisID)
+	result, err := h.analysisService.GetDiagram(userID, analysisID, c.GetHeader("If-None-Match"))
 	if err != nil {
 		switch err {
 		case service.ErrAnalysisNotFound:
@@ -306,6 +363,293 @@ func (h *AnalysisHandler) GetDiagram(c *gin.Context) {
 		return
 	}
 
-	// 直接返回 JSON 数据
-	c.Data(200, "application/json", data)
+	// 客户端支持存储编码时直接返回压缩数据，否则解压后返回
+	// 两种表示使用不同的 ETag，304 响应同样返回所选表示的 ETag
+	encoded := result.Encoding != "" && acceptsEncoding(c.GetHeader("Accept-Encoding"), result.Encoding)
+	etag := result.ETag
+	if encoded {
+		etag = storage.EncodedETag(result.ETag, result.Encoding)
+	}
+	if result.Encoding != "" {
+		c.Header("Vary", "Accept-Encoding")
+	}
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if result.NotModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data := result.Data
+	if encoded {
+		c.Header("Content-Encoding", result.Encoding)
+	} else if result.Encoding != "" {
+		if data, err = result.Raw(); err != nil {
+			log.Printf("Failed to decompress diagram %d: %v", analysisID, err)
+			response.ServerError(c, "")
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// Export 导出框图
+// GET /api/v1/analyses/:id/export?format=mermaid|plantuml|dot|svg&depth=2&hide_fields=true
+func (h *AnalysisHandler) Export(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	format := c.DefaultQuery("format", diagram.FormatSVG)
+	opts := diagram.Options{Root: c.Query("root")}
+	if v := c.Query("depth"); v != "" {
+		if opts.MaxDepth, err = strconv.Atoi(v); err != nil || opts.MaxDepth < 0 {
+			response.ParamError(c, "无效的深度")
+			return
+		}
+	}
+	if v := c.Query("hide_fields"); v != "" {
+		if opts.HideFields, err = strconv.ParseBool(v); err != nil {
+			response.ParamError(c, "无效的 hide_fields 参数")
+			return
+		}
+	}
+
+	data, err := h.analysisService.Export(userID, analysisID, format, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, diagram.ErrUnsupportedFormat):
+			response.ParamError(c, "不支持的导出格式，可选 mermaid、plantuml、dot、svg")
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			response.NotFoundError(c, err.Error())
+		case errors.Is(err, service.ErrAnalysisPermission):
+			response.PermissionError(c, err.Error())
+		default:
+			log.Printf("Failed to export analysis %d: %v", analysisID, err)
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	if c.Query("download") != "" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="analysis-%d%s"`, analysisID, diagram.FileExt(format)))
+	}
+	c.Data(http.StatusOK, diagram.ContentType(format), data)
+}
+
+// Neighbors 查询结构体的直接依赖和被依赖
+// GET /api/v1/analyses/:id/graph/neighbors?struct=X&direction=out|in|both
+func (h *AnalysisHandler) Neighbors(c *gin.Context) {
+	q := diagram.Query{Type: diagram.QueryNeighbors, Struct: c.Query("struct"), Direction: c.Query("direction")}
+	if q.Struct == "" {
+		response.ParamError(c, "缺少 struct 参数")
+		return
+	}
+	h.queryDiagram(c, q)
+}
+
+// Subgraph 查询结构体周围 k 跳内的子图
+// GET /api/v1/analyses/:id/graph/subgraph?struct=X&hops=2&direction=both
+func (h *AnalysisHandler) Subgraph(c *gin.Context) {
+	q := diagram.Query{Type: diagram.QuerySubgraph, Struct: c.Query("struct"), Direction: c.Query("direction"), Hops: 2}
+	if q.Struct == "" {
+		response.ParamError(c, "缺少 struct 参数")
+		return
+	}
+	if v := c.Query("hops"); v != "" {
+		hops, err := strconv.Atoi(v)
+		if err != nil || hops < 1 || hops > diagram.MaxHops {
+			response.ParamError(c, fmt.Sprintf("hops 取值范围为 1-%d", diagram.MaxHops))
+			return
+		}
+		q.Hops = hops
+	}
+	h.queryDiagram(c, q)
+}
+
+// Path 查询两个结构体之间的最短依赖路径
+// GET /api/v1/analyses/:id/graph/path?from=A&to=B
+func (h *AnalysisHandler) Path(c *gin.Context) {
+	q := diagram.Query{Type: diagram.QueryPath, From: c.Query("from"), To: c.Query("to")}
+	if q.From == "" || q.To == "" {
+		response.ParamError(c, "缺少 from 或 to 参数")
+		return
+	}
+	h.queryDiagram(c, q)
+}
+
+// Cycles 检测循环依赖
+// GET /api/v1/analyses/:id/graph/cycles
+func (h *AnalysisHandler) Cycles(c *gin.Context) {
+	h.queryDiagram(c, diagram.Query{Type: diagram.QueryCycles})
+}
+
+func (h *AnalysisHandler) queryDiagram(c *gin.Context, q diagram.Query) {
+	userID, _ := middleware.GetUserID(c)
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	result, err := h.analysisService.QueryDiagram(userID, analysisID, q)
+	if err != nil {
+		switch {
+		case errors.Is(err, diagram.ErrInvalidQuery):
+			response.ParamError(c, "无效的查询参数，方向可选 out、in、both")
+		case errors.Is(err, diagram.ErrStructNotFound):
+			response.NotFoundError(c, "结构体不存在")
+		case errors.Is(err, diagram.ErrNoPath):
+			response.NotFoundError(c, "两个结构体之间没有依赖路径")
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			response.NotFoundError(c, err.Error())
+		case errors.Is(err, service.ErrAnalysisPermission):
+			response.PermissionError(c, err.Error())
+		default:
+			log.Printf("Failed to query diagram of analysis %d: %v", analysisID, err)
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// GetRules 获取分析的架构规则
+// GET /api/v1/analyses/:id/rules
+func (h *AnalysisHandler) GetRules(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	rules, err := h.analysisService.GetRules(userID, analysisID)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	response.Success(c, rules)
+}
+
+// UpdateRules 替换分析的架构规则
+// PUT /api/v1/analyses/:id/rules
+func (h *AnalysisHandler) UpdateRules(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	var req dto.UpdateRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	rules, err := h.analysisService.UpdateRules(userID, analysisID, &req)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "规则已保存", rules)
+}
+
+// CheckRules 用当前规则检查最新的分析结果，CI 根据 data.passed 判断是否通过
+// GET /api/v1/analyses/:id/check
+func (h *AnalysisHandler) CheckRules(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	result, err := h.analysisService.CheckRules(userID, analysisID)
+	if err != nil {
+		ruleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func ruleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, diagram.ErrInvalidRule):
+		response.ParamError(c, "无效的规则："+err.Error())
+	case errors.Is(err, service.ErrAnalysisNotReady):
+		response.ParamError(c, err.Error())
+	case errors.Is(err, service.ErrAnalysisNotFound):
+		response.NotFoundError(c, err.Error())
+	case errors.Is(err, service.ErrAnalysisPermission):
+		response.PermissionError(c, err.Error())
+	default:
+		response.ServerError(c, "")
+	}
+}
+
+// DiagramSchema 获取框图数据的 JSON Schema
+// GET /api/v1/diagram-schema
+func (h *AnalysisHandler) DiagramSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", diagram.Schema())
+}
+
+// diagramParamError 框图校验失败或导入源码有语法错误时返回参数错误，data 为字段级/行级错误列表
+// 不是这两类错误时返回 false
+func diagramParamError(c *gin.Context, err error) bool {
+	var validationErrs diagram.ValidationError
+	var syntaxErrs diagram.SyntaxErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		response.ParamErrorWithData(c, "框图数据校验失败，"+validationErrs[0].String(), validationErrs)
+	case errors.As(err, &syntaxErrs):
+		response.ParamErrorWithData(c, "导入失败，"+syntaxErrs[0].Error(), syntaxErrs)
+	default:
+		return false
+	}
+	return true
+}
+
+// acceptsEncoding 判断 Accept-Encoding 是否接受指定编码（q=0 表示拒绝）
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +18,7 @@ import (
 	"github.com/qs3c/anal_go_server/internal/api/middleware"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
 	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/service"
 	"github.com/qs3c/anal_go_server/internal/testutil"
@@ -307,6 +309,44 @@ func TestAnalysisHandler_Update_Success(t *testing.T) {
 	assert.Equal(t, response.CodeSuccess, resp.Code)
 }
 
+func TestAnalysisHandler_Update_InvalidDiagram(t *testing.T) {
+	handler, ctx, cleanup := setupAnalysisHandler(t)
+	defer cleanup()
+
+	user := testutil.TestUser(t, ctx.DB)
+	analysis := testutil.TestAnalysis(t, ctx.DB, user.ID)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.PUT("/analyses/:id", handler.Update)
+
+	req := dto.UpdateAnalysisRequest{
+		DiagramData: json.RawMessage(`{"version":2,"structs":[{"name":"A","fields":[{"type":"int"}]}],"connections":[]}`),
+	}
+	w := performRequest(router, "PUT", fmt.Sprintf("/analyses/%d", analysis.ID), req)
+
+	resp := parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+	assert.Equal(t, "框图数据校验失败，structs[0].fields[0].name: is required", resp.Message)
+	assert.Equal(t, []interface{}{map[string]interface{}{"field": "structs[0].fields[0].name", "message": "is required"}}, resp.Data)
+}
+
+func TestAnalysisHandler_DiagramSchema(t *testing.T) {
+	handler, _, cleanup := setupAnalysisHandler(t)
+	defer cleanup()
+
+	router := gin.New()
+	router.GET("/diagram-schema", handler.DiagramSchema)
+
+	w := performRequest(router, "GET", "/diagram-schema", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+	assert.Contains(t, schema, "$schema")
+}
+
 func TestAnalysisHandler_Delete_Success(t *testing.T) {
 	handler, ctx, cleanup := setupAnalysisHandler(t)
 	defer cleanup()
@@ -459,3 +499,275 @@ func TestAnalysisHandler_InvalidID(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, response.CodeParamError, resp.Code)
 }
+
+func TestAnalysisHandler_GetDiagram_Encoding(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	analysisService := service.NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo,
+		service.NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+	handler := NewAnalysisHandler(analysisService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	diagramJSON := `{"connections":[],"structs":[{"id":"A"}],"version":2}`
+	_, err := analysisService.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/analyses/:id/diagram", handler.GetDiagram)
+	path := fmt.Sprintf("/analyses/%d/diagram", analysis.ID)
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 不接受 gzip：服务端解压
+	w := get(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, diagramJSON, w.Body.String())
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// 接受 gzip：直接返回压缩数据，压缩表示使用不同的强 ETag
+	w = get(map[string]string{"Accept-Encoding": "br, gzip"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	gzipETag := w.Header().Get("ETag")
+	assert.Equal(t, strings.TrimSuffix(etag, `"`)+`-gzip"`, gzipETag)
+	raw, err := storage.Decompress(w.Body.Bytes(), "gzip")
+	require.NoError(t, err)
+	assert.JSONEq(t, diagramJSON, string(raw))
+
+	// q=0 表示拒绝
+	w = get(map[string]string{"Accept-Encoding": "gzip;q=0"})
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+
+	// If-None-Match 命中返回 304
+	w = get(map[string]string{"If-None-Match": "W/" + etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	// 两种形式的 ETag 都能命中，304 返回当前请求所选表示的 ETag
+	w = get(map[string]string{"If-None-Match": gzipETag, "Accept-Encoding": "gzip"})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, gzipETag, w.Header().Get("ETag"))
+	w = get(map[string]string{"If-None-Match": gzipETag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = get(map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAnalysisHandler_Export(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	analysisService := service.NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo,
+		service.NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+	handler := NewAnalysisHandler(analysisService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	diagramJSON := `{"structs":[{"name":"A","fields":[{"name":"B","type":"*B"}]},{"name":"B"}],"connections":[{"from":"A","to":"B"}]}`
+	_, err := analysisService.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/analyses/:id/export", handler.Export)
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/analyses/%d/export?%s", analysis.ID, query), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认导出 SVG
+	w := get("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<svg")
+	assert.Contains(t, w.Body.String(), "B: *B")
+
+	w = get("format=mermaid&hide_fields=true&download=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "classDiagram\n    class A\n    class B\n    A --> B\n", w.Body.String())
+	assert.Equal(t, fmt.Sprintf(`attachment; filename="analysis-%d.mmd"`, analysis.ID), w.Header().Get("Content-Disposition"))
+
+	w = get("format=plantuml&depth=0")
+	assert.True(t, strings.HasPrefix(w.Body.String(), "@startuml"))
+
+	for _, query := range []string{"format=png", "depth=-1", "depth=x", "hide_fields=maybe"} {
+		w = get(query)
+		resp := parseResponse(t, w)
+		assert.Equal(t, response.CodeParamError, resp.Code, query)
+	}
+}
+
+func TestAnalysisHandler_GraphQueries(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	analysisService := service.NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo,
+		service.NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+	handler := NewAnalysisHandler(analysisService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	diagramJSON := `{"structs":[{"name":"A"},{"name":"B"},{"name":"C"}],"connections":[{"from":"A","to":"B"},{"from":"B","to":"C"},{"from":"C","to":"B"}]}`
+	_, err := analysisService.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/analyses/:id/graph/neighbors", handler.Neighbors)
+	router.GET("/analyses/:id/graph/path", handler.Path)
+	router.GET("/analyses/:id/graph/subgraph", handler.Subgraph)
+	router.GET("/analyses/:id/graph/cycles", handler.Cycles)
+	get := func(query string) response.Response {
+		w := performRequest(router, "GET", fmt.Sprintf("/analyses/%d/graph/%s", analysis.ID, query), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return parseResponse(t, w)
+	}
+	names := func(resp response.Response) []string {
+		var result []string
+		for _, s := range resp.Data.(map[string]interface{})["structs"].([]interface{}) {
+			result = append(result, s.(map[string]interface{})["name"].(string))
+		}
+		return result
+	}
+
+	resp := get("neighbors?struct=B&direction=in")
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Equal(t, []string{"A", "B", "C"}, names(resp))
+
+	resp = get("subgraph?struct=A&hops=1&direction=out")
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Equal(t, []string{"A", "B"}, names(resp))
+
+	resp = get("path?from=A&to=C")
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Equal(t, []interface{}{"A", "B", "C"}, resp.Data.(map[string]interface{})["path"])
+
+	resp = get("cycles")
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Equal(t, []interface{}{[]interface{}{"B", "C"}}, resp.Data.(map[string]interface{})["cycles"])
+	assert.EqualValues(t, 2, resp.Data.(map[string]interface{})["version"])
+
+	assert.Equal(t, response.CodeResourceNotFound, get("path?from=C&to=A").Code)
+	assert.Equal(t, response.CodeResourceNotFound, get("neighbors?struct=X").Code)
+	for _, query := range []string{"neighbors", "neighbors?struct=A&direction=up", "subgraph?struct=A&hops=9", "path?from=A"} {
+		assert.Equal(t, response.CodeParamError, get(query).Code, query)
+	}
+}
+
+func TestAnalysisHandler_Rules(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	analysisService := service.NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo,
+		service.NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+	handler := NewAnalysisHandler(analysisService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	diagramJSON := `{"structs":[{"name":"A"},{"name":"B"}],"connections":[{"from":"A","to":"B"},{"from":"B","to":"A"}]}`
+	_, err := analysisService.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/analyses/:id/rules", handler.GetRules)
+	router.PUT("/analyses/:id/rules", handler.UpdateRules)
+	router.GET("/analyses/:id/check", handler.CheckRules)
+	rulesPath := fmt.Sprintf("/analyses/%d/rules", analysis.ID)
+
+	w := performRequest(router, "PUT", rulesPath, dto.UpdateRulesRequest{Rules: []dto.RuleItem{{Type: "max_depth"}}})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "PUT", rulesPath, dto.UpdateRulesRequest{Rules: []dto.RuleItem{{Type: "no_dependency", From: "api"}}})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "PUT", rulesPath, dto.UpdateRulesRequest{Rules: []dto.RuleItem{{Type: "no_cycles"}, {Type: "max_fan_in", Max: 1}}})
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Len(t, resp.Data, 2)
+
+	w = performRequest(router, "GET", rulesPath, nil)
+	assert.Equal(t, []interface{}{map[string]interface{}{"type": "no_cycles"}, map[string]interface{}{"type": "max_fan_in", "max": float64(1)}}, parseResponse(t, w).Data)
+
+	w = performRequest(router, "GET", fmt.Sprintf("/analyses/%d/check", analysis.ID), nil)
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.Equal(t, false, data["passed"])
+	assert.Len(t, data["violations"], 1)
+}
+
+func TestAnalysisHandler_Import(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	analysisService := service.NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo,
+		service.NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+	handler := NewAnalysisHandler(analysisService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.POST("/analyses/:id/import", handler.Import)
+	path := fmt.Sprintf("/analyses/%d/import", analysis.ID)
+
+	w := performRequest(router, "POST", path, dto.ImportDiagramRequest{
+		Format: "plantuml",
+		Source: "@startuml\nclass A {\n  +Name : string\n}\nA --> B\n@enduml",
+	})
+	resp := parseResponse(t, w)
+	assert.Equal(t, response.CodeSuccess, resp.Code)
+
+	data, err := analysisService.GetDiagramData(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2,"structs":[{"name":"A","fields":[{"name":"Name","type":"string"}]},{"name":"B"}],"connections":[{"from":"A","to":"B","type":"field"}]}`, string(data))
+
+	// 行级错误放在 data 中
+	w = performRequest(router, "POST", path, dto.ImportDiagramRequest{Format: "mermaid", Source: "classDiagram\nclass A\nA ==> B\n"})
+	resp = parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+	assert.Contains(t, resp.Message, "line 3")
+	assert.Equal(t, []interface{}{map[string]interface{}{"line": float64(3), "message": `unrecognized statement "A ==> B"`}}, resp.Data)
+
+	w = performRequest(router, "POST", path, map[string]string{"format": "uml", "source": "x"})
+	resp = parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+}
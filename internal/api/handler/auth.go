@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
 	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
 	"github.com/qs3c/anal_go_server/internal/pkg/response"
@@ -15,6 +18,13 @@ import (
 
 const defaultFrontendCallbackURL = "http://47.250.132.14:5173/auth/callback"
 
+// 绑定第三方账号时写入发起绑定的浏览器的 cookie，回调时与 state 中的值比较
+const (
+	linkCookieName   = "oauth_link"
+	linkCookiePath   = "/api/v1/auth"
+	linkCookieMaxAge = 10 * 60 // 与 OAuth state 的有效期一致
+)
+
 type AuthHandler struct {
 	authService *service.AuthService
 	stateStore  *oauth.StateStore
@@ -102,12 +112,173 @@ func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	response.SuccessWithMessage(c, "邮箱验证成功", resp)
 }
 
+// ResendVerification 重新发送验证邮件
+// POST /api/v1/auth/resend-verification
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req dto.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTooManyRequests):
+			response.TooManyRequestsError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "如果该邮箱已注册且尚未验证，验证邮件已发送", nil)
+}
+
+// Refresh 用刷新令牌换取新的令牌
+// POST /api/v1/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	resp, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			response.AuthError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// Logout 退出当前设备的登录
+// POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req dto.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.SuccessWithMessage(c, "已退出登录", nil)
+}
+
+// LogoutAll 退出所有设备的登录
+// POST /api/v1/auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	if err := h.authService.LogoutAll(c.Request.Context(), userID); err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.SuccessWithMessage(c, "已退出所有设备的登录", nil)
+}
+
+// JWKS 公开验证访问令牌的公钥，供其它服务校验 Token，不使用统一响应格式
+// GET /.well-known/jwks.json
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
+// ForgotPassword 发送密码重置邮件
+// POST /api/v1/auth/forgot-password
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTooManyRequests):
+			response.TooManyRequestsError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "如果该邮箱已注册，密码重置邮件已发送", nil)
+}
+
+// ResetPassword 使用重置令牌设置新密码
+// POST /api/v1/auth/reset-password
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	if err := h.authService.ResetPassword(req.Token, req.Password); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidResetToken):
+			response.ParamError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "密码已重置，请重新登录", nil)
+}
+
+// ChangePassword 修改密码
+// PUT /api/v1/user/password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	resp, err := h.authService.ChangePassword(userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWrongPassword), errors.Is(err, service.ErrPasswordNotSet):
+			response.ParamError(c, err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			response.NotFoundError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "密码已修改，其它设备需要重新登录", resp)
+}
+
 // GithubAuth GitHub OAuth 登录
 // GET /api/v1/auth/github
 func (h *AuthHandler) GithubAuth(c *gin.Context) {
-	redirectURI := c.Query("redirect_uri")
-	if redirectURI == "" {
-		redirectURI = defaultFrontendCallbackURL
+	redirectURI, ok := h.redirectURI(c)
+	if !ok {
+		return
 	}
 
 	state, err := h.stateStore.GenerateState(c.Request.Context(), redirectURI)
@@ -129,20 +300,352 @@ func (h *AuthHandler) GithubCallback(c *gin.Context) {
 		return
 	}
 
+	// state 无效时拒绝登录，防止登录 CSRF
+	data, err := h.stateStore.ValidateStateData(c.Request.Context(), c.Query("state"))
+	if err != nil {
+		response.ParamError(c, "登录状态无效或已过期，请重新登录")
+		return
+	}
 	// 从 state 中恢复前端回调地址
-	state := c.Query("state")
-	redirectURI, err := h.stateStore.ValidateState(c.Request.Context(), state)
-	if err != nil || redirectURI == "" {
+	redirectURI := data.RedirectURI
+	if redirectURI == "" {
 		redirectURI = defaultFrontendCallbackURL
 	}
 
+	// 从账号设置发起的绑定
+	if data.LinkUserID != 0 {
+		h.finishLink(c, service.ProviderGithub, data, redirectURI, func() error {
+			return h.authService.LinkOAuth(c.Request.Context(), data.LinkUserID, service.ProviderGithub, code)
+		})
+		return
+	}
+
 	resp, err := h.authService.GithubCallback(c.Request.Context(), code)
 	if err != nil {
+		if errors.Is(err, service.ErrOAuthEmailConflict) {
+			response.ParamError(c, err.Error())
+			return
+		}
 		response.ServerError(c, "GitHub 登录失败")
 		return
 	}
 
-	// 重定向到前端，携带 token
-	redirectURL := fmt.Sprintf("%s?token=%s", redirectURI, resp.Token)
-	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
+	h.finishLogin(c, redirectURI, resp)
+}
+
+// WechatAuth 微信扫码登录
+// GET /api/v1/auth/wechat
+func (h *AuthHandler) WechatAuth(c *gin.Context) {
+	redirectURI, ok := h.redirectURI(c)
+	if !ok {
+		return
+	}
+
+	state, err := h.stateStore.GenerateState(c.Request.Context(), redirectURI)
+	if err != nil {
+		response.ServerError(c, "生成 OAuth 状态失败")
+		return
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, h.authService.GetWechatAuthURL(state))
+}
+
+// WechatCallback 微信扫码登录回调
+// GET /api/v1/auth/wechat/callback
+func (h *AuthHandler) WechatCallback(c *gin.Context) {
+	// 用户拒绝授权时微信不返回 code
+	code := c.Query("code")
+	if code == "" {
+		response.ParamError(c, "missing code parameter")
+		return
+	}
+
+	// state 无效时拒绝登录，防止登录 CSRF
+	data, err := h.stateStore.ValidateStateData(c.Request.Context(), c.Query("state"))
+	if err != nil {
+		response.ParamError(c, "登录状态无效或已过期，请重新扫码")
+		return
+	}
+	redirectURI := data.RedirectURI
+	if redirectURI == "" {
+		redirectURI = defaultFrontendCallbackURL
+	}
+
+	// 从账号设置发起的绑定
+	if data.LinkUserID != 0 {
+		h.finishLink(c, service.ProviderWechat, data, redirectURI, func() error {
+			return h.authService.LinkOAuth(c.Request.Context(), data.LinkUserID, service.ProviderWechat, code)
+		})
+		return
+	}
+
+	resp, err := h.authService.WechatCallback(c.Request.Context(), code)
+	if err != nil {
+		response.ServerError(c, "微信登录失败")
+		return
+	}
+
+	h.finishLogin(c, redirectURI, resp)
+}
+
+// OIDCAuth OIDC 单点登录
+// GET /api/v1/auth/oidc
+func (h *AuthHandler) OIDCAuth(c *gin.Context) {
+	if !h.authService.OIDCEnabled() {
+		response.NotFoundError(c, service.ErrOIDCDisabled.Error())
+		return
+	}
+
+	redirectURI, ok := h.redirectURI(c)
+	if !ok {
+		return
+	}
+
+	// nonce 和 PKCE verifier 只保存在服务端，回调时从 state 中取出
+	data := &oauth.StateData{
+		RedirectURI:  redirectURI,
+		Nonce:        oauth.GenerateNonce(),
+		CodeVerifier: oauth.GenerateVerifier(),
+	}
+	state, err := h.stateStore.GenerateStateWithData(c.Request.Context(), data)
+	if err != nil {
+		response.ServerError(c, "生成 OAuth 状态失败")
+		return
+	}
+
+	authURL, err := h.authService.OIDCAuthURL(c.Request.Context(), state, data.Nonce, data.CodeVerifier)
+	if err != nil {
+		response.ServerError(c, "获取单点登录地址失败")
+		return
+	}
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
+}
+
+// OIDCCallback OIDC 单点登录回调
+// GET /api/v1/auth/oidc/callback
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	// 用户拒绝授权时 IdP 返回 error 而不是 code
+	code := c.Query("code")
+	if code == "" {
+		response.ParamError(c, "missing code parameter")
+		return
+	}
+
+	// 没有 state 就没有 nonce 和 verifier，无法完成登录
+	data, err := h.stateStore.ValidateStateData(c.Request.Context(), c.Query("state"))
+	if err != nil || data.CodeVerifier == "" {
+		response.ParamError(c, "登录状态无效或已过期，请重新登录")
+		return
+	}
+	redirectURI := data.RedirectURI
+	if redirectURI == "" {
+		redirectURI = defaultFrontendCallbackURL
+	}
+
+	// 从账号设置发起的绑定
+	if data.LinkUserID != 0 {
+		h.finishLink(c, service.ProviderOIDC, data, redirectURI, func() error {
+			return h.authService.LinkOIDC(c.Request.Context(), data.LinkUserID, code, data.CodeVerifier, data.Nonce)
+		})
+		return
+	}
+
+	resp, err := h.authService.OIDCCallback(c.Request.Context(), code, data.CodeVerifier, data.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOAuthEmailConflict):
+			response.ParamError(c, err.Error())
+		case errors.Is(err, service.ErrOIDCNotProvisioned), errors.Is(err, service.ErrOIDCDomainNotAllowed):
+			response.PermissionError(c, err.Error())
+		default:
+			response.ServerError(c, "单点登录失败")
+		}
+		return
+	}
+
+	h.finishLogin(c, redirectURI, resp)
+}
+
+// ExchangeLoginCode 用第三方登录回调带回的一次性 login_code 换取令牌
+// POST /api/v1/auth/oauth/exchange
+func (h *AuthHandler) ExchangeLoginCode(c *gin.Context) {
+	var req dto.OAuthExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	var resp dto.LoginResponse
+	if err := h.stateStore.ConsumeLoginCode(c.Request.Context(), req.LoginCode, &resp); err != nil {
+		response.ParamError(c, "登录凭证无效或已过期，请重新登录")
+		return
+	}
+
+	response.Success(c, &resp)
+}
+
+// Identities 获取登录方式的绑定状态
+// GET /api/v1/user/identities
+func (h *AuthHandler) Identities(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	items, err := h.authService.Identities(userID)
+	if err != nil {
+		identityError(c, err)
+		return
+	}
+
+	response.Success(c, items)
+}
+
+// LinkIdentity 绑定登录方式
+// github、wechat、oidc 返回授权地址，前端跳转授权后回调完成绑定，再重定向到 redirect_uri；
+// email 直接设置邮箱和密码
+// POST /api/v1/user/identities/:provider
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	provider := c.Param("provider")
+	if provider == service.ProviderEmail {
+		var req dto.LinkEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ParamError(c, err.Error())
+			return
+		}
+		resp, err := h.authService.LinkEmail(userID, &req)
+		if err != nil {
+			identityError(c, err)
+			return
+		}
+		response.SuccessWithMessage(c, "邮箱登录已绑定", resp)
+		return
+	}
+
+	redirectURI, ok := h.redirectURI(c)
+	if !ok {
+		return
+	}
+	data := &oauth.StateData{
+		RedirectURI: redirectURI,
+		LinkUserID:  userID,
+		LinkBinding: oauth.GenerateNonce(),
+	}
+	if provider == service.ProviderOIDC {
+		data.Nonce = oauth.GenerateNonce()
+		data.CodeVerifier = oauth.GenerateVerifier()
+	}
+	state, err := h.stateStore.GenerateStateWithData(c.Request.Context(), data)
+	if err != nil {
+		response.ServerError(c, "生成 OAuth 状态失败")
+		return
+	}
+
+	var authURL string
+	if provider == service.ProviderOIDC {
+		authURL, err = h.authService.LinkOIDCAuthURL(c.Request.Context(), userID, state, data.Nonce, data.CodeVerifier)
+	} else {
+		authURL, err = h.authService.LinkAuthURL(userID, provider, state)
+	}
+	if err != nil {
+		identityError(c, err)
+		return
+	}
+
+	h.setLinkCookie(c, data.LinkBinding, linkCookieMaxAge)
+	response.Success(c, &dto.LinkIdentityResponse{AuthURL: authURL})
+}
+
+// UnlinkIdentity 解绑登录方式，不能解绑最后一种登录方式
+// DELETE /api/v1/user/identities/:provider
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	if err := h.authService.Unlink(userID, c.Param("provider")); err != nil {
+		identityError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "已解绑", nil)
+}
+
+// redirectURI 读取第三方登录完成后跳转的前端地址，为空时使用默认地址
+// 不在 oauth.allowed_redirect_origins 中时拒绝，避免把登录结果交给任意站点
+func (h *AuthHandler) redirectURI(c *gin.Context) (string, bool) {
+	redirectURI := c.Query("redirect_uri")
+	if redirectURI == "" {
+		return defaultFrontendCallbackURL, true
+	}
+	if !h.authService.AllowedRedirect(redirectURI) {
+		response.ParamError(c, "不允许的跳转地址")
+		return "", false
+	}
+	return redirectURI, true
+}
+
+// finishLogin 第三方登录成功后跳转到前端，地址中只携带一次性的 login_code，
+// 前端通过 POST /auth/oauth/exchange 换取令牌，令牌不会出现在 URL 和浏览器历史中
+func (h *AuthHandler) finishLogin(c *gin.Context, redirectURI string, resp *dto.LoginResponse) {
+	code, err := h.stateStore.GenerateLoginCode(c.Request.Context(), resp)
+	if err != nil {
+		response.ServerError(c, "生成登录凭证失败")
+		return
+	}
+	c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s?%s", redirectURI, url.Values{"login_code": {code}}.Encode()))
+}
+
+// finishLink 完成第三方账号绑定，结果通过 linked 或 error 参数带回前端
+// 回调必须来自发起绑定的浏览器，否则攻击者可以把授权地址发给受害者，把受害者的第三方账号绑定到自己的账号上
+func (h *AuthHandler) finishLink(c *gin.Context, provider string, data *oauth.StateData, redirectURI string, link func() error) {
+	binding, _ := c.Cookie(linkCookieName)
+	h.setLinkCookie(c, "", -1)
+	if data.LinkBinding == "" || subtle.ConstantTimeCompare([]byte(binding), []byte(data.LinkBinding)) != 1 {
+		params := url.Values{"error": {"绑定请求无效，请在账号设置中重新发起"}}
+		c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s?%s", redirectURI, params.Encode()))
+		return
+	}
+
+	params := url.Values{"linked": {provider}}
+	if err := link(); err != nil {
+		switch {
+		case errors.Is(err, service.ErrIdentityInUse), errors.Is(err, service.ErrIdentityLinked):
+			params = url.Values{"error": {err.Error()}}
+		default:
+			params = url.Values{"error": {"绑定失败"}}
+		}
+	}
+	c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s?%s", redirectURI, params.Encode()))
+}
+
+// setLinkCookie 写入或清除绑定 cookie，maxAge 小于 0 时清除
+// 发起绑定的请求需要带上 credentials，浏览器才会保存该 cookie
+func (h *AuthHandler) setLinkCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(linkCookieName, value, maxAge, linkCookiePath, "", secure, true)
+}
+
+func identityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnsupportedProvider), errors.Is(err, service.ErrIdentityLinked),
+		errors.Is(err, service.ErrIdentityNotLinked), errors.Is(err, service.ErrLastLoginMethod),
+		errors.Is(err, service.ErrEmailMismatch), errors.Is(err, service.ErrEmailExists),
+		errors.Is(err, service.ErrOIDCDisabled):
+		response.ParamError(c, err.Error())
+	case errors.Is(err, service.ErrUserNotFound):
+		response.NotFoundError(c, err.Error())
+	default:
+		response.ServerError(c, "")
+	}
 }
@@ -2,17 +2,23 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
+	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
 	"github.com/qs3c/anal_go_server/internal/pkg/response"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/service"
@@ -36,10 +42,16 @@ func setupAuthHandler(t *testing.T) (*AuthHandler, func()) {
 		},
 	}
 
-	authService := service.NewAuthService(userRepo, cfg)
-	handler := NewAuthHandler(authService)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	authService := service.NewAuthService(userRepo, repository.NewPasswordResetRepository(db), repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
+	handler := NewAuthHandler(authService, oauth.NewStateStore(rdb))
 
 	cleanup := func() {
+		rdb.Close()
+		mr.Close()
 		testutil.CleanupTestDB(t, db)
 	}
 
@@ -248,3 +260,410 @@ func TestAuthHandler_GithubCallback_MissingCode(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, response.CodeParamError, resp.Code)
 }
+
+func TestAuthHandler_ResendVerification(t *testing.T) {
+	handler, cleanup := setupAuthHandler(t)
+	defer cleanup()
+
+	router := gin.New()
+	router.POST("/resend-verification", handler.ResendVerification)
+
+	// 邮箱格式错误
+	w := performRequest(router, "POST", "/resend-verification", dto.ResendVerificationRequest{Email: "invalid"})
+	resp := parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+
+	// 未注册的邮箱同样返回成功
+	w = performRequest(router, "POST", "/resend-verification", dto.ResendVerificationRequest{Email: "nobody@example.com"})
+	resp = parseResponse(t, w)
+	assert.Equal(t, response.CodeSuccess, resp.Code)
+}
+
+func TestAuthHandler_ResetPassword_InvalidToken(t *testing.T) {
+	handler, cleanup := setupAuthHandler(t)
+	defer cleanup()
+
+	router := gin.New()
+	router.POST("/reset-password", handler.ResetPassword)
+
+	w := performRequest(router, "POST", "/reset-password", dto.ResetPasswordRequest{Token: "invalid", Password: "newpassword123"})
+	resp := parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+
+	// 密码太短
+	w = performRequest(router, "POST", "/reset-password", dto.ResetPasswordRequest{Token: "invalid", Password: "short"})
+	resp = parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+}
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	handler, cleanup := setupAuthHandler(t)
+	defer cleanup()
+
+	router := gin.New()
+	router.POST("/register", handler.Register)
+	w := performRequest(router, "POST", "/register", dto.RegisterRequest{Email: "change@example.com", Username: "changer", Password: "password123"})
+	registered := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, registered.Code)
+	userID := int64(registered.Data.(map[string]interface{})["user_id"].(float64))
+
+	authed := gin.New()
+	authed.Use(mockAuth(userID))
+	authed.PUT("/user/password", handler.ChangePassword)
+
+	w = performRequest(authed, "PUT", "/user/password", dto.ChangePasswordRequest{OldPassword: "wrongpassword", NewPassword: "newpassword123"})
+	resp := parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+
+	w = performRequest(authed, "PUT", "/user/password", dto.ChangePasswordRequest{OldPassword: "password123", NewPassword: "newpassword123"})
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.NotEmpty(t, resp.Data.(map[string]interface{})["token"])
+	assert.NotEmpty(t, resp.Data.(map[string]interface{})["refresh_token"])
+}
+
+func TestAuthHandler_RefreshAndLogout_InvalidToken(t *testing.T) {
+	handler, cleanup := setupAuthHandler(t)
+	defer cleanup()
+
+	router := gin.New()
+	router.POST("/refresh", handler.Refresh)
+	router.POST("/logout", handler.Logout)
+
+	w := performRequest(router, "POST", "/refresh", dto.RefreshTokenRequest{RefreshToken: "invalid"})
+	resp := parseResponse(t, w)
+	assert.Equal(t, response.CodeAuthFailed, resp.Code)
+
+	w = performRequest(router, "POST", "/refresh", map[string]string{})
+	resp = parseResponse(t, w)
+	assert.Equal(t, response.CodeParamError, resp.Code)
+
+	// 退出登录是幂等的
+	w = performRequest(router, "POST", "/logout", dto.RefreshTokenRequest{RefreshToken: "invalid"})
+	resp = parseResponse(t, w)
+	assert.Equal(t, response.CodeSuccess, resp.Code)
+}
+
+func TestAuthHandler_JWKS(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	key, _, err := jwt.GenerateKey("k1", jwt.AlgEdDSA)
+	require.NoError(t, err)
+	keys, err := jwt.NewKeySet("k1", key, jwt.NewSecretKey("", "test-secret-key"))
+	require.NoError(t, err)
+
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret-key"}}
+	authService := service.NewAuthService(repository.NewUserRepository(db), repository.NewPasswordResetRepository(db),
+		repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, keys, cfg)
+	handler := NewAuthHandler(authService, nil)
+
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", handler.JWKS)
+	w := performRequest(router, "GET", "/.well-known/jwks.json", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// 直接返回 JWKS，只包含非对称公钥
+	var set jwt.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "k1", set.Keys[0].Kid)
+	assert.Equal(t, "OKP", set.Keys[0].Kty)
+}
+
+func TestAuthHandler_Wechat(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		JWT:   config.JWTConfig{Secret: "test-secret-key"},
+		OAuth: config.OAuthConfig{Wechat: config.WechatOAuthConfig{AppID: "wx-app", RedirectURI: "http://localhost/api/v1/auth/wechat/callback"}},
+	}
+	authService := service.NewAuthService(repository.NewUserRepository(db), repository.NewPasswordResetRepository(db),
+		repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
+	handler := NewAuthHandler(authService, oauth.NewStateStore(rdb))
+
+	router := gin.New()
+	router.GET("/wechat", handler.WechatAuth)
+	router.GET("/wechat/callback", handler.WechatCallback)
+
+	// 跳转到微信扫码页，state 保存在 Redis 中
+	w := performRequest(router, "GET", "/wechat", nil)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "open.weixin.qq.com", location.Host)
+	assert.Equal(t, "wx-app", location.Query().Get("appid"))
+	assert.True(t, mr.Exists("oauth:state:"+location.Query().Get("state")))
+
+	w = performRequest(router, "GET", "/wechat/callback?state="+location.Query().Get("state"), nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	// 无效的 state 直接拒绝
+	w = performRequest(router, "GET", "/wechat/callback?code=abc&state=forged", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	// 不允许的前端地址
+	w = performRequest(router, "GET", "/wechat?redirect_uri="+url.QueryEscape("https://evil.example.com/"), nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+}
+
+func TestAuthHandler_OIDC(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	idp := testutil.NewFakeOIDC(t, "oidc-client")
+	cfg := &config.Config{
+		JWT: config.JWTConfig{Secret: "test-secret-key"},
+		OAuth: config.OAuthConfig{AllowedRedirectOrigins: []string{"http://localhost"}, OIDC: config.OIDCConfig{
+			Issuer:        idp.Issuer(),
+			ClientID:      "oidc-client",
+			RedirectURI:   "http://localhost/api/v1/auth/oidc/callback",
+			AutoProvision: true,
+		}},
+	}
+	authService := service.NewAuthService(repository.NewUserRepository(db), repository.NewPasswordResetRepository(db),
+		repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
+	handler := NewAuthHandler(authService, oauth.NewStateStore(rdb))
+
+	router := gin.New()
+	router.GET("/oidc", handler.OIDCAuth)
+	router.GET("/oidc/callback", handler.OIDCCallback)
+	router.POST("/oauth/exchange", handler.ExchangeLoginCode)
+
+	// 不允许的前端地址
+	w := performRequest(router, "GET", "/oidc?redirect_uri="+url.QueryEscape("https://evil.example.com/done"), nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	// 跳转到 IdP，nonce 和 verifier 只保存在服务端
+	w = performRequest(router, "GET", "/oidc?redirect_uri=http://localhost/done", nil)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	authURL := w.Header().Get("Location")
+	assert.NotContains(t, authURL, "code_verifier")
+	code, state := idp.Authorize(t, authURL, map[string]interface{}{"sub": "sub-1", "preferred_username": "sso-user"})
+
+	// 无效的 state 直接拒绝
+	w = performRequest(router, "GET", "/oidc/callback?code="+code+"&state=forged", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "GET", "/oidc/callback?code="+code+"&state="+state, nil)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/done", location.Path)
+	assert.Empty(t, location.Query().Get("token"))
+	assert.Empty(t, location.Query().Get("refresh_token"))
+
+	// 前端用一次性的 login_code 换取令牌
+	loginCode := location.Query().Get("login_code")
+	w = performRequest(router, "POST", "/oauth/exchange", dto.OAuthExchangeRequest{LoginCode: loginCode})
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.NotEmpty(t, data["token"])
+	assert.NotEmpty(t, data["refresh_token"])
+	w = performRequest(router, "POST", "/oauth/exchange", dto.OAuthExchangeRequest{LoginCode: loginCode})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	// state 只能使用一次
+	w = performRequest(router, "GET", "/oidc/callback?code="+code+"&state="+state, nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+}
+
+func TestAuthHandler_LinkBoundToBrowser(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	idp := testutil.NewFakeOIDC(t, "oidc-client")
+	cfg := &config.Config{
+		JWT: config.JWTConfig{Secret: "test-secret-key"},
+		OAuth: config.OAuthConfig{OIDC: config.OIDCConfig{
+			Issuer:      idp.Issuer(),
+			ClientID:    "oidc-client",
+			RedirectURI: "http://localhost/api/v1/auth/oidc/callback",
+		}},
+	}
+	userRepo := repository.NewUserRepository(db)
+	authService := service.NewAuthService(userRepo, repository.NewPasswordResetRepository(db),
+		repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
+	handler := NewAuthHandler(authService, oauth.NewStateStore(rdb))
+	attacker := testutil.TestUser(t, db)
+
+	router := gin.New()
+	router.GET("/api/v1/auth/oidc/callback", handler.OIDCCallback)
+	router.POST("/user/identities/:provider", mockAuth(attacker.ID), handler.LinkIdentity)
+
+	// 发起绑定，绑定值写入 HttpOnly cookie
+	startLink := func() (string, *http.Cookie) {
+		w := performRequest(router, "POST", "/user/identities/oidc", nil)
+		resp := parseResponse(t, w)
+		require.Equal(t, response.CodeSuccess, resp.Code)
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, linkCookieName, cookies[0].Name)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, linkCookiePath, cookies[0].Path)
+		return resp.Data.(map[string]interface{})["auth_url"].(string), cookies[0]
+	}
+	callback := func(authURL string, cookie *http.Cookie) *url.URL {
+		code, state := idp.Authorize(t, authURL, map[string]interface{}{"sub": "victim-sub"})
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/callback?code="+code+"&state="+state, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location
+	}
+
+	// 受害者的浏览器打开攻击者发起的授权地址，没有绑定 cookie，拒绝绑定
+	authURL, _ := startLink()
+	location := callback(authURL, nil)
+	assert.NotEmpty(t, location.Query().Get("error"))
+	_, err = userRepo.GetByOIDCSubject("victim-sub")
+	assert.Error(t, err)
+
+	// 其他绑定流程的 cookie 也不能使用
+	_, otherCookie := startLink()
+	authURL, _ = startLink()
+	location = callback(authURL, otherCookie)
+	assert.NotEmpty(t, location.Query().Get("error"))
+
+	// 发起绑定的浏览器完成回调
+	authURL, cookie := startLink()
+	location = callback(authURL, cookie)
+	assert.Equal(t, service.ProviderOIDC, location.Query().Get("linked"))
+	user, err := userRepo.GetByOIDCSubject("victim-sub")
+	require.NoError(t, err)
+	assert.Equal(t, attacker.ID, user.ID)
+}
+
+func TestAuthHandler_OAuthRedirectAllowlist(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{Secret: "test-secret-key"},
+		OAuth: config.OAuthConfig{
+			AllowedRedirectOrigins: []string{"https://app.example.com/"},
+			Github:                 config.GithubOAuthConfig{ClientID: "gh-client"},
+		},
+	}
+	authService := service.NewAuthService(repository.NewUserRepository(db), repository.NewPasswordResetRepository(db),
+		repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
+	handler := NewAuthHandler(authService, oauth.NewStateStore(rdb))
+
+	router := gin.New()
+	router.GET("/github", handler.GithubAuth)
+
+	for _, redirectURI := range []string{
+		"https://evil.example.com/auth/callback",
+		"https://app.example.com.evil.com/auth/callback",
+		"https://app.example.com@evil.com/auth/callback",
+		"http://app.example.com/auth/callback",
+		"//evil.example.com",
+		"javascript:alert(1)",
+	} {
+		w := performRequest(router, "GET", "/github?redirect_uri="+url.QueryEscape(redirectURI), nil)
+		assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code, redirectURI)
+	}
+	assert.Empty(t, mr.Keys())
+
+	w := performRequest(router, "GET", "/github?redirect_uri="+url.QueryEscape("https://APP.example.com/auth/callback"), nil)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "github.com", location.Host)
+}
+
+func TestAuthHandler_Identities(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{Secret: "test-secret-key"},
+		OAuth: config.OAuthConfig{
+			AllowedRedirectOrigins: []string{"http://localhost"},
+			Github:                 config.GithubOAuthConfig{ClientID: "gh-client"},
+		},
+	}
+	authService := service.NewAuthService(repository.NewUserRepository(db), repository.NewPasswordResetRepository(db),
+		repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
+	stateStore := oauth.NewStateStore(rdb)
+	handler := NewAuthHandler(authService, stateStore)
+	user := testutil.TestUser(t, db)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/user/identities", handler.Identities)
+	router.POST("/user/identities/:provider", handler.LinkIdentity)
+	router.DELETE("/user/identities/:provider", handler.UnlinkIdentity)
+	router.GET("/github/callback", handler.GithubCallback)
+
+	w := performRequest(router, "GET", "/user/identities", nil)
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Len(t, resp.Data, 3)
+
+	// 唯一的登录方式不能解绑
+	w = performRequest(router, "DELETE", "/user/identities/email", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "DELETE", "/user/identities/github", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "POST", "/user/identities/qq", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "POST", "/user/identities/email", dto.LinkEmailRequest{Email: "invalid"})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	// 绑定 GitHub 返回授权地址，state 中记录了当前用户
+	w = performRequest(router, "POST", "/user/identities/github?redirect_uri=http://localhost/settings", nil)
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	authURL, err := url.Parse(resp.Data.(map[string]interface{})["auth_url"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "gh-client", authURL.Query().Get("client_id"))
+	data, err := stateStore.ValidateStateData(context.Background(), authURL.Query().Get("state"))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, data.LinkUserID)
+	assert.Equal(t, "http://localhost/settings", data.RedirectURI)
+	assert.NotEmpty(t, data.LinkBinding)
+
+	// 不允许的前端地址
+	w = performRequest(router, "POST", "/user/identities/github?redirect_uri="+url.QueryEscape("https://evil.example.com/"), nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	// GitHub 回调同样要求有效的 state
+	w = performRequest(router, "GET", "/github/callback?code=abc", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "GET", "/github/callback?code=abc&state=forged", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/service"
+)
+
+type ChannelHandler struct {
+	channelService *service.ChannelService
+}
+
+func NewChannelHandler(channelService *service.ChannelService) *ChannelHandler {
+	return &ChannelHandler{
+		channelService: channelService,
+	}
+}
+
+// List 获取通知渠道列表
+// GET /api/v1/notification-channels
+func (h *ChannelHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	items, err := h.channelService.List(userID)
+	if err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.Success(c, items)
+}
+
+// Create 创建通知渠道
+// POST /api/v1/notification-channels
+func (h *ChannelHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var req dto.CreateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	item, err := h.channelService.Create(userID, &req)
+	if err != nil {
+		channelError(c, err)
+		return
+	}
+
+	response.Success(c, item)
+}
+
+// Update 修改通知渠道
+// PUT /api/v1/notification-channels/:id
+func (h *ChannelHandler) Update(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	channelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的通知渠道ID")
+		return
+	}
+
+	var req dto.UpdateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	item, err := h.channelService.Update(userID, channelID, &req)
+	if err != nil {
+		channelError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "通知渠道已更新", item)
+}
+
+// Delete 删除通知渠道
+// DELETE /api/v1/notification-channels/:id
+func (h *ChannelHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	channelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的通知渠道ID")
+		return
+	}
+
+	if err := h.channelService.Delete(userID, channelID); err != nil {
+		channelError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "删除成功", nil)
+}
+
+// ListDeliveries 获取通知渠道最近的投递记录
+// GET /api/v1/notification-channels/:id/deliveries
+func (h *ChannelHandler) ListDeliveries(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	channelID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的通知渠道ID")
+		return
+	}
+
+	items, err := h.channelService.ListDeliveries(userID, channelID)
+	if err != nil {
+		channelError(c, err)
+		return
+	}
+
+	response.Success(c, items)
+}
+
+func channelError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrChannelNotFound:
+		response.NotFoundError(c, err.Error())
+	case service.ErrChannelPermission:
+		response.PermissionError(c, err.Error())
+	case service.ErrInvalidChannelURL, service.ErrInvalidChannelEvent, service.ErrChannelLimitReached,
+		service.ErrChannelEmailMissing:
+		response.ParamError(c, err.Error())
+	default:
+		response.ServerError(c, "")
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/notify"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestChannelHandler_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	channelService := service.NewChannelService(repository.NewChannelRepository(db), repository.NewUserRepository(db), &config.Config{})
+	handler := NewChannelHandler(channelService)
+	user := testutil.TestUser(t, db)
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/notification-channels", handler.List)
+	router.POST("/notification-channels", handler.Create)
+	router.PUT("/notification-channels/:id", handler.Update)
+	router.DELETE("/notification-channels/:id", handler.Delete)
+	router.GET("/notification-channels/:id/deliveries", handler.ListDeliveries)
+
+	w := performRequest(router, "POST", "/notification-channels", dto.CreateChannelRequest{Type: "sms", Events: []string{notify.EventAnalysisCompleted}})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "POST", "/notification-channels", dto.CreateChannelRequest{Type: "webhook", URL: "not a url", Events: []string{notify.EventAnalysisCompleted}})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "POST", "/notification-channels", dto.CreateChannelRequest{
+		Type: "webhook", URL: "https://example.com/hook", Events: []string{notify.EventAnalysisFailed},
+	})
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.NotEmpty(t, data["secret"])
+	path := fmt.Sprintf("/notification-channels/%d", int64(data["id"].(float64)))
+
+	w = performRequest(router, "GET", "/notification-channels", nil)
+	items := parseResponse(t, w).Data.([]interface{})
+	require.Len(t, items, 1)
+	assert.Nil(t, items[0].(map[string]interface{})["secret"])
+
+	w = performRequest(router, "PUT", path, dto.UpdateChannelRequest{Events: []string{"unknown"}})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "GET", path+"/deliveries", nil)
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Empty(t, resp.Data)
+
+	w = performRequest(router, "DELETE", "/notification-channels/abc", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "DELETE", path, nil)
+	assert.Equal(t, response.CodeSuccess, parseResponse(t, w).Code)
+	w = performRequest(router, "DELETE", path, nil)
+	assert.Equal(t, response.CodeResourceNotFound, parseResponse(t, w).Code)
+}
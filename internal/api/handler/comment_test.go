@@ -30,7 +30,7 @@ func setupCommentHandler(t *testing.T) (*CommentHandler, *testContext, func()) {
 
 	cfg := &config.Config{}
 
-	commentService := service.NewCommentService(commentRepo, analysisRepo, userRepo, cfg)
+	commentService := service.NewCommentService(commentRepo, analysisRepo, userRepo, nil, cfg)
 	handler := NewCommentHandler(commentService)
 
 	ctx := &testContext{
@@ -26,7 +26,7 @@ func setupCommunityHandler(t *testing.T) (*CommunityHandler, *testContext, func(
 
 	cfg := &config.Config{}
 
-	communityService := service.NewCommunityService(analysisRepo, interactionRepo, cfg)
+	communityService := service.NewCommunityService(analysisRepo, interactionRepo, nil, nil, cfg)
 	handler := NewCommunityHandler(communityService)
 
 	ctx := &testContext{
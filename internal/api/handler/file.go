@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
+)
+
+// FileHandler 提供本地存储签名 URL 的下载，OSS/S3 的签名 URL 由存储服务直接校验
+type FileHandler struct {
+	local *storage.Local
+}
+
+func NewFileHandler(local *storage.Local) *FileHandler {
+	return &FileHandler{local: local}
+}
+
+// Get 校验签名后返回文件内容
+// GET /api/v1/files/*key?expires=&signature=
+func (h *FileHandler) Get(c *gin.Context) {
+	if h.local == nil {
+		response.NotFoundError(c, "文件不存在")
+		return
+	}
+
+	key := c.Param("key")
+	if len(key) > 0 && key[0] == '/' {
+		key = key[1:]
+	}
+	if err := h.local.VerifySignedURL(key, c.Query("expires"), c.Query("signature")); err != nil {
+		response.PermissionError(c, "链接无效或已过期")
+		return
+	}
+
+	info, err := h.local.Stat(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			response.NotFoundError(c, "文件不存在")
+		} else {
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	path, _ := h.local.Path(key)
+	c.Header("Content-Type", info.ContentType)
+	if info.ContentEncoding != "" {
+		c.Header("Content-Encoding", info.ContentEncoding)
+	}
+	c.File(path)
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
+)
+
+func TestFileHandler_Get(t *testing.T) {
+	local := storage.NewLocal(t.TempDir(), "/files", "secret")
+	require.NoError(t, local.Put(context.Background(), "diagrams/1/1.json", []byte(`{"ok":true}`), storage.PutOptions{ContentType: "application/json"}))
+
+	router := gin.New()
+	router.GET("/files/*key", NewFileHandler(local).Get)
+
+	signed, err := local.SignedURL("diagrams/1/1.json", time.Minute)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", signed, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"ok":true}`, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	// 没有签名
+	w = performRequest(router, "GET", "/files/diagrams/1/1.json", nil)
+	assert.Equal(t, response.CodePermissionDenied, parseResponse(t, w).Code)
+
+	// 签名有效但文件已删除
+	require.NoError(t, local.Delete(context.Background(), "diagrams/1/1.json"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", signed, nil))
+	assert.Equal(t, response.CodeResourceNotFound, parseResponse(t, w).Code)
+}
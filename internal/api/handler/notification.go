@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/service"
+)
+
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// List 获取通知列表，unread=true 时只返回未读
+// GET /api/v1/notifications
+func (h *NotificationHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	resp, err := h.notificationService.List(userID, page, pageSize, unreadOnly)
+	if err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// UnreadCount 获取未读通知数
+// GET /api/v1/notifications/unread-count
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	resp, err := h.notificationService.UnreadCount(userID)
+	if err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// MarkRead 标记通知为已读
+// PUT /api/v1/notifications/:id/read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的通知ID")
+		return
+	}
+
+	resp, err := h.notificationService.MarkRead(userID, notificationID)
+	if err != nil {
+		if err == service.ErrNotificationNotFound {
+			response.NotFoundError(c, err.Error())
+			return
+		}
+		response.ServerError(c, "")
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// MarkAllRead 标记全部通知为已读
+// PUT /api/v1/notifications/read-all
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	resp, err := h.notificationService.MarkAllRead(userID)
+	if err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.SuccessWithMessage(c, "已全部标记为已读", resp)
+}
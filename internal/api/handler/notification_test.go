@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestNotificationHandler(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	notificationService := service.NewNotificationService(repository.NewNotificationRepository(db), nil, &config.Config{})
+	handler := NewNotificationHandler(notificationService)
+	user := testutil.TestUser(t, db)
+	actor := testutil.TestUser(t, db)
+
+	notificationService.Notify(&model.Notification{UserID: user.ID, ActorID: actor.ID, Type: model.NotificationLike, AnalysisID: 1})
+	notificationService.Notify(&model.Notification{UserID: user.ID, Type: model.NotificationAnalysisFailed, AnalysisID: 2, Content: "仓库克隆失败"})
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/notifications", handler.List)
+	router.GET("/notifications/unread-count", handler.UnreadCount)
+	router.PUT("/notifications/read-all", handler.MarkAllRead)
+	router.PUT("/notifications/:id/read", handler.MarkRead)
+
+	w := performRequest(router, "GET", "/notifications?page_size=1", nil)
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.Equal(t, float64(2), data["total"])
+	assert.Equal(t, float64(2), data["unread_count"])
+	items := data["items"].([]interface{})
+	require.Len(t, items, 1)
+	latest := items[0].(map[string]interface{})
+	assert.Equal(t, model.NotificationAnalysisFailed, latest["type"])
+	assert.Equal(t, false, latest["read"])
+
+	w = performRequest(router, "PUT", fmt.Sprintf("/notifications/%d/read", int64(latest["id"].(float64))), nil)
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Equal(t, float64(1), resp.Data.(map[string]interface{})["unread_count"])
+
+	w = performRequest(router, "PUT", "/notifications/abc/read", nil)
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "PUT", "/notifications/99999/read", nil)
+	assert.Equal(t, response.CodeResourceNotFound, parseResponse(t, w).Code)
+
+	w = performRequest(router, "GET", "/notifications?unread=true", nil)
+	data = parseResponse(t, w).Data.(map[string]interface{})
+	assert.Equal(t, float64(1), data["total"])
+
+	w = performRequest(router, "PUT", "/notifications/read-all", nil)
+	assert.Equal(t, response.CodeSuccess, parseResponse(t, w).Code)
+
+	w = performRequest(router, "GET", "/notifications/unread-count", nil)
+	assert.Equal(t, float64(0), parseResponse(t, w).Data.(map[string]interface{})["unread_count"])
+}
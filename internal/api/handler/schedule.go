@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/service"
+)
+
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+}
+
+func NewScheduleHandler(scheduleService *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+// List 获取定时任务列表，可按 analysis_id 过滤
+// GET /api/v1/schedules
+func (h *ScheduleHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var analysisID int64
+	if v := c.Query("analysis_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			response.ParamError(c, "无效的分析ID")
+			return
+		}
+		analysisID = id
+	}
+
+	items, err := h.scheduleService.List(userID, analysisID)
+	if err != nil {
+		response.ServerError(c, "")
+		return
+	}
+
+	response.Success(c, items)
+}
+
+// Create 创建定时任务
+// POST /api/v1/schedules
+func (h *ScheduleHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var req dto.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	item, err := h.scheduleService.Create(userID, &req)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.Success(c, item)
+}
+
+// Get 获取定时任务
+// GET /api/v1/schedules/:id
+func (h *ScheduleHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的定时任务ID")
+		return
+	}
+
+	item, err := h.scheduleService.Get(userID, scheduleID)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.Success(c, item)
+}
+
+// Update 修改定时任务
+// PUT /api/v1/schedules/:id
+func (h *ScheduleHandler) Update(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的定时任务ID")
+		return
+	}
+
+	var req dto.UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	item, err := h.scheduleService.Update(userID, scheduleID, &req)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "定时任务已更新", item)
+}
+
+// Delete 删除定时任务
+// DELETE /api/v1/schedules/:id
+func (h *ScheduleHandler) Delete(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的定时任务ID")
+		return
+	}
+
+	if err := h.scheduleService.Delete(userID, scheduleID); err != nil {
+		scheduleError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "删除成功", nil)
+}
+
+func scheduleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrScheduleNotFound, service.ErrAnalysisNotFound:
+		response.NotFoundError(c, err.Error())
+	case service.ErrSchedulePermission, service.ErrAnalysisPermission:
+		response.PermissionError(c, err.Error())
+	case service.ErrInvalidCron, service.ErrScheduleTooFrequent, service.ErrScheduleLimitReached,
+		service.ErrInvalidRef, service.ErrRerunNotSupported:
+		response.ParamError(c, err.Error())
+	default:
+		response.ServerError(c, "")
+	}
+}
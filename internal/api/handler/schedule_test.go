@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestScheduleHandler_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	scheduleService := service.NewScheduleService(repository.NewScheduleRepository(db), repository.NewAnalysisRepository(db), &config.Config{})
+	handler := NewScheduleHandler(scheduleService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, func(a *model.Analysis) {
+		a.CreationType = "ai"
+		a.SourceType = "github"
+	})
+
+	router := gin.New()
+	router.Use(mockAuth(user.ID))
+	router.GET("/schedules", handler.List)
+	router.POST("/schedules", handler.Create)
+	router.GET("/schedules/:id", handler.Get)
+	router.PUT("/schedules/:id", handler.Update)
+	router.DELETE("/schedules/:id", handler.Delete)
+
+	w := performRequest(router, "POST", "/schedules", dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "* * * * *"})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+	w = performRequest(router, "POST", "/schedules", dto.CreateScheduleRequest{AnalysisID: analysis.ID})
+	assert.Equal(t, response.CodeParamError, parseResponse(t, w).Code)
+
+	w = performRequest(router, "POST", "/schedules", dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "0 3 * * 1", Ref: "main"})
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.Equal(t, "main", data["ref"])
+	assert.NotEmpty(t, data["next_run_at"])
+	path := fmt.Sprintf("/schedules/%d", int64(data["id"].(float64)))
+
+	w = performRequest(router, "GET", fmt.Sprintf("/schedules?analysis_id=%d", analysis.ID), nil)
+	assert.Len(t, parseResponse(t, w).Data, 1)
+
+	enabled := false
+	w = performRequest(router, "PUT", path, dto.UpdateScheduleRequest{Enabled: &enabled})
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Nil(t, resp.Data.(map[string]interface{})["next_run_at"])
+
+	w = performRequest(router, "DELETE", path, nil)
+	assert.Equal(t, response.CodeSuccess, parseResponse(t, w).Code)
+	w = performRequest(router, "GET", path, nil)
+	assert.Equal(t, response.CodeResourceNotFound, parseResponse(t, w).Code)
+}
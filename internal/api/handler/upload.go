@@ -1,13 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"io"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
 	"github.com/qs3c/anal_go_server/internal/pkg/response"
 	"github.com/qs3c/anal_go_server/internal/service"
 )
@@ -24,9 +26,15 @@ func NewUploadHandler(uploadService *service.UploadService, cfg *config.Config)
 	}
 }
 
-// Parse 解析上传的 ZIP 文件
+// Parse 解析上传的压缩包（ZIP / TAR / TAR.GZ）
 // POST /api/v1/upload/parse
 func (h *UploadHandler) Parse(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		response.ParamError(c, "请上传文件")
@@ -40,22 +48,8 @@ func (h *UploadHandler) Parse(c *gin.Context) {
 		return
 	}
 
-	// Check extension
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	allowed := false
-	for _, allowedExt := range h.cfg.Upload.AllowedExtensions {
-		if ext == allowedExt {
-			allowed = true
-			break
-		}
-	}
-	if !allowed {
-		response.ParamError(c, "仅支持 ZIP 格式")
-		return
-	}
-
-	// Save to temp file
-	tempFile, err := os.CreateTemp("", "upload-*.zip")
+	// Save to temp file (format is detected from content, not extension)
+	tempFile, err := os.CreateTemp("", "upload-*")
 	if err != nil {
 		response.ServerError(c, "文件保存失败")
 		return
@@ -68,19 +62,184 @@ func (h *UploadHandler) Parse(c *gin.Context) {
 		return
 	}
 
-	// Parse ZIP
-	result, err := h.uploadService.ParseZip(tempFile.Name())
+	// Parse archive
+	result, err := h.uploadService.ParseZip(userID, tempFile.Name(), header.Filename)
+	if err != nil {
+		respondParseError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// ImportModule 从 GOPROXY 下载模块并解析
+// POST /api/v1/upload/module
+func (h *UploadHandler) ImportModule(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var req dto.ImportModuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	result, err := h.uploadService.ImportModule(c.Request.Context(), userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidModule),
+			errors.Is(err, service.ErrModuleChecksum):
+			response.ParamError(c, err.Error())
+		case errors.Is(err, service.ErrModuleNotFound):
+			response.NotFoundError(c, err.Error())
+		case errors.Is(err, service.ErrFileTooLarge):
+			response.ParamError(c, "模块过大，最大支持 100MB")
+		case errors.Is(err, service.ErrModuleDownload):
+			response.ServerError(c, service.ErrModuleDownload.Error())
+		default:
+			respondParseError(c, err)
+		}
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// InitChunked 创建分片上传会话
+// POST /api/v1/upload/chunks
+func (h *UploadHandler) InitChunked(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	var req dto.InitChunkedUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	status, err := h.uploadService.InitChunkedUpload(userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrFileTooLarge) {
+			response.ParamError(c, "文件过大，最大支持 100MB")
+			return
+		}
+		response.ServerError(c, "创建上传会话失败")
+		return
+	}
+
+	response.Success(c, status)
+}
+
+// UploadChunk 上传单个分片，请求体为分片原始内容，X-Chunk-SHA256 头为分片的 SHA-256
+// PUT /api/v1/upload/chunks/:id/parts/:part
+func (h *UploadHandler) UploadChunk(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	partNumber, err := strconv.Atoi(c.Param("part"))
+	if err != nil {
+		response.ParamError(c, "无效的分片编号")
+		return
+	}
+
+	checksum := c.GetHeader("X-Chunk-SHA256")
+	if checksum == "" {
+		response.ParamError(c, "缺少分片校验值")
+		return
+	}
+
+	if err := h.uploadService.UploadChunk(userID, c.Param("id"), partNumber, checksum, c.Request.Body); err != nil {
+		respondChunkError(c, err, "分片保存失败")
+		return
+	}
+
+	response.SuccessWithMessage(c, "上传成功", nil)
+}
+
+// GetChunked 查询分片上传会话及已接收的分片，用于断点续传
+// GET /api/v1/upload/chunks/:id
+func (h *UploadHandler) GetChunked(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	status, err := h.uploadService.GetChunkedUpload(userID, c.Param("id"))
 	if err != nil {
-		switch err {
-		case service.ErrInvalidZip:
-			response.ParamError(c, "ZIP 文件损坏或无法解压")
-		case service.ErrNoGoFiles:
-			response.ParamError(c, "未找到 Go 源文件")
+		respondChunkError(c, err, "查询上传会话失败")
+		return
+	}
+
+	response.Success(c, status)
+}
+
+// CompleteChunked 合并分片并解析
+// POST /api/v1/upload/chunks/:id/complete
+func (h *UploadHandler) CompleteChunked(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	result, err := h.uploadService.CompleteChunkedUpload(userID, c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrChunkSessionNotFound),
+			errors.Is(err, service.ErrChunksIncomplete),
+			errors.Is(err, service.ErrChunkInvalid),
+			errors.Is(err, service.ErrFileChecksum),
+			errors.Is(err, service.ErrChunkCompleting):
+			respondChunkError(c, err, "解析失败")
 		default:
-			response.ServerError(c, "解析失败")
+			respondParseError(c, err)
 		}
 		return
 	}
 
 	response.Success(c, result)
 }
+
+// respondParseError 把压缩包解析错误转换为响应
+func respondParseError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidFormat),
+		errors.Is(err, service.ErrInvalidArchive),
+		errors.Is(err, service.ErrUnsafeArchive),
+		errors.Is(err, service.ErrArchiveTooLarge),
+		errors.Is(err, service.ErrArchiveTooMany),
+		errors.Is(err, service.ErrArchiveTooDeep),
+		errors.Is(err, service.ErrArchiveBomb),
+		errors.Is(err, service.ErrNoGoFiles):
+		response.ParamError(c, err.Error())
+	default:
+		response.ServerError(c, "解析失败")
+	}
+}
+
+// respondChunkError 把分片上传错误转换为响应
+func respondChunkError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrChunkSessionNotFound):
+		response.NotFoundError(c, err.Error())
+	case errors.Is(err, service.ErrChunkInvalid),
+		errors.Is(err, service.ErrChunkChecksum),
+		errors.Is(err, service.ErrChunksIncomplete),
+		errors.Is(err, service.ErrFileChecksum):
+		response.ParamError(c, err.Error())
+	case errors.Is(err, service.ErrChunkCompleting):
+		response.DuplicateError(c, err.Error())
+	default:
+		response.ServerError(c, fallback)
+	}
+}
@@ -1,18 +1,28 @@
 package handler
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/archive"
+	"github.com/qs3c/anal_go_server/internal/pkg/goproxy"
 	"github.com/qs3c/anal_go_server/internal/service"
 )
 
@@ -27,7 +37,7 @@ func TestUploadHandler_Parse(t *testing.T) {
 			AllowedExtensions: []string{".zip"},
 		},
 	}
-	uploadService := service.NewUploadService(cfg)
+	uploadService := service.NewUploadService(nil, cfg)
 	handler := NewUploadHandler(uploadService, cfg)
 
 	// 创建测试 ZIP
@@ -49,6 +59,7 @@ type App struct { Name string }
 
 	w := httptest.NewRecorder()
 	router := gin.New()
+	router.Use(mockAuth(1))
 	router.POST("/api/v1/upload/parse", handler.Parse)
 	router.ServeHTTP(w, req)
 
@@ -65,6 +76,212 @@ type App struct { Name string }
 	assert.Equal(t, 1, resp.Data.TotalFiles)
 }
 
+func TestUploadHandler_Parse_TarGz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Upload: config.UploadConfig{
+			MaxSize:           104857600,
+			TempDir:           t.TempDir(),
+			ExpireHours:       1,
+			AllowedExtensions: []string{".zip", ".tar.gz"},
+		},
+	}
+	uploadService := service.NewUploadService(nil, cfg)
+	handler := NewUploadHandler(uploadService, cfg)
+
+	// 创建测试 tar.gz
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	content := "package main\ntype App struct { Name string }\n"
+	tw.WriteHeader(&tar.Header{Name: "app/main.go", Mode: 0644, Size: int64(len(content))})
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "project.tgz")
+	part.Write(buf.Bytes())
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/upload/parse", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	router := gin.New()
+	router.Use(mockAuth(1))
+	router.POST("/api/v1/upload/parse", handler.Parse)
+	router.ServeHTTP(w, req)
+
+	var resp struct {
+		Code int                     `json:"code"`
+		Data dto.ParseUploadResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	assert.Equal(t, 0, resp.Code)
+	assert.Equal(t, 1, resp.Data.TotalFiles)
+	assert.Equal(t, "app/main.go", resp.Data.Files[0].Path)
+}
+
+func TestUploadHandler_ImportModule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	zipContent := createTestZipContent(t, map[string]string{
+		"example.com/lib@v1.0.0/lib.go": "package lib\ntype Client struct{}\n",
+	})
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.com/lib/@v/v1.0.0.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(zipContent)
+	}))
+	defer proxy.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "lib.zip")
+	os.WriteFile(zipPath, zipContent, 0644)
+	sum, _ := goproxy.HashZip(zipPath, archive.Limits{})
+
+	cfg := &config.Config{
+		Upload: config.UploadConfig{
+			MaxSize:     104857600,
+			TempDir:     t.TempDir(),
+			ExpireHours: 1,
+		},
+		GoProxy: config.GoProxyConfig{URL: proxy.URL},
+	}
+	uploadService := service.NewUploadService(nil, cfg)
+	handler := NewUploadHandler(uploadService, cfg)
+
+	router := gin.New()
+	router.Use(mockAuth(1))
+	router.POST("/api/v1/upload/module", handler.ImportModule)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/upload/module", dto.ImportModuleRequest{
+		Module: "example.com/lib@v1.0.0",
+		Sum:    sum,
+	})
+	var resp struct {
+		Code int                     `json:"code"`
+		Data dto.ParseUploadResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, 0, resp.Code)
+	assert.Equal(t, "example.com/lib@v1.0.0", resp.Data.Module)
+	assert.Equal(t, 1, resp.Data.TotalFiles)
+
+	// 校验和不匹配
+	w = performRequest(router, http.MethodPost, "/api/v1/upload/module", dto.ImportModuleRequest{
+		Module: "example.com/lib@v1.0.0",
+		Sum:    "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	})
+	assert.Equal(t, 1000, parseResponse(t, w).Code)
+
+	// 版本不存在
+	w = performRequest(router, http.MethodPost, "/api/v1/upload/module", dto.ImportModuleRequest{
+		Module: "example.com/lib@v2.0.0",
+		Sum:    sum,
+	})
+	assert.Equal(t, 1003, parseResponse(t, w).Code)
+
+	// 缺少校验和
+	w = performRequest(router, http.MethodPost, "/api/v1/upload/module", map[string]string{
+		"module": "example.com/lib@v1.0.0",
+	})
+	assert.Equal(t, 1000, parseResponse(t, w).Code)
+}
+
+func TestUploadHandler_ChunkedUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Upload: config.UploadConfig{
+			MaxSize:     104857600,
+			ChunkSize:   64,
+			TempDir:     t.TempDir(),
+			ExpireHours: 1,
+		},
+	}
+	uploadService := service.NewUploadService(nil, cfg)
+	handler := NewUploadHandler(uploadService, cfg)
+
+	router := gin.New()
+	router.Use(mockAuth(1))
+	router.POST("/api/v1/upload/chunks", handler.InitChunked)
+	router.GET("/api/v1/upload/chunks/:id", handler.GetChunked)
+	router.PUT("/api/v1/upload/chunks/:id/parts/:part", handler.UploadChunk)
+	router.POST("/api/v1/upload/chunks/:id/complete", handler.CompleteChunked)
+
+	zipContent := createTestZipContent(t, map[string]string{
+		"main.go": `package main
+type App struct { Name string }
+`,
+	})
+
+	// 创建会话
+	w := performRequest(router, http.MethodPost, "/api/v1/upload/chunks", dto.InitChunkedUploadRequest{
+		Filename:  "project.zip",
+		TotalSize: int64(len(zipContent)),
+	})
+	var initResp struct {
+		Code int                     `json:"code"`
+		Data dto.ChunkedUploadStatus `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &initResp)
+	assert.Equal(t, 0, initResp.Code)
+	sessionID := initResp.Data.SessionID
+	partsPath := "/api/v1/upload/chunks/" + sessionID + "/parts/"
+
+	// 校验值错误的分片被拒绝
+	req := httptest.NewRequest(http.MethodPut, partsPath+"1", bytes.NewReader(zipContent[:64]))
+	req.Header.Set("X-Chunk-SHA256", strings.Repeat("0", 64))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 1000, parseResponse(t, w).Code)
+
+	for i := 0; i < initResp.Data.TotalParts; i++ {
+		end := (i + 1) * 64
+		if end > len(zipContent) {
+			end = len(zipContent)
+		}
+		chunk := zipContent[i*64 : end]
+		sum := sha256.Sum256(chunk)
+
+		req := httptest.NewRequest(http.MethodPut, partsPath+strconv.Itoa(i+1), bytes.NewReader(chunk))
+		req.Header.Set("X-Chunk-SHA256", hex.EncodeToString(sum[:]))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 0, parseResponse(t, w).Code)
+	}
+
+	// 查询已接收分片
+	w = performRequest(router, http.MethodGet, "/api/v1/upload/chunks/"+sessionID, nil)
+	var statusResp struct {
+		Code int                     `json:"code"`
+		Data dto.ChunkedUploadStatus `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &statusResp)
+	assert.Len(t, statusResp.Data.ReceivedParts, initResp.Data.TotalParts)
+
+	// 合并并解析
+	w = performRequest(router, http.MethodPost, "/api/v1/upload/chunks/"+sessionID+"/complete", nil)
+	var parseResp struct {
+		Code int                     `json:"code"`
+		Data dto.ParseUploadResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &parseResp)
+	assert.Equal(t, 0, parseResp.Code)
+	assert.NotEmpty(t, parseResp.Data.UploadID)
+	assert.Equal(t, 1, parseResp.Data.TotalFiles)
+
+	// 会话合并后不可再访问
+	w = performRequest(router, http.MethodGet, "/api/v1/upload/chunks/"+sessionID, nil)
+	assert.Equal(t, 1003, parseResponse(t, w).Code)
+}
+
 func TestUploadHandler_Parse_NoFile(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -76,13 +293,14 @@ func TestUploadHandler_Parse_NoFile(t *testing.T) {
 			AllowedExtensions: []string{".zip"},
 		},
 	}
-	uploadService := service.NewUploadService(cfg)
+	uploadService := service.NewUploadService(nil, cfg)
 	handler := NewUploadHandler(uploadService, cfg)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/v1/upload/parse", nil)
 
 	w := httptest.NewRecorder()
 	router := gin.New()
+	router.Use(mockAuth(1))
 	router.POST("/api/v1/upload/parse", handler.Parse)
 	router.ServeHTTP(w, req)
 
@@ -108,7 +326,7 @@ func TestUploadHandler_Parse_WrongExtension(t *testing.T) {
 			AllowedExtensions: []string{".zip"},
 		},
 	}
-	uploadService := service.NewUploadService(cfg)
+	uploadService := service.NewUploadService(nil, cfg)
 	handler := NewUploadHandler(uploadService, cfg)
 
 	// 创建带错误扩展名的请求
@@ -123,6 +341,7 @@ func TestUploadHandler_Parse_WrongExtension(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := gin.New()
+	router.Use(mockAuth(1))
 	router.POST("/api/v1/upload/parse", handler.Parse)
 	router.ServeHTTP(w, req)
 
@@ -149,7 +368,7 @@ func TestUploadHandler_Parse_FileTooLarge(t *testing.T) {
 			AllowedExtensions: []string{".zip"},
 		},
 	}
-	uploadService := service.NewUploadService(cfg)
+	uploadService := service.NewUploadService(nil, cfg)
 	handler := NewUploadHandler(uploadService, cfg)
 
 	// 创建测试 ZIP (会大于 100 bytes)
@@ -170,6 +389,7 @@ type App struct { Name string }
 
 	w := httptest.NewRecorder()
 	router := gin.New()
+	router.Use(mockAuth(1))
 	router.POST("/api/v1/upload/parse", handler.Parse)
 	router.ServeHTTP(w, req)
 
@@ -196,7 +416,7 @@ func TestUploadHandler_Parse_NoGoFiles(t *testing.T) {
 			AllowedExtensions: []string{".zip"},
 		},
 	}
-	uploadService := service.NewUploadService(cfg)
+	uploadService := service.NewUploadService(nil, cfg)
 	handler := NewUploadHandler(uploadService, cfg)
 
 	// 创建不包含 Go 文件的 ZIP
@@ -215,6 +435,7 @@ func TestUploadHandler_Parse_NoGoFiles(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	router := gin.New()
+	router.Use(mockAuth(1))
 	router.POST("/api/v1/upload/parse", handler.Parse)
 	router.ServeHTTP(w, req)
 
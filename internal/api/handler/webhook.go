@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/pkg/webhook"
+	"github.com/qs3c/anal_go_server/internal/service"
+)
+
+// maxWebhookBody GitHub 投递的请求体上限为 25MB
+const maxWebhookBody = 25 << 20
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// Github 接收 GitHub 推送事件
+// POST /api/v1/webhooks/github
+func (h *WebhookHandler) Github(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		response.ParamError(c, "请求体过大或读取失败")
+		return
+	}
+
+	item, err := h.webhookService.HandleGithub(
+		c.GetHeader(webhook.HeaderGithubDelivery),
+		c.GetHeader(webhook.HeaderGithubEvent),
+		c.GetHeader(webhook.HeaderGithubSignature),
+		body,
+	)
+	if err != nil {
+		switch err {
+		case service.ErrWebhookSignature:
+			response.AuthError(c, err.Error())
+		case service.ErrWebhookDeliveryID:
+			response.ParamError(c, err.Error())
+		default:
+			response.ServerError(c, "")
+		}
+		return
+	}
+
+	response.Success(c, item)
+}
+
+// GetSettings 获取分析的推送触发设置、签名密钥和最近的投递记录，需要 analyses:write 权限
+// GET /api/v1/analyses/:id/webhook
+func (h *WebhookHandler) GetSettings(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	settings, err := h.webhookService.GetSettings(userID, analysisID)
+	if err != nil {
+		webhookSettingsError(c, err)
+		return
+	}
+
+	response.Success(c, settings)
+}
+
+// UpdateSettings 开启或关闭推送触发
+// PUT /api/v1/analyses/:id/webhook
+func (h *WebhookHandler) UpdateSettings(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		response.AuthError(c, "")
+		return
+	}
+
+	analysisID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的分析ID")
+		return
+	}
+
+	var req dto.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	settings, err := h.webhookService.UpdateSettings(userID, analysisID, &req)
+	if err != nil {
+		webhookSettingsError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "设置已保存", settings)
+}
+
+func webhookSettingsError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrAnalysisNotFound:
+		response.NotFoundError(c, err.Error())
+	case service.ErrAnalysisPermission:
+		response.PermissionError(c, err.Error())
+	case service.ErrRerunNotSupported, service.ErrInvalidRef:
+		response.ParamError(c, err.Error())
+	default:
+		response.ServerError(c, "")
+	}
+}
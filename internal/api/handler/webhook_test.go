@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/response"
+	"github.com/qs3c/anal_go_server/internal/pkg/webhook"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestWebhookHandler_Github(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{"free": {DailyQuota: 5, MaxDepth: 3}},
+		},
+		Models: []config.ModelConfig{{Name: "gpt-3.5-turbo", RequiredLevel: "free"}},
+	}
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	analysisService := service.NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo,
+		service.NewQuotaService(userRepo, cfg), nil, nil, nil, cfg)
+	handler := NewWebhookHandler(service.NewWebhookService(repository.NewWebhookRepository(db), analysisRepo, analysisService))
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, func(a *model.Analysis) {
+		a.CreationType = "ai"
+		a.SourceType = "github"
+		a.RepoURL = "https://github.com/octo/repo"
+		a.AnalysisDepth = 2
+		a.ModelName = "gpt-3.5-turbo"
+	})
+
+	router := gin.New()
+	router.POST("/webhooks/github", handler.Github)
+	authed := router.Group("", mockAuth(user.ID))
+	authed.GET("/analyses/:id/webhook", handler.GetSettings)
+	authed.PUT("/analyses/:id/webhook", handler.UpdateSettings)
+
+	settingsPath := fmt.Sprintf("/analyses/%d/webhook", analysis.ID)
+	w := performRequest(router, "PUT", settingsPath, dto.UpdateWebhookRequest{Enabled: true})
+	resp := parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	secret := []byte(resp.Data.(map[string]interface{})["secret"].(string))
+
+	deliver := func(deliveryID, signature string, body []byte) response.Response {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(body))
+		req.Header.Set(webhook.HeaderGithubEvent, "push")
+		req.Header.Set(webhook.HeaderGithubDelivery, deliveryID)
+		req.Header.Set(webhook.HeaderGithubSignature, signature)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return parseResponse(t, w)
+	}
+
+	body := []byte(`{"ref":"refs/heads/main","after":"0123456789abcdef0123456789abcdef01234567","repository":{"full_name":"octo/repo","html_url":"https://github.com/octo/repo","default_branch":"main"}}`)
+	assert.Equal(t, response.CodeAuthFailed, deliver("d-1", "sha256=00", body).Code)
+
+	resp = deliver("d-1", webhook.Sign(secret, body), body)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	data := resp.Data.(map[string]interface{})
+	assert.Equal(t, "processed", data["status"])
+	assert.Len(t, data["results"], 1)
+
+	resp = deliver("d-1", webhook.Sign(secret, body), body)
+	assert.Equal(t, true, resp.Data.(map[string]interface{})["duplicate"])
+
+	w = performRequest(router, "GET", settingsPath, nil)
+	resp = parseResponse(t, w)
+	require.Equal(t, response.CodeSuccess, resp.Code)
+	assert.Len(t, resp.Data.(map[string]interface{})["deliveries"], 1)
+}
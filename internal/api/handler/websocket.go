@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"github.com/qs3c/anal_go_server/internal/api/middleware"
 	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
 	"github.com/qs3c/anal_go_server/internal/pkg/ws"
 )
@@ -29,14 +30,16 @@ var upgrader = websocket.Upgrader{
 }
 
 type WebSocketHandler struct {
-	hub       *ws.Hub
-	jwtSecret string
+	hub          *ws.Hub
+	jwtKeys      *jwt.KeySet
+	tokenChecker middleware.TokenChecker
 }
 
-func NewWebSocketHandler(hub *ws.Hub, jwtSecret string) *WebSocketHandler {
+func NewWebSocketHandler(hub *ws.Hub, jwtKeys *jwt.KeySet, tokenChecker middleware.TokenChecker) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:       hub,
-		jwtSecret: jwtSecret,
+		hub:          hub,
+		jwtKeys:      jwtKeys,
+		tokenChecker: tokenChecker,
 	}
 }
 
@@ -50,7 +53,10 @@ func (h *WebSocketHandler) Handle(c *gin.Context) {
 		return
 	}
 
-	claims, err := jwt.ParseToken(token, h.jwtSecret)
+	claims, err := h.jwtKeys.Parse(token)
+	if err == nil && h.tokenChecker != nil {
+		err = h.tokenChecker.CheckToken(c.Request.Context(), claims)
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
@@ -1,20 +1,45 @@
 package middleware
 
 import (
+	"context"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
 	"github.com/qs3c/anal_go_server/internal/pkg/response"
 )
 
 const (
 	UserIDKey = "userID"
+	// ScopesKey 通过个人访问令牌认证时的权限范围，JWT 认证时不设置
+	ScopesKey = "tokenScopes"
 )
 
-// Auth JWT 认证中间件
-func Auth(jwtSecret string) gin.HandlerFunc {
+// TokenChecker 在签名校验通过后检查 Token 是否仍然有效，如修改密码后旧 Token 失效
+type TokenChecker interface {
+	CheckToken(ctx context.Context, claims *jwt.Claims) error
+}
+
+// AccessTokenVerifier 校验个人访问令牌，返回所属用户和权限范围
+type AccessTokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (int64, []string, error)
+}
+
+// Auth JWT 认证中间件，使用 HS256 密钥校验，checkers 依次检查 Token 是否已失效
+func Auth(jwtSecret string, checkers ...TokenChecker) gin.HandlerFunc {
+	return AuthWithKeys(jwt.NewSecretKeySet(jwtSecret), checkers...)
+}
+
+// AuthWithKeys 使用密钥集校验的 JWT 认证中间件，按 Token 的 kid 选择验证密钥
+func AuthWithKeys(keys *jwt.KeySet, checkers ...TokenChecker) gin.HandlerFunc {
+	return AuthWithAccessTokens(keys, nil, checkers...)
+}
+
+// AuthWithAccessTokens 同时接受 JWT 和个人访问令牌的认证中间件
+// 使用此中间件的接口都应通过 RequireScope 声明所需的权限范围
+func AuthWithAccessTokens(keys *jwt.KeySet, verifier AccessTokenVerifier, checkers ...TokenChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -30,7 +55,23 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := jwt.ParseToken(tokenString, jwtSecret)
+		if verifier != nil && strings.HasPrefix(tokenString, model.AccessTokenPrefix) {
+			userID, scopes, err := verifier.VerifyAccessToken(c.Request.Context(), tokenString)
+			if err != nil {
+				response.AuthError(c, "访问令牌无效或已过期")
+				c.Abort()
+				return
+			}
+			c.Set(UserIDKey, userID)
+			c.Set(ScopesKey, scopes)
+			c.Next()
+			return
+		}
+
+		claims, err := keys.Parse(tokenString)
+		if err == nil {
+			err = checkToken(c, claims, checkers)
+		}
 		if err != nil {
 			response.AuthError(c, "认证失败或已过期")
 			c.Abort()
@@ -42,8 +83,13 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 	}
 }
 
-// OptionalAuth 可选认证中间件（不强制要求登录）
-func OptionalAuth(jwtSecret string) gin.HandlerFunc {
+// OptionalAuth 可选认证中间件（不强制要求登录），已失效的 Token 按未登录处理
+func OptionalAuth(jwtSecret string, checkers ...TokenChecker) gin.HandlerFunc {
+	return OptionalAuthWithKeys(jwt.NewSecretKeySet(jwtSecret), checkers...)
+}
+
+// OptionalAuthWithKeys 使用密钥集校验的可选认证中间件
+func OptionalAuthWithKeys(keys *jwt.KeySet, checkers ...TokenChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -57,8 +103,8 @@ func OptionalAuth(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := jwt.ParseToken(tokenString, jwtSecret)
-		if err == nil {
+		claims, err := keys.Parse(tokenString)
+		if err == nil && checkToken(c, claims, checkers) == nil {
 			c.Set(UserIDKey, claims.UserID)
 		}
 
@@ -66,6 +112,42 @@ func OptionalAuth(jwtSecret string) gin.HandlerFunc {
 	}
 }
 
+func checkToken(c *gin.Context, claims *jwt.Claims, checkers []TokenChecker) error {
+	for _, checker := range checkers {
+		if checker == nil {
+			continue
+		}
+		if err := checker.CheckToken(c.Request.Context(), claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequireScope 通过个人访问令牌认证时要求令牌具有 scope，JWT 认证不受限制
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, exists := c.Get(ScopesKey); exists {
+			scopes, _ := value.([]string)
+			if !hasScope(scopes, scope) {
+				response.PermissionError(c, "访问令牌没有 "+scope+" 权限")
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // GetUserID 从上下文获取用户 ID
 func GetUserID(c *gin.Context) (int64, bool) {
 	userID, exists := c.Get(UserIDKey)
@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -140,6 +143,59 @@ func TestAuth_ExpiredToken(t *testing.T) {
 	assert.Equal(t, response.CodeAuthFailed, resp.Code)
 }
 
+// versionChecker 只接受指定版本的 Token
+type versionChecker int
+
+func (v versionChecker) CheckToken(ctx context.Context, claims *jwt.Claims) error {
+	if claims.Version != int(v) {
+		return errors.New("token revoked")
+	}
+	return nil
+}
+
+func TestAuth_RevokedToken(t *testing.T) {
+	router := gin.New()
+	router.Use(Auth(testJWTSecret, versionChecker(2)))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	oldToken, err := jwt.GenerateTokenWithVersion(123, 1, testJWTSecret, 24)
+	require.NoError(t, err)
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+oldToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, response.CodeAuthFailed, parseResponse(t, w).Code)
+
+	newToken, err := jwt.GenerateTokenWithVersion(123, 2, testJWTSecret, 24)
+	require.NoError(t, err)
+	req = httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+newToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{}", w.Body.String())
+}
+
+func TestOptionalAuth_WithRevokedToken(t *testing.T) {
+	router := gin.New()
+	router.Use(OptionalAuth(testJWTSecret, versionChecker(2)))
+	router.GET("/test", func(c *gin.Context) {
+		_, ok := GetUserID(c)
+		assert.False(t, ok)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	token, err := jwt.GenerateTokenWithVersion(123, 1, testJWTSecret, 24)
+	require.NoError(t, err)
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 func TestOptionalAuth_WithValidToken(t *testing.T) {
 	router := gin.New()
 	router.Use(OptionalAuth(testJWTSecret))
@@ -294,3 +350,85 @@ func TestGetUserID_ValidInt64(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestAuthWithKeys(t *testing.T) {
+	key, _, err := jwt.GenerateKey("k1", jwt.AlgRS256)
+	require.NoError(t, err)
+	keys, err := jwt.NewKeySet("k1", key, jwt.NewSecretKey("", testJWTSecret))
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(AuthWithKeys(keys))
+	router.GET("/test", func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID})
+	})
+
+	// 新密钥签发的 Token 和 secret 签发的旧 Token 都可以通过
+	token, err := keys.Sign(&jwt.Claims{UserID: 5}, time.Minute)
+	require.NoError(t, err)
+	legacy, err := jwt.GenerateToken(6, testJWTSecret, 1)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		token string
+		body  string
+	}{{token, `{"user_id":5}`}, {legacy, `{"user_id":6}`}} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.body, w.Body.String())
+	}
+}
+
+// staticTokens 把固定的访问令牌映射到用户 1 和给定的权限范围
+type staticTokens map[string][]string
+
+func (s staticTokens) VerifyAccessToken(ctx context.Context, token string) (int64, []string, error) {
+	scopes, ok := s[token]
+	if !ok {
+		return 0, nil, errors.New("unknown token")
+	}
+	return 1, scopes, nil
+}
+
+func TestAuthWithAccessTokens_Scopes(t *testing.T) {
+	verifier := staticTokens{"agp_read": {"analyses:read"}}
+	router := gin.New()
+	router.Use(AuthWithAccessTokens(jwt.NewSecretKeySet(testJWTSecret), verifier))
+	router.GET("/analyses", RequireScope("analyses:read"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	router.POST("/analyses", RequireScope("analyses:write"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	request := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/analyses", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "{}", request("GET", "agp_read").Body.String())
+	assert.Equal(t, response.CodePermissionDenied, parseResponse(t, request("POST", "agp_read")).Code)
+	assert.Equal(t, response.CodeAuthFailed, parseResponse(t, request("GET", "agp_unknown")).Code)
+
+	// JWT 认证不受权限范围限制
+	token, err := jwt.GenerateToken(1, testJWTSecret, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", request("POST", token).Body.String())
+
+	// 不接受访问令牌的中间件按 JWT 校验
+	jwtOnly := gin.New()
+	jwtOnly.Use(Auth(testJWTSecret))
+	jwtOnly.GET("/analyses", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	req := httptest.NewRequest("GET", "/analyses", nil)
+	req.Header.Set("Authorization", "Bearer agp_read")
+	w := httptest.NewRecorder()
+	jwtOnly.ServeHTTP(w, req)
+	assert.Equal(t, response.CodeAuthFailed, parseResponse(t, w).Code)
+}
@@ -6,19 +6,30 @@ import (
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/api/handler"
 	"github.com/qs3c/anal_go_server/internal/api/middleware"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
 )
 
 type Router struct {
-	authHandler      *handler.AuthHandler
-	userHandler      *handler.UserHandler
-	analysisHandler  *handler.AnalysisHandler
-	modelsHandler    *handler.ModelsHandler
-	websocketHandler *handler.WebSocketHandler
-	communityHandler *handler.CommunityHandler
-	commentHandler   *handler.CommentHandler
-	quotaHandler     *handler.QuotaHandler
-	uploadHandler    *handler.UploadHandler
-	cfg              *config.Config
+	authHandler         *handler.AuthHandler
+	userHandler         *handler.UserHandler
+	analysisHandler     *handler.AnalysisHandler
+	modelsHandler       *handler.ModelsHandler
+	websocketHandler    *handler.WebSocketHandler
+	communityHandler    *handler.CommunityHandler
+	commentHandler      *handler.CommentHandler
+	quotaHandler        *handler.QuotaHandler
+	uploadHandler       *handler.UploadHandler
+	fileHandler         *handler.FileHandler
+	scheduleHandler     *handler.ScheduleHandler
+	webhookHandler      *handler.WebhookHandler
+	channelHandler      *handler.ChannelHandler
+	notificationHandler *handler.NotificationHandler
+	accessTokenHandler  *handler.AccessTokenHandler
+	jwtKeys             *jwt.KeySet
+	tokenChecker        middleware.TokenChecker
+	accessTokens        middleware.AccessTokenVerifier
+	cfg                 *config.Config
 }
 
 func NewRouter(
@@ -31,19 +42,37 @@ func NewRouter(
 	commentHandler *handler.CommentHandler,
 	quotaHandler *handler.QuotaHandler,
 	uploadHandler *handler.UploadHandler,
+	fileHandler *handler.FileHandler,
+	scheduleHandler *handler.ScheduleHandler,
+	webhookHandler *handler.WebhookHandler,
+	channelHandler *handler.ChannelHandler,
+	notificationHandler *handler.NotificationHandler,
+	accessTokenHandler *handler.AccessTokenHandler,
+	jwtKeys *jwt.KeySet,
+	tokenChecker middleware.TokenChecker,
+	accessTokens middleware.AccessTokenVerifier,
 	cfg *config.Config,
 ) *Router {
 	return &Router{
-		authHandler:      authHandler,
-		userHandler:      userHandler,
-		analysisHandler:  analysisHandler,
-		modelsHandler:    modelsHandler,
-		websocketHandler: websocketHandler,
-		communityHandler: communityHandler,
-		commentHandler:   commentHandler,
-		quotaHandler:     quotaHandler,
-		uploadHandler:    uploadHandler,
-		cfg:              cfg,
+		authHandler:         authHandler,
+		userHandler:         userHandler,
+		analysisHandler:     analysisHandler,
+		modelsHandler:       modelsHandler,
+		websocketHandler:    websocketHandler,
+		communityHandler:    communityHandler,
+		commentHandler:      commentHandler,
+		quotaHandler:        quotaHandler,
+		uploadHandler:       uploadHandler,
+		fileHandler:         fileHandler,
+		scheduleHandler:     scheduleHandler,
+		webhookHandler:      webhookHandler,
+		channelHandler:      channelHandler,
+		notificationHandler: notificationHandler,
+		accessTokenHandler:  accessTokenHandler,
+		jwtKeys:             jwtKeys,
+		tokenChecker:        tokenChecker,
+		accessTokens:        accessTokens,
+		cfg:                 cfg,
 	}
 }
 
@@ -56,6 +85,9 @@ func (r *Router) Setup() *gin.Engine {
 	engine.Use(gin.Recovery())
 	engine.Use(middleware.CORS(r.cfg.CORS))
 
+	// 公开接口 - 验证访问令牌的公钥
+	engine.GET("/.well-known/jwks.json", r.authHandler.JWKS)
+
 	api := engine.Group("/api/v1")
 	{
 		// WebSocket
@@ -67,48 +99,127 @@ func (r *Router) Setup() *gin.Engine {
 			auth.POST("/register", r.authHandler.Register)
 			auth.POST("/login", r.authHandler.Login)
 			auth.POST("/verify-email", r.authHandler.VerifyEmail)
+			auth.POST("/resend-verification", r.authHandler.ResendVerification)
+			auth.POST("/refresh", r.authHandler.Refresh)
+			auth.POST("/logout", r.authHandler.Logout)
+			auth.POST("/forgot-password", r.authHandler.ForgotPassword)
+			auth.POST("/reset-password", r.authHandler.ResetPassword)
+			auth.POST("/oauth/exchange", r.authHandler.ExchangeLoginCode)
 			auth.GET("/github", r.authHandler.GithubAuth)
 			auth.GET("/github/callback", r.authHandler.GithubCallback)
-			// TODO: WeChat OAuth
+			auth.GET("/wechat", r.authHandler.WechatAuth)
+			auth.GET("/wechat/callback", r.authHandler.WechatCallback)
+			auth.GET("/oidc", r.authHandler.OIDCAuth)
+			auth.GET("/oidc/callback", r.authHandler.OIDCCallback)
 		}
 
 		// 公开接口 - 模型
 		api.GET("/models", r.modelsHandler.List)
 
-		// 需要认证的接口
+		// 公开接口 - 框图数据格式
+		api.GET("/diagram-schema", r.analysisHandler.DiagramSchema)
+
+		// 公开接口 - 代码托管平台的 webhook，按签名校验
+		api.POST("/webhooks/github", r.webhookHandler.Github)
+
+		// 公开接口 - 本地存储的签名文件下载
+		api.GET("/files/*key", r.fileHandler.Get)
+
+		// 需要认证的接口，不接受个人访问令牌
 		authenticated := api.Group("")
-		authenticated.Use(middleware.Auth(r.cfg.JWT.Secret))
+		authenticated.Use(middleware.AuthWithKeys(r.jwtKeys, r.tokenChecker))
 		{
+			// 退出所有设备
+			authenticated.POST("/auth/logout-all", r.authHandler.LogoutAll)
+
 			// 用户
 			user := authenticated.Group("/user")
 			{
 				user.GET("/profile", r.userHandler.GetProfile)
 				user.PUT("/profile", r.userHandler.UpdateProfile)
 				user.POST("/avatar", r.userHandler.UploadAvatar)
+				user.PUT("/password", r.authHandler.ChangePassword)
+				user.GET("/identities", r.authHandler.Identities)
+				user.POST("/identities/:provider", r.authHandler.LinkIdentity)
+				user.DELETE("/identities/:provider", r.authHandler.UnlinkIdentity)
 				user.GET("/quota", r.quotaHandler.GetQuota)
+
+				// 个人访问令牌
+				user.GET("/tokens", r.accessTokenHandler.List)
+				user.POST("/tokens", r.accessTokenHandler.Create)
+				user.DELETE("/tokens/:id", r.accessTokenHandler.Delete)
 			}
 
-			// 分析
-			analyses := authenticated.Group("/analyses")
+			// 定时分析
+			schedules := authenticated.Group("/schedules")
 			{
-				analyses.POST("", r.analysisHandler.Create)
-				analyses.GET("", r.analysisHandler.List)
-				analyses.GET("/:id", r.analysisHandler.Get)
-				analyses.PUT("/:id", r.analysisHandler.Update)
-				analyses.DELETE("/:id", r.analysisHandler.Delete)
-				analyses.POST("/:id/share", r.analysisHandler.Share)
-				analyses.DELETE("/:id/share", r.analysisHandler.Unshare)
-				analyses.GET("/:id/job-status", r.analysisHandler.GetJobStatus)
-				analyses.GET("/:id/diagram", r.analysisHandler.GetDiagram)
+				schedules.GET("", r.scheduleHandler.List)
+				schedules.POST("", r.scheduleHandler.Create)
+				schedules.GET("/:id", r.scheduleHandler.Get)
+				schedules.PUT("/:id", r.scheduleHandler.Update)
+				schedules.DELETE("/:id", r.scheduleHandler.Delete)
+			}
+
+			// 通知渠道
+			channels := authenticated.Group("/notification-channels")
+			{
+				channels.GET("", r.channelHandler.List)
+				channels.POST("", r.channelHandler.Create)
+				channels.PUT("/:id", r.channelHandler.Update)
+				channels.DELETE("/:id", r.channelHandler.Delete)
+				channels.GET("/:id/deliveries", r.channelHandler.ListDeliveries)
+			}
+
+			// 站内通知
+			notifications := authenticated.Group("/notifications")
+			{
+				notifications.GET("", r.notificationHandler.List)
+				notifications.GET("/unread-count", r.notificationHandler.UnreadCount)
+				notifications.PUT("/read-all", r.notificationHandler.MarkAllRead)
+				notifications.PUT("/:id/read", r.notificationHandler.MarkRead)
 			}
 
 			// 上传相关
 			authenticated.POST("/upload/parse", r.uploadHandler.Parse)
+			authenticated.POST("/upload/module", r.uploadHandler.ImportModule)
+			authenticated.POST("/upload/chunks", r.uploadHandler.InitChunked)
+			authenticated.GET("/upload/chunks/:id", r.uploadHandler.GetChunked)
+			authenticated.PUT("/upload/chunks/:id/parts/:part", r.uploadHandler.UploadChunk)
+			authenticated.POST("/upload/chunks/:id/complete", r.uploadHandler.CompleteChunked)
+		}
+
+		// 分析（需要认证，可使用个人访问令牌）
+		read := middleware.RequireScope(model.ScopeAnalysesRead)
+		write := middleware.RequireScope(model.ScopeAnalysesWrite)
+		analyses := api.Group("/analyses")
+		analyses.Use(middleware.AuthWithAccessTokens(r.jwtKeys, r.accessTokens, r.tokenChecker))
+		{
+			analyses.POST("", write, r.analysisHandler.Create)
+			analyses.GET("", read, r.analysisHandler.List)
+			analyses.GET("/:id", read, r.analysisHandler.Get)
+			analyses.PUT("/:id", write, r.analysisHandler.Update)
+			analyses.POST("/:id/import", write, r.analysisHandler.Import)
+			analyses.DELETE("/:id", write, r.analysisHandler.Delete)
+			analyses.POST("/:id/share", write, r.analysisHandler.Share)
+			analyses.DELETE("/:id/share", write, r.analysisHandler.Unshare)
+			analyses.GET("/:id/job-status", read, r.analysisHandler.GetJobStatus)
+			analyses.GET("/:id/diagram", read, r.analysisHandler.GetDiagram)
+			analyses.GET("/:id/export", read, r.analysisHandler.Export)
+			analyses.GET("/:id/graph/neighbors", read, r.analysisHandler.Neighbors)
+			analyses.GET("/:id/graph/path", read, r.analysisHandler.Path)
+			analyses.GET("/:id/graph/subgraph", read, r.analysisHandler.Subgraph)
+			analyses.GET("/:id/graph/cycles", read, r.analysisHandler.Cycles)
+			analyses.GET("/:id/rules", read, r.analysisHandler.GetRules)
+			analyses.PUT("/:id/rules", write, r.analysisHandler.UpdateRules)
+			analyses.GET("/:id/check", read, r.analysisHandler.CheckRules)
+			// 设置中包含签名密钥，拿到密钥即可触发重新分析，只读令牌不能查看
+			analyses.GET("/:id/webhook", write, r.webhookHandler.GetSettings)
+			analyses.PUT("/:id/webhook", write, r.webhookHandler.UpdateSettings)
 		}
 
 		// 公开接口 - 社区（可选认证）
 		community := api.Group("/community")
-		community.Use(middleware.OptionalAuth(r.cfg.JWT.Secret))
+		community.Use(middleware.OptionalAuthWithKeys(r.jwtKeys, r.tokenChecker))
 		{
 			community.GET("/analyses", r.communityHandler.List)
 			community.GET("/analyses/:id", r.communityHandler.Get)
@@ -116,7 +227,8 @@ func (r *Router) Setup() *gin.Engine {
 
 		// 社区互动（需要认证）
 		communityAuth := api.Group("/community")
-		communityAuth.Use(middleware.Auth(r.cfg.JWT.Secret))
+		communityAuth.Use(middleware.AuthWithAccessTokens(r.jwtKeys, r.accessTokens, r.tokenChecker))
+		communityAuth.Use(middleware.RequireScope(model.ScopeCommunityWrite))
 		{
 			communityAuth.POST("/analyses/:id/like", r.communityHandler.Like)
 			communityAuth.DELETE("/analyses/:id/like", r.communityHandler.Unlike)
@@ -126,14 +238,15 @@ func (r *Router) Setup() *gin.Engine {
 
 		// 评论 - 公开读取（可选认证）
 		commentsPublic := api.Group("/analyses")
-		commentsPublic.Use(middleware.OptionalAuth(r.cfg.JWT.Secret))
+		commentsPublic.Use(middleware.OptionalAuthWithKeys(r.jwtKeys, r.tokenChecker))
 		{
 			commentsPublic.GET("/:id/comments", r.commentHandler.List)
 		}
 
 		// 评论 - 需要认证
 		commentsAuth := api.Group("")
-		commentsAuth.Use(middleware.Auth(r.cfg.JWT.Secret))
+		commentsAuth.Use(middleware.AuthWithAccessTokens(r.jwtKeys, r.accessTokens, r.tokenChecker))
+		commentsAuth.Use(middleware.RequireScope(model.ScopeCommunityWrite))
 		{
 			commentsAuth.POST("/analyses/:id/comments", r.commentHandler.Create)
 			commentsAuth.DELETE("/comments/:id", r.commentHandler.Delete)
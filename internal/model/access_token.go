@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+// AccessTokenPrefix 个人访问令牌的前缀，用于和 JWT 区分
+const AccessTokenPrefix = "agp_"
+
+// 个人访问令牌的权限范围
+const (
+	ScopeAnalysesRead   = "analyses:read"
+	ScopeAnalysesWrite  = "analyses:write"
+	ScopeCommunityWrite = "community:write"
+)
+
+// AccessTokenScopes 所有可授予的权限范围
+var AccessTokenScopes = []string{ScopeAnalysesRead, ScopeAnalysesWrite, ScopeCommunityWrite}
+
+// AccessToken 个人访问令牌，供 CI 等无法走浏览器登录的场景使用
+// 只保存令牌的 SHA-256，Prefix 为令牌开头几位，便于用户辨认
+// 修改密码、重置密码或退出所有设备时用户的全部令牌被删除，需要重新创建
+type AccessToken struct {
+	ID         int64       `gorm:"primaryKey" json:"id"`
+	UserID     int64       `gorm:"not null;index" json:"user_id"`
+	Name       string      `gorm:"size:100;not null" json:"name"`
+	Prefix     string      `gorm:"size:16;not null" json:"prefix"`
+	TokenHash  string      `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     StringArray `gorm:"type:json" json:"scopes"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"` // 为空表示永不过期
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func (AccessToken) TableName() string {
+	return "access_tokens"
+}
+
+// HasScope 是否授予了 scope
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -29,35 +29,43 @@ func (s *StringArray) Scan(value interface{}) error {
 }
 
 type Analysis struct {
-	ID               int64       `gorm:"primaryKey" json:"id"`
-	UserID           int64       `gorm:"not null;index" json:"user_id"`
-	Title            string      `gorm:"size:200;not null" json:"title"`
-	Description      string      `gorm:"type:text" json:"description"`
-	CreationType     string      `gorm:"size:20;not null" json:"creation_type"` // ai, manual
-	RepoURL          string      `gorm:"size:500" json:"repo_url,omitempty"`
-	StartStruct      string      `gorm:"size:100" json:"start_struct,omitempty"`
-	AnalysisDepth    int         `json:"analysis_depth,omitempty"`
-	ModelName        string      `gorm:"size:50" json:"model_name,omitempty"`
-	SourceType       string      `gorm:"size:20;default:github"` // github 或 upload
-	UploadID         string      `gorm:"size:64"`
-	StartFile        string      `gorm:"size:500"`
-	DiagramOSSURL    string      `gorm:"size:500" json:"diagram_oss_url,omitempty"`
-	DiagramSize      int         `json:"diagram_size,omitempty"`
-	Status           string      `gorm:"size:20;default:draft;index" json:"status"` // draft, pending, analyzing, completed, failed
-	ErrorMessage     string      `gorm:"type:text" json:"error_message,omitempty"`
-	StartedAt        *time.Time  `json:"started_at,omitempty"`
-	CompletedAt      *time.Time  `json:"completed_at,omitempty"`
-	IsPublic         bool        `gorm:"default:false;index" json:"is_public"`
-	SharedAt         *time.Time  `gorm:"index" json:"shared_at,omitempty"`
-	ShareTitle       string      `gorm:"size:200" json:"share_title,omitempty"`
-	ShareDescription string      `gorm:"type:text" json:"share_description,omitempty"`
-	Tags             StringArray `gorm:"type:json" json:"tags,omitempty"`
-	ViewCount        int         `gorm:"default:0" json:"view_count"`
-	LikeCount        int         `gorm:"default:0" json:"like_count"`
-	CommentCount     int         `gorm:"default:0" json:"comment_count"`
-	BookmarkCount    int         `gorm:"default:0" json:"bookmark_count"`
-	CreatedAt        time.Time   `gorm:"index" json:"created_at"`
-	UpdatedAt        time.Time   `json:"updated_at"`
+	ID                   int64       `gorm:"primaryKey" json:"id"`
+	UserID               int64       `gorm:"not null;index" json:"user_id"`
+	Title                string      `gorm:"size:200;not null" json:"title"`
+	Description          string      `gorm:"type:text" json:"description"`
+	CreationType         string      `gorm:"size:20;not null" json:"creation_type"` // ai, manual
+	RepoURL              string      `gorm:"size:500" json:"repo_url,omitempty"`    // module 来源时为 path@version
+	StartStruct          string      `gorm:"size:100" json:"start_struct,omitempty"`
+	AnalysisDepth        int         `json:"analysis_depth,omitempty"`
+	ModelName            string      `gorm:"size:50" json:"model_name,omitempty"`
+	SourceType           string      `gorm:"size:20;default:github"` // github、upload 或 module
+	UploadID             string      `gorm:"size:64"`
+	StartFile            string      `gorm:"size:500"`
+	WebhookEnabled       bool        `gorm:"default:false;index" json:"webhook_enabled"` // 推送到仓库时自动重新分析
+	WebhookBranch        string      `gorm:"size:200" json:"webhook_branch,omitempty"`   // 监听的分支，空表示默认分支
+	WebhookSecret        string      `gorm:"size:64" json:"-"`                           // 该分析的 GitHub webhook 签名密钥
+	DiagramKey           string      `gorm:"size:500" json:"-"`                          // 对象存储 key，访问地址按需签名生成
+	DiagramBackend       string      `gorm:"size:20" json:"-"`                           // key 所在的存储后端：oss、s3、local
+	DiagramSize          int         `json:"diagram_size,omitempty"`                     // 原始 JSON 大小（字节）
+	DiagramStoredSize    int         `json:"diagram_stored_size,omitempty"`              // 压缩后实际存储的大小（字节）
+	DiagramEncoding      string      `gorm:"size:20" json:"-"`                           // 存储编码：gzip，旧数据为空表示未压缩
+	DiagramETag          string      `gorm:"size:64" json:"-"`                           // 按原始内容计算的 ETag
+	DiagramSchemaVersion int         `json:"-"`                                          // 框图格式版本，低于当前版本的读取时升级，0 表示旧数据
+	Status               string      `gorm:"size:20;default:draft;index" json:"status"`  // draft, pending, analyzing, completed, failed
+	ErrorMessage         string      `gorm:"type:text" json:"error_message,omitempty"`
+	StartedAt            *time.Time  `json:"started_at,omitempty"`
+	CompletedAt          *time.Time  `json:"completed_at,omitempty"`
+	IsPublic             bool        `gorm:"default:false;index" json:"is_public"`
+	SharedAt             *time.Time  `gorm:"index" json:"shared_at,omitempty"`
+	ShareTitle           string      `gorm:"size:200" json:"share_title,omitempty"`
+	ShareDescription     string      `gorm:"type:text" json:"share_description,omitempty"`
+	Tags                 StringArray `gorm:"type:json" json:"tags,omitempty"`
+	ViewCount            int         `gorm:"default:0" json:"view_count"`
+	LikeCount            int         `gorm:"default:0" json:"like_count"`
+	CommentCount         int         `gorm:"default:0" json:"comment_count"`
+	BookmarkCount        int         `gorm:"default:0" json:"bookmark_count"`
+	CreatedAt            time.Time   `gorm:"index" json:"created_at"`
+	UpdatedAt            time.Time   `json:"updated_at"`
 
 	// 关联
 	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+)
+
+// NotificationChannel 用户配置的通知渠道，Events 为订阅的事件类型（见 notify 包）
+type NotificationChannel struct {
+	ID        int64       `gorm:"primaryKey" json:"id"`
+	UserID    int64       `gorm:"not null;index" json:"user_id"`
+	Type      string      `gorm:"size:20;not null" json:"type"` // webhook, email
+	Name      string      `gorm:"size:100" json:"name,omitempty"`
+	URL       string      `gorm:"size:500" json:"url,omitempty"` // webhook 地址
+	Secret    string      `gorm:"size:64" json:"-"`              // webhook 签名密钥
+	Events    StringArray `gorm:"type:json" json:"events"`
+	Enabled   bool        `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func (NotificationChannel) TableName() string {
+	return "notification_channels"
+}
+
+// NotificationDelivery 一次事件向一个渠道的投递，失败后按退避时间重试
+type NotificationDelivery struct {
+	ID             int64      `gorm:"primaryKey" json:"id"`
+	ChannelID      int64      `gorm:"not null;index" json:"channel_id"`
+	UserID         int64      `gorm:"not null;index" json:"user_id"`
+	Event          string     `gorm:"size:50;not null" json:"event"`
+	AnalysisID     int64      `json:"analysis_id,omitempty"`
+	JobID          int64      `json:"job_id,omitempty"`
+	Payload        JSONText   `gorm:"type:json" json:"payload,omitempty"`
+	Status         string     `gorm:"size:20;not null;index" json:"status"` // pending, succeeded, failed
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"` // 待重试时间，结束后为空
+	ResponseStatus int        `json:"response_status,omitempty"`              // webhook 最后一次的 HTTP 状态码
+	LastError      string     `gorm:"size:500" json:"last_error,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
@@ -0,0 +1,20 @@
+package dto
+
+// CreateAccessTokenRequest 创建个人访问令牌，expires_in_days 为 0 表示永不过期
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=365"`
+}
+
+// AccessTokenItem 个人访问令牌，token 只在创建时返回一次
+type AccessTokenItem struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Token      string   `json:"token,omitempty"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
@@ -7,7 +7,7 @@ type CreateAnalysisRequest struct {
 	Title         string          `json:"title" binding:"required,max=200"`
 	Description   string          `json:"description,omitempty" binding:"omitempty,max=2000"`
 	CreationType  string          `json:"creation_type" binding:"required,oneof=ai manual"`
-	SourceType    string          `json:"source_type,omitempty"`    // "github" 或 "upload"
+	SourceType    string          `json:"source_type,omitempty"` // "github"、"upload" 或 "module"
 	RepoURL       string          `json:"repo_url,omitempty"`
 	UploadID      string          `json:"upload_id,omitempty"`
 	StartFile     string          `json:"start_file,omitempty"`
@@ -30,6 +30,12 @@ type UpdateAnalysisRequest struct {
 	DiagramData json.RawMessage `json:"diagram_data,omitempty"`
 }
 
+// ImportDiagramRequest 导入框图请求，source 为 Mermaid classDiagram、PlantUML 类图或 Go 源码
+type ImportDiagramRequest struct {
+	Format string `json:"format" binding:"required,oneof=mermaid plantuml go"`
+	Source string `json:"source" binding:"required,max=1048576"`
+}
+
 // ShareAnalysisRequest 分享分析请求
 type ShareAnalysisRequest struct {
 	ShareTitle       string   `json:"share_title" binding:"required,max=200"`
@@ -55,31 +61,47 @@ type AnalysisListItem struct {
 
 // AnalysisDetail 分析详情
 type AnalysisDetail struct {
-	ID               int64    `json:"id"`
-	Title            string   `json:"title"`
-	Description      string   `json:"description"`
-	CreationType     string   `json:"creation_type"`
-	RepoURL          string   `json:"repo_url,omitempty"`
-	StartStruct      string   `json:"start_struct,omitempty"`
-	AnalysisDepth    int      `json:"analysis_depth,omitempty"`
-	ModelName        string   `json:"model_name,omitempty"`
-	DiagramOSSURL    string   `json:"diagram_oss_url,omitempty"`
-	DiagramSize      int      `json:"diagram_size,omitempty"`
-	Status           string   `json:"status"`
-	ErrorMessage     string   `json:"error_message,omitempty"`
-	IsPublic         bool     `json:"is_public"`
-	ShareTitle       string   `json:"share_title,omitempty"`
-	ShareDescription string   `json:"share_description,omitempty"`
-	Tags             []string `json:"tags,omitempty"`
-	ViewCount        int      `json:"view_count"`
-	LikeCount        int      `json:"like_count"`
-	CommentCount     int      `json:"comment_count"`
-	BookmarkCount    int      `json:"bookmark_count"`
-	StartedAt        string   `json:"started_at,omitempty"`
-	CompletedAt      string   `json:"completed_at,omitempty"`
-	CreatedAt        string   `json:"created_at"`
-	UpdatedAt        string   `json:"updated_at"`
-	Warnings         []string `json:"warnings,omitempty"`
+	ID                int64    `json:"id"`
+	Title             string   `json:"title"`
+	Description       string   `json:"description"`
+	CreationType      string   `json:"creation_type"`
+	RepoURL           string   `json:"repo_url,omitempty"`
+	StartStruct       string   `json:"start_struct,omitempty"`
+	AnalysisDepth     int      `json:"analysis_depth,omitempty"`
+	ModelName         string   `json:"model_name,omitempty"`
+	DiagramOSSURL     string   `json:"diagram_oss_url,omitempty"`
+	DiagramSize       int      `json:"diagram_size,omitempty"`
+	DiagramStoredSize int      `json:"diagram_stored_size,omitempty"`
+	Status            string   `json:"status"`
+	ErrorMessage      string   `json:"error_message,omitempty"`
+	IsPublic          bool     `json:"is_public"`
+	ShareTitle        string   `json:"share_title,omitempty"`
+	ShareDescription  string   `json:"share_description,omitempty"`
+	Tags              []string `json:"tags,omitempty"`
+	ViewCount         int      `json:"view_count"`
+	LikeCount         int      `json:"like_count"`
+	CommentCount      int      `json:"comment_count"`
+	BookmarkCount     int      `json:"bookmark_count"`
+	StartedAt         string   `json:"started_at,omitempty"`
+	CompletedAt       string   `json:"completed_at,omitempty"`
+	CreatedAt         string   `json:"created_at"`
+	UpdatedAt         string   `json:"updated_at"`
+	Warnings          []string `json:"warnings,omitempty"`
+	Metrics           *Metrics `json:"metrics,omitempty"` // 分析完成后计算的架构指标
+}
+
+// Metrics 架构指标，Details 为各结构体扇入扇出、中心度、循环依赖、包耦合和最大结构体的明细
+type Metrics struct {
+	StructCount     int             `json:"struct_count"`
+	ConnectionCount int             `json:"connection_count"`
+	PackageCount    int             `json:"package_count"`
+	CycleCount      int             `json:"cycle_count"`
+	MaxFanIn        int             `json:"max_fan_in"`
+	MaxFanOut       int             `json:"max_fan_out"`
+	AvgFanOut       float64         `json:"avg_fan_out"`
+	MaxInstability  float64         `json:"max_instability"`
+	Details         json.RawMessage `json:"details,omitempty"`
+	ComputedAt      string          `json:"computed_at"`
 }
 
 // CommunityAnalysisItem 社区分析列表项
@@ -132,9 +154,44 @@ type UserInteraction struct {
 type JobStatusResponse struct {
 	JobID          int64  `json:"job_id"`
 	AnalysisID     int64  `json:"analysis_id"`
+	Ref            string `json:"ref,omitempty"` // 定时任务或 webhook 触发时克隆的引用
 	Status         string `json:"status"`
 	CurrentStep    string `json:"current_step,omitempty"`
 	ElapsedSeconds int    `json:"elapsed_seconds,omitempty"`
 	ErrorMessage   string `json:"error_message,omitempty"`
 	StartedAt      string `json:"started_at,omitempty"`
+	// 本次运行的架构规则检查结果，没有规则时为空
+	RuleResult json.RawMessage `json:"rule_result,omitempty"`
+}
+
+// RuleItem 架构规则
+// no_dependency：from 包不能依赖 to 包，包名支持通配符；no_cycles：不能有循环依赖；
+// max_fan_out / max_fan_in：每个结构体的扇出 / 扇入不超过 max
+type RuleItem struct {
+	Type string `json:"type" binding:"required,oneof=no_dependency no_cycles max_fan_out max_fan_in"`
+	From string `json:"from,omitempty" binding:"max=200"`
+	To   string `json:"to,omitempty" binding:"max=200"`
+	Max  int    `json:"max,omitempty" binding:"min=0"`
+}
+
+// UpdateRulesRequest 替换分析的架构规则
+type UpdateRulesRequest struct {
+	Rules []RuleItem `json:"rules" binding:"max=100,dive"`
+}
+
+// RuleViolation 违反的规则
+type RuleViolation struct {
+	Rule    int      `json:"rule"` // 规则在列表中的下标
+	Type    string   `json:"type"`
+	Message string   `json:"message"`
+	Structs []string `json:"structs"`
+}
+
+// RuleCheckResponse 架构规则检查结果，CI 根据 passed 决定是否通过
+type RuleCheckResponse struct {
+	AnalysisID int64           `json:"analysis_id"`
+	Passed     bool            `json:"passed"`
+	Rules      int             `json:"rules"`
+	Violations []RuleViolation `json:"violations"`
+	CheckedAt  string          `json:"checked_at"`
 }
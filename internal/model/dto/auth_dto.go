@@ -18,10 +18,22 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse 登录响应
+// LoginResponse 登录响应，Token 为访问令牌，过期后用 RefreshToken 换取新的令牌
 type LoginResponse struct {
-	Token string    `json:"token"`
-	User  *UserInfo `json:"user"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"` // 访问令牌有效秒数
+	User         *UserInfo `json:"user"`
+}
+
+// OAuthExchangeRequest 用第三方登录回调中的一次性 login_code 换取令牌
+type OAuthExchangeRequest struct {
+	LoginCode string `json:"login_code" binding:"required"`
+}
+
+// RefreshTokenRequest 刷新令牌和退出登录请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // VerifyEmailRequest 邮箱验证请求
@@ -29,6 +41,48 @@ type VerifyEmailRequest struct {
 	Code string `json:"code" binding:"required"`
 }
 
+// ResendVerificationRequest 重新发送验证邮件请求
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPasswordRequest 忘记密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=32"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=32"`
+}
+
+// IdentityItem 一种登录方式的绑定状态
+type IdentityItem struct {
+	Provider  string `json:"provider"` // github, wechat, email
+	Linked    bool   `json:"linked"`
+	Detail    string `json:"detail,omitempty"` // email 为账号邮箱
+	Removable bool   `json:"removable"`        // 解绑后仍有其它登录方式
+}
+
+// LinkEmailRequest 为账号设置邮箱和密码登录，账号已有邮箱时必须使用该邮箱
+type LinkEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=32"`
+}
+
+// LinkIdentityResponse 绑定登录方式的结果，第三方账号需要跳转 AuthURL 授权后完成绑定
+type LinkIdentityResponse struct {
+	AuthURL              string `json:"auth_url,omitempty"`
+	VerificationRequired bool   `json:"verification_required,omitempty"` // 新邮箱需要验证后才能登录
+}
+
 // UserInfo 用户信息（返回给前端）
 type UserInfo struct {
 	ID                int64      `json:"id"`
@@ -0,0 +1,45 @@
+package dto
+
+// CreateChannelRequest 创建通知渠道，type 为 webhook 时 url 必填；email 渠道发送到账号绑定的邮箱
+type CreateChannelRequest struct {
+	Type    string   `json:"type" binding:"required,oneof=webhook email"`
+	Name    string   `json:"name" binding:"max=100"`
+	URL     string   `json:"url" binding:"max=500"`
+	Events  []string `json:"events" binding:"required,min=1"`
+	Enabled *bool    `json:"enabled"` // 默认启用
+}
+
+// UpdateChannelRequest 更新通知渠道，只修改传入的字段
+type UpdateChannelRequest struct {
+	Name         *string  `json:"name" binding:"omitempty,max=100"`
+	URL          *string  `json:"url" binding:"omitempty,max=500"`
+	Events       []string `json:"events"`
+	Enabled      *bool    `json:"enabled"`
+	RotateSecret bool     `json:"rotate_secret"` // 重新生成 webhook 签名密钥
+}
+
+// ChannelItem 通知渠道，secret 只在创建和重新生成时返回
+type ChannelItem struct {
+	ID        int64    `json:"id"`
+	Type      string   `json:"type"`
+	Name      string   `json:"name,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Secret    string   `json:"secret,omitempty"`
+	Events    []string `json:"events"`
+	Enabled   bool     `json:"enabled"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// ChannelDeliveryItem 通知投递记录
+type ChannelDeliveryItem struct {
+	ID             int64  `json:"id"`
+	Event          string `json:"event"`
+	AnalysisID     int64  `json:"analysis_id"`
+	JobID          int64  `json:"job_id,omitempty"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	ResponseStatus int    `json:"response_status,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	NextAttemptAt  string `json:"next_attempt_at,omitempty"`
+	CreatedAt      string `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+// NotificationItem 站内通知
+type NotificationItem struct {
+	ID         int64        `json:"id"`
+	Type       string       `json:"type"`
+	Actor      *CommentUser `json:"actor,omitempty"`
+	AnalysisID int64        `json:"analysis_id,omitempty"`
+	CommentID  int64        `json:"comment_id,omitempty"`
+	JobID      int64        `json:"job_id,omitempty"`
+	Title      string       `json:"title,omitempty"`
+	Content    string       `json:"content,omitempty"`
+	Read       bool         `json:"read"`
+	CreatedAt  string       `json:"created_at"`
+}
+
+// NotificationListResponse 通知列表，unread_count 为全部未读数，不受分页和过滤影响
+type NotificationListResponse struct {
+	Total       int64               `json:"total"`
+	Page        int                 `json:"page"`
+	PageSize    int                 `json:"page_size"`
+	UnreadCount int64               `json:"unread_count"`
+	Items       []*NotificationItem `json:"items"`
+}
+
+// UnreadCountResponse 未读通知数
+type UnreadCountResponse struct {
+	UnreadCount int64 `json:"unread_count"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+// CreateScheduleRequest 创建定时任务，cron 为 5 段表达式（分 时 日 月 周，按 UTC 计算）或 @daily 等宏
+type CreateScheduleRequest struct {
+	AnalysisID int64  `json:"analysis_id" binding:"required"`
+	Cron       string `json:"cron" binding:"required,max=100"`
+	Ref        string `json:"ref" binding:"max=200"`
+	Enabled    *bool  `json:"enabled"` // 默认启用
+}
+
+// UpdateScheduleRequest 更新定时任务，只修改传入的字段
+type UpdateScheduleRequest struct {
+	Cron    *string `json:"cron" binding:"omitempty,max=100"`
+	Ref     *string `json:"ref" binding:"omitempty,max=200"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// ScheduleItem 定时任务
+type ScheduleItem struct {
+	ID         int64  `json:"id"`
+	AnalysisID int64  `json:"analysis_id"`
+	Cron       string `json:"cron"`
+	Ref        string `json:"ref,omitempty"`
+	Enabled    bool   `json:"enabled"`
+	LastRunAt  string `json:"last_run_at,omitempty"`
+	NextRunAt  string `json:"next_run_at,omitempty"`
+	LastJobID  int64  `json:"last_job_id,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
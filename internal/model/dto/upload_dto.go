@@ -2,11 +2,21 @@ package dto
 
 // ParseUploadResponse 解析上传文件的响应
 type ParseUploadResponse struct {
-	UploadID     string       `json:"upload_id"`
-	ExpiresAt    string       `json:"expires_at"`
-	Files        []GoFileInfo `json:"files"`
-	TotalFiles   int          `json:"total_files"`
-	TotalStructs int          `json:"total_structs"`
+	UploadID     string        `json:"upload_id"`
+	Module       string        `json:"module,omitempty"`     // 从 GOPROXY 下载时为 path@version
+	ModuleSum    string        `json:"module_sum,omitempty"` // 模块 zip 的 go.sum 哈希
+	ExpiresAt    string        `json:"expires_at"`
+	Files        []GoFileInfo  `json:"files"`
+	TotalFiles   int           `json:"total_files"`
+	TotalStructs int           `json:"total_structs"`
+	Skipped      []SkippedFile `json:"skipped,omitempty"`       // 解压时跳过的条目（最多列出 100 条）
+	SkippedCount int           `json:"skipped_count,omitempty"` // 跳过的条目总数
+}
+
+// SkippedFile 解压时被跳过的文件或目录
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"` // vcs, vendor, binary
 }
 
 // GoFileInfo Go 文件信息
@@ -14,3 +24,27 @@ type GoFileInfo struct {
 	Path    string   `json:"path"`
 	Structs []string `json:"structs"`
 }
+
+// ImportModuleRequest 从 GOPROXY 下载模块的请求
+type ImportModuleRequest struct {
+	Module string `json:"module" binding:"required,max=300"`             // path@version，如 github.com/foo/bar@v1.2.3
+	Sum    string `json:"sum" binding:"required,startswith=h1:,max=100"` // go.sum 中的 h1: 哈希
+}
+
+// InitChunkedUploadRequest 创建分片上传会话的请求
+type InitChunkedUploadRequest struct {
+	Filename  string `json:"filename" binding:"required,max=255"`
+	TotalSize int64  `json:"total_size" binding:"required,min=1"`
+	SHA256    string `json:"sha256" binding:"omitempty,len=64,hexadecimal"` // 整个文件的 SHA-256，可选，合并时校验
+}
+
+// ChunkedUploadStatus 分片上传会话状态
+type ChunkedUploadStatus struct {
+	SessionID     string `json:"session_id"`
+	Filename      string `json:"filename"`
+	TotalSize     int64  `json:"total_size"`
+	ChunkSize     int64  `json:"chunk_size"`
+	TotalParts    int    `json:"total_parts"`
+	ReceivedParts []int  `json:"received_parts"` // 已接收的分片编号，从 1 开始
+	ExpiresAt     string `json:"expires_at"`
+}
@@ -0,0 +1,40 @@
+package dto
+
+// UpdateWebhookRequest 开启或关闭分析的推送触发
+type UpdateWebhookRequest struct {
+	Enabled      bool   `json:"enabled"`
+	Branch       string `json:"branch" binding:"max=200"` // 监听的分支，空表示仓库默认分支
+	RotateSecret bool   `json:"rotate_secret"`            // 重新生成签名密钥，旧密钥立即失效
+}
+
+// WebhookSettings 分析的推送触发设置和最近的投递记录
+type WebhookSettings struct {
+	AnalysisID int64                  `json:"analysis_id"`
+	Enabled    bool                   `json:"enabled"`
+	Branch     string                 `json:"branch,omitempty"`
+	URL        string                 `json:"url"`              // 在 GitHub 仓库设置中填写的 Payload URL 路径
+	Secret     string                 `json:"secret,omitempty"` // 在 GitHub 仓库设置中填写的 Secret，开启后生成
+	Deliveries []*WebhookDeliveryItem `json:"deliveries"`
+}
+
+// WebhookTriggerResult 一次投递对单个分析的触发结果
+type WebhookTriggerResult struct {
+	AnalysisID int64  `json:"analysis_id"`
+	JobID      int64  `json:"job_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WebhookDeliveryItem webhook 投递记录
+type WebhookDeliveryItem struct {
+	ID         int64                  `json:"id"`
+	DeliveryID string                 `json:"delivery_id"`
+	Event      string                 `json:"event"`
+	Repository string                 `json:"repository,omitempty"`
+	Ref        string                 `json:"ref,omitempty"`
+	Commit     string                 `json:"commit,omitempty"`
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message,omitempty"`
+	Results    []WebhookTriggerResult `json:"results"`
+	Duplicate  bool                   `json:"duplicate,omitempty"` // 重复投递，未再次处理
+	CreatedAt  string                 `json:"created_at"`
+}
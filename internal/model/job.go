@@ -9,6 +9,7 @@ type AnalysisJob struct {
 	AnalysisID     int64      `gorm:"not null;index" json:"analysis_id"`
 	UserID         int64      `gorm:"not null;index" json:"user_id"`
 	RepoURL        string     `gorm:"size:500;not null" json:"repo_url"`
+	Ref            string     `gorm:"size:200" json:"ref,omitempty"` // 克隆的分支、标签或提交，空表示默认分支
 	StartStruct    string     `gorm:"size:100;not null" json:"start_struct"`
 	Depth          int        `gorm:"not null" json:"depth"`
 	ModelName      string     `gorm:"size:50;not null" json:"model_name"`
@@ -19,6 +20,7 @@ type AnalysisJob struct {
 	StartedAt      *time.Time `json:"started_at,omitempty"`
 	CompletedAt    *time.Time `json:"completed_at,omitempty"`
 	ElapsedSeconds int        `json:"elapsed_seconds,omitempty"`
+	RuleResult     JSONText   `gorm:"type:json" json:"rule_result,omitempty"` // 本次运行的架构规则检查结果（diagram.CheckResult）
 }
 
 func (AnalysisJob) TableName() string {
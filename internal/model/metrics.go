@@ -0,0 +1,63 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// JSONText 原样存取的 JSON 字段
+type JSONText json.RawMessage
+
+func (j JSONText) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j *JSONText) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSONText(v)
+	default:
+		*j = nil
+	}
+	return nil
+}
+
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSONText) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// AnalysisMetrics 分析完成后计算的架构指标，每个分析一行
+// 汇总值单独成列便于查询和统计趋势，各结构体、包的明细存放在 Details 中
+type AnalysisMetrics struct {
+	ID              int64     `gorm:"primaryKey" json:"id"`
+	AnalysisID      int64     `gorm:"not null;uniqueIndex" json:"analysis_id"`
+	StructCount     int       `json:"struct_count"`
+	ConnectionCount int       `json:"connection_count"`
+	PackageCount    int       `json:"package_count"`
+	CycleCount      int       `json:"cycle_count"`
+	MaxFanIn        int       `json:"max_fan_in"`
+	MaxFanOut       int       `json:"max_fan_out"`
+	AvgFanOut       float64   `json:"avg_fan_out"`
+	MaxInstability  float64   `json:"max_instability"`          // 各包 Ce/(Ca+Ce) 的最大值
+	Details         JSONText  `gorm:"type:json" json:"details"` // diagram.Metrics 的完整 JSON
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (AnalysisMetrics) TableName() string {
+	return "analysis_metrics"
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+)
+
+// 站内通知类型
+const (
+	NotificationComment           = "comment"            // 有人评论了我的分析
+	NotificationReply             = "reply"              // 有人回复了我的评论
+	NotificationLike              = "like"               // 有人点赞了我的分析
+	NotificationAnalysisCompleted = "analysis_completed" // 分析任务完成
+	NotificationAnalysisFailed    = "analysis_failed"    // 分析任务失败
+)
+
+// Notification 站内通知，ActorID 为触发通知的用户，任务事件为 0
+type Notification struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	UserID     int64      `gorm:"not null;index:idx_user_read" json:"user_id"`
+	ActorID    int64      `json:"actor_id,omitempty"`
+	Type       string     `gorm:"size:30;not null" json:"type"`
+	AnalysisID int64      `json:"analysis_id,omitempty"`
+	CommentID  int64      `json:"comment_id,omitempty"`
+	JobID      int64      `json:"job_id,omitempty"`
+	Title      string     `gorm:"size:200" json:"title,omitempty"`   // 分析标题快照
+	Content    string     `gorm:"size:500" json:"content,omitempty"` // 评论内容摘要或失败原因
+	ReadAt     *time.Time `gorm:"index:idx_user_read" json:"read_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+
+	// 关联
+	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
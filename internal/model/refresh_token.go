@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken 刷新令牌，只保存令牌的 SHA-256
+// 每次刷新都签发新令牌并撤销旧令牌，同一次登录产生的令牌共享 SessionID
+type RefreshToken struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	UserID    int64      `gorm:"not null;index" json:"user_id"`
+	SessionID string     `gorm:"size:32;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
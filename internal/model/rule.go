@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// AnalysisRule 挂在分析上的架构规则，每次运行结束时检查，类型和参数含义见 diagram.Rule
+type AnalysisRule struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	AnalysisID int64     `gorm:"not null;index" json:"analysis_id"`
+	Type       string    `gorm:"size:30;not null" json:"type"` // no_dependency, no_cycles, max_fan_out, max_fan_in
+	FromPkg    string    `gorm:"size:200" json:"from,omitempty"`
+	ToPkg      string    `gorm:"size:200" json:"to,omitempty"`
+	Max        int       `json:"max,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (AnalysisRule) TableName() string {
+	return "analysis_rules"
+}
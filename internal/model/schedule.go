@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// AnalysisSchedule 定时重新运行 AI 分析，CronExpr 为 5 段 cron 表达式，按 UTC 计算
+type AnalysisSchedule struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	AnalysisID int64      `gorm:"not null;index" json:"analysis_id"`
+	UserID     int64      `gorm:"not null;index" json:"user_id"`
+	CronExpr   string     `gorm:"size:100;not null" json:"cron"`
+	Ref        string     `gorm:"size:200" json:"ref,omitempty"` // 分支、标签或提交，空表示默认分支
+	Enabled    bool       `gorm:"default:true" json:"enabled"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt  *time.Time `gorm:"index" json:"next_run_at,omitempty"` // 停用时为空
+	LastJobID  int64      `json:"last_job_id,omitempty"`
+	LastError  string     `gorm:"size:500" json:"last_error,omitempty"` // 上次触发失败的原因，如配额不足
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (AnalysisSchedule) TableName() string {
+	return "analysis_schedules"
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// Upload 用户上传的源码压缩包，解压目录为 Upload.TempDir/<id>
+type Upload struct {
+	ID            string    `gorm:"primaryKey;size:64" json:"id"`
+	UserID        int64     `gorm:"not null;index" json:"user_id"`
+	Filename      string    `gorm:"size:255" json:"filename"`
+	Size          int64     `gorm:"not null" json:"size"`                       // 压缩包大小（字节）
+	ExtractedSize int64     `json:"extracted_size"`                             // 解压后大小（字节）
+	SHA256        string    `gorm:"column:sha256;size:64" json:"sha256"`        // 压缩包的 SHA-256
+	Module        string    `gorm:"size:300" json:"module,omitempty"`           // 从 GOPROXY 下载时为 path@version
+	Status        string    `gorm:"size:20;default:active;index" json:"status"` // active, expired
+	ExpiresAt     time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (Upload) TableName() string {
+	return "uploads"
+}
@@ -13,6 +13,7 @@ type User struct {
 	Bio                   string     `gorm:"type:text" json:"bio"`
 	GithubID              *string    `gorm:"column:github_id;size:50;uniqueIndex" json:"-"`
 	WechatOpenID          *string    `gorm:"column:wechat_openid;size:100;uniqueIndex" json:"-"`
+	OIDCSubject           *string    `gorm:"column:oidc_subject;size:255;uniqueIndex" json:"-"`
 	SubscriptionLevel     string     `gorm:"size:20;default:free" json:"subscription_level"`
 	DailyQuota            int        `gorm:"default:5" json:"daily_quota"`
 	QuotaUsedToday        int        `gorm:"default:0" json:"quota_used_today"`
@@ -21,6 +22,7 @@ type User struct {
 	EmailVerified         bool       `gorm:"default:false" json:"email_verified"`
 	VerificationCode      *string    `gorm:"size:100" json:"-"`
 	VerificationExpiresAt *time.Time `json:"-"`
+	TokenVersion          int        `gorm:"default:0" json:"-"` // 修改密码等操作时加一，使已签发的 Token 失效
 	CreatedAt             time.Time  `json:"created_at"`
 	UpdatedAt             time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// WebhookDelivery 收到的 webhook 投递记录，DeliveryID 唯一用于去重
+type WebhookDelivery struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	DeliveryID string    `gorm:"size:100;not null;uniqueIndex" json:"delivery_id"` // X-GitHub-Delivery
+	AnalysisID int64     `gorm:"index" json:"analysis_id"`                         // 签名匹配的分析
+	Event      string    `gorm:"size:50;not null" json:"event"`
+	Repository string    `gorm:"size:300;index" json:"repository,omitempty"` // 规范化后的 host/owner/repo
+	Ref        string    `gorm:"size:200" json:"ref,omitempty"`
+	Commit     string    `gorm:"size:64" json:"commit,omitempty"`
+	Status     string    `gorm:"size:20;not null" json:"status"` // processing, processed, ignored, failed
+	Message    string    `gorm:"size:500" json:"message,omitempty"`
+	Results    JSONText  `gorm:"type:json" json:"results,omitempty"` // 每个匹配分析的触发结果
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
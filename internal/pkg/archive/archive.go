@@ -0,0 +1,458 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Format 压缩包格式
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatZip     Format = "zip"
+	FormatTar     Format = "tar"
+	FormatTarGz   Format = "tar.gz"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported archive format")
+	ErrUnsafePath        = errors.New("archive entry escapes destination directory")
+	ErrSymlink           = errors.New("archive contains symbolic or hard link")
+	ErrSpecialFile       = errors.New("archive contains device or special file")
+	ErrEntryTooLarge     = errors.New("archive entry exceeds size limit")
+	ErrTooLarge          = errors.New("archive exceeds total size limit")
+	ErrTooManyEntries    = errors.New("archive contains too many entries")
+	ErrTooDeep           = errors.New("archive directory nesting too deep")
+	ErrCompressionRatio  = errors.New("archive compression ratio too high")
+)
+
+// 跳过原因
+const (
+	SkipVCS    = "vcs"    // 版本控制目录，如 .git/
+	SkipVendor = "vendor" // 第三方依赖目录，如 vendor/
+	SkipBinary = "binary" // 二进制文件
+)
+
+const (
+	// sniffLen 识别格式和二进制文件所需读取的字节数（tar 头部为 512 字节）
+	sniffLen = 512
+	// ratioCheckMin 解压量超过该值后才校验压缩比，避免小文件误判
+	ratioCheckMin = 1 << 20
+
+	filePerm = 0644
+	dirPerm  = 0755
+)
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	zipEmptyMagic = []byte("PK\x05\x06")
+	gzipMagic     = []byte{0x1f, 0x8b}
+	tarMagic      = []byte("ustar")
+	tarMagicPos   = 257
+)
+
+// skipDirs 与源码分析无关、直接跳过的目录
+var skipDirs = map[string]string{
+	".git":         SkipVCS,
+	".hg":          SkipVCS,
+	".svn":         SkipVCS,
+	"vendor":       SkipVendor,
+	"node_modules": SkipVendor,
+}
+
+// binaryExts 常见二进制文件扩展名，无需读取内容即可跳过
+var binaryExts = map[string]bool{
+	".exe": true, ".dll": true, ".so": true, ".dylib": true,
+	".a": true, ".o": true, ".lib": true, ".obj": true,
+	".bin": true, ".class": true, ".jar": true, ".pyc": true,
+	".wasm": true, ".test": true,
+}
+
+// Limits 解压限制，零值表示不限制
+type Limits struct {
+	MaxEntrySize        int64 // 单个文件解压后最大字节数
+	MaxTotalSize        int64 // 所有文件解压后总字节数
+	MaxEntries          int   // 条目数量上限（包括目录和被跳过的条目）
+	MaxDepth            int   // 目录层级上限
+	MaxCompressionRatio int64 // 解压后大小与压缩大小之比的上限
+}
+
+// Skipped 被跳过的条目
+type Skipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Result 解压结果
+type Result struct {
+	Files   int       // 实际写入的文件数
+	Bytes   int64     // 实际写入的字节数
+	Skipped []Skipped // 被跳过的条目，整个目录被跳过时只记录目录本身
+}
+
+// Detect 根据文件内容识别压缩包格式，不依赖扩展名
+func Detect(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatUnknown, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic), bytes.HasPrefix(head, zipEmptyMagic):
+		return FormatZip, nil
+	case isTarHeader(head):
+		return FormatTar, nil
+	case bytes.HasPrefix(head, gzipMagic):
+		// gzip 只是压缩层，需要确认内部是 tar
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return FormatUnknown, err
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return FormatUnknown, ErrUnsupportedFormat
+		}
+		defer gz.Close()
+
+		inner := make([]byte, sniffLen)
+		n, _ := io.ReadFull(gz, inner)
+		if isTarHeader(inner[:n]) {
+			return FormatTarGz, nil
+		}
+	}
+
+	return FormatUnknown, ErrUnsupportedFormat
+}
+
+func isTarHeader(head []byte) bool {
+	if len(head) < tarMagicPos+len(tarMagic) {
+		return false
+	}
+	return bytes.Equal(head[tarMagicPos:tarMagicPos+len(tarMagic)], tarMagic)
+}
+
+// Extract 识别格式并解压到 destDir
+func Extract(path, destDir string, limits Limits) (*Result, error) {
+	format, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	x := newExtractor(destDir, limits)
+	switch format {
+	case FormatZip:
+		err = x.extractZip(path)
+	case FormatTar, FormatTarGz:
+		// tar.gz 无法得知单个条目的压缩大小，按整个压缩包计算压缩比
+		if format == FormatTarGz {
+			x.archiveSize = info.Size()
+		}
+		err = x.extractTar(path, format == FormatTarGz)
+	default:
+		err = ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &x.result, nil
+}
+
+// entry 统一描述 zip 和 tar 中的条目
+type entry struct {
+	name           string
+	mode           os.FileMode
+	hardLink       bool
+	size           int64 // 声明的解压后大小
+	compressedSize int64 // 压缩后大小，未知时为 0
+	open           func() (io.ReadCloser, error)
+}
+
+// extractor 负责把条目安全地写入目标目录，并统计解压量
+type extractor struct {
+	destDir     string
+	limits      Limits
+	archiveSize int64
+	entries     int
+	result      Result
+	skippedDirs map[string]bool
+}
+
+func newExtractor(destDir string, limits Limits) *extractor {
+	return &extractor{
+		destDir:     filepath.Clean(destDir),
+		limits:      limits,
+		skippedDirs: make(map[string]bool),
+	}
+}
+
+func (x *extractor) extractZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		f := f
+		e := entry{
+			name:           f.Name,
+			mode:           f.Mode(),
+			size:           int64(f.UncompressedSize64),
+			compressedSize: int64(f.CompressedSize64),
+			open:           f.Open,
+		}
+		if err := x.handle(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) extractTar(path string, gzipped bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = bufio.NewReader(file)
+	if gzipped {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		e := entry{
+			name:     hdr.Name,
+			mode:     hdr.FileInfo().Mode(),
+			hardLink: hdr.Typeflag == tar.TypeLink,
+			size:     hdr.Size,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(tr), nil
+			},
+		}
+		if err := x.handle(e); err != nil {
+			return err
+		}
+	}
+}
+
+// handle 校验单个条目并写入或跳过
+func (x *extractor) handle(e entry) error {
+	x.entries++
+	if x.limits.MaxEntries > 0 && x.entries > x.limits.MaxEntries {
+		return ErrTooManyEntries
+	}
+
+	name, err := x.cleanName(e.name)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+
+	if x.limits.MaxDepth > 0 && strings.Count(name, "/")+1 > x.limits.MaxDepth {
+		return fmt.Errorf("%w: %s", ErrTooDeep, e.name)
+	}
+
+	switch {
+	case e.hardLink, e.mode&os.ModeSymlink != 0:
+		return fmt.Errorf("%w: %s", ErrSymlink, e.name)
+	case e.mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket|os.ModeIrregular) != 0:
+		return fmt.Errorf("%w: %s", ErrSpecialFile, e.name)
+	}
+
+	if x.skipByPath(name, e.mode.IsDir()) {
+		return nil
+	}
+
+	if e.mode.IsDir() {
+		return os.MkdirAll(filepath.Join(x.destDir, filepath.FromSlash(name)), dirPerm)
+	}
+
+	if binaryExts[strings.ToLower(path.Ext(name))] {
+		x.skip(name, SkipBinary)
+		return nil
+	}
+
+	// 先用声明的大小快速拒绝，实际写入时仍按真实字节数计数
+	if x.limits.MaxEntrySize > 0 && e.size > x.limits.MaxEntrySize {
+		return fmt.Errorf("%w: %s", ErrEntryTooLarge, e.name)
+	}
+	if err := x.checkRatio(e.size, e.compressedSize, e.name); err != nil {
+		return err
+	}
+
+	src, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return x.writeFile(name, e, src)
+}
+
+// cleanName 规范化条目路径，防止 zip slip；返回空字符串表示目标目录本身
+func (x *extractor) cleanName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	cleaned := path.Clean(slashed)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	destPath := filepath.Join(x.destDir, filepath.FromSlash(cleaned))
+	if !strings.HasPrefix(destPath, x.destDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return cleaned, nil
+}
+
+// skipByPath 判断条目是否位于需要跳过的目录中
+func (x *extractor) skipByPath(name string, isDir bool) bool {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		if i == len(parts)-1 && !isDir {
+			break
+		}
+		reason, ok := skipDirs[part]
+		if !ok {
+			continue
+		}
+		dir := strings.Join(parts[:i+1], "/") + "/"
+		if !x.skippedDirs[dir] {
+			x.skippedDirs[dir] = true
+			x.skip(dir, reason)
+		}
+		return true
+	}
+	return false
+}
+
+func (x *extractor) skip(name, reason string) {
+	x.result.Skipped = append(x.result.Skipped, Skipped{Path: name, Reason: reason})
+}
+
+// checkRatio 校验压缩比；zip 按单个条目计算，tar.gz 按整个压缩包计算
+func (x *extractor) checkRatio(uncompressed, compressed int64, name string) error {
+	maxRatio := x.limits.MaxCompressionRatio
+	if maxRatio <= 0 {
+		return nil
+	}
+	if compressed <= 0 && x.archiveSize > 0 {
+		uncompressed = x.result.Bytes + uncompressed
+		compressed = x.archiveSize
+	}
+	if compressed <= 0 || uncompressed < ratioCheckMin {
+		return nil
+	}
+	if uncompressed/compressed > maxRatio {
+		return fmt.Errorf("%w: %s", ErrCompressionRatio, name)
+	}
+	return nil
+}
+
+func (x *extractor) writeFile(name string, e entry, src io.Reader) error {
+	// 读取开头一段判断是否为二进制文件（包含 NUL 字节）
+	br := bufio.NewReaderSize(src, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	if bytes.IndexByte(head, 0) >= 0 {
+		x.skip(name, SkipBinary)
+		return nil
+	}
+
+	destPath := filepath.Join(x.destDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(destPath), dirPerm); err != nil {
+		return err
+	}
+
+	// 不沿用压缩包内的权限位，统一使用 0644，避免写出可执行或全局可写的文件
+	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	// 多读 1 字节用于判断是否超出限制
+	limit := x.remaining()
+	n, err := io.Copy(destFile, io.LimitReader(br, limit+1))
+	if err != nil {
+		return err
+	}
+	x.result.Bytes += n
+	x.result.Files++
+
+	if x.limits.MaxEntrySize > 0 && n > x.limits.MaxEntrySize {
+		return fmt.Errorf("%w: %s", ErrEntryTooLarge, e.name)
+	}
+	if x.limits.MaxTotalSize > 0 && x.result.Bytes > x.limits.MaxTotalSize {
+		return ErrTooLarge
+	}
+	// 声明的大小可能是伪造的，按实际写入量再校验一次压缩比
+	if e.compressedSize > 0 {
+		return x.checkRatio(n, e.compressedSize, e.name)
+	}
+	return x.checkRatio(0, 0, e.name)
+}
+
+// remaining 返回当前条目最多还能写入的字节数
+func (x *extractor) remaining() int64 {
+	limit := int64(1<<63 - 2)
+	if x.limits.MaxEntrySize > 0 && x.limits.MaxEntrySize < limit {
+		limit = x.limits.MaxEntrySize
+	}
+	if x.limits.MaxTotalSize > 0 {
+		if left := x.limits.MaxTotalSize - x.result.Bytes; left < limit {
+			limit = left
+		}
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	return limit
+}
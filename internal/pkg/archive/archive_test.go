@@ -0,0 +1,328 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	Name     string
+	Body     string
+	Typeflag byte
+	Linkname string
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	for _, e := range entries {
+		typeflag := e.Typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{
+			Name:     e.Name,
+			Mode:     0644,
+			Size:     int64(len(e.Body)),
+			Typeflag: typeflag,
+			Linkname: e.Linkname,
+		}
+		if typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		require.NoError(t, w.WriteHeader(hdr))
+		if typeflag == tar.TypeReg {
+			_, err := w.Write([]byte(e.Body))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	tarData := buildTar(t, []tarEntry{{Name: "main.go", Body: "package main"}})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Format
+		wantErr error
+	}{
+		{"zip", buildZip(t, map[string]string{"main.go": "package main"}), FormatZip, nil},
+		{"tar", tarData, FormatTar, nil},
+		{"tar.gz", gzipBytes(t, tarData), FormatTarGz, nil},
+		{"plain gzip", gzipBytes(t, []byte("not a tarball")), FormatUnknown, ErrUnsupportedFormat},
+		{"text", []byte("some content"), FormatUnknown, ErrUnsupportedFormat},
+		{"empty", nil, FormatUnknown, ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 故意使用与内容不符的扩展名，确认识别只依赖内容
+			path := writeTestFile(t, "upload.bin", tt.data)
+			got, err := Detect(path)
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExtract_Tar(t *testing.T) {
+	data := buildTar(t, []tarEntry{
+		{Name: "project/", Typeflag: tar.TypeDir},
+		{Name: "project/go.mod", Body: "module example.com/project"},
+		{Name: "project/internal/model/user.go", Body: "package model"},
+	})
+
+	for name, payload := range map[string][]byte{"tar": data, "tgz": gzipBytes(t, data)} {
+		t.Run(name, func(t *testing.T) {
+			dest := t.TempDir()
+			_, err := Extract(writeTestFile(t, "upload", payload), dest, Limits{})
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(filepath.Join(dest, "project", "internal", "model", "user.go"))
+			require.NoError(t, err)
+			assert.Equal(t, "package model", string(content))
+		})
+	}
+}
+
+func TestExtract_Zip(t *testing.T) {
+	dest := t.TempDir()
+	path := writeTestFile(t, "upload", buildZip(t, map[string]string{
+		"main.go":       "package main",
+		"pkg/util/a.go": "package util",
+	}))
+
+	_, err := Extract(path, dest, Limits{})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dest, "main.go"))
+	assert.FileExists(t, filepath.Join(dest, "pkg", "util", "a.go"))
+}
+
+func TestExtract_RejectsPathTraversal(t *testing.T) {
+	cases := map[string][]byte{
+		"zip relative": buildZip(t, map[string]string{"../evil.go": "package evil"}),
+		"tar relative": buildTar(t, []tarEntry{{Name: "../../evil.go", Body: "package evil"}}),
+		"tar absolute": buildTar(t, []tarEntry{{Name: "/etc/evil.go", Body: "package evil"}}),
+	}
+
+	for name, payload := range cases {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			require.NoError(t, os.MkdirAll(dest, 0755))
+
+			_, err := Extract(writeTestFile(t, "upload", payload), dest, Limits{})
+			assert.ErrorIs(t, err, ErrUnsafePath)
+			assert.NoFileExists(t, filepath.Join(parent, "evil.go"))
+		})
+	}
+}
+
+func TestExtract_RejectsLinks(t *testing.T) {
+	cases := map[string][]tarEntry{
+		"symlink":  {{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		"hardlink": {{Name: "link", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+	}
+
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Extract(writeTestFile(t, "upload", buildTar(t, entries)), t.TempDir(), Limits{})
+			assert.ErrorIs(t, err, ErrSymlink)
+		})
+	}
+
+	t.Run("zip symlink", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := zip.NewWriter(buf)
+		hdr := &zip.FileHeader{Name: "link"}
+		hdr.SetMode(os.ModeSymlink | 0777)
+		f, err := w.CreateHeader(hdr)
+		require.NoError(t, err)
+		f.Write([]byte("/etc/passwd"))
+		require.NoError(t, w.Close())
+
+		_, err = Extract(writeTestFile(t, "upload", buf.Bytes()), t.TempDir(), Limits{})
+		assert.ErrorIs(t, err, ErrSymlink)
+	})
+}
+
+func TestExtract_SizeLimits(t *testing.T) {
+	big := strings.Repeat("a", 1024)
+
+	t.Run("entry too large", func(t *testing.T) {
+		path := writeTestFile(t, "upload", gzipBytes(t, buildTar(t, []tarEntry{{Name: "big.go", Body: big}})))
+		_, err := Extract(path, t.TempDir(), Limits{MaxEntrySize: 512})
+		assert.ErrorIs(t, err, ErrEntryTooLarge)
+	})
+
+	t.Run("total too large", func(t *testing.T) {
+		path := writeTestFile(t, "upload", buildZip(t, map[string]string{
+			"a.go": big,
+			"b.go": big,
+			"c.go": big,
+		}))
+		_, err := Extract(path, t.TempDir(), Limits{MaxEntrySize: 2048, MaxTotalSize: 2048})
+		assert.ErrorIs(t, err, ErrTooLarge)
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		path := writeTestFile(t, "upload", buildTar(t, []tarEntry{{Name: "ok.go", Body: big}}))
+		_, err := Extract(path, t.TempDir(), Limits{MaxEntrySize: 1024, MaxTotalSize: 1024})
+		assert.NoError(t, err)
+	})
+}
+
+func TestExtract_EntryCountAndDepth(t *testing.T) {
+	t.Run("too many entries", func(t *testing.T) {
+		files := map[string]string{}
+		for i := 0; i < 20; i++ {
+			files[filepath.Join("pkg", strings.Repeat("x", i+1)+".go")] = "package pkg"
+		}
+		path := writeTestFile(t, "upload", buildZip(t, files))
+		_, err := Extract(path, t.TempDir(), Limits{MaxEntries: 10})
+		assert.ErrorIs(t, err, ErrTooManyEntries)
+	})
+
+	t.Run("too deep", func(t *testing.T) {
+		deep := strings.Repeat("d/", 10) + "main.go"
+		path := writeTestFile(t, "upload", buildTar(t, []tarEntry{{Name: deep, Body: "package main"}}))
+		_, err := Extract(path, t.TempDir(), Limits{MaxDepth: 5})
+		assert.ErrorIs(t, err, ErrTooDeep)
+	})
+}
+
+func TestExtract_CompressionRatio(t *testing.T) {
+	// 2MB 的零字节压缩后只有几 KB，压缩比远超 100
+	bomb := strings.Repeat("\x00", 2<<20)
+	text := strings.Repeat("a", 2<<20)
+
+	t.Run("zip entry", func(t *testing.T) {
+		path := writeTestFile(t, "upload", buildZip(t, map[string]string{"bomb.go": text}))
+		_, err := Extract(path, t.TempDir(), Limits{MaxCompressionRatio: 100})
+		assert.ErrorIs(t, err, ErrCompressionRatio)
+	})
+
+	t.Run("tar.gz archive", func(t *testing.T) {
+		path := writeTestFile(t, "upload", gzipBytes(t, buildTar(t, []tarEntry{{Name: "bomb.go", Body: text}})))
+		_, err := Extract(path, t.TempDir(), Limits{MaxCompressionRatio: 100})
+		assert.ErrorIs(t, err, ErrCompressionRatio)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		path := writeTestFile(t, "upload", buildZip(t, map[string]string{"data.txt": text, "zero.dat": bomb}))
+		_, err := Extract(path, t.TempDir(), Limits{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestExtract_RejectsSpecialFiles(t *testing.T) {
+	cases := map[string]byte{
+		"char device":  tar.TypeChar,
+		"block device": tar.TypeBlock,
+		"fifo":         tar.TypeFifo,
+	}
+
+	for name, typeflag := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := writeTestFile(t, "upload", buildTar(t, []tarEntry{{Name: "dev", Typeflag: typeflag}}))
+			_, err := Extract(path, t.TempDir(), Limits{})
+			assert.ErrorIs(t, err, ErrSpecialFile)
+		})
+	}
+}
+
+func TestExtract_SkipsNoise(t *testing.T) {
+	dest := t.TempDir()
+	path := writeTestFile(t, "upload", buildTar(t, []tarEntry{
+		{Name: "project/main.go", Body: "package main"},
+		{Name: "project/.git/HEAD", Body: "ref: refs/heads/main"},
+		{Name: "project/.git/config", Body: "[core]"},
+		{Name: "project/vendor/github.com/foo/bar/bar.go", Body: "package bar"},
+		{Name: "project/bin/server.exe", Body: "MZ"},
+		{Name: "project/assets/blob", Body: "\x7fELF\x00\x00\x00"},
+	}))
+
+	result, err := Extract(path, dest, Limits{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Files)
+	assert.FileExists(t, filepath.Join(dest, "project", "main.go"))
+	assert.NoDirExists(t, filepath.Join(dest, "project", ".git"))
+	assert.NoDirExists(t, filepath.Join(dest, "project", "vendor"))
+	assert.NoFileExists(t, filepath.Join(dest, "project", "bin", "server.exe"))
+	assert.NoFileExists(t, filepath.Join(dest, "project", "assets", "blob"))
+
+	assert.ElementsMatch(t, []Skipped{
+		{Path: "project/.git/", Reason: SkipVCS},
+		{Path: "project/vendor/", Reason: SkipVendor},
+		{Path: "project/bin/server.exe", Reason: SkipBinary},
+		{Path: "project/assets/blob", Reason: SkipBinary},
+	}, result.Skipped)
+}
+
+func TestExtract_SanitizesPermissions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	body := "#!/bin/sh\necho hi\n"
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "run.sh", Mode: 04777, Size: int64(len(body))}))
+	w.Write([]byte(body))
+	require.NoError(t, w.Close())
+
+	dest := t.TempDir()
+	_, err := Extract(writeTestFile(t, "upload", buf.Bytes()), dest, Limits{})
+	require.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dest, "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm()&0644)
+	assert.Zero(t, info.Mode().Perm()&0111, "execute bits must not be preserved")
+	assert.Zero(t, info.Mode()&os.ModeSetuid)
+}
@@ -8,30 +8,46 @@ import (
 	"strings"
 	"time"
 
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/service"
 )
 
+// expiredUploadBatch 每批处理的过期上传记录数
+const expiredUploadBatch = 500
+
 type Service struct {
-	quotaService   *service.QuotaService
-	analysisRepo   *repository.AnalysisRepository
-	uploadTempDir  string
-	expireHours    int
-	stopChan       chan struct{}
+	quotaService    *service.QuotaService
+	analysisService *service.AnalysisService
+	analysisRepo    *repository.AnalysisRepository
+	scheduleRepo    *repository.ScheduleRepository
+	uploadRepo      *repository.UploadRepository
+	locker          *lock.Locker
+	uploadTempDir   string
+	expireHours     int
+	stopChan        chan struct{}
 }
 
 func NewService(
 	quotaService *service.QuotaService,
+	analysisService *service.AnalysisService,
 	analysisRepo *repository.AnalysisRepository,
+	scheduleRepo *repository.ScheduleRepository,
+	uploadRepo *repository.UploadRepository,
+	locker *lock.Locker,
 	uploadTempDir string,
 	expireHours int,
 ) *Service {
 	return &Service{
-		quotaService:  quotaService,
-		analysisRepo:  analysisRepo,
-		uploadTempDir: uploadTempDir,
-		expireHours:   expireHours,
-		stopChan:      make(chan struct{}),
+		quotaService:    quotaService,
+		analysisService: analysisService,
+		analysisRepo:    analysisRepo,
+		scheduleRepo:    scheduleRepo,
+		uploadRepo:      uploadRepo,
+		locker:          locker,
+		uploadTempDir:   uploadTempDir,
+		expireHours:     expireHours,
+		stopChan:        make(chan struct{}),
 	}
 }
 
@@ -39,7 +55,10 @@ func NewService(
 func (s *Service) Start() {
 	go s.runDailyQuotaReset()
 	go s.runCleanup()
-	log.Println("Cron service started (quota reset + temp cleanup)")
+	if s.analysisService != nil && s.scheduleRepo != nil {
+		go s.runSchedules()
+	}
+	log.Println("Cron service started (quota reset + temp cleanup + schedules)")
 }
 
 // Stop 停止定时任务
@@ -100,30 +119,116 @@ func (s *Service) cleanupAll() {
 	expireDuration := time.Duration(expireHours) * time.Hour
 
 	c1 := s.cleanupUploadDirs(expireDuration)
-	c2 := s.cleanupCloneDirs(expireDuration)
-	c3 := s.cleanupMigratedDiagrams()
+	c2 := s.cleanupChunkSessions(expireDuration)
+	c3 := s.cleanupCloneDirs(expireDuration)
+	c4 := s.cleanupMigratedDiagrams()
 
-	total := c1 + c2 + c3
+	total := c1 + c2 + c3 + c4
 	if total > 0 {
-		log.Printf("Cleanup summary: uploads=%d, clones=%d, diagrams=%d", c1, c2, c3)
+		log.Printf("Cleanup summary: uploads=%d, chunks=%d, clones=%d, diagrams=%d", c1, c2, c3, c4)
 	}
 }
 
 // cleanupUploadDirs 清理过期的用户上传临时目录（/tmp/uploads/<upload_id>/）
+// 以 uploads 表的 expires_at 为准；表中没有 active 记录的目录（如历史遗留）仍按修改时间清理
 func (s *Service) cleanupUploadDirs(expireDuration time.Duration) int {
 	if s.uploadTempDir == "" {
 		return 0
 	}
 
-	entries, err := os.ReadDir(s.uploadTempDir)
+	cleaned := s.cleanupExpiredUploads()
+
+	var active map[string]bool
+	if s.uploadRepo != nil {
+		entries, err := os.ReadDir(s.uploadTempDir)
+		if err != nil {
+			log.Printf("Cleanup uploads: failed to read dir %s: %v", s.uploadTempDir, err)
+			return cleaned
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		ids, err := s.uploadRepo.ListActiveIDs(names)
+		if err != nil {
+			log.Printf("Cleanup uploads: failed to query active uploads: %v", err)
+			return cleaned
+		}
+		active = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			active[id] = true
+		}
+	}
+
+	cleaned += removeExpiredDirs("uploads", s.uploadTempDir, expireDuration, func(name string) bool {
+		return name == "diagrams" || name == service.ChunkSessionDir || active[name]
+	})
+	return cleaned
+}
+
+// cleanupExpiredUploads 删除 uploads 表中已到期记录对应的目录，并标记为 expired
+func (s *Service) cleanupExpiredUploads() int {
+	if s.uploadRepo == nil {
+		return 0
+	}
+
+	cleaned := 0
+	for {
+		uploads, err := s.uploadRepo.ListExpired(time.Now(), expiredUploadBatch)
+		if err != nil {
+			log.Printf("Cleanup uploads: failed to query expired uploads: %v", err)
+			return cleaned
+		}
+
+		batchCleaned := 0
+		for _, upload := range uploads {
+			dirPath := filepath.Join(s.uploadTempDir, upload.ID)
+			if err := os.RemoveAll(dirPath); err != nil {
+				log.Printf("Cleanup uploads: failed to remove %s: %v", dirPath, err)
+				continue
+			}
+			if err := s.uploadRepo.MarkExpired(upload.ID); err != nil {
+				log.Printf("Cleanup uploads: failed to mark %s expired: %v", upload.ID, err)
+				continue
+			}
+			batchCleaned++
+		}
+		cleaned += batchCleaned
+
+		// 本批有失败的记录会被再次查出，没有进展时停止，留到下一轮
+		if len(uploads) < expiredUploadBatch || batchCleaned < len(uploads) {
+			return cleaned
+		}
+	}
+}
+
+// cleanupChunkSessions 清理过期的分片上传会话（/tmp/uploads/chunks/<session_id>/）
+// 会话目录在每次上传分片时更新修改时间，因此按最后一次活动计算过期
+func (s *Service) cleanupChunkSessions(expireDuration time.Duration) int {
+	if s.uploadTempDir == "" {
+		return 0
+	}
+
+	chunkDir := filepath.Join(s.uploadTempDir, service.ChunkSessionDir)
+	if _, err := os.Stat(chunkDir); os.IsNotExist(err) {
+		return 0
+	}
+	return removeExpiredDirs("chunks", chunkDir, expireDuration, nil)
+}
+
+// removeExpiredDirs 删除 dir 下修改时间早于 expireDuration 的子目录，skip 返回 true 的目录保留
+func removeExpiredDirs(label, dir string, expireDuration time.Duration, skip func(name string) bool) int {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Printf("Cleanup uploads: failed to read dir %s: %v", s.uploadTempDir, err)
+		log.Printf("Cleanup %s: failed to read dir %s: %v", label, dir, err)
 		return 0
 	}
 
 	cleaned := 0
 	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == "diagrams" {
+		if !entry.IsDir() || (skip != nil && skip(entry.Name())) {
 			continue
 		}
 
@@ -133,9 +238,9 @@ func (s *Service) cleanupUploadDirs(expireDuration time.Duration) int {
 		}
 
 		if time.Since(info.ModTime()) > expireDuration {
-			dirPath := filepath.Join(s.uploadTempDir, entry.Name())
+			dirPath := filepath.Join(dir, entry.Name())
 			if err := os.RemoveAll(dirPath); err != nil {
-				log.Printf("Cleanup uploads: failed to remove %s: %v", dirPath, err)
+				log.Printf("Cleanup %s: failed to remove %s: %v", label, dirPath, err)
 			} else {
 				cleaned++
 			}
@@ -176,7 +281,8 @@ func (s *Service) cleanupCloneDirs(expireDuration time.Duration) int {
 	return cleaned
 }
 
-// cleanupMigratedDiagrams 清理已迁移到 OSS 的本地 diagram 文件
+// cleanupMigratedDiagrams 清理已同步到远程存储的本地 diagram 文件
+// 包括旧版本的 diagrams/<id>.json 和 Reuploader 未删掉的 diagrams/<id>/ 目录
 func (s *Service) cleanupMigratedDiagrams() int {
 	if s.uploadTempDir == "" || s.analysisRepo == nil {
 		return 0
@@ -187,8 +293,8 @@ func (s *Service) cleanupMigratedDiagrams() int {
 		return 0
 	}
 
-	// 查询已迁移到 OSS 的分析 ID
-	migratedIDs, err := s.analysisRepo.ListOSSMigratedDiagramIDs()
+	// 查询框图已在远程存储的分析 ID
+	migratedIDs, err := s.analysisRepo.ListRemoteDiagramIDs()
 	if err != nil {
 		log.Printf("Cleanup diagrams: failed to query migrated IDs: %v", err)
 		return 0
@@ -200,13 +306,18 @@ func (s *Service) cleanupMigratedDiagrams() int {
 
 	cleaned := 0
 	for _, id := range migratedIDs {
-		localPath := filepath.Join(diagramDir, fmt.Sprintf("%d.json", id))
-		if err := os.Remove(localPath); err != nil {
-			if !os.IsNotExist(err) {
+		for _, localPath := range []string{
+			filepath.Join(diagramDir, fmt.Sprintf("%d.json", id)),
+			filepath.Join(diagramDir, fmt.Sprintf("%d", id)),
+		} {
+			if _, err := os.Lstat(localPath); err != nil {
+				continue
+			}
+			if err := os.RemoveAll(localPath); err != nil {
 				log.Printf("Cleanup diagrams: failed to remove %s: %v", localPath, err)
+			} else {
+				cleaned++
 			}
-		} else {
-			cleaned++
 		}
 	}
 	return cleaned
@@ -1,6 +1,8 @@
 package cron
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -42,7 +44,7 @@ func setupCronService(t *testing.T) (*Service, *gorm.DB, func()) {
 
 	userRepo := repository.NewUserRepository(db)
 	quotaService := service.NewQuotaService(userRepo, cfg)
-	cronService := NewService(quotaService, nil, "", 1)
+	cronService := NewService(quotaService, nil, nil, nil, nil, nil, "", 1)
 
 	cleanup := func() {
 		sqlDB, _ := db.DB()
@@ -57,7 +59,7 @@ func TestNewService(t *testing.T) {
 	defer cleanup()
 
 	// Test with nil quotaService
-	svc := NewService(nil, nil, "", 1)
+	svc := NewService(nil, nil, nil, nil, nil, nil, "", 1)
 	assert.NotNil(t, svc)
 	assert.Nil(t, svc.quotaService)
 	assert.NotNil(t, svc.stopChan)
@@ -182,8 +184,111 @@ func TestService_Structure(t *testing.T) {
 	userRepo := repository.NewUserRepository(db)
 	quotaService := service.NewQuotaService(userRepo, cfg)
 
-	svc := NewService(quotaService, nil, "", 1)
+	svc := NewService(quotaService, nil, nil, nil, nil, nil, "", 1)
 
 	assert.Equal(t, quotaService, svc.quotaService)
 	assert.NotNil(t, svc.stopChan)
 }
+
+func TestService_CleanupUploadDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+
+	mkdir := func(parts ...string) string {
+		dir := filepath.Join(append([]string{tempDir}, parts...)...)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		return dir
+	}
+
+	expiredUpload := mkdir("expired")
+	freshUpload := mkdir("fresh")
+	expiredChunk := mkdir(service.ChunkSessionDir, "expired")
+	freshChunk := mkdir(service.ChunkSessionDir, "fresh")
+	diagrams := mkdir("diagrams")
+	for _, dir := range []string{expiredUpload, expiredChunk, diagrams} {
+		require.NoError(t, os.Chtimes(dir, old, old))
+	}
+	// chunks 目录本身过期也不能被整体删除
+	require.NoError(t, os.Chtimes(filepath.Join(tempDir, service.ChunkSessionDir), old, old))
+
+	svc := NewService(nil, nil, nil, nil, nil, nil, tempDir, 1)
+	assert.Equal(t, 1, svc.cleanupUploadDirs(time.Hour))
+	assert.Equal(t, 1, svc.cleanupChunkSessions(time.Hour))
+
+	for _, dir := range []string{freshUpload, freshChunk, diagrams} {
+		assert.DirExists(t, dir)
+	}
+	assert.NoDirExists(t, expiredUpload)
+	assert.NoDirExists(t, expiredChunk)
+}
+
+func TestService_CleanupUploadDirs_FromTable(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+	require.NoError(t, db.AutoMigrate(&model.Upload{}))
+	uploadRepo := repository.NewUploadRepository(db)
+
+	tempDir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	now := time.Now()
+
+	// 记录已到期，但目录修改时间很新：按表清理
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "expired"), 0755))
+	require.NoError(t, uploadRepo.Create(&model.Upload{ID: "expired", UserID: 1, Status: "active", ExpiresAt: now.Add(-time.Minute)}))
+
+	// 记录未到期，但目录修改时间很旧（如排队期间续期）：保留
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "extended"), 0755))
+	require.NoError(t, os.Chtimes(filepath.Join(tempDir, "extended"), old, old))
+	require.NoError(t, uploadRepo.Create(&model.Upload{ID: "extended", UserID: 1, Status: "active", ExpiresAt: now.Add(time.Hour)}))
+
+	// 表中没有记录的旧目录：按修改时间清理
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "untracked"), 0755))
+	require.NoError(t, os.Chtimes(filepath.Join(tempDir, "untracked"), old, old))
+
+	svc := NewService(nil, nil, nil, nil, uploadRepo, nil, tempDir, 1)
+	assert.Equal(t, 2, svc.cleanupUploadDirs(time.Hour))
+
+	assert.NoDirExists(t, filepath.Join(tempDir, "expired"))
+	assert.NoDirExists(t, filepath.Join(tempDir, "untracked"))
+	assert.DirExists(t, filepath.Join(tempDir, "extended"))
+
+	upload, err := uploadRepo.GetByID("expired")
+	require.NoError(t, err)
+	assert.Equal(t, "expired", upload.Status)
+}
+
+func TestService_CleanupMigratedDiagrams(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+	require.NoError(t, db.AutoMigrate(&model.Analysis{}))
+	analysisRepo := repository.NewAnalysisRepository(db)
+
+	tempDir := t.TempDir()
+	diagramDir := filepath.Join(tempDir, "diagrams")
+	require.NoError(t, os.MkdirAll(filepath.Join(diagramDir, "2"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(diagramDir, "3"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(diagramDir, "1.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(diagramDir, "2", "1.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(diagramDir, "3", "1.json"), []byte("{}"), 0644))
+
+	// 1 为旧版本迁移过的数据，2 为 Reuploader 同步后未删掉的目录，3 仍在本地存储
+	for id, backend := range map[int64]string{1: "oss", 2: "s3", 3: "local"} {
+		require.NoError(t, db.Create(&model.Analysis{
+			ID: id, UserID: 1, Title: "t", CreationType: "ai", Status: "completed",
+			DiagramKey: "diagrams/key.json", DiagramBackend: backend,
+		}).Error)
+	}
+
+	svc := NewService(nil, nil, analysisRepo, nil, nil, nil, tempDir, 1)
+	assert.Equal(t, 2, svc.cleanupMigratedDiagrams())
+
+	assert.NoFileExists(t, filepath.Join(diagramDir, "1.json"))
+	assert.NoDirExists(t, filepath.Join(diagramDir, "2"))
+	assert.FileExists(t, filepath.Join(diagramDir, "3", "1.json"))
+}
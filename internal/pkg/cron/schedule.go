@@ -0,0 +1,108 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
+	"github.com/qs3c/anal_go_server/internal/service"
+)
+
+const (
+	// scheduleLockKey 多副本部署时只有持有该锁的实例触发定时分析
+	scheduleLockKey = "cron:analysis_schedules"
+	// scheduleLockTTL 锁的过期时间，短于检查间隔，实例崩溃后下一轮可由其他实例接手
+	scheduleLockTTL = 50 * time.Second
+	// scheduleBatch 每轮最多触发的定时任务数
+	scheduleBatch = 100
+)
+
+// runSchedules 每分钟检查一次到期的定时分析
+func (s *Service) runSchedules() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.fireDueSchedules(time.Now().UTC())
+		}
+	}
+}
+
+// fireDueSchedules 触发到期的定时分析，返回成功创建的任务数
+// 分布式锁保证同一时刻只有一个实例在处理，Claim 的条件更新保证同一次触发不会重复执行
+func (s *Service) fireDueSchedules(now time.Time) int {
+	ctx := context.Background()
+	if s.locker != nil {
+		lk, err := s.locker.Acquire(ctx, scheduleLockKey, scheduleLockTTL)
+		if err != nil {
+			if !errors.Is(err, lock.ErrNotAcquired) {
+				log.Printf("Schedules: failed to acquire lock: %v", err)
+			}
+			return 0
+		}
+		defer func() {
+			if err := lk.Release(ctx); err != nil {
+				log.Printf("Schedules: %v", err)
+			}
+		}()
+	}
+
+	schedules, err := s.scheduleRepo.ListDue(now, scheduleBatch)
+	if err != nil {
+		log.Printf("Schedules: failed to list due schedules: %v", err)
+		return 0
+	}
+
+	fired := 0
+	for _, schedule := range schedules {
+		// 表达式在保存时已校验，这里失败说明数据被改坏，停用该任务
+		var next *time.Time
+		if t, err := service.NextScheduleRun(schedule.CronExpr, now); err == nil {
+			next = &t
+		} else {
+			log.Printf("Schedules: schedule %d has invalid cron %q, disabling", schedule.ID, schedule.CronExpr)
+		}
+
+		claimed, err := s.scheduleRepo.Claim(schedule.ID, now, next)
+		if err != nil {
+			log.Printf("Schedules: failed to claim schedule %d: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if next == nil {
+			s.recordScheduleRun(schedule.ID, 0, service.ErrInvalidCron.Error())
+			continue
+		}
+
+		resp, err := s.analysisService.Rerun(schedule.UserID, schedule.AnalysisID, schedule.Ref)
+		if err != nil {
+			log.Printf("Schedules: schedule %d failed to enqueue analysis %d: %v", schedule.ID, schedule.AnalysisID, err)
+			s.recordScheduleRun(schedule.ID, 0, err.Error())
+			continue
+		}
+		s.recordScheduleRun(schedule.ID, resp.JobID, "")
+		fired++
+	}
+
+	if fired > 0 {
+		log.Printf("Schedules: enqueued %d analyses", fired)
+	}
+	return fired
+}
+
+func (s *Service) recordScheduleRun(scheduleID, jobID int64, lastError string) {
+	if r := []rune(lastError); len(r) > 500 {
+		lastError = string(r[:500])
+	}
+	if err := s.scheduleRepo.RecordRun(scheduleID, jobID, lastError); err != nil {
+		log.Printf("Schedules: failed to record run of schedule %d: %v", scheduleID, err)
+	}
+}
@@ -0,0 +1,97 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestService_FireDueSchedules(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+			},
+		},
+		Models: []config.ModelConfig{
+			{Name: "gpt-3.5-turbo", RequiredLevel: "free"},
+		},
+	}
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	scheduleRepo := repository.NewScheduleRepository(db)
+	quotaService := service.NewQuotaService(userRepo, cfg)
+	analysisService := service.NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, nil, nil, nil, cfg)
+
+	user := testutil.TestUser(t, db, testutil.WithQuotaUsed(4))
+	newAnalysis := func() *model.Analysis {
+		return testutil.TestAnalysis(t, db, user.ID, func(a *model.Analysis) {
+			a.CreationType = "ai"
+			a.SourceType = "github"
+			a.RepoURL = "https://github.com/example/repo"
+			a.AnalysisDepth = 2
+			a.ModelName = "gpt-3.5-turbo"
+		})
+	}
+
+	now := time.Date(2026, 3, 14, 2, 0, 0, 0, time.UTC)
+	due := now.Add(-time.Minute)
+	first := &model.AnalysisSchedule{AnalysisID: newAnalysis().ID, UserID: user.ID, CronExpr: "0 2 * * *", Ref: "main", Enabled: true, NextRunAt: &due}
+	second := &model.AnalysisSchedule{AnalysisID: newAnalysis().ID, UserID: user.ID, CronExpr: "@weekly", Enabled: true, NextRunAt: &due}
+	require.NoError(t, scheduleRepo.Create(first))
+	require.NoError(t, scheduleRepo.Create(second))
+
+	// 两个副本共享同一个 Redis
+	replicaA := NewService(quotaService, analysisService, analysisRepo, scheduleRepo, nil, lock.NewLocker(rdb), "", 1)
+	replicaB := NewService(quotaService, analysisService, analysisRepo, scheduleRepo, nil, lock.NewLocker(rdb), "", 1)
+
+	// 锁被其他实例持有时不触发
+	held, err := lock.NewLocker(rdb).Acquire(context.Background(), scheduleLockKey, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 0, replicaA.fireDueSchedules(now))
+	require.NoError(t, held.Release(context.Background()))
+
+	// 只剩 1 次配额：第一个任务入队，第二个记录配额不足
+	assert.Equal(t, 1, replicaA.fireDueSchedules(now))
+	assert.Equal(t, 0, replicaB.fireDueSchedules(now))
+
+	found, err := scheduleRepo.GetByID(first.ID)
+	require.NoError(t, err)
+	assert.NotZero(t, found.LastJobID)
+	assert.Empty(t, found.LastError)
+	assert.True(t, found.NextRunAt.Equal(time.Date(2026, 3, 15, 2, 0, 0, 0, time.UTC)))
+	job, err := jobRepo.GetByID(found.LastJobID)
+	require.NoError(t, err)
+	assert.Equal(t, "main", job.Ref)
+
+	found, err = scheduleRepo.GetByID(second.ID)
+	require.NoError(t, err)
+	assert.Zero(t, found.LastJobID)
+	assert.Equal(t, service.ErrQuotaExceeded.Error(), found.LastError)
+	assert.True(t, found.NextRunAt.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)))
+
+	// 锁已释放
+	assert.False(t, mr.Exists(scheduleLockKey))
+}
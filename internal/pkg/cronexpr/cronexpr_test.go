@@ -0,0 +1,77 @@
+package cronexpr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every 1h",
+	} {
+		_, err := Parse(expr)
+		assert.ErrorIs(t, err, ErrInvalidExpr, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// 2026-03-14 是周六
+	base := time.Date(2026, 3, 14, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 14, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 15, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 3 * * mon-fri", time.Date(2026, 3, 16, 3, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 JAN *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"5,50 9-11 * * *", time.Date(2026, 3, 14, 10, 50, 0, 0, time.UTC)},
+		{"10/20 * * * *", time.Date(2026, 3, 14, 10, 50, 0, 0, time.UTC)},
+		// 日和周都被限制时取并集：每月 20 日或每周一
+		{"0 0 20 * 1", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, s.Next(base), tt.expr)
+	}
+}
+
+func TestSchedule_Next_Never(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestSchedule_Next_Location(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	s, err := Parse("0 2 * * *")
+	require.NoError(t, err)
+
+	next := s.Next(time.Date(2026, 3, 14, 1, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2026, 3, 14, 2, 0, 0, 0, loc), next)
+	assert.Equal(t, 18, next.UTC().Hour())
+}
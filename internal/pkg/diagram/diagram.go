@@ -0,0 +1,263 @@
+package diagram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrInvalidDiagram 框图 JSON 无法解析
+var ErrInvalidDiagram = errors.New("invalid diagram data")
+
+// Diagram 可视化框图，对应分析器生成的 {"structs": [...], "connections": [...]}
+// 兼容手动创建时前端提交的 {"nodes": [...], "edges": [...]}
+type Diagram struct {
+	Structs     []Struct     `json:"structs"`
+	Connections []Connection `json:"connections"`
+}
+
+// Struct 框图中的结构体节点
+type Struct struct {
+	ID          string  `json:"id,omitempty"`
+	Name        string  `json:"name"`
+	Package     string  `json:"package,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Depth       int     `json:"depth,omitempty"`
+	Fields      []Field `json:"fields,omitempty"`
+}
+
+// Key 节点的唯一标识，未设置 id 时使用名称
+func (s *Struct) Key() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	return s.Name
+}
+
+// Label 节点显示名称
+func (s *Struct) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.ID
+}
+
+// Field 结构体字段
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	Tag  string `json:"tag,omitempty"`
+}
+
+// Connection 结构体之间的依赖关系，From/To 为节点的 Key
+type Connection struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Type  string `json:"type,omitempty"`
+	Label string `json:"label,omitempty"`
+}
+
+// 连接类型
+const (
+	ConnField     = "field"
+	ConnEmbed     = "embed"
+	ConnImplement = "implement"
+)
+
+// rawDiagram 解析时兼容的字段别名
+type rawDiagram struct {
+	Structs     []rawStruct     `json:"structs"`
+	Nodes       []rawStruct     `json:"nodes"`
+	Connections []rawConnection `json:"connections"`
+	Edges       []rawConnection `json:"edges"`
+}
+
+type rawStruct struct {
+	ID          json.RawMessage `json:"id"`
+	Name        string          `json:"name"`
+	Label       string          `json:"label"`
+	Package     string          `json:"package"`
+	Description string          `json:"description"`
+	Depth       int             `json:"depth"`
+	Fields      []Field         `json:"fields"`
+}
+
+type rawConnection struct {
+	From   json.RawMessage `json:"from"`
+	To     json.RawMessage `json:"to"`
+	Source json.RawMessage `json:"source"`
+	Target json.RawMessage `json:"target"`
+	Type   string          `json:"type"`
+	Label  string          `json:"label"`
+}
+
+// Parse 解析框图 JSON，id/from/to 既可以是字符串也可以是数字
+func Parse(data []byte) (*Diagram, error) {
+	var raw rawDiagram
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+	}
+
+	d := &Diagram{}
+	for _, s := range append(raw.Structs, raw.Nodes...) {
+		name := s.Name
+		if name == "" {
+			name = s.Label
+		}
+		d.Structs = append(d.Structs, Struct{
+			ID:          idString(s.ID),
+			Name:        name,
+			Package:     s.Package,
+			Description: s.Description,
+			Depth:       s.Depth,
+			Fields:      s.Fields,
+		})
+	}
+	for _, c := range append(raw.Connections, raw.Edges...) {
+		from, to := idString(c.From), idString(c.To)
+		if from == "" {
+			from = idString(c.Source)
+		}
+		if to == "" {
+			to = idString(c.Target)
+		}
+		d.Connections = append(d.Connections, Connection{From: from, To: to, Type: c.Type, Label: c.Label})
+	}
+
+	// 连接可能按名称引用设置了 id 的节点，统一为 Key
+	for i := range d.Connections {
+		c := &d.Connections[i]
+		if s := d.Find(c.From); s != nil {
+			c.From = s.Key()
+		}
+		if s := d.Find(c.To); s != nil {
+			c.To = s.Key()
+		}
+	}
+	return d, nil
+}
+
+// idString 把字符串或数字形式的 id 统一转为字符串
+func idString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	return ""
+}
+
+// Find 按 Key 或名称查找节点
+func (d *Diagram) Find(key string) *Struct {
+	for i := range d.Structs {
+		if d.Structs[i].Key() == key {
+			return &d.Structs[i]
+		}
+	}
+	for i := range d.Structs {
+		if d.Structs[i].Name == key {
+			return &d.Structs[i]
+		}
+	}
+	return nil
+}
+
+// Levels 从 root 出发按 BFS 计算每个节点的层级
+// root 为空或不存在时以没有入边的节点为起点；从起点不可达的节点（如孤立的环）依次作为新的起点
+func (d *Diagram) Levels(root string) map[string]int {
+	out := make(map[string][]string)
+	inDegree := make(map[string]int)
+	for _, c := range d.Connections {
+		out[c.From] = append(out[c.From], c.To)
+		inDegree[c.To]++
+	}
+
+	levels := make(map[string]int, len(d.Structs))
+	bfs := func(starts []string) {
+		queue := make([]string, 0, len(starts))
+		for _, s := range starts {
+			if _, ok := levels[s]; !ok {
+				levels[s] = 0
+				queue = append(queue, s)
+			}
+		}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, next := range out[cur] {
+				if _, ok := levels[next]; !ok && d.Find(next) != nil {
+					levels[next] = levels[cur] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+
+	if s := d.Find(root); root != "" && s != nil {
+		bfs([]string{s.Key()})
+	} else {
+		var sources []string
+		for _, s := range d.Structs {
+			if inDegree[s.Key()] == 0 {
+				sources = append(sources, s.Key())
+			}
+		}
+		bfs(sources)
+	}
+	for _, s := range d.Structs {
+		if _, ok := levels[s.Key()]; !ok {
+			bfs([]string{s.Key()})
+		}
+	}
+	return levels
+}
+
+// Options 导出和渲染选项
+type Options struct {
+	Root       string // 计算层级的起点，一般为分析的起点结构体
+	MaxDepth   int    // 只保留层级不超过 MaxDepth 的节点，<= 0 表示不限制
+	HideFields bool   // 不输出字段
+}
+
+// Filter 按选项过滤节点和字段，并丢弃端点不存在的连接，返回新的框图
+func (d *Diagram) Filter(opts Options) *Diagram {
+	levels := d.Levels(opts.Root)
+	keep := make(map[string]bool, len(d.Structs))
+	result := &Diagram{}
+	for _, s := range d.Structs {
+		if opts.MaxDepth > 0 && levels[s.Key()] > opts.MaxDepth {
+			continue
+		}
+		if opts.HideFields {
+			s.Fields = nil
+		}
+		keep[s.Key()] = true
+		result.Structs = append(result.Structs, s)
+	}
+	for _, c := range d.Connections {
+		if keep[c.From] && keep[c.To] {
+			result.Connections = append(result.Connections, c)
+		}
+	}
+	return result
+}
+
+// sortedByLevel 按层级、名称排序节点，保证输出稳定
+func (d *Diagram) sortedByLevel(levels map[string]int) []Struct {
+	structs := append([]Struct(nil), d.Structs...)
+	sort.SliceStable(structs, func(i, j int) bool {
+		li, lj := levels[structs[i].Key()], levels[structs[j].Key()]
+		if li != lj {
+			return li < lj
+		}
+		return structs[i].Label() < structs[j].Label()
+	})
+	return structs
+}
@@ -0,0 +1,131 @@
+package diagram
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDiagram = `{
+	"structs": [
+		{"name": "Server", "package": "app", "fields": [{"name": "Handler", "type": "*Handler"}, {"name": "cfg", "type": "map[string]string"}]},
+		{"name": "Handler", "fields": [{"name": "Service", "type": "*Service"}]},
+		{"name": "Service", "fields": [{"name": "repo", "type": "Repo"}]},
+		{"name": "Repo"},
+		{"name": "Orphan<T>"}
+	],
+	"connections": [
+		{"from": "Server", "to": "Handler", "type": "field"},
+		{"from": "Handler", "to": "Service", "type": "embed"},
+		{"from": "Service", "to": "Repo", "type": "uses"}
+	]
+}`
+
+func TestParse_Aliases(t *testing.T) {
+	d, err := Parse([]byte(`{"nodes":[{"id":1,"label":"A"},{"id":"b","name":"B"}],"edges":[{"source":1,"target":"B"}]}`))
+	require.NoError(t, err)
+	require.Len(t, d.Structs, 2)
+	assert.Equal(t, "1", d.Structs[0].Key())
+	assert.Equal(t, "A", d.Structs[0].Label())
+
+	// 按名称引用的端点统一为 Key
+	require.Len(t, d.Connections, 1)
+	assert.Equal(t, Connection{From: "1", To: "b"}, d.Connections[0])
+
+	_, err = Parse([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrInvalidDiagram)
+}
+
+func TestDiagram_LevelsAndFilter(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+
+	levels := d.Levels("Server")
+	assert.Equal(t, 0, levels["Server"])
+	assert.Equal(t, 2, levels["Service"])
+	assert.Equal(t, 3, levels["Repo"])
+	// 不可达节点作为新的起点
+	assert.Equal(t, 0, levels["Orphan<T>"])
+
+	// 起点不存在时从没有入边的节点开始
+	assert.Equal(t, 1, d.Levels("")["Handler"])
+
+	filtered := d.Filter(Options{Root: "Server", MaxDepth: 1, HideFields: true})
+	var names []string
+	for _, s := range filtered.Structs {
+		names = append(names, s.Name)
+		assert.Empty(t, s.Fields)
+	}
+	assert.Equal(t, []string{"Server", "Handler", "Orphan<T>"}, names)
+	assert.Equal(t, []Connection{{From: "Server", To: "Handler", Type: "field"}}, filtered.Connections)
+
+	// 原框图不受影响
+	assert.Len(t, d.Structs[0].Fields, 2)
+}
+
+func TestExport_Formats(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+	opts := Options{Root: "Server"}
+
+	out, err := Export(d, FormatMermaid, opts)
+	require.NoError(t, err)
+	mermaid := string(out)
+	assert.True(t, strings.HasPrefix(mermaid, "classDiagram\n"))
+	assert.Contains(t, mermaid, "class Server {\n        +*Handler Handler\n        -map~string~string cfg\n    }")
+	assert.Contains(t, mermaid, `class Orphan_T_["Orphan<T>"]`)
+	assert.Contains(t, mermaid, "Server --> Handler\n")
+	assert.Contains(t, mermaid, "Handler *-- Service\n")
+	assert.Contains(t, mermaid, "Service --> Repo : uses\n")
+
+	out, err = Export(d, FormatPlantUML, opts)
+	require.NoError(t, err)
+	puml := string(out)
+	assert.True(t, strings.HasPrefix(puml, "@startuml\n"))
+	assert.True(t, strings.HasSuffix(puml, "@enduml\n"))
+	assert.Contains(t, puml, "  -cfg : map[string]string\n")
+	assert.Contains(t, puml, `class "Orphan<T>" as Orphan_T_`)
+
+	out, err = Export(d, FormatDOT, opts)
+	require.NoError(t, err)
+	dot := string(out)
+	assert.Contains(t, dot, `"Server" [label="{Server|Handler: *Handler\lcfg: map[string]string\l}"];`)
+	assert.Contains(t, dot, `"Orphan<T>" [label="Orphan\<T\>"];`)
+	assert.Contains(t, dot, `"Handler" -> "Service" [dir=both, arrowtail=diamond, arrowhead=none];`)
+
+	_, err = Export(d, "png", opts)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestExport_SVG(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+
+	out, err := Export(d, FormatSVG, Options{Root: "Server", MaxDepth: 2})
+	require.NoError(t, err)
+
+	// 输出必须是合法的 XML
+	dec := xml.NewDecoder(strings.NewReader(string(out)))
+	for {
+		_, err := dec.Token()
+		if err != nil {
+			assert.Equal(t, "EOF", err.Error())
+			break
+		}
+	}
+
+	svg := string(out)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.Contains(t, svg, `data-key="Orphan&lt;T&gt;"`)
+	assert.Contains(t, svg, `marker-start="url(#diamond)"`)
+	assert.NotContains(t, svg, `data-key="Repo"`)
+	assert.Equal(t, 1, strings.Count(svg, `marker-end="url(#arrow)"`))
+
+	// 空框图也能渲染
+	out, err = Export(&Diagram{}, FormatSVG, Options{})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "</svg>")
+}
@@ -0,0 +1,267 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 导出格式
+const (
+	FormatMermaid  = "mermaid"
+	FormatPlantUML = "plantuml"
+	FormatDOT      = "dot"
+	FormatSVG      = "svg"
+)
+
+// ErrUnsupportedFormat 不支持的导出格式
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// Export 按格式导出框图，导出前先按 opts 过滤
+func Export(d *Diagram, format string, opts Options) ([]byte, error) {
+	filtered := d.Filter(opts)
+	levels := filtered.Levels(opts.Root)
+
+	var out string
+	switch format {
+	case FormatMermaid:
+		out = Mermaid(filtered, levels)
+	case FormatPlantUML:
+		out = PlantUML(filtered, levels)
+	case FormatDOT:
+		out = DOT(filtered, levels)
+	case FormatSVG:
+		out = SVG(filtered, levels)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return []byte(out), nil
+}
+
+// ContentType 返回导出格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatSVG:
+		return "image/svg+xml; charset=utf-8"
+	case FormatDOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// FileExt 返回导出格式对应的文件扩展名
+func FileExt(format string) string {
+	switch format {
+	case FormatMermaid:
+		return ".mmd"
+	case FormatPlantUML:
+		return ".puml"
+	case FormatDOT:
+		return ".dot"
+	case FormatSVG:
+		return ".svg"
+	default:
+		return ".txt"
+	}
+}
+
+// Mermaid 生成 Mermaid classDiagram
+func Mermaid(d *Diagram, levels map[string]int) string {
+	var b strings.Builder
+	b.WriteString("classDiagram\n")
+	ids := identifiers(d)
+	for _, s := range d.sortedByLevel(levels) {
+		id := ids[s.Key()]
+		header := "    class " + id
+		if id != s.Label() {
+			header += `["` + strings.ReplaceAll(s.Label(), `"`, "'") + `"]`
+		}
+		if len(s.Fields) == 0 {
+			b.WriteString(header + "\n")
+			continue
+		}
+		b.WriteString(header + " {\n")
+		for _, f := range s.Fields {
+			b.WriteString("        " + mermaidField(f) + "\n")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, c := range d.Connections {
+		arrow := "-->"
+		switch c.Type {
+		case ConnEmbed:
+			arrow = "*--"
+		case ConnImplement:
+			arrow = "..|>"
+		}
+		line := fmt.Sprintf("    %s %s %s", ids[c.From], arrow, ids[c.To])
+		if label := connLabel(c); label != "" {
+			line += " : " + label
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// mermaidField Mermaid 中泛型用 ~T~ 表示，[] 和 {} 需要替换
+func mermaidField(f Field) string {
+	name := f.Name
+	if f.Type != "" {
+		name = f.Type + " " + name
+	}
+	name = strings.NewReplacer("[", "~", "]", "~", "{", "(", "}", ")").Replace(name)
+	if isExported(f.Name) {
+		return "+" + name
+	}
+	return "-" + name
+}
+
+// PlantUML 生成 PlantUML 类图
+func PlantUML(d *Diagram, levels map[string]int) string {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	b.WriteString("hide empty members\n")
+	ids := identifiers(d)
+	for _, s := range d.sortedByLevel(levels) {
+		id := ids[s.Key()]
+		header := "class " + id
+		if id != s.Label() {
+			header = fmt.Sprintf(`class "%s" as %s`, strings.ReplaceAll(s.Label(), `"`, "'"), id)
+		}
+		if len(s.Fields) == 0 {
+			b.WriteString(header + "\n")
+			continue
+		}
+		b.WriteString(header + " {\n")
+		for _, f := range s.Fields {
+			vis := "-"
+			if isExported(f.Name) {
+				vis = "+"
+			}
+			line := "  " + vis + f.Name
+			if f.Type != "" {
+				line += " : " + f.Type
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	for _, c := range d.Connections {
+		arrow := "-->"
+		switch c.Type {
+		case ConnEmbed:
+			arrow = "*--"
+		case ConnImplement:
+			arrow = "..|>"
+		}
+		line := fmt.Sprintf("%s %s %s", ids[c.From], arrow, ids[c.To])
+		if label := connLabel(c); label != "" {
+			line += " : " + label
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// DOT 生成 Graphviz DOT，节点使用 record 形状
+func DOT(d *Diagram, levels map[string]int) string {
+	var b strings.Builder
+	b.WriteString("digraph G {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=record, fontname=\"Helvetica\", fontsize=10];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=9];\n")
+	for _, s := range d.sortedByLevel(levels) {
+		label := dotRecordEscape(s.Label())
+		if len(s.Fields) > 0 {
+			var fields strings.Builder
+			for _, f := range s.Fields {
+				fields.WriteString(dotRecordEscape(fieldText(f)) + `\l`)
+			}
+			label = "{" + label + "|" + fields.String() + "}"
+		}
+		fmt.Fprintf(&b, "  %s [label=\"%s\"];\n", dotQuote(s.Key()), label)
+	}
+	for _, c := range d.Connections {
+		attrs := []string{}
+		if label := connLabel(c); label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%s", dotQuote(label)))
+		}
+		switch c.Type {
+		case ConnEmbed:
+			attrs = append(attrs, "dir=both", "arrowtail=diamond", "arrowhead=none")
+		case ConnImplement:
+			attrs = append(attrs, "style=dashed", "arrowhead=empty")
+		}
+		line := fmt.Sprintf("  %s -> %s", dotQuote(c.From), dotQuote(c.To))
+		if len(attrs) > 0 {
+			line += " [" + strings.Join(attrs, ", ") + "]"
+		}
+		b.WriteString(line + ";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// dotRecordEscape 转义 record 标签中的特殊字符
+func dotRecordEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`, "\n", " ").Replace(s)
+}
+
+// identifiers 为每个节点生成 Mermaid/PlantUML 可用的标识符（字母、数字、下划线），重名时追加序号
+func identifiers(d *Diagram) map[string]string {
+	ids := make(map[string]string, len(d.Structs))
+	used := make(map[string]bool, len(d.Structs))
+	for _, s := range d.Structs {
+		base := sanitizeID(s.Label())
+		id := base
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[id] = true
+		ids[s.Key()] = id
+	}
+	return ids
+}
+
+func sanitizeID(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	id := b.String()
+	if id == "" || ('0' <= id[0] && id[0] <= '9') {
+		id = "N" + id
+	}
+	return id
+}
+
+func fieldText(f Field) string {
+	if f.Type == "" {
+		return f.Name
+	}
+	return f.Name + ": " + f.Type
+}
+
+func connLabel(c Connection) string {
+	if c.Label != "" {
+		return c.Label
+	}
+	if c.Type != "" && c.Type != ConnField && c.Type != ConnEmbed && c.Type != ConnImplement {
+		return c.Type
+	}
+	return ""
+}
+
+func isExported(name string) bool {
+	return name != "" && 'A' <= name[0] && name[0] <= 'Z'
+}
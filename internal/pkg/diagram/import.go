@@ -0,0 +1,172 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 导入格式（Mermaid、PlantUML 复用导出格式常量）
+const FormatGo = "go"
+
+// SyntaxError 导入源码的行级错误，Line 从 1 开始
+type SyntaxError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// SyntaxErrors 导入时收集的全部行级错误，按行号排列
+type SyntaxErrors []SyntaxError
+
+func (e SyntaxErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, se := range e {
+		msgs = append(msgs, se.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Import 把 Mermaid classDiagram、PlantUML 类图或 Go 源码转换为框图，并校验结果
+func Import(format, src string) (*Diagram, error) {
+	var (
+		d   *Diagram
+		err error
+	)
+	switch format {
+	case FormatMermaid:
+		d, err = ImportMermaid(src)
+	case FormatPlantUML:
+		d, err = ImportPlantUML(src)
+	case FormatGo:
+		d, err = ImportGo(src)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(d.Structs) == 0 {
+		return nil, SyntaxErrors{{Line: 1, Message: "no class or struct definitions found"}}
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// builder 按出现顺序收集节点、字段和连接，连接引用的未声明节点自动创建
+type builder struct {
+	d       Diagram
+	index   map[string]int
+	aliases map[string]string
+	edges   map[Connection]bool
+	errs    SyntaxErrors
+}
+
+func newBuilder() *builder {
+	return &builder{
+		index:   make(map[string]int),
+		aliases: make(map[string]string),
+		edges:   make(map[Connection]bool),
+	}
+}
+
+// resolve 把别名转换为节点名称
+func (b *builder) resolve(name string) string {
+	if real, ok := b.aliases[name]; ok {
+		return real
+	}
+	return name
+}
+
+// declare 声明节点，重复声明时返回已有节点（补充包名）
+func (b *builder) declare(name, pkg string) *Struct {
+	name = b.resolve(name)
+	if i, ok := b.index[name]; ok {
+		if b.d.Structs[i].Package == "" {
+			b.d.Structs[i].Package = pkg
+		}
+		return &b.d.Structs[i]
+	}
+	b.index[name] = len(b.d.Structs)
+	b.d.Structs = append(b.d.Structs, Struct{Name: name, Package: pkg})
+	return &b.d.Structs[len(b.d.Structs)-1]
+}
+
+func (b *builder) addField(name string, f Field) {
+	s := b.declare(name, "")
+	s.Fields = append(s.Fields, f)
+}
+
+// connect 添加连接，相同的连接只保留一条
+func (b *builder) connect(c Connection) {
+	c.From, c.To = b.resolve(c.From), b.resolve(c.To)
+	b.declare(c.From, "")
+	b.declare(c.To, "")
+	if b.edges[c] {
+		return
+	}
+	b.edges[c] = true
+	b.d.Connections = append(b.d.Connections, c)
+}
+
+func (b *builder) errorf(line int, format string, args ...interface{}) {
+	b.errs = append(b.errs, SyntaxError{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (b *builder) result() (*Diagram, error) {
+	if len(b.errs) > 0 {
+		return nil, b.errs
+	}
+	return &b.d, nil
+}
+
+// relation 把 UML 关系箭头转换为连接
+// leftHead/rightHead 取值：""、">"（关联）、"|>"（继承/实现）、"*"（组合）、"o"（聚合）
+// 继承对应 Go 的嵌入，虚线继承对应接口实现，组合对应嵌入，其余视为字段引用
+func relation(left, right, leftHead, rightHead string, dotted bool, label string) Connection {
+	c := Connection{From: left, To: right, Type: ConnField, Label: label}
+	switch {
+	case rightHead == "|>":
+		c.Type = ConnEmbed
+	case leftHead == "|>":
+		c.From, c.To, c.Type = right, left, ConnEmbed
+	case leftHead == "*":
+		c.Type = ConnEmbed
+	case rightHead == "*":
+		c.From, c.To, c.Type = right, left, ConnEmbed
+	case leftHead == "o":
+	case rightHead == "o":
+		c.From, c.To = right, left
+	case leftHead == ">" && rightHead == "":
+		c.From, c.To = right, left
+	}
+	if c.Type == ConnEmbed && dotted && (leftHead == "|>" || rightHead == "|>") {
+		c.Type = ConnImplement
+	}
+	return c
+}
+
+// parseMember 解析类成员，支持 "name : Type" 和 "Type name" 两种写法，方法返回 ok=false
+func parseMember(line string) (Field, bool) {
+	line = strings.TrimSpace(line)
+	if strings.Contains(line, "(") {
+		return Field{}, false
+	}
+	line = strings.TrimLeft(line, "+-#~ ")
+	line = strings.TrimRight(line, "$* ")
+	if line == "" {
+		return Field{}, false
+	}
+	if name, typ, ok := strings.Cut(line, ":"); ok {
+		return Field{Name: strings.TrimSpace(name), Type: strings.TrimSpace(typ)}, strings.TrimSpace(name) != ""
+	}
+	parts := strings.Fields(line)
+	if len(parts) == 1 {
+		return Field{Name: parts[0]}, true
+	}
+	return Field{Name: parts[len(parts)-1], Type: strings.Join(parts[:len(parts)-1], " ")}, true
+}
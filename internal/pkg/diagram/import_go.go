@@ -0,0 +1,128 @@
+package diagram
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// ImportGo 解析 Go 源码片段中的结构体定义，片段可以省略 package 声明
+// 字段类型引用了片段内的其他结构体时生成连接，匿名嵌入生成 embed 连接
+func ImportGo(src string) (*Diagram, error) {
+	fset := token.NewFileSet()
+	offset := 0
+	file, err := parser.ParseFile(fset, "snippet.go", src, parser.SkipObjectResolution)
+	if err != nil && needsPackageClause(err) {
+		offset = 1
+		file, err = parser.ParseFile(fset, "snippet.go", "package snippet\n"+src, parser.SkipObjectResolution)
+	}
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			errs := make(SyntaxErrors, 0, len(list))
+			for _, e := range list {
+				errs = append(errs, SyntaxError{Line: max(e.Pos.Line-offset, 1), Message: e.Msg})
+			}
+			return nil, errs
+		}
+		return nil, SyntaxErrors{{Line: 1, Message: err.Error()}}
+	}
+
+	pkg := file.Name.Name
+	if offset > 0 {
+		pkg = ""
+	}
+
+	// 先收集全部结构体名称，字段可以引用后面定义的类型
+	var specs []*ast.TypeSpec
+	declared := make(map[string]bool)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); ok {
+				specs = append(specs, ts)
+				declared[ts.Name.Name] = true
+			}
+		}
+	}
+
+	b := newBuilder()
+	for _, ts := range specs {
+		b.declare(ts.Name.Name, pkg)
+	}
+	for _, ts := range specs {
+		name := ts.Name.Name
+		for _, field := range ts.Type.(*ast.StructType).Fields.List {
+			typ := types.ExprString(field.Type)
+			tag := ""
+			if field.Tag != nil {
+				if v, err := strconv.Unquote(field.Tag.Value); err == nil {
+					tag = v
+				}
+			}
+
+			connType := ConnField
+			if len(field.Names) == 0 {
+				// 匿名嵌入，字段名为类型名（去掉指针和包名）
+				connType = ConnEmbed
+				b.addField(name, Field{Name: embeddedName(typ), Type: typ, Tag: tag})
+			}
+			for _, ident := range field.Names {
+				b.addField(name, Field{Name: ident.Name, Type: typ, Tag: tag})
+			}
+
+			for _, ref := range referencedTypes(field.Type) {
+				if declared[ref] {
+					b.connect(Connection{From: name, To: ref, Type: connType})
+				}
+			}
+		}
+	}
+	return b.result()
+}
+
+// needsPackageClause 判断解析失败是否只是因为缺少 package 声明
+func needsPackageClause(err error) bool {
+	var list scanner.ErrorList
+	return errors.As(err, &list) && len(list) > 0 && list[0].Pos.Line == 1 &&
+		strings.Contains(list[0].Msg, "expected 'package'")
+}
+
+// referencedTypes 返回类型表达式中引用的本包类型名（如 map[string]*User 中的 User）
+func referencedTypes(expr ast.Expr) []string {
+	var refs []string
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch t := n.(type) {
+		case *ast.SelectorExpr:
+			// 其他包的类型
+			return false
+		case *ast.FuncType:
+			return false
+		case *ast.Ident:
+			refs = append(refs, t.Name)
+		}
+		return true
+	})
+	return refs
+}
+
+// embeddedName 嵌入字段的名称：*pkg.Type[T] -> Type
+func embeddedName(typ string) string {
+	typ = strings.TrimPrefix(typ, "*")
+	if i := strings.Index(typ, "["); i >= 0 {
+		typ = typ[:i]
+	}
+	if i := strings.LastIndex(typ, "."); i >= 0 {
+		typ = typ[i+1:]
+	}
+	return typ
+}
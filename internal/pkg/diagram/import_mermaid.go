@@ -0,0 +1,157 @@
+package diagram
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mermaidName     = "(`[^`]+`|[\\w~]+)"
+	mermaidRelation = regexp.MustCompile(`^` + mermaidName + `\s*(?:"[^"]*"\s*)?(<\||\*|o|<)?(--|\.\.)(\|>|\*|o|>)?\s*(?:"[^"]*"\s*)?` + mermaidName + `\s*(?::\s*(.*))?$`)
+	mermaidClass    = regexp.MustCompile(`^class\s+` + mermaidName + `(?:\["([^"]*)"\])?(?:\s*:::\s*[\w-]+)?\s*(\{)?\s*(\})?$`)
+	mermaidMember   = regexp.MustCompile(`^` + mermaidName + `\s*:\s*(.+)$`)
+	mermaidNS       = regexp.MustCompile(`^namespace\s+([\w.]+)\s*\{$`)
+	mermaidIgnored  = regexp.MustCompile(`^(direction\s|note\s|note$|classDef\s|cssClass\s|style\s|click\s|link\s|callback\s|accTitle|accDescr|title\s|<<)`)
+)
+
+// ImportMermaid 解析 Mermaid classDiagram
+// 支持 class 声明与成员块、namespace、"Class : member" 形式的成员定义和各类关系箭头，方法会被忽略
+func ImportMermaid(src string) (*Diagram, error) {
+	b := newBuilder()
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	headerSeen := false
+	inFrontMatter := false
+	namespace := ""
+	class := "" // 正在解析成员块的类
+	nsLine := 0 // namespace 起始行，用于报告未闭合
+	classLine := 0
+
+	for i, raw := range lines {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+
+		// YAML front matter
+		if !headerSeen && line == "---" {
+			inFrontMatter = !inFrontMatter
+			continue
+		}
+		if inFrontMatter || line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+
+		if !headerSeen {
+			if line != "classDiagram" && line != "classDiagram-v2" {
+				b.errorf(lineNo, "expected classDiagram header, got %q", line)
+				return b.result()
+			}
+			headerSeen = true
+			continue
+		}
+
+		if class != "" {
+			if line == "}" {
+				class = ""
+				continue
+			}
+			if strings.HasPrefix(line, "<<") {
+				continue
+			}
+			if f, ok := parseMember(line); ok {
+				f.Type = mermaidGeneric(f.Type)
+				b.addField(class, f)
+			}
+			continue
+		}
+
+		if line == "}" {
+			if namespace == "" {
+				b.errorf(lineNo, "unexpected }")
+			}
+			namespace = ""
+			continue
+		}
+
+		if m := mermaidNS.FindStringSubmatch(line); m != nil {
+			if namespace != "" {
+				b.errorf(lineNo, "nested namespace is not supported")
+			}
+			namespace, nsLine = m[1], lineNo
+			continue
+		}
+
+		if m := mermaidClass.FindStringSubmatch(line); m != nil {
+			name := mermaidClassName(m[1])
+			if m[2] != "" {
+				b.aliases[name] = m[2]
+			}
+			s := b.declare(name, namespace)
+			if m[3] != "" && m[4] == "" {
+				class, classLine = s.Name, lineNo
+			}
+			continue
+		}
+
+		if m := mermaidRelation.FindStringSubmatch(line); m != nil {
+			leftHead, rightHead := m[2], m[4]
+			if leftHead == "<|" {
+				leftHead = "|>"
+			} else if leftHead == "<" {
+				leftHead = ">"
+			}
+			b.connect(relation(mermaidClassName(m[1]), mermaidClassName(m[5]), leftHead, rightHead, m[3] == "..", strings.TrimSpace(m[6])))
+			continue
+		}
+
+		if m := mermaidMember.FindStringSubmatch(line); m != nil {
+			if f, ok := parseMember(m[2]); ok {
+				f.Type = mermaidGeneric(f.Type)
+				b.addField(mermaidClassName(m[1]), f)
+			}
+			continue
+		}
+
+		if mermaidIgnored.MatchString(line) {
+			continue
+		}
+		b.errorf(lineNo, "unrecognized statement %q", line)
+	}
+
+	if !headerSeen {
+		b.errorf(1, "missing classDiagram header")
+	}
+	if class != "" {
+		b.errorf(classLine, "class %s is not closed", class)
+	}
+	if namespace != "" {
+		b.errorf(nsLine, "namespace %s is not closed", namespace)
+	}
+	return b.result()
+}
+
+// mermaidClassName 去掉反引号，Foo~T~ 转为 Foo[T]
+func mermaidClassName(name string) string {
+	return mermaidGeneric(strings.Trim(name, "`"))
+}
+
+// mermaidGeneric Mermaid 用成对的 ~ 表示泛型，转换回 Go 的方括号，如 map~string~int -> map[string]int
+func mermaidGeneric(s string) string {
+	if !strings.Contains(s, "~") {
+		return s
+	}
+	var b strings.Builder
+	open := true
+	for _, r := range s {
+		if r == '~' {
+			if open {
+				b.WriteByte('[')
+			} else {
+				b.WriteByte(']')
+			}
+			open = !open
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
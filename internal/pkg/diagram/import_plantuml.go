@@ -0,0 +1,169 @@
+package diagram
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	plantName     = `("[^"]+"|[\w$]+(?:\.[\w$]+)*)`
+	plantRelation = regexp.MustCompile(`^` + plantName + `\s*(?:"[^"]*"\s*)?(<\||<|\*|o|#|x|\+|\^|\})?([-.]+(?:(?:up|down|left|right|u|d|l|r)[-.]+)?)(\|>|>|\*|o|#|x|\+|\^|\{)?\s*(?:"[^"]*"\s*)?` + plantName + `\s*(?::\s*(.*))?$`)
+	plantClass    = regexp.MustCompile(`^(?:abstract\s+class|abstract|class|struct|interface|entity|enum|annotation|protocol|exception|metaclass|stereotype)\s+` + plantName + `(?:\s*<[^>]*>)?(?:\s+as\s+([\w$.]+))?(?:\s*<<[^>]*>>)?(?:\s*#\S+)?\s*(\{)?\s*(\})?$`)
+	plantMember   = regexp.MustCompile(`^` + plantName + `\s*:\s*(.+)$`)
+	plantPackage  = regexp.MustCompile(`^(?:package|namespace)\s+("[^"]+"|[\w$./]+)(?:\s+as\s+\w+)?(?:\s*<<[^>]*>>)?(?:\s*#\S+)?\s*\{$`)
+	plantStyle    = regexp.MustCompile(`\[[^\]]*\]`)
+	plantModifier = regexp.MustCompile(`\{(field|method|static|abstract|classifier)\}`)
+	plantIgnored  = regexp.MustCompile(`^(hide\s|show\s|skinparam\b|title\s|header\b|footer\b|caption\s|left to right direction|top to bottom direction|!|scale\s|legend\b|endlegend\b|set\s|allowmixing\b|remove\s|together\b)`)
+)
+
+// ImportPlantUML 解析 PlantUML 类图
+// 支持 class/struct/interface 等声明与成员块、package/namespace、"Class : member" 成员定义和关系箭头，方法会被忽略
+func ImportPlantUML(src string) (*Diagram, error) {
+	b := newBuilder()
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var packages []string // package 嵌套栈
+	class := ""
+	classLine := 0
+	inComment := false
+	inNote := false
+	ended := false
+
+	for i, raw := range lines {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+
+		// 块注释 /' ... '/
+		if inComment {
+			if strings.Contains(line, "'/") {
+				inComment = false
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "/'") {
+			inComment = !strings.Contains(line[2:], "'/")
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "'") || ended {
+			continue
+		}
+		if inNote {
+			if line == "end note" || line == "endnote" {
+				inNote = false
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@startuml"):
+			continue
+		case line == "@enduml":
+			ended = true
+			continue
+		}
+
+		if class != "" {
+			if line == "}" {
+				class = ""
+				continue
+			}
+			if isPlantSeparator(line) {
+				continue
+			}
+			if f, ok := parseMember(plantModifier.ReplaceAllString(line, "")); ok {
+				b.addField(class, f)
+			}
+			continue
+		}
+
+		if line == "}" {
+			if len(packages) == 0 {
+				b.errorf(lineNo, "unexpected }")
+			} else {
+				packages = packages[:len(packages)-1]
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "note ") || line == "note" {
+			// 单行 note 带冒号，多行 note 到 end note 结束
+			if !strings.Contains(line, ":") {
+				inNote = true
+			}
+			continue
+		}
+
+		if m := plantPackage.FindStringSubmatch(line); m != nil {
+			packages = append(packages, strings.Trim(m[1], `"`))
+			continue
+		}
+
+		if m := plantClass.FindStringSubmatch(line); m != nil {
+			name := strings.Trim(m[1], `"`)
+			if alias := m[2]; alias != "" {
+				b.aliases[alias] = name
+			}
+			pkg := ""
+			if len(packages) > 0 {
+				pkg = strings.Join(packages, ".")
+			}
+			s := b.declare(name, pkg)
+			if m[3] != "" && m[4] == "" {
+				class, classLine = s.Name, lineNo
+			}
+			continue
+		}
+
+		if m := plantRelation.FindStringSubmatch(plantStyle.ReplaceAllString(line, "")); m != nil {
+			leftHead, rightHead := plantHead(m[2]), plantHead(m[4])
+			if leftHead == "" && m[2] == "<" {
+				leftHead = ">"
+			}
+			b.connect(relation(strings.Trim(m[1], `"`), strings.Trim(m[5], `"`), leftHead, rightHead, strings.Contains(m[3], "."), strings.TrimSpace(m[6])))
+			continue
+		}
+
+		if m := plantMember.FindStringSubmatch(line); m != nil {
+			if f, ok := parseMember(plantModifier.ReplaceAllString(m[2], "")); ok {
+				b.addField(strings.Trim(m[1], `"`), f)
+			}
+			continue
+		}
+
+		if plantIgnored.MatchString(line) {
+			continue
+		}
+		b.errorf(lineNo, "unrecognized statement %q", line)
+	}
+
+	if class != "" {
+		b.errorf(classLine, "class %s is not closed", class)
+	}
+	if len(packages) > 0 {
+		b.errorf(len(lines), "package %s is not closed", packages[len(packages)-1])
+	}
+	return b.result()
+}
+
+// plantHead 把 PlantUML 箭头端点统一为 relation 使用的取值，不关心的端点（#、x、+、^ 等）视为普通关联
+func plantHead(head string) string {
+	switch head {
+	case "<|", "|>":
+		return "|>"
+	case "*", "o":
+		return head
+	case ">":
+		return ">"
+	default:
+		return ""
+	}
+}
+
+func isPlantSeparator(line string) bool {
+	for _, sep := range []string{"--", "..", "==", "__"} {
+		if strings.HasPrefix(line, sep) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package diagram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportMermaid(t *testing.T) {
+	src := `---
+title: demo
+---
+classDiagram
+    %% 注释
+    direction LR
+    namespace app {
+        class Server {
+            <<service>>
+            +*Handler Handler
+            -map~string~string cfg
+            +Run(addr string) error
+        }
+    }
+    class Orphan_T_["Orphan<T>"]
+    Server --> Handler : handles
+    Handler *-- Service
+    Repo <|.. SQLRepo
+    Base <|-- Service
+    Service "1" --> "*" Repo
+    Handler : +int retries
+`
+	d, err := Import(FormatMermaid, src)
+	require.NoError(t, err)
+
+	server := d.Find("Server")
+	require.NotNil(t, server)
+	assert.Equal(t, "app", server.Package)
+	assert.Equal(t, []Field{{Name: "Handler", Type: "*Handler"}, {Name: "cfg", Type: "map[string]string"}}, server.Fields)
+	assert.NotNil(t, d.Find("Orphan<T>"))
+	assert.Equal(t, []Field{{Name: "retries", Type: "int"}}, d.Find("Handler").Fields)
+
+	assert.Equal(t, []Connection{
+		{From: "Server", To: "Handler", Type: ConnField, Label: "handles"},
+		{From: "Handler", To: "Service", Type: ConnEmbed},
+		{From: "SQLRepo", To: "Repo", Type: ConnImplement},
+		{From: "Service", To: "Base", Type: ConnEmbed},
+		{From: "Service", To: "Repo", Type: ConnField},
+	}, d.Connections)
+}
+
+func TestImportMermaid_Errors(t *testing.T) {
+	_, err := Import(FormatMermaid, "graph TD\nA-->B")
+	var errs SyntaxErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, 1, errs[0].Line)
+
+	_, err = Import(FormatMermaid, "classDiagram\nclass A {\n  +int x\n\nA ==> B\nfoo bar baz")
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, SyntaxErrors{{Line: 2, Message: "class A is not closed"}}, errs)
+
+	_, err = Import(FormatMermaid, "classDiagram\nclass A\nA ==> B\n}\n")
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, []int{3, 4}, []int{errs[0].Line, errs[1].Line})
+
+	_, err = Import(FormatMermaid, "classDiagram\n%% empty\n")
+	require.ErrorAs(t, err, &errs)
+	assert.Contains(t, errs[0].Message, "no class")
+}
+
+func TestImportMermaid_RoundTrip(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+	out, err := Export(d, FormatMermaid, Options{})
+	require.NoError(t, err)
+
+	imported, err := Import(FormatMermaid, string(out))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Server", "Handler", "Service", "Repo", "Orphan<T>"}, structNames(imported))
+	assert.Equal(t, d.Find("Server").Fields, imported.Find("Server").Fields)
+	assert.Contains(t, imported.Connections, Connection{From: "Handler", To: "Service", Type: ConnEmbed})
+}
+
+func TestImportPlantUML(t *testing.T) {
+	src := `@startuml
+' 注释
+/' 多行
+   注释 '/
+skinparam classAttributeIconSize 0
+package "model" {
+  class User {
+    +ID : int64
+    {field} -name : string
+    ==
+    +GetName() : string
+  }
+  class "Profile<T>" as Profile {
+    String bio
+  }
+}
+interface Repo
+note right of User
+  多行注释
+end note
+User *-- Profile
+User -[#red]-> Account : owns
+UserRepo ..|> Repo
+Account <|-- Admin
+User : +Email : string
+@enduml
+ignored after end`
+	d, err := Import(FormatPlantUML, src)
+	require.NoError(t, err)
+
+	user := d.Find("User")
+	require.NotNil(t, user)
+	assert.Equal(t, "model", user.Package)
+	assert.Equal(t, []Field{{Name: "ID", Type: "int64"}, {Name: "name", Type: "string"}, {Name: "Email", Type: "string"}}, user.Fields)
+	profile := d.Find("Profile<T>")
+	require.NotNil(t, profile)
+	assert.Equal(t, []Field{{Name: "bio", Type: "String"}}, profile.Fields)
+
+	assert.Equal(t, []Connection{
+		{From: "User", To: "Profile<T>", Type: ConnEmbed},
+		{From: "User", To: "Account", Type: ConnField, Label: "owns"},
+		{From: "UserRepo", To: "Repo", Type: ConnImplement},
+		{From: "Admin", To: "Account", Type: ConnEmbed},
+	}, d.Connections)
+}
+
+func TestImportPlantUML_Errors(t *testing.T) {
+	_, err := Import(FormatPlantUML, "@startuml\nclass A {\n  +x : int\n}\nwhat is this\npackage p {\n@enduml")
+	var errs SyntaxErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, 5, errs[0].Line)
+	assert.Contains(t, errs[1].Message, "package p is not closed")
+}
+
+func TestImportPlantUML_RoundTrip(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+	out, err := Export(d, FormatPlantUML, Options{})
+	require.NoError(t, err)
+
+	imported, err := Import(FormatPlantUML, string(out))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, structNames(d), structNames(imported))
+	assert.Equal(t, d.Find("Server").Fields, imported.Find("Server").Fields)
+	assert.Contains(t, imported.Connections, Connection{From: "Service", To: "Repo", Type: ConnField, Label: "uses"})
+}
+
+func TestImportGo(t *testing.T) {
+	src := `type Server struct {
+	*Base
+	handler *Handler ` + "`json:\"handler\"`" + `
+	routes  map[string][]*Route
+	log     log.Logger
+	a, b    int
+}
+
+type Handler struct{ svc Service }
+
+type Route struct{}
+type Base struct{}
+type Service interface{ Do() }
+`
+	d, err := Import(FormatGo, src)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Server", "Handler", "Route", "Base"}, structNames(d))
+	server := d.Find("Server")
+	assert.Empty(t, server.Package)
+	assert.Equal(t, []Field{
+		{Name: "Base", Type: "*Base"},
+		{Name: "handler", Type: "*Handler", Tag: `json:"handler"`},
+		{Name: "routes", Type: "map[string][]*Route"},
+		{Name: "log", Type: "log.Logger"},
+		{Name: "a", Type: "int"},
+		{Name: "b", Type: "int"},
+	}, server.Fields)
+	assert.Equal(t, []Connection{
+		{From: "Server", To: "Base", Type: ConnEmbed},
+		{From: "Server", To: "Handler", Type: ConnField},
+		{From: "Server", To: "Route", Type: ConnField},
+	}, d.Connections)
+
+	d, err = Import(FormatGo, "package model\n\ntype A struct{ B B }\ntype B struct{}")
+	require.NoError(t, err)
+	assert.Equal(t, "model", d.Find("A").Package)
+}
+
+func TestImportGo_Errors(t *testing.T) {
+	// 省略 package 声明时行号与原片段一致
+	_, err := Import(FormatGo, "type A struct {\n\tx int\n\ty int int\n}\n")
+	var errs SyntaxErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, 3, errs[0].Line)
+
+	_, err = Import(FormatGo, "func main() {}")
+	require.ErrorAs(t, err, &errs)
+	assert.Contains(t, errs[0].Message, "no class")
+
+	_, err = Import("uml", "")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestDiagram_Validate(t *testing.T) {
+	d := &Diagram{
+		Structs:     []Struct{{Name: "A", Fields: []Field{{Type: "int"}}}, {Name: "A"}, {}},
+		Connections: []Connection{{From: "A", To: "B"}, {From: "A"}},
+	}
+	err := d.Validate()
+	var errs ValidationError
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, ValidationError{
+		{Field: "structs[0].fields[0].name", Message: "is required"},
+		{Field: "structs[1].name", Message: `duplicate struct "A" (also structs[0])`},
+		{Field: "structs[2].name", Message: "is required"},
+		{Field: "connections[0].to", Message: `unknown struct "B"`},
+		{Field: "connections[1].to", Message: "is required"},
+	}, errs)
+
+	assert.NoError(t, (&Diagram{}).Validate())
+}
+
+func structNames(d *Diagram) []string {
+	var names []string
+	for _, s := range d.Structs {
+		names = append(names, s.Name)
+	}
+	return names
+}
@@ -0,0 +1,229 @@
+package diagram
+
+import (
+	"math"
+	"sort"
+)
+
+// topN 中心度、最大结构体等排行保留的条数
+const topN = 10
+
+// Metrics 框图的架构指标
+type Metrics struct {
+	StructCount     int              `json:"struct_count"`
+	ConnectionCount int              `json:"connection_count"`
+	PackageCount    int              `json:"package_count"`
+	MaxFanIn        int              `json:"max_fan_in"`
+	MaxFanOut       int              `json:"max_fan_out"`
+	AvgFanOut       float64          `json:"avg_fan_out"`
+	Structs         []StructMetrics  `json:"structs"`  // 每个结构体的扇入扇出，按框图顺序
+	Central         []StructMetrics  `json:"central"`  // 中介中心度最高的结构体
+	Largest         []StructMetrics  `json:"largest"`  // 字段最多的结构体
+	Cycles          [][]string       `json:"cycles"`   // 循环依赖，每组为互相依赖的结构体
+	Packages        []PackageMetrics `json:"packages"` // 包之间的耦合，按包名排序
+}
+
+// StructMetrics 单个结构体的指标
+type StructMetrics struct {
+	Key        string  `json:"key"`
+	Name       string  `json:"name"`
+	Package    string  `json:"package,omitempty"`
+	FanIn      int     `json:"fan_in"`     // 依赖它的结构体数
+	FanOut     int     `json:"fan_out"`    // 它依赖的结构体数
+	Centrality float64 `json:"centrality"` // 归一化的中介中心度，0-1
+	Fields     int     `json:"fields"`
+}
+
+// PackageMetrics 包的耦合指标
+type PackageMetrics struct {
+	Name        string  `json:"name"`
+	Structs     int     `json:"structs"`
+	Afferent    int     `json:"afferent"`    // Ca：依赖本包的其他包数量
+	Efferent    int     `json:"efferent"`    // Ce：本包依赖的其他包数量
+	Instability float64 `json:"instability"` // I = Ce / (Ca + Ce)，0 表示最稳定
+}
+
+// ComputeMetrics 计算扇入扇出、中心度、循环依赖、包耦合和最大结构体
+// 同一对结构体之间的多条连接只计一次，忽略端点不存在的连接和自环
+func ComputeMetrics(d *Diagram) *Metrics {
+	out, in := d.dependencies()
+
+	m := &Metrics{
+		StructCount: len(d.Structs),
+		Structs:     make([]StructMetrics, 0, len(d.Structs)),
+		Cycles:      d.cycles().Cycles,
+		Packages:    []PackageMetrics{},
+	}
+	if m.Cycles == nil {
+		m.Cycles = [][]string{}
+	}
+
+	centrality := d.betweenness(out)
+	for _, s := range d.Structs {
+		key := s.Key()
+		sm := StructMetrics{
+			Key:        key,
+			Name:       s.Label(),
+			Package:    s.Package,
+			FanIn:      len(in[key]),
+			FanOut:     len(out[key]),
+			Centrality: round(centrality[key]),
+			Fields:     len(s.Fields),
+		}
+		m.ConnectionCount += sm.FanOut
+		m.MaxFanIn = max(m.MaxFanIn, sm.FanIn)
+		m.MaxFanOut = max(m.MaxFanOut, sm.FanOut)
+		m.Structs = append(m.Structs, sm)
+	}
+	if m.StructCount > 0 {
+		m.AvgFanOut = round(float64(m.ConnectionCount) / float64(m.StructCount))
+	}
+
+	m.Central = rank(m.Structs, func(a, b StructMetrics) bool { return a.Centrality > b.Centrality }, func(s StructMetrics) bool { return s.Centrality > 0 })
+	m.Largest = rank(m.Structs, func(a, b StructMetrics) bool { return a.Fields > b.Fields }, func(s StructMetrics) bool { return s.Fields > 0 })
+	m.Packages = d.packageMetrics(out)
+	m.PackageCount = len(m.Packages)
+	return m
+}
+
+// dependencies 去重后的依赖关系，out[a] 为 a 依赖的结构体，in[a] 为依赖 a 的结构体
+func (d *Diagram) dependencies() (out, in map[string][]string) {
+	adjOut, _ := d.adjacency()
+	out = make(map[string][]string, len(adjOut))
+	in = make(map[string][]string)
+	for _, s := range d.Structs {
+		from := s.Key()
+		seen := make(map[string]bool)
+		for _, to := range adjOut[from] {
+			if to == from || seen[to] {
+				continue
+			}
+			seen[to] = true
+			out[from] = append(out[from], to)
+			in[to] = append(in[to], from)
+		}
+	}
+	return out, in
+}
+
+// betweenness 用 Brandes 算法计算有向图的中介中心度，按 (n-1)(n-2) 归一化
+func (d *Diagram) betweenness(out map[string][]string) map[string]float64 {
+	n := len(d.Structs)
+	cb := make(map[string]float64, n)
+	if n < 3 {
+		return cb
+	}
+
+	for _, src := range d.Structs {
+		s := src.Key()
+		var order []string
+		preds := make(map[string][]string)
+		sigma := map[string]float64{s: 1}
+		dist := map[string]int{s: 0}
+		queue := []string{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			order = append(order, v)
+			for _, w := range out[v] {
+				if _, ok := dist[w]; !ok {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+
+		delta := make(map[string]float64, len(order))
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				cb[w] += delta[w]
+			}
+		}
+	}
+
+	norm := float64((n - 1) * (n - 2))
+	for k, v := range cb {
+		cb[k] = v / norm
+	}
+	return cb
+}
+
+// packageMetrics 按结构体所属包汇总包之间的依赖
+func (d *Diagram) packageMetrics(out map[string][]string) []PackageMetrics {
+	pkgOf := make(map[string]string, len(d.Structs))
+	counts := make(map[string]int)
+	for _, s := range d.Structs {
+		pkgOf[s.Key()] = s.Package
+		counts[s.Package]++
+	}
+	if len(counts) == 1 && counts[""] > 0 {
+		// 没有包信息
+		return []PackageMetrics{}
+	}
+
+	efferent := make(map[string]map[string]bool)
+	afferent := make(map[string]map[string]bool)
+	for from, targets := range out {
+		for _, to := range targets {
+			pf, pt := pkgOf[from], pkgOf[to]
+			if pf == pt {
+				continue
+			}
+			if efferent[pf] == nil {
+				efferent[pf] = make(map[string]bool)
+			}
+			if afferent[pt] == nil {
+				afferent[pt] = make(map[string]bool)
+			}
+			efferent[pf][pt] = true
+			afferent[pt][pf] = true
+		}
+	}
+
+	pkgs := make([]PackageMetrics, 0, len(counts))
+	for name, n := range counts {
+		pm := PackageMetrics{Name: name, Structs: n, Afferent: len(afferent[name]), Efferent: len(efferent[name])}
+		if total := pm.Afferent + pm.Efferent; total > 0 {
+			pm.Instability = round(float64(pm.Efferent) / float64(total))
+		}
+		pkgs = append(pkgs, pm)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs
+}
+
+// rank 按 less 排序后取前 topN 个满足 keep 的结构体，相同时按名称排序
+func rank(structs []StructMetrics, less func(a, b StructMetrics) bool, keep func(StructMetrics) bool) []StructMetrics {
+	ranked := make([]StructMetrics, 0, len(structs))
+	for _, s := range structs {
+		if keep(s) {
+			ranked = append(ranked, s)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if less(ranked[i], ranked[j]) {
+			return true
+		}
+		if less(ranked[j], ranked[i]) {
+			return false
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+	return ranked
+}
+
+// round 保留 4 位小数
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
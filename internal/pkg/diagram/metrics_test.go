@@ -0,0 +1,67 @@
+package diagram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeMetrics(t *testing.T) {
+	d, err := Parse([]byte(`{"structs":[
+		{"name":"Server","package":"app","fields":[{"name":"h"},{"name":"cfg"},{"name":"log"}]},
+		{"name":"Handler","package":"api","fields":[{"name":"svc"}]},
+		{"name":"Service","package":"service","fields":[{"name":"repo"},{"name":"cache"}]},
+		{"name":"Repo","package":"repository"},
+		{"name":"Cache","package":"service"}
+	],"connections":[
+		{"from":"Server","to":"Handler"},
+		{"from":"Server","to":"Handler","type":"embed"},
+		{"from":"Handler","to":"Service"},
+		{"from":"Service","to":"Repo"},
+		{"from":"Service","to":"Cache"},
+		{"from":"Cache","to":"Service"},
+		{"from":"Repo","to":"Repo"},
+		{"from":"Repo","to":"Missing"}
+	]}`))
+	require.NoError(t, err)
+
+	m := ComputeMetrics(d)
+	assert.Equal(t, 5, m.StructCount)
+	// 重复连接、自环和悬空连接不计入
+	assert.Equal(t, 5, m.ConnectionCount)
+	assert.Equal(t, 1.0, m.AvgFanOut)
+	assert.Equal(t, 2, m.MaxFanIn)
+	assert.Equal(t, 2, m.MaxFanOut)
+
+	service := m.Structs[2]
+	assert.Equal(t, StructMetrics{Key: "Service", Name: "Service", Package: "service", FanIn: 2, FanOut: 2, Centrality: 0.4167, Fields: 2}, service)
+	assert.Equal(t, []string{"Service", "Handler"}, []string{m.Central[0].Name, m.Central[1].Name})
+	assert.Equal(t, []string{"Server", "Service", "Handler"}, []string{m.Largest[0].Name, m.Largest[1].Name, m.Largest[2].Name})
+
+	// Repo 的自环也算循环依赖
+	assert.Equal(t, [][]string{{"Cache", "Service"}, {"Repo"}}, m.Cycles)
+
+	assert.Equal(t, 4, m.PackageCount)
+	assert.Equal(t, []PackageMetrics{
+		{Name: "api", Structs: 1, Afferent: 1, Efferent: 1, Instability: 0.5},
+		{Name: "app", Structs: 1, Afferent: 0, Efferent: 1, Instability: 1},
+		{Name: "repository", Structs: 1, Afferent: 1, Efferent: 0, Instability: 0},
+		{Name: "service", Structs: 2, Afferent: 1, Efferent: 1, Instability: 0.5},
+	}, m.Packages)
+}
+
+func TestComputeMetrics_Empty(t *testing.T) {
+	m := ComputeMetrics(&Diagram{})
+	assert.Zero(t, m.StructCount)
+	assert.Empty(t, m.Structs)
+	assert.NotNil(t, m.Cycles)
+	assert.Empty(t, m.Packages)
+
+	// 没有包信息时不统计包耦合
+	d, err := Parse([]byte(`{"structs":[{"name":"A"},{"name":"B"},{"name":"C"}],"connections":[{"from":"A","to":"B"},{"from":"B","to":"C"}]}`))
+	require.NoError(t, err)
+	m = ComputeMetrics(d)
+	assert.Zero(t, m.PackageCount)
+	assert.Equal(t, 0.5, m.Structs[1].Centrality)
+}
@@ -0,0 +1,109 @@
+package diagram
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// migrations[v] 把版本 v 的文档原地升级到 v+1
+var migrations = map[int]func(doc map[string]interface{}){
+	1: migrateV1,
+}
+
+// Migrate 把旧版本框图升级到 SchemaVersion，返回升级后的 JSON 和原始版本
+// 已是当前版本时原样返回；未知字段在升级过程中保留
+func Migrate(data []byte) ([]byte, int, error) {
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidDiagram, err)
+	}
+	from, err := migrateDocument(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from == SchemaVersion {
+		return data, from, nil
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, from, nil
+}
+
+// migrateDocument 逐版本升级文档，返回原始版本；没有 version 字段的视为 v1
+func migrateDocument(doc map[string]interface{}) (int, error) {
+	version := 1
+	if v, ok := doc["version"]; ok {
+		n, isInt := intValue(v)
+		if !isInt || n < 1 {
+			return 0, fmt.Errorf("invalid schema version %v", v)
+		}
+		version = int(n)
+	}
+	if version > SchemaVersion {
+		return 0, fmt.Errorf("unsupported schema version %d (latest %d)", version, SchemaVersion)
+	}
+
+	from := version
+	for ; version < SchemaVersion; version++ {
+		migrations[version](doc)
+	}
+	doc["version"] = json.Number(fmt.Sprint(SchemaVersion))
+	return from, nil
+}
+
+// migrateV1 v1 是没有版本号的旧格式：nodes/edges 别名、source/target 端点、label 作为名称、数字 id
+func migrateV1(doc map[string]interface{}) {
+	rename(doc, "nodes", "structs")
+	rename(doc, "edges", "connections")
+	for _, key := range []string{"structs", "connections"} {
+		if _, ok := doc[key]; !ok {
+			doc[key] = []interface{}{}
+		}
+	}
+
+	if structs, ok := doc["structs"].([]interface{}); ok {
+		for _, item := range structs {
+			s, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			stringifyID(s, "id")
+			if _, ok := s["name"]; !ok {
+				rename(s, "label", "name")
+			}
+		}
+	}
+	if connections, ok := doc["connections"].([]interface{}); ok {
+		for _, item := range connections {
+			c, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rename(c, "source", "from")
+			rename(c, "target", "to")
+			stringifyID(c, "from")
+			stringifyID(c, "to")
+		}
+	}
+}
+
+// rename 在 to 不存在时把 from 字段改名为 to
+func rename(m map[string]interface{}, from, to string) {
+	v, ok := m[from]
+	if !ok {
+		return
+	}
+	if _, exists := m[to]; !exists {
+		m[to] = v
+		delete(m, from)
+	}
+}
+
+// stringifyID 数字形式的 id 转为字符串
+func stringifyID(m map[string]interface{}, key string) {
+	if n, ok := m[key].(json.Number); ok {
+		m[key] = n.String()
+	}
+}
@@ -0,0 +1,282 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrStructNotFound 查询的结构体不在框图中
+	ErrStructNotFound = errors.New("struct not found")
+	// ErrNoPath 两个结构体之间没有依赖路径
+	ErrNoPath = errors.New("no dependency path")
+	// ErrInvalidQuery 查询类型、方向或跳数不合法
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
+// 查询类型
+const (
+	QueryNeighbors = "neighbors"
+	QueryPath      = "path"
+	QuerySubgraph  = "subgraph"
+	QueryCycles    = "cycles"
+)
+
+// 查询方向，连接 From -> To 表示 From 依赖 To
+const (
+	DirectionOut  = "out"  // 依赖：该结构体引用的结构体
+	DirectionIn   = "in"   // 被依赖：引用该结构体的结构体
+	DirectionBoth = "both" // 两个方向
+)
+
+// MaxHops 子图查询允许的最大跳数
+const MaxHops = 5
+
+// Query 框图上的图查询
+type Query struct {
+	Type      string
+	Struct    string // neighbors/subgraph 的中心节点
+	From      string // path 的起点
+	To        string // path 的终点
+	Hops      int    // subgraph 的跳数
+	Direction string // neighbors/subgraph 的方向，默认 both
+}
+
+// QueryResult 查询结果，与 diagram_data 格式相同，前端可直接渲染
+type QueryResult struct {
+	Version int `json:"version"`
+	*Diagram
+	Path   []string   `json:"path,omitempty"`   // path 查询：路径上依次经过的节点
+	Cycles [][]string `json:"cycles,omitempty"` // cycles 查询：每个元素为一组互相依赖的节点
+}
+
+// Query 执行图查询，节点可以按 Key 或名称指定
+func (d *Diagram) Query(q Query) (*QueryResult, error) {
+	switch q.Type {
+	case QueryNeighbors:
+		return d.subgraph(q.Struct, 1, q.Direction)
+	case QuerySubgraph:
+		return d.subgraph(q.Struct, q.Hops, q.Direction)
+	case QueryPath:
+		return d.shortestPath(q.From, q.To)
+	case QueryCycles:
+		return d.cycles(), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q", ErrInvalidQuery, q.Type)
+	}
+}
+
+// adjacency 邻接表，忽略端点不存在的连接
+func (d *Diagram) adjacency() (out, in map[string][]string) {
+	exists := make(map[string]bool, len(d.Structs))
+	for _, s := range d.Structs {
+		exists[s.Key()] = true
+	}
+	out = make(map[string][]string)
+	in = make(map[string][]string)
+	for _, c := range d.Connections {
+		if exists[c.From] && exists[c.To] {
+			out[c.From] = append(out[c.From], c.To)
+			in[c.To] = append(in[c.To], c.From)
+		}
+	}
+	return out, in
+}
+
+// subgraph 从 key 出发按方向 BFS，返回 hops 跳内的节点及它们之间的连接
+func (d *Diagram) subgraph(key string, hops int, direction string) (*QueryResult, error) {
+	s := d.Find(key)
+	if s == nil {
+		return nil, fmt.Errorf("%w: %s", ErrStructNotFound, key)
+	}
+	if hops < 1 || hops > MaxHops {
+		return nil, fmt.Errorf("%w: hops must be between 1 and %d", ErrInvalidQuery, MaxHops)
+	}
+	if direction == "" {
+		direction = DirectionBoth
+	}
+	if direction != DirectionOut && direction != DirectionIn && direction != DirectionBoth {
+		return nil, fmt.Errorf("%w: unsupported direction %q", ErrInvalidQuery, direction)
+	}
+
+	out, in := d.adjacency()
+	visited := map[string]bool{s.Key(): true}
+	frontier := []string{s.Key()}
+	for i := 0; i < hops && len(frontier) > 0; i++ {
+		var next []string
+		for _, cur := range frontier {
+			var neighbors []string
+			if direction != DirectionIn {
+				neighbors = append(neighbors, out[cur]...)
+			}
+			if direction != DirectionOut {
+				neighbors = append(neighbors, in[cur]...)
+			}
+			for _, n := range neighbors {
+				if !visited[n] {
+					visited[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+	return &QueryResult{Version: SchemaVersion, Diagram: d.induced(visited)}, nil
+}
+
+// shortestPath 沿依赖方向（From -> To）求最短路径，结果只包含路径上的节点和连接
+func (d *Diagram) shortestPath(from, to string) (*QueryResult, error) {
+	src, dst := d.Find(from), d.Find(to)
+	if src == nil {
+		return nil, fmt.Errorf("%w: %s", ErrStructNotFound, from)
+	}
+	if dst == nil {
+		return nil, fmt.Errorf("%w: %s", ErrStructNotFound, to)
+	}
+
+	out, _ := d.adjacency()
+	prev := map[string]string{src.Key(): ""}
+	queue := []string{src.Key()}
+	for len(queue) > 0 && !hasKey(prev, dst.Key()) {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range out[cur] {
+			if !hasKey(prev, next) {
+				prev[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	if !hasKey(prev, dst.Key()) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoPath, from, to)
+	}
+
+	var path []string
+	for cur := dst.Key(); cur != src.Key(); cur = prev[cur] {
+		path = append(path, cur)
+	}
+	path = append(path, src.Key())
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	// 只保留路径上相邻节点之间的第一条连接
+	keep := make(map[string]bool, len(path))
+	for _, k := range path {
+		keep[k] = true
+	}
+	result := &Diagram{Structs: d.structsIn(keep), Connections: []Connection{}}
+	for i := 0; i+1 < len(path); i++ {
+		for _, c := range d.Connections {
+			if c.From == path[i] && c.To == path[i+1] {
+				result.Connections = append(result.Connections, c)
+				break
+			}
+		}
+	}
+	return &QueryResult{Version: SchemaVersion, Diagram: result, Path: path}, nil
+}
+
+// cycles 用 Tarjan 算法找出强连通分量，节点数大于 1 或有自环的分量即为循环依赖
+// 结果包含参与循环的节点和分量内部的连接
+func (d *Diagram) cycles() *QueryResult {
+	out, _ := d.adjacency()
+	index := make(map[string]int, len(d.Structs))
+	low := make(map[string]int, len(d.Structs))
+	onStack := make(map[string]bool)
+	var stack []string
+	var groups [][]string
+
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		low[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range out[v] {
+			if _, seen := index[w]; !seen {
+				visit(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var group []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			group = append(group, w)
+			if w == v {
+				break
+			}
+		}
+		if len(group) > 1 || selfLoop(out, v) {
+			sort.Strings(group)
+			groups = append(groups, group)
+		}
+	}
+	for _, s := range d.Structs {
+		if _, seen := index[s.Key()]; !seen {
+			visit(s.Key())
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	// 只保留同一分量内部的连接
+	component := make(map[string]int)
+	keep := make(map[string]bool)
+	for i, g := range groups {
+		for _, k := range g {
+			component[k] = i
+			keep[k] = true
+		}
+	}
+	result := &Diagram{Structs: d.structsIn(keep), Connections: []Connection{}}
+	for _, c := range d.Connections {
+		if keep[c.From] && keep[c.To] && component[c.From] == component[c.To] {
+			result.Connections = append(result.Connections, c)
+		}
+	}
+	return &QueryResult{Version: SchemaVersion, Diagram: result, Cycles: groups}
+}
+
+// induced 返回只包含 keep 中节点及它们之间连接的子图
+func (d *Diagram) induced(keep map[string]bool) *Diagram {
+	result := &Diagram{Structs: d.structsIn(keep), Connections: []Connection{}}
+	for _, c := range d.Connections {
+		if keep[c.From] && keep[c.To] {
+			result.Connections = append(result.Connections, c)
+		}
+	}
+	return result
+}
+
+// structsIn 按原顺序返回 keep 中的节点
+func (d *Diagram) structsIn(keep map[string]bool) []Struct {
+	structs := []Struct{}
+	for _, s := range d.Structs {
+		if keep[s.Key()] {
+			structs = append(structs, s)
+		}
+	}
+	return structs
+}
+
+func selfLoop(out map[string][]string, v string) bool {
+	for _, w := range out[v] {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasKey(m map[string]string, k string) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,104 @@
+package diagram
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagram_Query_Neighbors(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+
+	result, err := d.Query(Query{Type: QueryNeighbors, Struct: "Handler"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Server", "Handler", "Service"}, structNames(result.Diagram))
+	assert.Len(t, result.Connections, 2)
+
+	result, err = d.Query(Query{Type: QueryNeighbors, Struct: "Handler", Direction: DirectionOut})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Handler", "Service"}, structNames(result.Diagram))
+	assert.Equal(t, []Connection{{From: "Handler", To: "Service", Type: ConnEmbed}}, result.Connections)
+
+	result, err = d.Query(Query{Type: QueryNeighbors, Struct: "Handler", Direction: DirectionIn})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Server", "Handler"}, structNames(result.Diagram))
+
+	_, err = d.Query(Query{Type: QueryNeighbors, Struct: "Missing"})
+	assert.ErrorIs(t, err, ErrStructNotFound)
+	_, err = d.Query(Query{Type: QueryNeighbors, Struct: "Handler", Direction: "up"})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestDiagram_Query_Subgraph(t *testing.T) {
+	d, err := Parse([]byte(testDiagram))
+	require.NoError(t, err)
+
+	result, err := d.Query(Query{Type: QuerySubgraph, Struct: "Server", Hops: 2, Direction: DirectionOut})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Server", "Handler", "Service"}, structNames(result.Diagram))
+
+	result, err = d.Query(Query{Type: QuerySubgraph, Struct: "Repo", Hops: MaxHops})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Server", "Handler", "Service", "Repo"}, structNames(result.Diagram))
+	assert.Len(t, result.Connections, 3)
+
+	// 孤立节点只返回自身
+	result, err = d.Query(Query{Type: QuerySubgraph, Struct: "Orphan<T>", Hops: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Orphan<T>"}, structNames(result.Diagram))
+	assert.Empty(t, result.Connections)
+
+	_, err = d.Query(Query{Type: QuerySubgraph, Struct: "Server", Hops: MaxHops + 1})
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestDiagram_Query_Path(t *testing.T) {
+	d, err := Parse([]byte(`{"structs":[{"name":"A"},{"name":"B"},{"name":"C"},{"name":"D"}],
+		"connections":[{"from":"A","to":"B"},{"from":"B","to":"C"},{"from":"C","to":"D"},{"from":"A","to":"C","label":"short"}]}`))
+	require.NoError(t, err)
+
+	result, err := d.Query(Query{Type: QueryPath, From: "A", To: "D"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "C", "D"}, result.Path)
+	assert.Equal(t, []string{"A", "C", "D"}, structNames(result.Diagram))
+	assert.Equal(t, []Connection{{From: "A", To: "C", Label: "short"}, {From: "C", To: "D"}}, result.Connections)
+
+	// 只沿依赖方向查找
+	_, err = d.Query(Query{Type: QueryPath, From: "D", To: "A"})
+	assert.ErrorIs(t, err, ErrNoPath)
+
+	result, err = d.Query(Query{Type: QueryPath, From: "B", To: "B"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"B"}, result.Path)
+
+	_, err = d.Query(Query{Type: QueryPath, From: "A", To: "X"})
+	assert.ErrorIs(t, err, ErrStructNotFound)
+}
+
+func TestDiagram_Query_Cycles(t *testing.T) {
+	d, err := Parse([]byte(`{"structs":[{"name":"A"},{"name":"B"},{"name":"C"},{"name":"D"},{"name":"E"}],
+		"connections":[{"from":"A","to":"B"},{"from":"B","to":"C"},{"from":"C","to":"A"},{"from":"C","to":"D"},{"from":"E","to":"E"}]}`))
+	require.NoError(t, err)
+
+	result, err := d.Query(Query{Type: QueryCycles})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"A", "B", "C"}, {"E"}}, result.Cycles)
+	assert.Equal(t, []string{"A", "B", "C", "E"}, structNames(result.Diagram))
+	assert.Len(t, result.Connections, 4)
+
+	// 结果可以直接作为 diagram_data 使用
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	_, err = ValidateJSON(data, Limits{})
+	assert.NoError(t, err)
+
+	d, err = Parse([]byte(testDiagram))
+	require.NoError(t, err)
+	result, err = d.Query(Query{Type: QueryCycles})
+	require.NoError(t, err)
+	assert.Empty(t, result.Cycles)
+	assert.Empty(t, result.Structs)
+}
@@ -0,0 +1,141 @@
+package diagram
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidRule 规则类型未知或参数不完整
+var ErrInvalidRule = errors.New("invalid rule")
+
+// 规则类型
+const (
+	RuleNoDependency = "no_dependency" // From 包不能依赖 To 包
+	RuleNoCycles     = "no_cycles"     // 不能有循环依赖
+	RuleMaxFanOut    = "max_fan_out"   // 每个结构体依赖的结构体数不超过 Max
+	RuleMaxFanIn     = "max_fan_in"    // 每个结构体被依赖的次数不超过 Max
+)
+
+// Rule 架构规则，From/To 为包名，支持 path.Match 通配符（如 internal/*），a/... 匹配 a 及其子包
+type Rule struct {
+	Type string `json:"type"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	Max  int    `json:"max,omitempty"`
+}
+
+// Validate 检查规则参数
+func (r Rule) Validate() error {
+	switch r.Type {
+	case RuleNoDependency:
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf("%w: %s requires from and to", ErrInvalidRule, r.Type)
+		}
+		for _, pattern := range []string{r.From, r.To} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%w: bad package pattern %q", ErrInvalidRule, pattern)
+			}
+		}
+	case RuleNoCycles:
+	case RuleMaxFanOut, RuleMaxFanIn:
+		if r.Max < 1 {
+			return fmt.Errorf("%w: %s requires max >= 1", ErrInvalidRule, r.Type)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidRule, r.Type)
+	}
+	return nil
+}
+
+// String 规则的可读描述
+func (r Rule) String() string {
+	switch r.Type {
+	case RuleNoDependency:
+		return fmt.Sprintf("package %s must not depend on %s", r.From, r.To)
+	case RuleNoCycles:
+		return "no dependency cycles"
+	case RuleMaxFanOut:
+		return fmt.Sprintf("max fan-out %d", r.Max)
+	case RuleMaxFanIn:
+		return fmt.Sprintf("max fan-in %d", r.Max)
+	}
+	return r.Type
+}
+
+// Violation 违反规则的一处依赖或结构体
+type Violation struct {
+	Rule    int      `json:"rule"` // 规则在列表中的下标
+	Type    string   `json:"type"`
+	Message string   `json:"message"`
+	Structs []string `json:"structs"` // 涉及的结构体
+}
+
+// CheckResult 规则检查结果
+type CheckResult struct {
+	Passed     bool        `json:"passed"`
+	Rules      int         `json:"rules"`
+	Violations []Violation `json:"violations"`
+}
+
+// Check 按顺序检查所有规则，参数不合法的规则会返回错误
+func Check(d *Diagram, rules []Rule) (*CheckResult, error) {
+	result := &CheckResult{Rules: len(rules), Violations: []Violation{}}
+	out, in := d.dependencies()
+	for i, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		add := func(structs []string, format string, args ...interface{}) {
+			result.Violations = append(result.Violations, Violation{
+				Rule:    i,
+				Type:    r.Type,
+				Message: fmt.Sprintf(format, args...),
+				Structs: structs,
+			})
+		}
+
+		switch r.Type {
+		case RuleNoDependency:
+			for _, s := range d.Structs {
+				if !matchPackage(r.From, s.Package) {
+					continue
+				}
+				for _, to := range out[s.Key()] {
+					target := d.Find(to)
+					if target.Package != s.Package && matchPackage(r.To, target.Package) {
+						add([]string{s.Key(), to}, "%s (%s) depends on %s (%s)", s.Label(), s.Package, target.Label(), target.Package)
+					}
+				}
+			}
+		case RuleNoCycles:
+			for _, group := range d.cycles().Cycles {
+				add(group, "dependency cycle: %s", strings.Join(group, ", "))
+			}
+		case RuleMaxFanOut, RuleMaxFanIn:
+			structs := append([]Struct(nil), d.Structs...)
+			sort.SliceStable(structs, func(a, b int) bool { return structs[a].Label() < structs[b].Label() })
+			for _, s := range structs {
+				if n := len(out[s.Key()]); r.Type == RuleMaxFanOut && n > r.Max {
+					add([]string{s.Key()}, "%s has fan-out %d (max %d)", s.Label(), n, r.Max)
+				}
+				if n := len(in[s.Key()]); r.Type == RuleMaxFanIn && n > r.Max {
+					add([]string{s.Key()}, "%s has fan-in %d (max %d)", s.Label(), n, r.Max)
+				}
+			}
+		}
+	}
+	result.Passed = len(result.Violations) == 0
+	return result, nil
+}
+
+// matchPackage 包名匹配规则中的模式，模式 a/... 匹配 a 及其子包
+func matchPackage(pattern, pkg string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return pkg == prefix || strings.HasPrefix(pkg, prefix+"/")
+	}
+	ok, _ := path.Match(pattern, pkg)
+	return ok
+}
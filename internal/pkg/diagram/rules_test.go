@@ -0,0 +1,60 @@
+package diagram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	d, err := Parse([]byte(`{"structs":[
+		{"name":"Handler","package":"internal/api/handler"},
+		{"name":"Service","package":"internal/service"},
+		{"name":"Repo","package":"internal/repository"},
+		{"name":"Cache","package":"internal/service"}
+	],"connections":[
+		{"from":"Handler","to":"Service"},
+		{"from":"Handler","to":"Repo"},
+		{"from":"Service","to":"Repo"},
+		{"from":"Service","to":"Cache"},
+		{"from":"Cache","to":"Service"}
+	]}`))
+	require.NoError(t, err)
+
+	result, err := Check(d, []Rule{
+		{Type: RuleNoDependency, From: "internal/api/...", To: "internal/repository"},
+		{Type: RuleNoDependency, From: "internal/service", To: "internal/*"}, // 同包依赖不算
+		{Type: RuleNoCycles},
+		{Type: RuleMaxFanOut, Max: 1},
+		{Type: RuleMaxFanIn, Max: 2},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Equal(t, 5, result.Rules)
+	assert.Equal(t, []Violation{
+		{Rule: 0, Type: RuleNoDependency, Message: "Handler (internal/api/handler) depends on Repo (internal/repository)", Structs: []string{"Handler", "Repo"}},
+		{Rule: 1, Type: RuleNoDependency, Message: "Service (internal/service) depends on Repo (internal/repository)", Structs: []string{"Service", "Repo"}},
+		{Rule: 2, Type: RuleNoCycles, Message: "dependency cycle: Cache, Service", Structs: []string{"Cache", "Service"}},
+		{Rule: 3, Type: RuleMaxFanOut, Message: "Handler has fan-out 2 (max 1)", Structs: []string{"Handler"}},
+		{Rule: 3, Type: RuleMaxFanOut, Message: "Service has fan-out 2 (max 1)", Structs: []string{"Service"}},
+	}, result.Violations)
+
+	result, err = Check(d, []Rule{{Type: RuleNoDependency, From: "internal/repository", To: "internal/api/..."}})
+	require.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.Empty(t, result.Violations)
+
+	_, err = Check(d, []Rule{{Type: RuleMaxFanOut}})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestRule_Validate(t *testing.T) {
+	assert.NoError(t, Rule{Type: RuleNoCycles}.Validate())
+	assert.NoError(t, Rule{Type: RuleNoDependency, From: "a/...", To: "b*"}.Validate())
+	assert.ErrorIs(t, Rule{Type: RuleNoDependency, From: "a"}.Validate(), ErrInvalidRule)
+	assert.ErrorIs(t, Rule{Type: RuleNoDependency, From: "a", To: "[b"}.Validate(), ErrInvalidRule)
+	assert.ErrorIs(t, Rule{Type: RuleMaxFanIn, Max: 0}.Validate(), ErrInvalidRule)
+	assert.ErrorIs(t, Rule{Type: "max_depth"}.Validate(), ErrInvalidRule)
+	assert.Equal(t, "package a must not depend on b", Rule{Type: RuleNoDependency, From: "a", To: "b"}.String())
+}
@@ -0,0 +1,209 @@
+package diagram
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion 当前框图格式版本，写入时统一升级到该版本
+const SchemaVersion = 2
+
+// maxFieldErrors 校验错误最多返回的条数，避免超大框图返回过长的错误列表
+const maxFieldErrors = 50
+
+//go:embed schema/v2.json
+var schemaV2 []byte
+
+// Schema 返回当前版本的 JSON Schema 文档
+func Schema() []byte {
+	return schemaV2
+}
+
+// Limits 框图大小限制，0 表示不限制
+type Limits struct {
+	MaxBytes       int
+	MaxStructs     int
+	MaxConnections int
+}
+
+// ValidateJSON 校验写入的框图：检查大小、升级到当前版本、按 Schema 校验字段类型，
+// 再校验节点引用和数量限制，返回升级后的 JSON；校验失败返回 ValidationError
+func ValidateJSON(data []byte, limits Limits) ([]byte, error) {
+	if limits.MaxBytes > 0 && len(data) > limits.MaxBytes {
+		return nil, ValidationError{{Message: fmt.Sprintf("diagram is too large: %d bytes (limit %d)", len(data), limits.MaxBytes)}}
+	}
+
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return nil, ValidationError{{Message: err.Error()}}
+	}
+	if _, err := migrateDocument(doc); err != nil {
+		return nil, ValidationError{{Field: "version", Message: err.Error()}}
+	}
+	if errs := validateSchema(doc); len(errs) > 0 {
+		return nil, errs
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	d, err := Parse(out)
+	if err != nil {
+		return nil, ValidationError{{Message: err.Error()}}
+	}
+	if err := d.Validate(); err != nil {
+		return nil, truncateErrors(err.(ValidationError))
+	}
+
+	var errs ValidationError
+	if limits.MaxStructs > 0 && len(d.Structs) > limits.MaxStructs {
+		errs = append(errs, FieldError{Field: "structs", Message: fmt.Sprintf("too many structs: %d (limit %d)", len(d.Structs), limits.MaxStructs)})
+	}
+	if limits.MaxConnections > 0 && len(d.Connections) > limits.MaxConnections {
+		errs = append(errs, FieldError{Field: "connections", Message: fmt.Sprintf("too many connections: %d (limit %d)", len(d.Connections), limits.MaxConnections)})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// decodeDocument 解析为通用结构，数字保留为 json.Number 以免精度丢失
+func decodeDocument(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a JSON object")
+	}
+	return doc, nil
+}
+
+// validateSchema 按 schema/v2.json 校验字段类型和必填项，未声明的字段不做限制
+func validateSchema(doc map[string]interface{}) ValidationError {
+	var errs ValidationError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if v, ok := intValue(doc["version"]); !ok || v != SchemaVersion {
+		add("version", "must be %d", SchemaVersion)
+	}
+
+	structs, ok := doc["structs"].([]interface{})
+	if !ok {
+		add("structs", "must be an array")
+	}
+	for i, item := range structs {
+		path := fmt.Sprintf("structs[%d]", i)
+		s, ok := item.(map[string]interface{})
+		if !ok {
+			add(path, "must be an object")
+			continue
+		}
+		for _, key := range []string{"id", "name", "package", "description"} {
+			if v, ok := s[key]; ok {
+				if _, isString := v.(string); !isString {
+					add(path+"."+key, "must be a string")
+				}
+			}
+		}
+		name, _ := s["name"].(string)
+		id, _ := s["id"].(string)
+		if name == "" && id == "" {
+			add(path+".name", "is required")
+		}
+		if len([]rune(name)) > 200 {
+			add(path+".name", "must be at most 200 characters")
+		}
+		if v, ok := s["depth"]; ok {
+			if depth, isInt := intValue(v); !isInt || depth < 0 {
+				add(path+".depth", "must be a non-negative integer")
+			}
+		}
+		if v, ok := s["fields"]; ok {
+			fields, isArray := v.([]interface{})
+			if !isArray {
+				add(path+".fields", "must be an array")
+			}
+			for k, fv := range fields {
+				fpath := fmt.Sprintf("%s.fields[%d]", path, k)
+				f, ok := fv.(map[string]interface{})
+				if !ok {
+					add(fpath, "must be an object")
+					continue
+				}
+				if fname, _ := f["name"].(string); fname == "" {
+					add(fpath+".name", "is required")
+				} else if len([]rune(fname)) > 200 {
+					add(fpath+".name", "must be at most 200 characters")
+				}
+				for _, key := range []string{"type", "tag"} {
+					if v, ok := f[key]; ok {
+						if _, isString := v.(string); !isString {
+							add(fpath+"."+key, "must be a string")
+						}
+					}
+				}
+			}
+		}
+		if len(errs) >= maxFieldErrors {
+			return errs[:maxFieldErrors]
+		}
+	}
+
+	connections, ok := doc["connections"].([]interface{})
+	if !ok {
+		add("connections", "must be an array")
+	}
+	for i, item := range connections {
+		path := fmt.Sprintf("connections[%d]", i)
+		c, ok := item.(map[string]interface{})
+		if !ok {
+			add(path, "must be an object")
+			continue
+		}
+		for _, key := range []string{"from", "to"} {
+			if v, _ := c[key].(string); v == "" {
+				add(path+"."+key, "is required")
+			}
+		}
+		for _, key := range []string{"type", "label"} {
+			if v, ok := c[key]; ok {
+				if _, isString := v.(string); !isString {
+					add(path+"."+key, "must be a string")
+				}
+			}
+		}
+		if len(errs) >= maxFieldErrors {
+			return errs[:maxFieldErrors]
+		}
+	}
+	return errs
+}
+
+func intValue(v interface{}) (int64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return i, err == nil
+}
+
+func truncateErrors(errs ValidationError) ValidationError {
+	if len(errs) > maxFieldErrors {
+		return errs[:maxFieldErrors]
+	}
+	return errs
+}
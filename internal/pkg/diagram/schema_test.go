@@ -0,0 +1,133 @@
+package diagram
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema_IsValidJSON(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(Schema(), &doc))
+	assert.Equal(t, float64(SchemaVersion), doc["properties"].(map[string]interface{})["version"].(map[string]interface{})["const"])
+}
+
+func TestMigrate_V1(t *testing.T) {
+	out, from, err := Migrate([]byte(`{"nodes":[{"id":1,"label":"A","x":10},{"id":"b","name":"B"}],"edges":[{"source":1,"target":"b","color":"red"}],"layout":"tb"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, from)
+	// 未知字段（坐标、颜色、布局）保留
+	assert.JSONEq(t, `{
+		"version": 2,
+		"structs": [{"id":"1","name":"A","x":10},{"id":"b","name":"B"}],
+		"connections": [{"from":"1","to":"b","color":"red"}],
+		"layout": "tb"
+	}`, string(out))
+
+	// 当前版本原样返回
+	current := []byte(`{"version":2,"structs":[],"connections":[]}`)
+	out, from, err = Migrate(current)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, from)
+	assert.Equal(t, current, out)
+
+	_, _, err = Migrate([]byte(`{"version":99}`))
+	assert.ErrorContains(t, err, "unsupported schema version 99")
+	_, _, err = Migrate([]byte(`[1]`))
+	assert.ErrorIs(t, err, ErrInvalidDiagram)
+}
+
+func TestValidateJSON(t *testing.T) {
+	out, err := ValidateJSON([]byte(`{"structs":[{"name":"A","fields":[{"name":"B","type":"*B"}]},{"name":"B"}],"connections":[{"from":"A","to":"B"}]}`), Limits{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2,"structs":[{"name":"A","fields":[{"name":"B","type":"*B"}]},{"name":"B"}],"connections":[{"from":"A","to":"B"}]}`, string(out))
+
+	tests := []struct {
+		name   string
+		data   string
+		limits Limits
+		want   ValidationError
+	}{
+		{
+			name: "field types",
+			data: `{"version":2,"structs":[{"name":1},{"name":"A","depth":-1,"fields":[{"type":"int"},"x"]},3],"connections":{}}`,
+			want: ValidationError{
+				{Field: "structs[0].name", Message: "must be a string"},
+				{Field: "structs[0].name", Message: "is required"},
+				{Field: "structs[1].depth", Message: "must be a non-negative integer"},
+				{Field: "structs[1].fields[0].name", Message: "is required"},
+				{Field: "structs[1].fields[1]", Message: "must be an object"},
+				{Field: "structs[2]", Message: "must be an object"},
+				{Field: "connections", Message: "must be an array"},
+			},
+		},
+		{
+			name: "connection endpoints",
+			data: `{"structs":[{"name":"A"}],"connections":[{"from":"A","type":1}]}`,
+			want: ValidationError{
+				{Field: "connections[0].to", Message: "is required"},
+				{Field: "connections[0].type", Message: "must be a string"},
+			},
+		},
+		{
+			name: "references",
+			data: `{"structs":[{"name":"A"},{"name":"A"}],"connections":[{"from":"A","to":"C"}]}`,
+			want: ValidationError{
+				{Field: "structs[1].name", Message: `duplicate struct "A" (also structs[0])`},
+				{Field: "connections[0].to", Message: `unknown struct "C"`},
+			},
+		},
+		{
+			name:   "size limit",
+			data:   `{"structs":[]}`,
+			limits: Limits{MaxBytes: 10},
+			want:   ValidationError{{Message: "diagram is too large: 14 bytes (limit 10)"}},
+		},
+		{
+			name:   "count limits",
+			data:   `{"structs":[{"name":"A"},{"name":"B"}],"connections":[{"from":"A","to":"B"},{"from":"B","to":"A"}]}`,
+			limits: Limits{MaxStructs: 1, MaxConnections: 1},
+			want: ValidationError{
+				{Field: "structs", Message: "too many structs: 2 (limit 1)"},
+				{Field: "connections", Message: "too many connections: 2 (limit 1)"},
+			},
+		},
+		{
+			name: "version",
+			data: `{"version":3,"structs":[],"connections":[]}`,
+			want: ValidationError{{Field: "version", Message: "unsupported schema version 3 (latest 2)"}},
+		},
+		{
+			name: "not an object",
+			data: `"x"`,
+			want: ValidationError{{Message: "must be a JSON object"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateJSON([]byte(tt.data), tt.limits)
+			var errs ValidationError
+			require.ErrorAs(t, err, &errs)
+			assert.Equal(t, tt.want, errs)
+		})
+	}
+
+	// 错误条数有上限
+	var b strings.Builder
+	b.WriteString(`{"structs":[`)
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(`{}`)
+	}
+	b.WriteString(`],"connections":[]}`)
+	_, err = ValidateJSON([]byte(b.String()), Limits{})
+	var errs ValidationError
+	require.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, maxFieldErrors)
+}
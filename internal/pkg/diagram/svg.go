@@ -0,0 +1,198 @@
+package diagram
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// SVG 布局参数（像素）
+const (
+	svgMargin     = 20
+	svgHGap       = 40
+	svgVGap       = 60
+	svgHeaderH    = 26
+	svgFieldH     = 16
+	svgPadding    = 8
+	svgCharW      = 7
+	svgWideCharW  = 12
+	svgMinBoxW    = 80
+	svgMaxBoxText = 48
+)
+
+type svgBox struct {
+	s          Struct
+	x, y, w, h float64
+}
+
+func (b *svgBox) center() (float64, float64) {
+	return b.x + b.w/2, b.y + b.h/2
+}
+
+// border 返回从中心指向 (tx, ty) 的射线与矩形边框的交点
+func (b *svgBox) border(tx, ty float64) (float64, float64) {
+	cx, cy := b.center()
+	dx, dy := tx-cx, ty-cy
+	if dx == 0 && dy == 0 {
+		return cx, cy
+	}
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = math.Min(scale, (b.w/2)/math.Abs(dx))
+	}
+	if dy != 0 {
+		scale = math.Min(scale, (b.h/2)/math.Abs(dy))
+	}
+	return cx + dx*scale, cy + dy*scale
+}
+
+// SVG 不依赖 graphviz，按层级自上而下分层排列节点并用直线连接
+func SVG(d *Diagram, levels map[string]int) string {
+	// 按层级分组，组内按名称排序
+	var rows [][]*svgBox
+	boxes := make(map[string]*svgBox, len(d.Structs))
+	for _, s := range d.sortedByLevel(levels) {
+		level := levels[s.Key()]
+		for len(rows) <= level {
+			rows = append(rows, nil)
+		}
+		box := &svgBox{s: s}
+		box.w, box.h = boxSize(s)
+		rows[level] = append(rows[level], box)
+		boxes[s.Key()] = box
+	}
+
+	// 逐行计算坐标，每行水平居中
+	width, height := 0.0, float64(svgMargin)
+	rowWidths := make([]float64, len(rows))
+	for i, row := range rows {
+		for j, box := range row {
+			if j > 0 {
+				rowWidths[i] += svgHGap
+			}
+			rowWidths[i] += box.w
+		}
+		width = math.Max(width, rowWidths[i])
+	}
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		x := svgMargin + (width-rowWidths[i])/2
+		rowH := 0.0
+		for _, box := range row {
+			box.x, box.y = x, height
+			x += box.w + svgHGap
+			rowH = math.Max(rowH, box.h)
+		}
+		height += rowH + svgVGap
+	}
+	width += 2 * svgMargin
+	height += svgMargin - svgVGap
+	if len(d.Structs) == 0 {
+		width, height = 2*svgMargin, 2*svgMargin
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="Helvetica, Arial, sans-serif" font-size="12">`+"\n",
+		num(width), num(height), num(width), num(height))
+	b.WriteString(`<defs>` +
+		`<marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10 z" fill="#555"/></marker>` +
+		`<marker id="diamond" viewBox="0 0 12 12" refX="12" refY="6" markerWidth="10" markerHeight="10" orient="auto-start-reverse"><path d="M0,6 L6,0 L12,6 L6,12 z" fill="#555"/></marker>` +
+		`<marker id="triangle" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="10" markerHeight="10" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10 z" fill="#fff" stroke="#555"/></marker>` +
+		"</defs>\n")
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#fff"/>`+"\n")
+
+	// 先画连线，节点盖在连线上面
+	for _, c := range d.Connections {
+		from, to := boxes[c.From], boxes[c.To]
+		if from == nil || to == nil || from == to {
+			continue
+		}
+		tx, ty := to.center()
+		fx, fy := from.center()
+		x1, y1 := from.border(tx, ty)
+		x2, y2 := to.border(fx, fy)
+
+		// 嵌入用实心菱形标在外层结构体一端，与 UML 组合关系一致
+		markers := ` marker-end="url(#arrow)"`
+		switch c.Type {
+		case ConnEmbed:
+			markers = ` marker-start="url(#diamond)"`
+		case ConnImplement:
+			markers = ` stroke-dasharray="5,3" marker-end="url(#triangle)"`
+		}
+		fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="#555"%s/>`+"\n",
+			num(x1), num(y1), num(x2), num(y2), markers)
+		if label := connLabel(c); label != "" {
+			fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" font-size="10" fill="#555">%s</text>`+"\n",
+				num((x1+x2)/2), num((y1+y2)/2-3), html.EscapeString(label))
+		}
+	}
+
+	for _, row := range rows {
+		for _, box := range row {
+			writeBox(&b, box)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func writeBox(b *strings.Builder, box *svgBox) {
+	fmt.Fprintf(b, `<g class="struct" data-key="%s">`+"\n", html.EscapeString(box.s.Key()))
+	fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" rx="4" fill="#f5f8ff" stroke="#4a6fa5"/>`+"\n",
+		num(box.x), num(box.y), num(box.w), num(box.h))
+	fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle" font-weight="bold">%s</text>`+"\n",
+		num(box.x+box.w/2), num(box.y+svgHeaderH-9), html.EscapeString(truncate(box.s.Label())))
+	if len(box.s.Fields) > 0 {
+		fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="#4a6fa5"/>`+"\n",
+			num(box.x), num(box.y+svgHeaderH), num(box.x+box.w), num(box.y+svgHeaderH))
+		for i, f := range box.s.Fields {
+			fmt.Fprintf(b, `<text x="%s" y="%s">%s</text>`+"\n",
+				num(box.x+svgPadding), num(box.y+svgHeaderH+float64(i+1)*svgFieldH-3), html.EscapeString(truncate(fieldText(f))))
+		}
+	}
+	b.WriteString("</g>\n")
+}
+
+// boxSize 按文字宽度估算节点尺寸，中日韩等宽字符按两倍宽度计算
+func boxSize(s Struct) (float64, float64) {
+	w := textWidth(truncate(s.Label()))
+	for _, f := range s.Fields {
+		w = math.Max(w, textWidth(truncate(fieldText(f))))
+	}
+	w = math.Max(w+2*svgPadding, svgMinBoxW)
+	h := float64(svgHeaderH)
+	if len(s.Fields) > 0 {
+		h += float64(len(s.Fields))*svgFieldH + svgPadding/2
+	}
+	return w, h
+}
+
+func textWidth(s string) float64 {
+	w := 0.0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			w += svgCharW
+		} else {
+			w += svgWideCharW
+		}
+	}
+	return w
+}
+
+// truncate 过长的文字截断，避免节点过宽
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= svgMaxBoxText {
+		return s
+	}
+	return string([]rune(s)[:svgMaxBoxText-1]) + "…"
+}
+
+// num 格式化坐标，保留至多一位小数
+func num(f float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", f), ".0")
+}
@@ -0,0 +1,74 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError 框图某个字段的校验错误，Field 为 JSON 路径（如 structs[2].name），整体错误时为空
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError 框图校验失败时返回的全部字段错误
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.String())
+	}
+	return "invalid diagram: " + strings.Join(msgs, "; ")
+}
+
+// Validate 校验节点标识非空且唯一、字段有名称、连接的两端都存在
+func (d *Diagram) Validate() error {
+	var errs ValidationError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[string]int, len(d.Structs))
+	for i, s := range d.Structs {
+		path := fmt.Sprintf("structs[%d]", i)
+		key := s.Key()
+		if key == "" {
+			add(path+".name", "is required")
+			continue
+		}
+		if j, ok := seen[key]; ok {
+			add(path+".name", "duplicate struct %q (also structs[%d])", key, j)
+			continue
+		}
+		seen[key] = i
+		for k, f := range s.Fields {
+			if f.Name == "" {
+				add(fmt.Sprintf("%s.fields[%d].name", path, k), "is required")
+			}
+		}
+	}
+
+	for i, c := range d.Connections {
+		path := fmt.Sprintf("connections[%d]", i)
+		for _, end := range []struct{ name, key string }{{"from", c.From}, {"to", c.To}} {
+			if end.key == "" {
+				add(path+"."+end.name, "is required")
+			} else if _, ok := seen[end.key]; !ok {
+				add(path+"."+end.name, "unknown struct %q", end.key)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -1,152 +1,82 @@
 package email
 
 import (
-	"fmt"
-	"net/smtp"
+	"log"
+	"net/url"
 	"strings"
 
 	"github.com/qs3c/anal_go_server/config"
 )
 
+// verificationExpiresHours 与注册时生成的验证码有效期一致
+const verificationExpiresHours = 24
+
+// passwordResetExpiresMinutes 密码重置链接的有效期
+const passwordResetExpiresMinutes = 30
+
 type Service struct {
-	cfg *config.EmailConfig
+	cfg       *config.EmailConfig
+	templates *Templates
+	transport Transport
 }
 
+// NewService 通过 SMTP 同步发送
 func NewService(cfg *config.EmailConfig) *Service {
-	return &Service{cfg: cfg}
+	return NewServiceWithTransport(cfg, NewSMTPTransport(cfg))
 }
 
-// SendVerificationCode 发送邮箱验证码
-func (s *Service) SendVerificationCode(to, code string) error {
-	subject := "验证码 - Go 项目结构分析平台"
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-</head>
-<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-    <div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-        <h2 style="color: #2563eb;">邮箱验证</h2>
-        <p>您好，</p>
-        <p>您正在注册 Go 项目结构分析平台账号，验证码为：</p>
-        <div style="background-color: #f3f4f6; padding: 15px; text-align: center; font-size: 24px; font-weight: bold; letter-spacing: 5px; margin: 20px 0;">
-            %s
-        </div>
-        <p>验证码有效期为 10 分钟，请尽快完成验证。</p>
-        <p>如果您没有进行此操作，请忽略此邮件。</p>
-        <hr style="border: none; border-top: 1px solid #e5e7eb; margin: 20px 0;">
-        <p style="color: #6b7280; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
-    </div>
-</body>
-</html>
-`, code)
+// NewServiceWithTransport 使用指定的 Transport 发送，如 Outbox 或测试用的 MemoryTransport
+// 配置中覆盖的模板无效时记录日志并使用内置模板
+func NewServiceWithTransport(cfg *config.EmailConfig, transport Transport) *Service {
+	templates, err := NewTemplates(cfg.Templates)
+	if err != nil {
+		log.Printf("Warning: invalid email templates, using built-in ones: %v", err)
+		templates, _ = NewTemplates(nil)
+	}
+	return &Service{
+		cfg:       cfg,
+		templates: templates,
+		transport: transport,
+	}
+}
 
-	return s.sendHTML(to, subject, body)
+// SendVerificationCode 发送邮箱验证码，配置了 base_url 时附带验证链接
+func (s *Service) SendVerificationCode(to, code string) error {
+	data := VerificationData{Code: code, ExpiresHours: verificationExpiresHours}
+	if s.cfg.BaseURL != "" {
+		data.Link = s.Link("/verify-email", url.Values{"code": {code}})
+	}
+	return s.send(to, TemplateVerification, data)
 }
 
 // SendPasswordReset 发送密码重置邮件
 func (s *Service) SendPasswordReset(to, resetLink string) error {
-	subject := "密码重置 - Go 项目结构分析平台"
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-</head>
-<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-    <div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-        <h2 style="color: #2563eb;">密码重置</h2>
-        <p>您好，</p>
-        <p>您正在请求重置密码，请点击下方按钮完成重置：</p>
-        <div style="text-align: center; margin: 30px 0;">
-            <a href="%s" style="background-color: #2563eb; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; display: inline-block;">重置密码</a>
-        </div>
-        <p>或者复制以下链接到浏览器：</p>
-        <p style="background-color: #f3f4f6; padding: 10px; word-break: break-all;">%s</p>
-        <p>链接有效期为 30 分钟。</p>
-        <p>如果您没有请求重置密码，请忽略此邮件。</p>
-        <hr style="border: none; border-top: 1px solid #e5e7eb; margin: 20px 0;">
-        <p style="color: #6b7280; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
-    </div>
-</body>
-</html>
-`, resetLink, resetLink)
-
-	return s.sendHTML(to, subject, body)
+	return s.send(to, TemplatePasswordReset, PasswordResetData{Link: resetLink, ExpiresMinutes: passwordResetExpiresMinutes})
 }
 
 // SendWelcome 发送欢迎邮件
 func (s *Service) SendWelcome(to, username string) error {
-	subject := "欢迎加入 - Go 项目结构分析平台"
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-</head>
-<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-    <div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-        <h2 style="color: #2563eb;">欢迎加入！</h2>
-        <p>您好，%s！</p>
-        <p>感谢您注册 Go 项目结构分析平台。</p>
-        <p>现在您可以：</p>
-        <ul>
-            <li>分析 Go 项目的结构体依赖关系</li>
-            <li>生成可视化框图</li>
-            <li>与社区分享您的分析</li>
-        </ul>
-        <p>开始探索吧！</p>
-        <hr style="border: none; border-top: 1px solid #e5e7eb; margin: 20px 0;">
-        <p style="color: #6b7280; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
-    </div>
-</body>
-</html>
-`, username)
-
-	return s.sendHTML(to, subject, body)
+	return s.send(to, TemplateWelcome, WelcomeData{Username: username})
 }
 
-// sendHTML 发送 HTML 邮件
-func (s *Service) sendHTML(to, subject, body string) error {
-	headers := make(map[string]string)
-	headers["From"] = s.cfg.From
-	headers["To"] = to
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
+// SendAnalysisResult 发送分析完成或失败的通知
+func (s *Service) SendAnalysisResult(to, title string, succeeded bool, detail string) error {
+	return s.send(to, TemplateAnalysisResult, AnalysisResultData{Title: title, Succeeded: succeeded, Detail: detail})
+}
 
-	var msg strings.Builder
-	for k, v := range headers {
-		msg.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+// Link 基于 base_url 拼接前端链接
+func (s *Service) Link(path string, query url.Values) string {
+	link := strings.TrimRight(s.cfg.BaseURL, "/") + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
 	}
-	msg.WriteString("\r\n")
-	msg.WriteString(body)
-
-	auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.SMTPHost)
-	addr := fmt.Sprintf("%s:%d", s.cfg.SMTPHost, s.cfg.SMTPPort)
-
-	return smtp.SendMail(addr, auth, s.cfg.From, []string{to}, []byte(msg.String()))
+	return link
 }
 
-// sendPlain 发送纯文本邮件
-func (s *Service) sendPlain(to, subject, body string) error {
-	headers := make(map[string]string)
-	headers["From"] = s.cfg.From
-	headers["To"] = to
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/plain; charset=UTF-8"
-
-	var msg strings.Builder
-	for k, v := range headers {
-		msg.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+func (s *Service) send(to, template string, data interface{}) error {
+	subject, body, err := s.templates.Render(template, data)
+	if err != nil {
+		return err
 	}
-	msg.WriteString("\r\n")
-	msg.WriteString(body)
-
-	auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.SMTPHost)
-	addr := fmt.Sprintf("%s:%d", s.cfg.SMTPHost, s.cfg.SMTPPort)
-
-	return smtp.SendMail(addr, auth, s.cfg.From, []string{to}, []byte(msg.String()))
+	return s.transport.Send(&Message{To: to, Subject: subject, Body: body})
 }
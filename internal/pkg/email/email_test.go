@@ -0,0 +1,228 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+func TestService_SendVerificationCode(t *testing.T) {
+	transport := NewMemoryTransport()
+	service := NewServiceWithTransport(&config.EmailConfig{BaseURL: "https://app.example.com/"}, transport)
+
+	require.NoError(t, service.SendVerificationCode("a@example.com", "abc123"))
+
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "a@example.com", messages[0].To)
+	assert.Contains(t, messages[0].Subject, "验证码")
+	assert.Contains(t, messages[0].Body, "abc123")
+	assert.Contains(t, messages[0].Body, "https://app.example.com/verify-email?code=abc123")
+	assert.Contains(t, messages[0].Body, "24 小时")
+}
+
+func TestService_EscapesData(t *testing.T) {
+	transport := NewMemoryTransport()
+	service := NewServiceWithTransport(&config.EmailConfig{}, transport)
+
+	require.NoError(t, service.SendAnalysisResult("a@example.com", "<script>x</script>", false, "boom & bust"))
+
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "分析失败 - Go 项目结构分析平台", messages[0].Subject)
+	assert.NotContains(t, messages[0].Body, "<script>")
+	assert.Contains(t, messages[0].Body, "&lt;script&gt;")
+	assert.Contains(t, messages[0].Body, "boom &amp; bust")
+}
+
+func TestTemplates_Override(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "welcome.html")
+	require.NoError(t, os.WriteFile(file, []byte(`<p>Hi {{.Username}}</p>`), 0o644))
+
+	transport := NewMemoryTransport()
+	service := NewServiceWithTransport(&config.EmailConfig{
+		Templates: map[string]config.EmailTemplateConfig{
+			TemplateWelcome: {Subject: "Welcome, {{.Username}}", File: file},
+		},
+	}, transport)
+	require.NoError(t, service.SendWelcome("a@example.com", "alice"))
+
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "Welcome, alice", messages[0].Subject)
+	assert.Equal(t, "<p>Hi alice</p>", messages[0].Body)
+
+	_, err := NewTemplates(map[string]config.EmailTemplateConfig{"unknown": {Subject: "x"}})
+	assert.Error(t, err)
+	_, err = NewTemplates(map[string]config.EmailTemplateConfig{TemplateWelcome: {File: filepath.Join(dir, "missing.html")}})
+	assert.Error(t, err)
+	_, err = NewTemplates(map[string]config.EmailTemplateConfig{TemplateWelcome: {Subject: "{{.Username"}})
+	assert.Error(t, err)
+}
+
+func TestOutbox_RetryAndDeadLetter(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+	outbox := NewOutbox(client, transport, 2)
+	service := NewServiceWithTransport(&config.EmailConfig{}, outbox)
+
+	// 入队后不会立即发送
+	require.NoError(t, service.SendWelcome("a@example.com", "alice"))
+	assert.Empty(t, transport.Messages())
+
+	now := time.Now()
+	sent, err := outbox.Process(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, transport.Messages(), 1)
+
+	// 发送失败后按退避时间重试
+	transport.SetError(errors.New("smtp unavailable"))
+	require.NoError(t, service.SendWelcome("b@example.com", "bob"))
+	sent, err = outbox.Process(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	pending, err := outbox.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+
+	// 未到重试时间
+	_, err = outbox.Process(ctx, now.Add(10*time.Second))
+	require.NoError(t, err)
+	pending, err = outbox.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+
+	// 第二次仍然失败，超过次数进入死信列表
+	_, err = outbox.Process(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	pending, err = outbox.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+	dead, err := client.LRange(ctx, outboxDeadKey, 0, -1).Result()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Contains(t, dead[0], "smtp unavailable")
+	assert.Contains(t, dead[0], "b@example.com")
+}
+
+func TestOutbox_RetrySucceeds(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	transport := NewMemoryTransport()
+	outbox := NewOutbox(client, transport, 5)
+
+	transport.SetError(errors.New("temporary"))
+	require.NoError(t, outbox.Send(&Message{To: "a@example.com", Subject: "s", Body: "b"}))
+	now := time.Now()
+	_, err = outbox.Process(ctx, now)
+	require.NoError(t, err)
+
+	transport.SetError(nil)
+	sent, err := outbox.Process(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	require.Len(t, transport.Messages(), 1)
+	assert.Equal(t, "a@example.com", transport.Messages()[0].To)
+}
+
+// fakeSMTP 只实现 smtp.SendMail 用到的命令的本地 SMTP 服务，收到的邮件内容写入 received
+func fakeSMTP(t *testing.T) (host string, port int, received chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	received = make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		write := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		write("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"), strings.HasPrefix(cmd, "RSET"):
+				write("250 OK")
+			case cmd == "DATA":
+				write("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- data.String()
+				write("250 OK")
+			case cmd == "QUIT":
+				write("221 Bye")
+				return
+			default:
+				write("502 Not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestSMTPTransport_Send(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	cfg := &config.EmailConfig{SMTPHost: host, SMTPPort: port, From: "noreply@example.com"}
+
+	require.NoError(t, NewService(cfg).SendWelcome("a@example.com", "alice"))
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "From: noreply@example.com")
+		assert.Contains(t, data, "To: a@example.com")
+		assert.Contains(t, data, "Subject: =?UTF-8?q?")
+		assert.Contains(t, data, "Content-Type: text/html; charset=UTF-8")
+		assert.Contains(t, data, "您好，alice！")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for smtp data")
+	}
+}
@@ -0,0 +1,168 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	outboxQueueKey  = "email:outbox"       // 待发送，RPUSH/LPOP
+	outboxRetryKey  = "email:outbox:retry" // 等待重试，score 为下次发送时间
+	outboxDeadKey   = "email:outbox:dead"  // 超过次数仍失败的邮件，只保留最近 outboxDeadLimit 封
+	outboxDeadLimit = 1000
+	// outboxBatch 每轮最多发送的邮件数
+	outboxBatch = 100
+)
+
+// outboxBackoff 第 n 次失败后到下一次发送的间隔，超出部分使用最后一项
+var outboxBackoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute}
+
+// promoteScript 把到期的重试移回待发送队列
+var promoteScript = redis.NewScript(`
+local items = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call("ZREM", KEYS[1], item)
+	redis.call("RPUSH", KEYS[2], item)
+end
+return #items
+`)
+
+// envelope 发件箱中的一封邮件，ID 使重试集合中内容相同的邮件互不覆盖
+type envelope struct {
+	ID        string   `json:"id"`
+	Message   *Message `json:"message"`
+	Attempts  int      `json:"attempts"`
+	LastError string   `json:"last_error,omitempty"`
+}
+
+// Outbox 基于 Redis 的发件箱，Send 只入队，由 Run 在后台通过下层 Transport 发送并按退避时间重试
+// 多个实例可以同时运行 Run，每封邮件只会被一个实例取出
+type Outbox struct {
+	client      *redis.Client
+	transport   Transport
+	maxAttempts int
+}
+
+func NewOutbox(client *redis.Client, transport Transport, maxAttempts int) *Outbox {
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	return &Outbox{
+		client:      client,
+		transport:   transport,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Send 把邮件放入发件箱
+func (o *Outbox) Send(msg *Message) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&envelope{ID: hex.EncodeToString(id), Message: msg})
+	if err != nil {
+		return err
+	}
+	return o.client.RPush(context.Background(), outboxQueueKey, data).Err()
+}
+
+// Run 每秒处理一次发件箱，直到 ctx 取消
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := o.Process(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				log.Printf("Email outbox: %v", err)
+			}
+		}
+	}
+}
+
+// Process 把到期的重试移回队列并发送队列中的邮件，返回成功发送的数量
+func (o *Outbox) Process(ctx context.Context, now time.Time) (int, error) {
+	err := promoteScript.Run(ctx, o.client, []string{outboxRetryKey, outboxQueueKey},
+		strconv.FormatInt(now.UnixMilli(), 10), outboxBatch).Err()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := 0; i < outboxBatch; i++ {
+		data, err := o.client.LPop(ctx, outboxQueueKey).Bytes()
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			return sent, err
+		}
+
+		var env envelope
+		if err := json.Unmarshal(data, &env); err != nil || env.Message == nil {
+			log.Printf("Email outbox: dropping malformed message: %s", data)
+			continue
+		}
+		if o.deliver(ctx, &env, now) {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Pending 待发送和等待重试的邮件数
+func (o *Outbox) Pending(ctx context.Context) (int64, error) {
+	queued, err := o.client.LLen(ctx, outboxQueueKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	retrying, err := o.client.ZCard(ctx, outboxRetryKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	return queued + retrying, nil
+}
+
+// deliver 发送一封邮件，失败时安排重试或放入死信列表
+func (o *Outbox) deliver(ctx context.Context, env *envelope, now time.Time) bool {
+	err := o.transport.Send(env.Message)
+	if err == nil {
+		return true
+	}
+
+	env.Attempts++
+	env.LastError = err.Error()
+	data, marshalErr := json.Marshal(env)
+	if marshalErr != nil {
+		return false
+	}
+
+	if env.Attempts >= o.maxAttempts {
+		log.Printf("Email outbox: giving up on %s after %d attempts: %v", env.Message.To, env.Attempts, err)
+		pipe := o.client.TxPipeline()
+		pipe.LPush(ctx, outboxDeadKey, data)
+		pipe.LTrim(ctx, outboxDeadKey, 0, outboxDeadLimit-1)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Email outbox: failed to record dead message: %v", err)
+		}
+		return false
+	}
+
+	wait := outboxBackoff[min(env.Attempts, len(outboxBackoff))-1]
+	score := float64(now.Add(wait).UnixMilli())
+	if err := o.client.ZAdd(ctx, outboxRetryKey, &redis.Z{Score: score, Member: data}).Err(); err != nil {
+		log.Printf("Email outbox: failed to schedule retry for %s: %v", env.Message.To, err)
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	texttemplate "text/template"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+// 模板名，可在配置 email.templates 中按名覆盖
+const (
+	TemplateVerification   = "verification"
+	TemplatePasswordReset  = "password_reset"
+	TemplateWelcome        = "welcome"
+	TemplateAnalysisResult = "analysis_result"
+)
+
+// VerificationData 验证邮件的模板数据，Link 在未配置 base_url 时为空
+type VerificationData struct {
+	Code         string
+	Link         string
+	ExpiresHours int
+}
+
+// PasswordResetData 密码重置邮件的模板数据
+type PasswordResetData struct {
+	Link           string
+	ExpiresMinutes int
+}
+
+// WelcomeData 欢迎邮件的模板数据
+type WelcomeData struct {
+	Username string
+}
+
+// AnalysisResultData 分析结果通知的模板数据
+type AnalysisResultData struct {
+	Title     string
+	Succeeded bool
+	Detail    string
+}
+
+const layoutHead = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+</head>
+<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+    <div style="max-width: 600px; margin: 0 auto; padding: 20px;">
+`
+
+const layoutFoot = `        <hr style="border: none; border-top: 1px solid #e5e7eb; margin: 20px 0;">
+        <p style="color: #6b7280; font-size: 12px;">此邮件由系统自动发送，请勿回复。</p>
+    </div>
+</body>
+</html>
+`
+
+type builtinTemplate struct {
+	subject string
+	body    string
+}
+
+var builtinTemplates = map[string]builtinTemplate{
+	TemplateVerification: {
+		subject: "验证码 - Go 项目结构分析平台",
+		body: layoutHead + `        <h2 style="color: #2563eb;">邮箱验证</h2>
+        <p>您好，</p>
+        <p>您正在注册 Go 项目结构分析平台账号，验证码为：</p>
+        <div style="background-color: #f3f4f6; padding: 15px; text-align: center; font-size: 24px; font-weight: bold; letter-spacing: 5px; margin: 20px 0;">
+            {{.Code}}
+        </div>
+        {{if .Link}}<div style="text-align: center; margin: 30px 0;">
+            <a href="{{.Link}}" style="background-color: #2563eb; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; display: inline-block;">验证邮箱</a>
+        </div>
+        {{end}}<p>验证码有效期为 {{.ExpiresHours}} 小时，请尽快完成验证。</p>
+        <p>如果您没有进行此操作，请忽略此邮件。</p>
+` + layoutFoot,
+	},
+	TemplatePasswordReset: {
+		subject: "密码重置 - Go 项目结构分析平台",
+		body: layoutHead + `        <h2 style="color: #2563eb;">密码重置</h2>
+        <p>您好，</p>
+        <p>您正在请求重置密码，请点击下方按钮完成重置：</p>
+        <div style="text-align: center; margin: 30px 0;">
+            <a href="{{.Link}}" style="background-color: #2563eb; color: white; padding: 12px 30px; text-decoration: none; border-radius: 5px; display: inline-block;">重置密码</a>
+        </div>
+        <p>或者复制以下链接到浏览器：</p>
+        <p style="background-color: #f3f4f6; padding: 10px; word-break: break-all;">{{.Link}}</p>
+        <p>链接有效期为 {{.ExpiresMinutes}} 分钟。</p>
+        <p>如果您没有请求重置密码，请忽略此邮件。</p>
+` + layoutFoot,
+	},
+	TemplateWelcome: {
+		subject: "欢迎加入 - Go 项目结构分析平台",
+		body: layoutHead + `        <h2 style="color: #2563eb;">欢迎加入！</h2>
+        <p>您好，{{.Username}}！</p>
+        <p>感谢您注册 Go 项目结构分析平台。</p>
+        <p>现在您可以：</p>
+        <ul>
+            <li>分析 Go 项目的结构体依赖关系</li>
+            <li>生成可视化框图</li>
+            <li>与社区分享您的分析</li>
+        </ul>
+        <p>开始探索吧！</p>
+` + layoutFoot,
+	},
+	TemplateAnalysisResult: {
+		subject: `{{if .Succeeded}}分析完成{{else}}分析失败{{end}} - Go 项目结构分析平台`,
+		body: layoutHead + `        <h2 style="color: #2563eb;">{{if .Succeeded}}分析已完成{{else}}分析失败{{end}}</h2>
+        <p>您好，</p>
+        <p>您的分析「{{.Title}}」{{if .Succeeded}}已完成{{else}}失败{{end}}。</p>
+        <p style="background-color: #f3f4f6; padding: 10px; word-break: break-all;">{{.Detail}}</p>
+        <p>如果不想再收到此类邮件，可以在通知设置中关闭。</p>
+` + layoutFoot,
+	},
+}
+
+type compiledTemplate struct {
+	subject *texttemplate.Template
+	body    *htmltemplate.Template
+}
+
+// Templates 邮件模板集合，内置模板可被配置覆盖
+type Templates struct {
+	templates map[string]*compiledTemplate
+}
+
+// NewTemplates 编译内置模板并应用配置中的覆盖，覆盖的模板无法读取或解析时返回错误
+func NewTemplates(overrides map[string]config.EmailTemplateConfig) (*Templates, error) {
+	t := &Templates{templates: make(map[string]*compiledTemplate, len(builtinTemplates))}
+	for name, builtin := range builtinTemplates {
+		subject, body := builtin.subject, builtin.body
+		if override, ok := overrides[name]; ok {
+			if override.Subject != "" {
+				subject = override.Subject
+			}
+			if override.File != "" {
+				data, err := os.ReadFile(override.File)
+				if err != nil {
+					return nil, fmt.Errorf("read email template %s: %w", name, err)
+				}
+				body = string(data)
+			}
+		}
+
+		compiled := &compiledTemplate{}
+		var err error
+		if compiled.subject, err = texttemplate.New(name).Parse(subject); err != nil {
+			return nil, fmt.Errorf("parse email subject %s: %w", name, err)
+		}
+		if compiled.body, err = htmltemplate.New(name).Parse(body); err != nil {
+			return nil, fmt.Errorf("parse email template %s: %w", name, err)
+		}
+		t.templates[name] = compiled
+	}
+	for name := range overrides {
+		if _, ok := builtinTemplates[name]; !ok {
+			return nil, fmt.Errorf("unknown email template %q", name)
+		}
+	}
+	return t, nil
+}
+
+// Render 渲染主题和正文
+func (t *Templates) Render(name string, data interface{}) (subject, body string, err error) {
+	compiled, ok := t.templates[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown email template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := compiled.subject.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	subject = buf.String()
+	buf.Reset()
+	if err := compiled.body.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+	return subject, buf.String(), nil
+}
@@ -0,0 +1,94 @@
+package email
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"sync"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+// Message 一封待发送的 HTML 邮件
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Transport 邮件的发送方式，SMTP、发件箱或测试用的内存实现
+type Transport interface {
+	Send(msg *Message) error
+}
+
+// SMTPTransport 通过 SMTP 同步发送
+type SMTPTransport struct {
+	cfg *config.EmailConfig
+}
+
+func NewSMTPTransport(cfg *config.EmailConfig) *SMTPTransport {
+	return &SMTPTransport{cfg: cfg}
+}
+
+func (t *SMTPTransport) Send(msg *Message) error {
+	headers := [][2]string{
+		{"From", t.cfg.From},
+		{"To", msg.To},
+		{"Subject", mime.QEncoding.Encode("UTF-8", msg.Subject)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/html; charset=UTF-8"},
+	}
+
+	var data strings.Builder
+	for _, h := range headers {
+		data.WriteString(fmt.Sprintf("%s: %s\r\n", h[0], h[1]))
+	}
+	data.WriteString("\r\n")
+	data.WriteString(msg.Body)
+
+	// 未配置用户名时不认证，便于连接本地的 SMTP 中继
+	var auth smtp.Auth
+	if t.cfg.Username != "" {
+		auth = smtp.PlainAuth("", t.cfg.Username, t.cfg.Password, t.cfg.SMTPHost)
+	}
+	addr := fmt.Sprintf("%s:%d", t.cfg.SMTPHost, t.cfg.SMTPPort)
+
+	return smtp.SendMail(addr, auth, t.cfg.From, []string{msg.To}, []byte(data.String()))
+}
+
+// MemoryTransport 把邮件保存在内存中，用于测试
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []*Message
+	err      error
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(msg *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	copied := *msg
+	t.messages = append(t.messages, &copied)
+	return nil
+}
+
+// SetError 之后的发送都返回 err，传 nil 恢复
+func (t *MemoryTransport) SetError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.err = err
+}
+
+// Messages 已发送的邮件
+func (t *MemoryTransport) Messages() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Message(nil), t.messages...)
+}
@@ -0,0 +1,257 @@
+package goproxy
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/qs3c/anal_go_server/internal/pkg/archive"
+)
+
+const DefaultProxyURL = "https://proxy.golang.org"
+
+var (
+	ErrInvalidModule    = errors.New("invalid module path or version")
+	ErrNotFound         = errors.New("module version not found")
+	ErrTooLarge         = errors.New("module zip too large")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Client 访问 GOPROXY 协议的模块代理（https://go.dev/ref/mod#goproxy-protocol）
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	if baseURL == "" {
+		baseURL = DefaultProxyURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// ParseModuleRef 解析 "path@version" 形式的模块引用
+func ParseModuleRef(ref string) (string, string, error) {
+	path, version, ok := strings.Cut(strings.TrimSpace(ref), "@")
+	if !ok {
+		return "", "", ErrInvalidModule
+	}
+	if err := checkPath(path); err != nil {
+		return "", "", err
+	}
+	if err := checkVersion(version); err != nil {
+		return "", "", err
+	}
+	return path, version, nil
+}
+
+// DownloadZip 下载模块 zip 写入 dst，超过 maxSize 字节时返回 ErrTooLarge
+func (c *Client) DownloadZip(ctx context.Context, path, version string, dst io.Writer, maxSize int64) (int64, error) {
+	if err := checkPath(path); err != nil {
+		return 0, err
+	}
+	if err := checkVersion(version); err != nil {
+		return 0, err
+	}
+
+	url := fmt.Sprintf("%s/%s/@v/%s.zip", c.baseURL, escapeString(path), escapeString(version))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download module: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return 0, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("goproxy error: %s: %s", resp.Status, string(body))
+	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return 0, ErrTooLarge
+	}
+
+	reader := io.Reader(resp.Body)
+	if maxSize > 0 {
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+	n, err := io.Copy(dst, reader)
+	if err != nil {
+		return n, fmt.Errorf("failed to download module: %w", err)
+	}
+	if maxSize > 0 && n > maxSize {
+		return n, ErrTooLarge
+	}
+	return n, nil
+}
+
+// HashZip 计算模块 zip 的 go.sum 哈希（h1:），与 golang.org/x/mod/sumdb/dirhash.HashZip 一致
+// 条目数量和解压大小受 limits 限制（零值表示不限制），超出时返回 archive 包对应的错误
+func HashZip(zipPath string, limits archive.Limits) (string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	if limits.MaxEntries > 0 && len(r.File) > limits.MaxEntries {
+		return "", archive.ErrTooManyEntries
+	}
+
+	// 先按声明的大小检查，避免读取明显超限的条目
+	var total uint64
+	files := make(map[string]*zip.File, len(r.File))
+	names := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		if strings.Contains(f.Name, "\n") {
+			return "", fmt.Errorf("file name with newline: %q", f.Name)
+		}
+		if _, dup := files[f.Name]; dup {
+			return "", fmt.Errorf("duplicate file name: %q", f.Name)
+		}
+		if limits.MaxEntrySize > 0 && f.UncompressedSize64 > uint64(limits.MaxEntrySize) {
+			return "", fmt.Errorf("%w: %s", archive.ErrEntryTooLarge, f.Name)
+		}
+		total += f.UncompressedSize64
+		if limits.MaxTotalSize > 0 && total > uint64(limits.MaxTotalSize) {
+			return "", archive.ErrTooLarge
+		}
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	// 声明的大小可能是伪造的，读取时再按实际字节数限制
+	var hashed int64
+	summary := sha256.New()
+	for _, name := range names {
+		rc, err := files[name].Open()
+		if err != nil {
+			return "", err
+		}
+		limit := remaining(limits, hashed)
+		h := sha256.New()
+		n, err := io.Copy(h, io.LimitReader(rc, limit+1))
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		hashed += n
+		if n > limit {
+			if limits.MaxEntrySize > 0 && n > limits.MaxEntrySize {
+				return "", fmt.Errorf("%w: %s", archive.ErrEntryTooLarge, name)
+			}
+			return "", archive.ErrTooLarge
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// remaining 返回已读取 hashed 字节后，下一个条目最多还能读取的字节数
+func remaining(limits archive.Limits, hashed int64) int64 {
+	limit := int64(1<<63 - 2)
+	if limits.MaxEntrySize > 0 && limits.MaxEntrySize < limit {
+		limit = limits.MaxEntrySize
+	}
+	if limits.MaxTotalSize > 0 {
+		if left := limits.MaxTotalSize - hashed; left < limit {
+			limit = left
+		}
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	return limit
+}
+
+// VerifyZip 校验模块 zip 与期望的 go.sum 哈希是否一致，返回实际哈希
+func VerifyZip(zipPath, want string, limits archive.Limits) (string, error) {
+	got, err := HashZip(zipPath, limits)
+	if err != nil {
+		return "", err
+	}
+	if got != want {
+		return got, ErrChecksumMismatch
+	}
+	return got, nil
+}
+
+// escapeString 按 GOPROXY 协议转义大写字母：A -> !a
+func escapeString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			b.WriteRune(r + ('a' - 'A'))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// checkPath 粗略校验模块路径，只允许模块路径中合法的字符
+func checkPath(path string) error {
+	if path == "" || len(path) > 300 || !utf8.ValidString(path) {
+		return ErrInvalidModule
+	}
+	if strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") || strings.Contains(path, "//") {
+		return ErrInvalidModule
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "." || elem == ".." || strings.HasPrefix(elem, ".") {
+			return ErrInvalidModule
+		}
+	}
+	for _, r := range path {
+		if !isPathChar(r) {
+			return ErrInvalidModule
+		}
+	}
+	if !strings.Contains(strings.Split(path, "/")[0], ".") {
+		return ErrInvalidModule
+	}
+	return nil
+}
+
+// checkVersion 只接受 v 开头的具体版本（不支持 latest、分支名等查询）
+func checkVersion(version string) error {
+	if len(version) < 2 || len(version) > 128 || version[0] != 'v' {
+		return ErrInvalidModule
+	}
+	for _, r := range version {
+		if !isVersionChar(r) {
+			return ErrInvalidModule
+		}
+	}
+	return nil
+}
+
+func isPathChar(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' ||
+		r == '-' || r == '.' || r == '_' || r == '~' || r == '/'
+}
+
+func isVersionChar(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' ||
+		r == '-' || r == '.' || r == '+'
+}
@@ -0,0 +1,169 @@
+package goproxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/pkg/archive"
+)
+
+func buildModuleZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "module.zip")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func TestParseModuleRef(t *testing.T) {
+	path, version, err := ParseModuleRef("github.com/foo/bar@v1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/foo/bar", path)
+	assert.Equal(t, "v1.2.3", version)
+
+	path, version, err = ParseModuleRef(" golang.org/x/mod@v0.0.0-20240101000000-abcdef123456 ")
+	require.NoError(t, err)
+	assert.Equal(t, "golang.org/x/mod", path)
+	assert.Equal(t, "v0.0.0-20240101000000-abcdef123456", version)
+
+	invalid := []string{
+		"github.com/foo/bar",
+		"github.com/foo/bar@latest",
+		"github.com/foo/bar@master",
+		"github.com/foo/bar@v1/../x",
+		"github.com/../bar@v1.0.0",
+		"/github.com/foo@v1.0.0",
+		"localmodule@v1.0.0",
+		"github.com/foo bar@v1.0.0",
+		"@v1.0.0",
+	}
+	for _, ref := range invalid {
+		_, _, err := ParseModuleRef(ref)
+		assert.ErrorIs(t, err, ErrInvalidModule, ref)
+	}
+}
+
+func TestEscapeString(t *testing.T) {
+	assert.Equal(t, "github.com/!azure/azure-sdk-for-go", escapeString("github.com/Azure/azure-sdk-for-go"))
+	assert.Equal(t, "v1.0.0-!r!c1", escapeString("v1.0.0-RC1"))
+}
+
+func TestHashZip(t *testing.T) {
+	files := map[string]string{
+		"example.com/m@v1.0.0/go.mod": "module example.com/m\n",
+		"example.com/m@v1.0.0/m.go":   "package m\n\ntype T struct{}\n",
+	}
+
+	hash, err := HashZip(writeZip(t, buildModuleZip(t, files)), archive.Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, "h1:2ClUawP2Rf639pFKfTavgp5RD/vl4xt3EHFxkm6fEac=", hash)
+
+	// 哈希只取决于文件名和内容
+	again, err := HashZip(writeZip(t, buildModuleZip(t, files)), archive.Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, hash, again)
+
+	_, err = VerifyZip(writeZip(t, buildModuleZip(t, files)), hash, archive.Limits{})
+	assert.NoError(t, err)
+
+	files["example.com/m@v1.0.0/m.go"] = "package m\n"
+	got, err := VerifyZip(writeZip(t, buildModuleZip(t, files)), hash, archive.Limits{})
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.NotEqual(t, hash, got)
+}
+
+func TestHashZip_Limits(t *testing.T) {
+	// 高度可压缩的超大条目：8MB 的 0 压缩后只有几 KB
+	bomb := writeZip(t, buildModuleZip(t, map[string]string{
+		"example.com/m@v1.0.0/go.mod":  "module example.com/m\n",
+		"example.com/m@v1.0.0/zero.go": strings.Repeat("\x00", 8<<20),
+	}))
+	info, err := os.Stat(bomb)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(1<<20))
+
+	_, err = HashZip(bomb, archive.Limits{MaxEntrySize: 1 << 20})
+	assert.ErrorIs(t, err, archive.ErrEntryTooLarge)
+	_, err = HashZip(bomb, archive.Limits{MaxTotalSize: 4 << 20})
+	assert.ErrorIs(t, err, archive.ErrTooLarge)
+	_, err = VerifyZip(bomb, "h1:x", archive.Limits{MaxEntrySize: 1 << 20})
+	assert.ErrorIs(t, err, archive.ErrEntryTooLarge)
+	_, err = HashZip(bomb, archive.Limits{MaxEntries: 1})
+	assert.ErrorIs(t, err, archive.ErrTooManyEntries)
+
+	_, err = HashZip(bomb, archive.Limits{MaxEntrySize: 16 << 20, MaxTotalSize: 16 << 20, MaxEntries: 2})
+	assert.NoError(t, err)
+}
+
+func TestClient_DownloadZip(t *testing.T) {
+	data := buildModuleZip(t, map[string]string{
+		"github.com/!foo/bar@v1.0.0/go.mod": "module github.com/Foo/bar\n",
+	})
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		if r.URL.Path != "/github.com/!foo/bar/@v/v1.0.0.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", 5*time.Second)
+
+	buf := &bytes.Buffer{}
+	n, err := client.DownloadZip(context.Background(), "github.com/Foo/bar", "v1.0.0", buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "/github.com/!foo/bar/@v/v1.0.0.zip", requested)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, buf.Bytes())
+
+	_, err = client.DownloadZip(context.Background(), "github.com/Foo/bar", "v2.0.0", &bytes.Buffer{}, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.DownloadZip(context.Background(), "github.com/Foo/bar", "v1.0.0", &bytes.Buffer{}, 10)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = client.DownloadZip(context.Background(), "../etc", "v1.0.0", &bytes.Buffer{}, 0)
+	assert.ErrorIs(t, err, ErrInvalidModule)
+}
+
+func TestClient_DownloadZip_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 5*time.Second)
+	_, err := client.DownloadZip(context.Background(), "github.com/foo/bar", "v1.0.0", &bytes.Buffer{}, 0)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
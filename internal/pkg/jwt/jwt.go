@@ -14,44 +14,29 @@ var (
 
 type Claims struct {
 	UserID int64 `json:"user_id"`
+	// Version 签发时用户的 Token 版本，与当前版本不一致的 Token 视为已失效
+	Version int `json:"ver,omitempty"`
+	// SessionID 所属登录会话，退出登录后该会话的 Token 失效
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成 JWT Token
 func GenerateToken(userID int64, secret string, expireHours int) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expireHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return GenerateTokenWithVersion(userID, 0, secret, expireHours)
 }
 
-// ParseToken 解析并验证 JWT Token
+// GenerateTokenWithVersion 生成带 Token 版本的 JWT Token
+func GenerateTokenWithVersion(userID int64, version int, secret string, expireHours int) (string, error) {
+	return GenerateTokenWithClaims(&Claims{UserID: userID, Version: version}, secret, time.Duration(expireHours)*time.Hour)
+}
+
+// GenerateTokenWithClaims 按 claims 中的用户、版本和会话生成有效期为 ttl 的 HS256 Token
+func GenerateTokenWithClaims(claims *Claims, secret string, ttl time.Duration) (string, error) {
+	return NewSecretKeySet(secret).Sign(claims, ttl)
+}
+
+// ParseToken 解析并验证 HS256 Token
 func ParseToken(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(secret), nil
-	})
-
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, ErrExpiredToken
-		}
-		return nil, ErrInvalidToken
-	}
-
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, ErrInvalidToken
-	}
-
-	return claims, nil
+	return NewSecretKeySet(secret).Parse(tokenString)
 }
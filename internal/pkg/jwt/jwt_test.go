@@ -222,3 +222,24 @@ func TestErrors(t *testing.T) {
 		assert.Equal(t, "token has expired", ErrExpiredToken.Error())
 	})
 }
+
+func TestGenerateTokenWithVersion(t *testing.T) {
+	token, err := GenerateTokenWithVersion(123, 3, testSecret, 24)
+	require.NoError(t, err)
+
+	claims, err := ParseToken(token, testSecret)
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), claims.UserID)
+	assert.Equal(t, 3, claims.Version)
+}
+
+func TestGenerateTokenWithClaims(t *testing.T) {
+	token, err := GenerateTokenWithClaims(&Claims{UserID: 7, Version: 1, SessionID: "s1"}, testSecret, 15*time.Minute)
+	require.NoError(t, err)
+
+	claims, err := ParseToken(token, testSecret)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), claims.UserID)
+	assert.Equal(t, "s1", claims.SessionID)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), claims.ExpiresAt.Time, 5*time.Second)
+}
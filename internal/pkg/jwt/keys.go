@@ -0,0 +1,292 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// rsaKeyBits 生成 RSA 密钥的长度
+const rsaKeyBits = 2048
+
+var ErrNoSigningKey = errors.New("no signing key")
+
+// Key 一个签名或验证密钥，ID 写入 Token 的 kid 头
+// 只有公钥的密钥只用于验证；HS256 密钥不会出现在 JWKS 中
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign 是否持有私钥
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// NewSecretKey HS256 密钥
+func NewSecretKey(id, secret string) *Key {
+	return &Key{ID: id, Algorithm: AlgHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+}
+
+// ParsePrivateKeyPEM 解析 PKCS#8 或 PKCS#1 格式的 RSA、Ed25519 私钥
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: invalid PEM", id)
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgRS256, signKey: key, verifyKey: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, signKey: key, verifyKey: key.Public()}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported private key type %T", id, parsed)
+	}
+}
+
+// ParsePublicKeyPEM 解析 PKIX 或 PKCS#1 格式的 RSA、Ed25519 公钥，只用于验证
+func ParsePublicKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: invalid PEM", id)
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PUBLIC KEY" {
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Algorithm: AlgRS256, verifyKey: key}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Algorithm: AlgEdDSA, verifyKey: key}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported public key type %T", id, parsed)
+	}
+}
+
+// GenerateKey 生成 RS256 或 EdDSA 密钥，同时返回 PKCS#8 PEM 格式的私钥
+func GenerateKey(id, algorithm string) (*Key, []byte, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgRS256, "":
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, nil, err
+		}
+		private = key
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		private = key
+	default:
+		return nil, nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	key, err := ParsePrivateKeyPEM(id, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, data, nil
+}
+
+// KeySet 一个签名密钥和若干验证密钥，验证时按 Token 的 kid 选择密钥
+// 并发安全，轮换时通过 Replace 整体替换
+type KeySet struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet 创建密钥集，signingID 对应的密钥必须持有私钥
+func NewKeySet(signingID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{}
+	if err := ks.Replace(signingID, keys...); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewSecretKeySet 只包含一个 HS256 密钥的密钥集，签发的 Token 不带 kid
+func NewSecretKeySet(secret string) *KeySet {
+	key := NewSecretKey("", secret)
+	return &KeySet{signing: key, keys: map[string]*Key{"": key}}
+}
+
+// Replace 替换全部密钥
+func (ks *KeySet) Replace(signingID string, keys ...*Key) error {
+	byID := make(map[string]*Key, len(keys))
+	for _, key := range keys {
+		if _, ok := byID[key.ID]; ok {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		byID[key.ID] = key
+	}
+	signing, ok := byID[signingID]
+	if !ok || !signing.CanSign() {
+		return fmt.Errorf("%w: %q", ErrNoSigningKey, signingID)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.signing = signing
+	ks.keys = byID
+	return nil
+}
+
+// SigningKeyID 当前签名密钥的 ID
+func (ks *KeySet) SigningKeyID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.signing.ID
+}
+
+// Sign 用当前签名密钥签发有效期为 ttl 的 Token
+func (ks *KeySet) Sign(claims *Claims, ttl time.Duration) (string, error) {
+	ks.mu.RLock()
+	key := ks.signing
+	ks.mu.RUnlock()
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey)
+}
+
+// Parse 按 kid 选择密钥解析并验证 Token，Token 的算法必须与密钥一致
+func (ks *KeySet) Parse(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		ks.mu.RLock()
+		key, ok := ks.keys[kid]
+		ks.mu.RUnlock()
+		if !ok || token.Method.Alg() != key.Algorithm {
+			return nil, ErrInvalidToken
+		}
+		return key.verifyKey, nil
+	})
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// JWK 一个公钥的 JSON Web Key 表示
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 所有非对称密钥的公钥，按 kid 排序，HS256 密钥不公开
+func (ks *KeySet) JWKS() *JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := &JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: AlgRS256,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: AlgEdDSA,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
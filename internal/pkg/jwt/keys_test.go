@@ -0,0 +1,201 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+func TestKeySet_SignAndParse(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key, _, err := GenerateKey("k1", alg)
+			require.NoError(t, err)
+			ks, err := NewKeySet("k1", key, NewSecretKey("", testSecret))
+			require.NoError(t, err)
+
+			token, err := ks.Sign(&Claims{UserID: 42}, time.Minute)
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, "k1", parsed.Header["kid"])
+			assert.Equal(t, alg, parsed.Header["alg"])
+
+			claims, err := ks.Parse(token)
+			require.NoError(t, err)
+			assert.Equal(t, int64(42), claims.UserID)
+
+			// 旧的 HS256 Token 仍可验证
+			legacy, err := GenerateToken(7, testSecret, 1)
+			require.NoError(t, err)
+			claims, err = ks.Parse(legacy)
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), claims.UserID)
+		})
+	}
+}
+
+func TestKeySet_RejectsUnknownKidAndAlgorithmConfusion(t *testing.T) {
+	key, _, err := GenerateKey("rsa", AlgRS256)
+	require.NoError(t, err)
+	ks, err := NewKeySet("rsa", key)
+	require.NoError(t, err)
+
+	other, _, err := GenerateKey("other", AlgRS256)
+	require.NoError(t, err)
+	otherSet, err := NewKeySet("other", other)
+	require.NoError(t, err)
+	token, err := otherSet.Sign(&Claims{UserID: 1}, time.Minute)
+	require.NoError(t, err)
+	_, err = ks.Parse(token)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// 用公钥作为 HMAC 密钥伪造的 Token
+	der, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+	require.NoError(t, err)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1})
+	forged.Header["kid"] = "rsa"
+	forgedString, err := forged.SignedString(pubPEM)
+	require.NoError(t, err)
+	_, err = ks.Parse(forgedString)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	// 没有 secret 时不接受不带 kid 的 Token
+	legacy, err := GenerateToken(1, testSecret, 1)
+	require.NoError(t, err)
+	_, err = ks.Parse(legacy)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestKeySet_VerifyOnlyKey(t *testing.T) {
+	key, _, err := GenerateKey("old", AlgRS256)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+	require.NoError(t, err)
+	public, err := ParsePublicKeyPEM("old", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.False(t, public.CanSign())
+
+	// 只有公钥的密钥不能用于签名
+	_, err = NewKeySet("old", public)
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+
+	signer, err := NewKeySet("old", key)
+	require.NoError(t, err)
+	token, err := signer.Sign(&Claims{UserID: 5}, time.Minute)
+	require.NoError(t, err)
+
+	ks, err := NewKeySet("", NewSecretKey("", testSecret), public)
+	require.NoError(t, err)
+	claims, err := ks.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), claims.UserID)
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	rsaKey, _, err := GenerateKey("a-rsa", AlgRS256)
+	require.NoError(t, err)
+	edKey, _, err := GenerateKey("b-ed", AlgEdDSA)
+	require.NoError(t, err)
+	ks, err := NewKeySet("a-rsa", rsaKey, edKey, NewSecretKey("", testSecret))
+	require.NoError(t, err)
+
+	set := ks.JWKS()
+	require.Len(t, set.Keys, 2)
+
+	assert.Equal(t, "RSA", set.Keys[0].Kty)
+	assert.Equal(t, "a-rsa", set.Keys[0].Kid)
+	assert.Equal(t, AlgRS256, set.Keys[0].Alg)
+	n, err := base64.RawURLEncoding.DecodeString(set.Keys[0].N)
+	require.NoError(t, err)
+	assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(rsaKey.verifyKey.(*rsa.PublicKey).N))
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+
+	assert.Equal(t, "OKP", set.Keys[1].Kty)
+	assert.Equal(t, "Ed25519", set.Keys[1].Crv)
+	assert.Equal(t, "b-ed", set.Keys[1].Kid)
+	assert.NotEmpty(t, set.Keys[1].X)
+}
+
+func TestLoadKeySet_Config(t *testing.T) {
+	dir := t.TempDir()
+	_, data, err := GenerateKey("", AlgEdDSA)
+	require.NoError(t, err)
+	file := filepath.Join(dir, "ed.pem")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+
+	ks, err := LoadKeySet(&config.JWTConfig{
+		Secret:     testSecret,
+		SigningKey: "from-file",
+		Keys:       []config.JWTKeyConfig{{ID: "from-file", PrivateKeyFile: file}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", ks.SigningKeyID())
+
+	_, err = LoadKeySet(&config.JWTConfig{Keys: []config.JWTKeyConfig{{ID: "empty"}}})
+	assert.Error(t, err)
+	_, err = LoadKeySet(&config.JWTConfig{})
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
+
+func TestRotator_Tick(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.JWTConfig{Secret: testSecret, KeyDir: dir, RotateHours: 24, Algorithm: AlgEdDSA}
+	ks, err := LoadKeySet(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "", ks.SigningKeyID())
+
+	rotator := NewRotator(ks, cfg, nil)
+	ctx := context.Background()
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// 第一次生成密钥，生效前仍使用 secret 签名，但新密钥已可用于验证
+	require.NoError(t, rotator.Tick(ctx, start))
+	assert.Equal(t, "", ks.SigningKeyID())
+	require.Len(t, ks.JWKS().Keys, 1)
+	first := ks.JWKS().Keys[0].Kid
+
+	// 生效后用于签名，未到轮换间隔不会生成新密钥
+	require.NoError(t, rotator.Tick(ctx, start.Add(keyActivationDelay)))
+	assert.Equal(t, first, ks.SigningKeyID())
+	require.NoError(t, rotator.Tick(ctx, start.Add(time.Hour)))
+	assert.Len(t, ks.JWKS().Keys, 1)
+
+	token, err := ks.Sign(&Claims{UserID: 9}, time.Hour)
+	require.NoError(t, err)
+
+	// 到期轮换，新密钥生效后旧密钥签发的 Token 仍可验证
+	now := start.Add(25 * time.Hour)
+	require.NoError(t, rotator.Tick(ctx, now))
+	require.NoError(t, rotator.Tick(ctx, now.Add(keyActivationDelay)))
+	assert.NotEqual(t, first, ks.SigningKeyID())
+	_, err = ks.Parse(token)
+	assert.NoError(t, err)
+
+	// 只保留最近的 retainedKeys 个密钥，被删除的密钥签发的 Token 不再有效
+	for i := 0; i < 3; i++ {
+		now = now.Add(25 * time.Hour)
+		require.NoError(t, rotator.Tick(ctx, now))
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, retainedKeys)
+	assert.Len(t, ks.JWKS().Keys, retainedKeys)
+	_, err = ks.Parse(token)
+	assert.Equal(t, ErrInvalidToken, err)
+}
@@ -0,0 +1,265 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
+)
+
+const (
+	// rotateCheckInterval 重新加载密钥和检查是否需要轮换的间隔
+	rotateCheckInterval = time.Minute
+	// keyActivationDelay 新密钥生成后等待其它实例加载的时间，之后才用于签名
+	keyActivationDelay = 10 * time.Minute
+	// retainedKeys key_dir 中保留的密钥数，更早的密钥在轮换时删除
+	retainedKeys  = 3
+	rotateLockKey = "jwt:rotate"
+)
+
+// LoadKeySet 从配置和 key_dir 加载密钥集
+func LoadKeySet(cfg *config.JWTConfig) (*KeySet, error) {
+	ks := &KeySet{}
+	if err := ks.Load(cfg, time.Now()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Load 重新从配置和 key_dir 加载全部密钥
+// 签名密钥依次取 signing_key、key_dir 中已生效的最新密钥、secret
+func (ks *KeySet) Load(cfg *config.JWTConfig, now time.Time) error {
+	var keys []*Key
+	if cfg.Secret != "" {
+		keys = append(keys, NewSecretKey("", cfg.Secret))
+	}
+
+	for _, kc := range cfg.Keys {
+		key, err := loadConfigKey(&kc)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	dirKeys, err := loadKeyDir(cfg.KeyDir)
+	if err != nil {
+		return err
+	}
+	keys = append(keys, dirKeys...)
+
+	signingID, err := selectSigningKey(cfg, dirKeys, now)
+	if err != nil {
+		return err
+	}
+	return ks.Replace(signingID, keys...)
+}
+
+func selectSigningKey(cfg *config.JWTConfig, dirKeys []*Key, now time.Time) (string, error) {
+	if cfg.SigningKey != "" {
+		return cfg.SigningKey, nil
+	}
+	// dirKeys 按创建时间从新到旧排列
+	for _, key := range dirKeys {
+		if now.Sub(key.CreatedAt) >= keyActivationDelay {
+			return key.ID, nil
+		}
+	}
+	if cfg.Secret != "" {
+		return "", nil
+	}
+	// 没有 secret 可以回退时，直接使用最新的密钥
+	if len(dirKeys) > 0 {
+		return dirKeys[0].ID, nil
+	}
+	return "", ErrNoSigningKey
+}
+
+func loadConfigKey(kc *config.JWTKeyConfig) (*Key, error) {
+	if kc.ID == "" {
+		return nil, errors.New("jwt key without kid")
+	}
+	private, err := readPEM(kc.PrivateKey, kc.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", kc.ID, err)
+	}
+	if private != nil {
+		return ParsePrivateKeyPEM(kc.ID, private)
+	}
+	public, err := readPEM(kc.PublicKey, kc.PublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", kc.ID, err)
+	}
+	if public != nil {
+		return ParsePublicKeyPEM(kc.ID, public)
+	}
+	return nil, fmt.Errorf("key %s: no private or public key", kc.ID)
+}
+
+func readPEM(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
+
+// loadKeyDir 加载目录中的 <kid>.pem 私钥，以文件修改时间为创建时间，按从新到旧排列
+func loadKeyDir(dir string) ([]*Key, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePrivateKeyPEM(strings.TrimSuffix(name, ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		key.CreatedAt = info.ModTime()
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// RotateKey 在 dir 中生成一个新密钥，返回 kid
+// 新密钥在 keyActivationDelay 之后才会被选为签名密钥
+func RotateKey(dir, algorithm string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	kid := now.UTC().Format("20060102T150405Z")
+	_, data, err := GenerateKey(kid, algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	// 先写临时文件再改名，避免其它实例读到不完整的文件
+	tmp := filepath.Join(dir, "."+kid+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	// 创建时间以文件修改时间为准
+	if err := os.Chtimes(path, now, now); err != nil {
+		return "", err
+	}
+	return kid, nil
+}
+
+// Rotator 定期重新加载密钥集，并按 rotate_hours 在 key_dir 中生成新密钥、删除过旧的密钥
+// 多个实例共享 key_dir，生成和删除密钥通过分布式锁保证只在一个实例上执行
+type Rotator struct {
+	keys   *KeySet
+	cfg    *config.JWTConfig
+	locker *lock.Locker
+}
+
+func NewRotator(keys *KeySet, cfg *config.JWTConfig, locker *lock.Locker) *Rotator {
+	return &Rotator{
+		keys:   keys,
+		cfg:    cfg,
+		locker: locker,
+	}
+}
+
+// Run 每分钟执行一次 Tick，直到 ctx 取消
+func (r *Rotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(rotateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Tick(ctx, time.Now()); err != nil {
+				log.Printf("JWT key rotation: %v", err)
+			}
+		}
+	}
+}
+
+// Tick 到期时生成新密钥，然后重新加载密钥集
+func (r *Rotator) Tick(ctx context.Context, now time.Time) error {
+	if r.cfg.RotateHours > 0 && r.cfg.KeyDir != "" {
+		if err := r.rotateIfDue(ctx, now); err != nil {
+			return err
+		}
+	}
+	return r.keys.Load(r.cfg, now)
+}
+
+func (r *Rotator) rotateIfDue(ctx context.Context, now time.Time) error {
+	if r.locker != nil {
+		lk, err := r.locker.Acquire(ctx, rotateLockKey, time.Minute)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer lk.Release(ctx)
+	}
+
+	keys, err := loadKeyDir(r.cfg.KeyDir)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(r.cfg.RotateHours) * time.Hour
+	if len(keys) > 0 && now.Sub(keys[0].CreatedAt) < interval {
+		return nil
+	}
+
+	kid, err := RotateKey(r.cfg.KeyDir, r.cfg.Algorithm, now)
+	if err != nil {
+		return err
+	}
+	log.Printf("JWT key rotation: generated key %s", kid)
+
+	// 新密钥加上保留的旧密钥共 retainedKeys 个，配置中指定的签名密钥不删除
+	for i := retainedKeys - 1; i < len(keys); i++ {
+		if keys[i].ID == r.cfg.SigningKey {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.cfg.KeyDir, keys[i].ID+".pem")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		log.Printf("JWT key rotation: removed key %s", keys[i].ID)
+	}
+	return nil
+}
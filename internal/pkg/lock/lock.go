@@ -0,0 +1,65 @@
+// Package lock 基于 Redis 的分布式锁，用于多副本部署时保证定时任务只在一个实例上执行
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNotAcquired 锁已被其他实例持有
+var ErrNotAcquired = errors.New("lock not acquired")
+
+// releaseScript 只删除自己持有的锁，避免锁过期后误删其他实例的锁
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type Locker struct {
+	client *redis.Client
+}
+
+func NewLocker(client *redis.Client) *Locker {
+	return &Locker{client: client}
+}
+
+// Lock 已获取的锁
+type Lock struct {
+	client *redis.Client
+	key    string
+	token  string
+}
+
+// Acquire 尝试获取锁，不等待；锁被占用时返回 ErrNotAcquired，ttl 到期后锁自动释放
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err := l.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return &Lock{client: l.client, key: key, token: token}, nil
+}
+
+// Release 释放锁，锁已过期或被其他实例持有时不做任何事
+func (lk *Lock) Release(ctx context.Context) error {
+	if err := releaseScript.Run(ctx, lk.client, []string{lk.key}, lk.token).Err(); err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to release lock %s: %w", lk.key, err)
+	}
+	return nil
+}
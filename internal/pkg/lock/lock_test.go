@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		mr.Close()
+	})
+	return mr, client
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	_, client := setupTestRedis(t)
+	ctx := context.Background()
+
+	// 两个副本竞争同一把锁
+	a, b := NewLocker(client), NewLocker(client)
+
+	lk, err := a.Acquire(ctx, "cron:test", time.Minute)
+	require.NoError(t, err)
+
+	_, err = b.Acquire(ctx, "cron:test", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	require.NoError(t, lk.Release(ctx))
+	lk2, err := b.Acquire(ctx, "cron:test", time.Minute)
+	require.NoError(t, err)
+	assert.NotNil(t, lk2)
+}
+
+func TestLock_Release_Expired(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	ctx := context.Background()
+	locker := NewLocker(client)
+
+	lk, err := locker.Acquire(ctx, "cron:test", time.Second)
+	require.NoError(t, err)
+
+	// 锁过期后被其他实例获取，原持有者释放时不能删除别人的锁
+	mr.FastForward(2 * time.Second)
+	other, err := locker.Acquire(ctx, "cron:test", time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, lk.Release(ctx))
+	_, err = locker.Acquire(ctx, "cron:test", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	require.NoError(t, other.Release(ctx))
+	assert.False(t, mr.Exists("cron:test"))
+}
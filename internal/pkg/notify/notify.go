@@ -0,0 +1,418 @@
+// Package notify 把分析完成、失败等事件投递到用户配置的通知渠道（webhook、邮件），失败后按退避时间重试
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/lock"
+	"github.com/qs3c/anal_go_server/internal/pkg/webhook"
+	"github.com/qs3c/anal_go_server/internal/repository"
+)
+
+// 事件类型
+const (
+	EventAnalysisCompleted = "analysis.completed"
+	EventAnalysisFailed    = "analysis.failed"
+)
+
+// Events 用户可以订阅的全部事件
+var Events = []string{EventAnalysisCompleted, EventAnalysisFailed}
+
+// 渠道类型
+const (
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// 投递状态
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// 发往用户 webhook 的请求头
+const (
+	HeaderEvent     = "X-Anal-Event"
+	HeaderDelivery  = "X-Anal-Delivery"
+	HeaderSignature = "X-Anal-Signature-256" // sha256=<hex>，用渠道密钥对请求体做 HMAC-SHA256
+)
+
+const (
+	// retryLockKey 多实例时只有持有该锁的实例执行重试
+	retryLockKey = "notify:retries"
+	// retryLease 认领一次投递后的租约，实例在发送途中崩溃时租约到期后可被重新认领
+	retryLease = 2 * time.Minute
+	// retryBatch 每轮最多重试的投递数
+	retryBatch = 100
+)
+
+// backoff 第 n 次失败后到下一次尝试的间隔，超出部分使用最后一项
+var backoff = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// ErrPrivateAddress webhook 地址解析到了内网或本机地址
+var ErrPrivateAddress = errors.New("webhook address is not allowed")
+
+// blockedNets net.IP 的判断方法没有覆盖、同样不能访问的地址段
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级 NAT，云厂商 VPC 和元数据代理常用
+	"192.0.0.0/24",    // IETF 协议分配
+	"192.0.2.0/24",    // 文档示例
+	"198.18.0.0/15",   // 基准测试
+	"198.51.100.0/24", // 文档示例
+	"203.0.113.0/24",  // 文档示例
+	"240.0.0.0/4",     // 保留地址和广播地址
+	"::/96",           // IPv4 兼容地址
+	"64:ff9b::/96",    // NAT64，可以映射到任意 IPv4 地址
+	"64:ff9b:1::/48",  // 本地 NAT64
+	"2002::/16",       // 6to4，地址中嵌入了 IPv4 地址
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// blockedIP webhook 不能访问的地址：本机、内网、链路本地、组播和 blockedNets 中的地址段
+// IPv4 映射的 IPv6 地址（::ffff:a.b.c.d）按 IPv4 地址判断
+func blockedIP(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range blockedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Event 需要通知用户的事件
+type Event struct {
+	Type       string
+	UserID     int64
+	AnalysisID int64
+	JobID      int64
+	Title      string
+	Error      string
+	OccurredAt time.Time
+}
+
+// Payload 发往 webhook 的请求体
+type Payload struct {
+	Event      string      `json:"event"`
+	OccurredAt string      `json:"occurred_at"`
+	Data       PayloadData `json:"data"`
+}
+
+// PayloadData 事件内容
+type PayloadData struct {
+	AnalysisID int64  `json:"analysis_id"`
+	JobID      int64  `json:"job_id,omitempty"`
+	Title      string `json:"title"`
+	Status     string `json:"status"` // completed, failed
+	Error      string `json:"error,omitempty"`
+}
+
+// Mailer 发送通知邮件，email.Service 实现了该接口
+type Mailer interface {
+	SendAnalysisResult(to, title string, succeeded bool, detail string) error
+}
+
+type Notifier struct {
+	channelRepo *repository.ChannelRepository
+	userRepo    *repository.UserRepository
+	mailer      Mailer
+	locker      *lock.Locker
+	client      *http.Client
+	maxAttempts int
+}
+
+func NewNotifier(
+	channelRepo *repository.ChannelRepository,
+	userRepo *repository.UserRepository,
+	mailer Mailer,
+	locker *lock.Locker,
+	cfg *config.NotificationConfig,
+) *Notifier {
+	timeout := time.Duration(cfg.WebhookTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if !cfg.AllowPrivateNetworks {
+		// 在连接时检查解析后的地址，避免 DNS 重绑定绕过
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if blockedIP(net.ParseIP(host)) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+
+	return &Notifier{
+		channelRepo: channelRepo,
+		userRepo:    userRepo,
+		mailer:      mailer,
+		locker:      locker,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+			// 不跟随重定向，避免被引导到内网地址
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Notify 为订阅了该事件的每个渠道创建投递记录并立即尝试一次，失败的由 RunRetries 重试
+func (n *Notifier) Notify(ctx context.Context, event *Event) error {
+	channels, err := n.channelRepo.ListEnabled(event.UserID)
+	if err != nil {
+		return err
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	status := "completed"
+	if event.Type == EventAnalysisFailed {
+		status = "failed"
+	}
+	payload, err := json.Marshal(Payload{
+		Event:      event.Type,
+		OccurredAt: event.OccurredAt.UTC().Format(time.RFC3339),
+		Data: PayloadData{
+			AnalysisID: event.AnalysisID,
+			JobID:      event.JobID,
+			Title:      event.Title,
+			Status:     status,
+			Error:      event.Error,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		if !subscribed(channel, event.Type) {
+			continue
+		}
+		// 先占住一个短租约，发送途中崩溃时由重试任务接手
+		next := time.Now().Add(retryLease)
+		delivery := &model.NotificationDelivery{
+			ChannelID:     channel.ID,
+			UserID:        event.UserID,
+			Event:         event.Type,
+			AnalysisID:    event.AnalysisID,
+			JobID:         event.JobID,
+			Payload:       model.JSONText(payload),
+			Status:        StatusPending,
+			NextAttemptAt: &next,
+		}
+		if err := n.channelRepo.CreateDelivery(delivery); err != nil {
+			log.Printf("Notify: failed to create delivery for channel %d: %v", channel.ID, err)
+			continue
+		}
+		n.attempt(ctx, channel, delivery)
+	}
+	return nil
+}
+
+// RunRetries 每分钟重试到期的投递，直到 ctx 取消
+func (n *Notifier) RunRetries(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.RetryDue(ctx, time.Now())
+		}
+	}
+}
+
+// RetryDue 重试到期的投递，返回本轮尝试的次数
+func (n *Notifier) RetryDue(ctx context.Context, now time.Time) int {
+	if n.locker != nil {
+		lk, err := n.locker.Acquire(ctx, retryLockKey, 50*time.Second)
+		if err != nil {
+			if !errors.Is(err, lock.ErrNotAcquired) {
+				log.Printf("Notify: failed to acquire lock: %v", err)
+			}
+			return 0
+		}
+		defer lk.Release(ctx)
+	}
+
+	deliveries, err := n.channelRepo.ListDueDeliveries(now, retryBatch)
+	if err != nil {
+		log.Printf("Notify: failed to list due deliveries: %v", err)
+		return 0
+	}
+
+	attempted := 0
+	for _, delivery := range deliveries {
+		claimed, err := n.channelRepo.ClaimDelivery(delivery.ID, now, retryLease)
+		if err != nil || !claimed {
+			continue
+		}
+		channel, err := n.channelRepo.GetByID(delivery.ChannelID)
+		if err != nil || !channel.Enabled {
+			// 渠道已删除或停用，不再重试
+			n.finish(delivery, StatusFailed, 0, "渠道已删除或停用")
+			continue
+		}
+		n.attempt(ctx, channel, delivery)
+		attempted++
+	}
+	return attempted
+}
+
+// attempt 发送一次并更新投递记录，失败且未超过次数时安排下一次重试
+func (n *Notifier) attempt(ctx context.Context, channel *model.NotificationChannel, delivery *model.NotificationDelivery) {
+	delivery.Attempts++
+
+	var statusCode int
+	var err error
+	switch channel.Type {
+	case ChannelWebhook:
+		statusCode, err = n.sendWebhook(ctx, channel, delivery)
+	case ChannelEmail:
+		err = n.sendEmail(channel, delivery)
+	default:
+		err = fmt.Errorf("unknown channel type %q", channel.Type)
+	}
+
+	if err == nil {
+		n.finish(delivery, StatusSucceeded, statusCode, "")
+		return
+	}
+	if delivery.Attempts >= n.maxAttempts {
+		n.finish(delivery, StatusFailed, statusCode, err.Error())
+		return
+	}
+	wait := backoff[min(delivery.Attempts, len(backoff))-1]
+	next := time.Now().Add(wait)
+	delivery.NextAttemptAt = &next
+	delivery.ResponseStatus = statusCode
+	delivery.LastError = truncate(err.Error())
+	if err := n.channelRepo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Notify: failed to update delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func (n *Notifier) finish(delivery *model.NotificationDelivery, status string, statusCode int, lastError string) {
+	delivery.Status = status
+	delivery.NextAttemptAt = nil
+	delivery.ResponseStatus = statusCode
+	delivery.LastError = truncate(lastError)
+	if err := n.channelRepo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Notify: failed to update delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// sendWebhook POST 签名后的请求体，2xx 视为成功
+func (n *Notifier) sendWebhook(ctx context.Context, channel *model.NotificationChannel, delivery *model.NotificationDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "anal-go-server-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, webhook.Sign([]byte(channel.Secret), delivery.Payload))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// sendEmail 发送到用户账号的邮箱
+func (n *Notifier) sendEmail(channel *model.NotificationChannel, delivery *model.NotificationDelivery) error {
+	if n.mailer == nil {
+		return errors.New("email is not configured")
+	}
+	user, err := n.userRepo.GetByID(channel.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email == nil || *user.Email == "" {
+		return errors.New("user has no email")
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(delivery.Payload, &payload); err != nil {
+		return err
+	}
+	succeeded := payload.Event == EventAnalysisCompleted
+	detail := fmt.Sprintf("分析ID：%d", payload.Data.AnalysisID)
+	if payload.Data.Error != "" {
+		detail = payload.Data.Error
+	}
+	return n.mailer.SendAnalysisResult(*user.Email, payload.Data.Title, succeeded, detail)
+}
+
+func subscribed(channel *model.NotificationChannel, event string) bool {
+	for _, e := range channel.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string) string {
+	if r := []rune(s); len(r) > 500 {
+		return string(r[:500])
+	}
+	return s
+}
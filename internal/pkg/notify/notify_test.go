@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/webhook"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+type fakeMailer struct {
+	to        string
+	title     string
+	succeeded bool
+	detail    string
+	err       error
+}
+
+func (m *fakeMailer) SendAnalysisResult(to, title string, succeeded bool, detail string) error {
+	m.to, m.title, m.succeeded, m.detail = to, title, succeeded, detail
+	return m.err
+}
+
+func setupNotifier(t *testing.T, mailer Mailer) (*gorm.DB, *repository.ChannelRepository, *Notifier) {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+	t.Cleanup(func() { testutil.CleanupTestDB(t, db) })
+
+	channelRepo := repository.NewChannelRepository(db)
+	notifier := NewNotifier(channelRepo, repository.NewUserRepository(db), mailer, nil, &config.NotificationConfig{
+		WebhookTimeoutSeconds: 5,
+		MaxAttempts:           3,
+		AllowPrivateNetworks:  true,
+	})
+	return db, channelRepo, notifier
+}
+
+func createChannel(t *testing.T, repo *repository.ChannelRepository, channel *model.NotificationChannel) *model.NotificationChannel {
+	t.Helper()
+	require.NoError(t, repo.Create(channel))
+	return channel
+}
+
+func TestNotifier_Notify_Webhook(t *testing.T) {
+	var received []byte
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		headers = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	db, repo, notifier := setupNotifier(t, nil)
+	user := testutil.TestUser(t, db)
+	channel := createChannel(t, repo, &model.NotificationChannel{
+		UserID: user.ID, Type: ChannelWebhook, URL: server.URL, Secret: "s3cret",
+		Events: model.StringArray{EventAnalysisCompleted}, Enabled: true,
+	})
+
+	err := notifier.Notify(context.Background(), &Event{
+		Type: EventAnalysisCompleted, UserID: user.ID, AnalysisID: 7, JobID: 9, Title: "demo",
+	})
+	require.NoError(t, err)
+
+	// 签名可以用渠道密钥校验
+	require.NotEmpty(t, received)
+	assert.NoError(t, webhook.VerifySignature([]byte("s3cret"), received, headers.Get(HeaderSignature)))
+	assert.Equal(t, EventAnalysisCompleted, headers.Get(HeaderEvent))
+	assert.NotEmpty(t, headers.Get(HeaderDelivery))
+
+	var payload Payload
+	require.NoError(t, json.Unmarshal(received, &payload))
+	assert.Equal(t, EventAnalysisCompleted, payload.Event)
+	assert.Equal(t, int64(7), payload.Data.AnalysisID)
+	assert.Equal(t, "completed", payload.Data.Status)
+
+	deliveries, err := repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, StatusSucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Nil(t, deliveries[0].NextAttemptAt)
+}
+
+func TestNotifier_Notify_SkipsUnsubscribed(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer server.Close()
+
+	db, repo, notifier := setupNotifier(t, nil)
+	user := testutil.TestUser(t, db)
+	channel := createChannel(t, repo, &model.NotificationChannel{
+		UserID: user.ID, Type: ChannelWebhook, URL: server.URL, Secret: "s",
+		Events: model.StringArray{EventAnalysisFailed}, Enabled: true,
+	})
+
+	require.NoError(t, notifier.Notify(context.Background(), &Event{Type: EventAnalysisCompleted, UserID: user.ID, AnalysisID: 1}))
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+	deliveries, err := repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestNotifier_RetryUntilFailed(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	db, repo, notifier := setupNotifier(t, nil)
+	user := testutil.TestUser(t, db)
+	channel := createChannel(t, repo, &model.NotificationChannel{
+		UserID: user.ID, Type: ChannelWebhook, URL: server.URL, Secret: "s",
+		Events: model.StringArray{EventAnalysisFailed}, Enabled: true,
+	})
+
+	require.NoError(t, notifier.Notify(context.Background(), &Event{
+		Type: EventAnalysisFailed, UserID: user.ID, AnalysisID: 1, Error: "boom",
+	}))
+
+	deliveries, err := repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, StatusPending, deliveries[0].Status)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+	require.NotNil(t, deliveries[0].NextAttemptAt)
+
+	// 还没到重试时间
+	assert.Equal(t, 0, notifier.RetryDue(context.Background(), time.Now()))
+
+	// 最多 3 次，之后标记为失败
+	assert.Equal(t, 1, notifier.RetryDue(context.Background(), time.Now().Add(time.Hour)))
+	assert.Equal(t, 1, notifier.RetryDue(context.Background(), time.Now().Add(3*time.Hour)))
+	assert.Equal(t, 0, notifier.RetryDue(context.Background(), time.Now().Add(24*time.Hour)))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	deliveries, err = repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Contains(t, deliveries[0].LastError, "500")
+}
+
+func TestNotifier_Email(t *testing.T) {
+	mailer := &fakeMailer{}
+	db, repo, notifier := setupNotifier(t, mailer)
+	user := testutil.TestUser(t, db, testutil.WithEmail("owner@example.com"))
+	channel := createChannel(t, repo, &model.NotificationChannel{
+		UserID: user.ID, Type: ChannelEmail, Events: model.StringArray{EventAnalysisFailed}, Enabled: true,
+	})
+
+	require.NoError(t, notifier.Notify(context.Background(), &Event{
+		Type: EventAnalysisFailed, UserID: user.ID, AnalysisID: 3, Title: "demo", Error: "仓库克隆失败",
+	}))
+
+	assert.Equal(t, "owner@example.com", mailer.to)
+	assert.Equal(t, "demo", mailer.title)
+	assert.False(t, mailer.succeeded)
+	assert.Equal(t, "仓库克隆失败", mailer.detail)
+
+	// 发送失败时进入重试
+	mailer.err = errors.New("smtp unavailable")
+	require.NoError(t, notifier.Notify(context.Background(), &Event{Type: EventAnalysisFailed, UserID: user.ID, AnalysisID: 4}))
+	deliveries, err := repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, StatusPending, deliveries[0].Status)
+	assert.Equal(t, "smtp unavailable", deliveries[0].LastError)
+}
+
+func TestNotifier_BlocksPrivateNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	repo := repository.NewChannelRepository(db)
+	notifier := NewNotifier(repo, repository.NewUserRepository(db), nil, nil, &config.NotificationConfig{MaxAttempts: 1})
+
+	user := testutil.TestUser(t, db)
+	channel := createChannel(t, repo, &model.NotificationChannel{
+		UserID: user.ID, Type: ChannelWebhook, URL: server.URL, Secret: "s",
+		Events: model.StringArray{EventAnalysisCompleted}, Enabled: true,
+	})
+
+	require.NoError(t, notifier.Notify(context.Background(), &Event{Type: EventAnalysisCompleted, UserID: user.ID, AnalysisID: 1}))
+
+	deliveries, err := repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, StatusFailed, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, "not allowed")
+}
+
+func TestBlockedIP(t *testing.T) {
+	for _, addr := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"0.0.0.0", "0.1.2.3", "100.64.0.1", "100.100.100.200", "100.127.255.255",
+		"192.0.0.170", "198.18.0.1", "198.19.255.255", "240.0.0.1", "255.255.255.255",
+		"::1", "::", "fd00::1", "fe80::1", "ff02::1",
+		"::ffff:127.0.0.1", "::ffff:100.64.0.1", "::127.0.0.1", "::a9fe:a9fe",
+		"64:ff9b::a9fe:a9fe", "64:ff9b:1::1", "2002:7f00:1::",
+	} {
+		assert.True(t, blockedIP(net.ParseIP(addr)), addr)
+	}
+	for _, addr := range []string{"8.8.8.8", "100.63.255.255", "100.128.0.1", "198.20.0.1", "2606:4700:4700::1111", "::ffff:8.8.8.8"} {
+		assert.False(t, blockedIP(net.ParseIP(addr)), addr)
+	}
+	assert.True(t, blockedIP(nil))
+}
@@ -17,6 +17,8 @@ type GithubUser struct {
 	Email     string `json:"email"`
 	AvatarURL string `json:"avatar_url"`
 	Name      string `json:"name"`
+	// EmailVerified 邮箱是否已在 GitHub 验证，来自 /user/emails
+	EmailVerified bool `json:"-"`
 }
 
 type GithubOAuth struct {
@@ -65,39 +67,56 @@ func (g *GithubOAuth) GetUser(ctx context.Context, token *oauth2.Token) (*Github
 		return nil, fmt.Errorf("failed to decode user info: %w", err)
 	}
 
-	// 如果邮箱为空，尝试获取主邮箱
-	if user.Email == "" {
-		email, err := g.getPrimaryEmail(ctx, client)
-		if err == nil {
-			user.Email = email
-		}
+	// 邮箱为空时使用主邮箱，并记录邮箱是否已验证
+	emails, err := g.getEmails(client)
+	if err == nil {
+		user.Email, user.EmailVerified = selectGithubEmail(user.Email, emails)
 	}
 
 	return &user, nil
 }
 
-func (g *GithubOAuth) getPrimaryEmail(ctx context.Context, client *http.Client) (string, error) {
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (g *GithubOAuth) getEmails(client *http.Client) ([]githubEmail, error) {
 	resp, err := client.Get("https://api.github.com/user/emails")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var emails []struct {
-		Email   string `json:"email"`
-		Primary bool   `json:"primary"`
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github api status %d", resp.StatusCode)
 	}
+
+	var emails []githubEmail
 	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
-		return "", err
+		return nil, err
 	}
+	return emails, nil
+}
 
+// selectGithubEmail 公开邮箱为空时依次选择主邮箱、第一个邮箱，返回邮箱及其是否已验证
+func selectGithubEmail(public string, emails []githubEmail) (string, bool) {
+	if public != "" {
+		for _, e := range emails {
+			if e.Email == public {
+				return public, e.Verified
+			}
+		}
+		return public, false
+	}
 	for _, e := range emails {
 		if e.Primary {
-			return e.Email, nil
+			return e.Email, e.Verified
 		}
 	}
 	if len(emails) > 0 {
-		return emails[0].Email, nil
+		return emails[0].Email, emails[0].Verified
 	}
-	return "", nil
+	return "", false
 }
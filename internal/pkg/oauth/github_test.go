@@ -159,3 +159,23 @@ func TestGithubOAuth_SpecialCharactersInState(t *testing.T) {
 
 	assert.Contains(t, url, "state=state-with-special-chars_123")
 }
+
+func TestSelectGithubEmail(t *testing.T) {
+	emails := []githubEmail{
+		{Email: "old@example.com", Verified: false},
+		{Email: "main@example.com", Primary: true, Verified: true},
+	}
+
+	email, verified := selectGithubEmail("", emails)
+	assert.Equal(t, "main@example.com", email)
+	assert.True(t, verified)
+
+	// 公开邮箱以 /user/emails 中的验证状态为准
+	email, verified = selectGithubEmail("old@example.com", emails)
+	assert.Equal(t, "old@example.com", email)
+	assert.False(t, verified)
+
+	email, verified = selectGithubEmail("unknown@example.com", nil)
+	assert.Equal(t, "unknown@example.com", email)
+	assert.False(t, verified)
+}
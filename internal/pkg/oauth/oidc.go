@@ -0,0 +1,364 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+const (
+	// oidcDiscoveryTTL 发现文档的缓存时间
+	oidcDiscoveryTTL = time.Hour
+	// jwksRefreshInterval 遇到未知 kid 时重新获取 JWKS 的最小间隔，防止伪造的 kid 频繁触发请求
+	jwksRefreshInterval = time.Minute
+	// idTokenLeeway 校验 ID Token 时间时允许的时钟偏差
+	idTokenLeeway = time.Minute
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// oidcSigningMethods 接受的 ID Token 签名算法
+var oidcSigningMethods = []string{"RS256", "ES256", "EdDSA"}
+
+// OIDCClaims ID Token 中映射到用户的声明
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Picture           string
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string   `json:"nonce"`
+	AuthorizedParty   string   `json:"azp"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+}
+
+// flexBool 部分 IdP 以字符串 "true" 返回 email_verified
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	*b = flexBool(string(data) == "true" || string(data) == `"true"`)
+	return nil
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey JWKS 中的一个公钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDC 按配置接入的 OpenID Connect 身份提供方
+// 端点通过发现文档获取，授权码流程使用 PKCE，ID Token 用 IdP 的 JWKS 验证
+type OIDC struct {
+	cfg    *config.OIDCConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	discoveredAt  time.Time
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// NewOIDC 创建 OIDC 客户端，第一次使用时才请求发现文档
+func NewOIDC(cfg *config.OIDCConfig) *OIDC {
+	return &OIDC{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name 身份提供方的显示名称
+func (o *OIDC) Name() string {
+	if o.cfg.Name != "" {
+		return o.cfg.Name
+	}
+	return "OIDC"
+}
+
+// GenerateVerifier 生成 PKCE code verifier
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// GenerateNonce 生成写入 ID Token 的 nonce，用于防止 ID Token 重放
+func GenerateNonce() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthURL 授权地址，nonce 写入 ID Token，verifier 用于 PKCE
+func (o *OIDC) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	oc, err := o.oauth2Config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oc.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange 用授权码和 PKCE verifier 换取 token，验证其中的 ID Token 并返回声明
+func (o *OIDC) Exchange(ctx context.Context, code, verifier, nonce string) (*OIDCClaims, error) {
+	oc, err := o.oauth2Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := oc.Exchange(context.WithValue(ctx, oauth2.HTTPClient, o.client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%w: missing id_token in token response", ErrInvalidIDToken)
+	}
+	return o.VerifyIDToken(ctx, rawIDToken, nonce)
+}
+
+// VerifyIDToken 校验 ID Token 的签名、issuer、audience、有效期和 nonce
+func (o *OIDC) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCClaims, error) {
+	discovery, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims idTokenClaims
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return o.publicKey(ctx, kid)
+	},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(o.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	// 有多个 audience 时 azp 必须是本应用
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != o.cfg.ClientID {
+		return nil, fmt.Errorf("%w: unexpected azp %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+
+	return &OIDCClaims{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+		Picture:           claims.Picture,
+	}, nil
+}
+
+func (o *OIDC) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	discovery, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scopes := o.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &oauth2.Config{
+		ClientID:     o.cfg.ClientID,
+		ClientSecret: o.cfg.ClientSecret,
+		RedirectURL:  o.cfg.RedirectURI,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}, nil
+}
+
+// discover 获取并缓存发现文档，文档中的 issuer 必须与配置一致
+func (o *OIDC) discover(ctx context.Context) (*oidcDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.discovery != nil && time.Since(o.discoveredAt) < oidcDiscoveryTTL {
+		return o.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(o.cfg.Issuer, "/")
+	var discovery oidcDiscovery
+	if err := o.getJSON(ctx, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to get discovery document: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", discovery.Issuer, o.cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document missing endpoints")
+	}
+
+	o.discovery = &discovery
+	o.discoveredAt = time.Now()
+	return o.discovery, nil
+}
+
+// publicKey 按 kid 查找验证密钥，未知的 kid 可能是 IdP 轮换了密钥，间隔 jwksRefreshInterval 重新获取
+// kid 为空时只有 JWKS 中恰好一个密钥才使用
+func (o *OIDC) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	discovery, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if key := o.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(o.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys, err := o.fetchJWKS(ctx, discovery.JWKSURI)
+	o.keysFetchedAt = time.Now()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get jwks: %w", err)
+	}
+	o.keys = keys
+
+	if key := o.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (o *OIDC) lookupKey(kid string) interface{} {
+	if kid == "" && len(o.keys) == 1 {
+		for _, key := range o.keys {
+			return key
+		}
+	}
+	return o.keys[kid]
+}
+
+func (o *OIDC) fetchJWKS(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := o.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// 跳过不支持的密钥类型，不影响其它密钥
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC point")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func (o *OIDC) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
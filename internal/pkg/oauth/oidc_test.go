@@ -0,0 +1,120 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func newTestOIDC(t *testing.T) (*OIDC, *testutil.FakeOIDC) {
+	idp := testutil.NewFakeOIDC(t, "client-1")
+	return NewOIDC(&config.OIDCConfig{
+		Issuer:       idp.Issuer(),
+		ClientID:     "client-1",
+		ClientSecret: "secret-1",
+		RedirectURI:  "http://localhost:8080/api/v1/auth/oidc/callback",
+	}), idp
+}
+
+func TestOIDC_AuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	o, idp := newTestOIDC(t)
+
+	verifier := GenerateVerifier()
+	authURL, err := o.AuthURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+	assert.Contains(t, authURL, idp.Issuer()+"/authorize")
+	assert.Contains(t, authURL, "scope=openid+email+profile")
+
+	code, state := idp.Authorize(t, authURL, jwt.MapClaims{
+		"sub": "user-1", "email": "alice@corp.example.com", "email_verified": "true",
+		"preferred_username": "alice", "name": "Alice",
+	})
+	assert.Equal(t, "state-1", state)
+
+	t.Run("错误的 verifier 被拒绝", func(t *testing.T) {
+		code, _ := idp.Authorize(t, authURL, jwt.MapClaims{"sub": "user-1"})
+		_, err := o.Exchange(ctx, code, GenerateVerifier(), "nonce-1")
+		assert.Error(t, err)
+	})
+
+	claims, err := o.Exchange(ctx, code, verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "alice@corp.example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, "alice", claims.PreferredUsername)
+	assert.Equal(t, "Alice", claims.Name)
+}
+
+func TestOIDC_VerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	o, idp := newTestOIDC(t)
+
+	claims, err := o.VerifyIDToken(ctx, idp.SignIDToken(t, jwt.MapClaims{"sub": "user-1", "nonce": "n"}), "n")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.False(t, claims.EmailVerified)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"nonce 不匹配", jwt.MapClaims{"sub": "user-1", "nonce": "other"}},
+		{"audience 不匹配", jwt.MapClaims{"sub": "user-1", "nonce": "n", "aud": "client-2"}},
+		{"多个 audience 且 azp 不是本应用", jwt.MapClaims{"sub": "user-1", "nonce": "n", "aud": []string{"client-1", "client-2"}, "azp": "client-2"}},
+		{"issuer 不匹配", jwt.MapClaims{"sub": "user-1", "nonce": "n", "iss": "https://evil.example.com"}},
+		{"已过期", jwt.MapClaims{"sub": "user-1", "nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()}},
+		{"缺少 sub", jwt.MapClaims{"nonce": "n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := o.VerifyIDToken(ctx, idp.SignIDToken(t, tt.claims), "n")
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+
+	t.Run("签名被篡改", func(t *testing.T) {
+		token := idp.SignIDToken(t, jwt.MapClaims{"sub": "user-1", "nonce": "n"})
+		_, err := o.VerifyIDToken(ctx, token[:len(token)-4]+"AAAA", "n")
+		assert.ErrorIs(t, err, ErrInvalidIDToken)
+	})
+}
+
+func TestOIDC_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	o, idp := newTestOIDC(t)
+
+	_, err := o.VerifyIDToken(ctx, idp.SignIDToken(t, jwt.MapClaims{"sub": "user-1", "nonce": "n"}), "n")
+	require.NoError(t, err)
+
+	idp.RotateKey(t)
+	token := idp.SignIDToken(t, jwt.MapClaims{"sub": "user-1", "nonce": "n"})
+
+	// 刚获取过 JWKS，未知 kid 不会立即重新请求
+	_, err = o.VerifyIDToken(ctx, token, "n")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	o.keysFetchedAt = time.Now().Add(-jwksRefreshInterval)
+	_, err = o.VerifyIDToken(ctx, token, "n")
+	assert.NoError(t, err)
+}
+
+func TestOIDC_DiscoveryIssuerMismatch(t *testing.T) {
+	idp := testutil.NewFakeOIDC(t, "client-1")
+	// 同一个 IdP 换个主机名访问，发现文档中的 issuer 与配置不一致
+	issuer := strings.Replace(idp.Issuer(), "127.0.0.1", "localhost", 1)
+	o := NewOIDC(&config.OIDCConfig{Issuer: issuer, ClientID: "client-1"})
+
+	_, err := o.AuthURL(context.Background(), "state", "nonce", GenerateVerifier())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 const (
 	stateKeyPrefix = "oauth:state:"
 	stateTTL       = 10 * time.Minute
+
+	loginCodeKeyPrefix = "oauth:login:"
+	loginCodeTTL       = time.Minute
 )
 
 // StateStore handles OAuth state parameter storage and validation
@@ -28,11 +32,27 @@ func NewStateStore(rdb *redis.Client) *StateStore {
 // StateData holds the data associated with an OAuth state
 type StateData struct {
 	RedirectURI string `json:"redirect_uri"`
+	// LinkUserID is set when the flow links a provider account to an
+	// already signed-in user instead of logging in
+	LinkUserID int64 `json:"link_user_id,omitempty"`
+	// LinkBinding is also set in an HttpOnly cookie of the browser that
+	// started the link, so the callback can tell it is finished by the same
+	// browser and not by a victim who was sent the authorize URL
+	LinkBinding string `json:"link_binding,omitempty"`
+	// Nonce and CodeVerifier are kept server-side for OpenID Connect flows
+	// so the callback can check the ID token nonce and complete PKCE
+	Nonce        string `json:"nonce,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
 }
 
 // GenerateState creates a new cryptographically secure state token
 // and stores the associated redirect URI in Redis
 func (s *StateStore) GenerateState(ctx context.Context, redirectURI string) (string, error) {
+	return s.GenerateStateWithData(ctx, &StateData{RedirectURI: redirectURI})
+}
+
+// GenerateStateWithData creates a new state token and stores data with it
+func (s *StateStore) GenerateStateWithData(ctx context.Context, data *StateData) (string, error) {
 	// Generate 32 random bytes (256 bits)
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -40,9 +60,14 @@ func (s *StateStore) GenerateState(ctx context.Context, redirectURI string) (str
 	}
 	state := hex.EncodeToString(bytes)
 
-	// Store state with redirect URI in Redis
+	value, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	// Store state with its data in Redis
 	key := stateKeyPrefix + state
-	if err := s.rdb.Set(ctx, key, redirectURI, stateTTL).Err(); err != nil {
+	if err := s.rdb.Set(ctx, key, value, stateTTL).Err(); err != nil {
 		return "", fmt.Errorf("failed to store state: %w", err)
 	}
 
@@ -52,35 +77,85 @@ func (s *StateStore) GenerateState(ctx context.Context, redirectURI string) (str
 // ValidateState checks if the state is valid and returns the associated redirect URI
 // The state is consumed (deleted) after validation to prevent replay attacks
 func (s *StateStore) ValidateState(ctx context.Context, state string) (string, error) {
+	data, err := s.ValidateStateData(ctx, state)
+	if err != nil {
+		return "", err
+	}
+	return data.RedirectURI, nil
+}
+
+// ValidateStateData checks and consumes the state like ValidateState and returns all of its data
+func (s *StateStore) ValidateStateData(ctx context.Context, state string) (*StateData, error) {
 	if state == "" {
-		return "", fmt.Errorf("empty state parameter")
+		return nil, fmt.Errorf("empty state parameter")
 	}
 
-	key := stateKeyPrefix + state
+	value, err := s.consume(ctx, stateKeyPrefix+state)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired state: %w", err)
+	}
+
+	// States stored before the data was JSON encoded hold the bare redirect URI
+	var data StateData
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return &StateData{RedirectURI: value}, nil
+	}
+	return &data, nil
+}
+
+// GenerateLoginCode stores the result of an OAuth login under a short-lived
+// one-time code. The callback redirects to the frontend with the code only,
+// and the frontend exchanges it for the tokens with a POST, so the tokens
+// never appear in a URL
+func (s *StateStore) GenerateLoginCode(ctx context.Context, result interface{}) (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate login code: %w", err)
+	}
+	code := hex.EncodeToString(bytes)
+
+	value, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode login result: %w", err)
+	}
+	if err := s.rdb.Set(ctx, loginCodeKeyPrefix+code, value, loginCodeTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store login code: %w", err)
+	}
+	return code, nil
+}
+
+// ConsumeLoginCode loads the login result stored under code into result and
+// deletes the code so it can only be exchanged once
+func (s *StateStore) ConsumeLoginCode(ctx context.Context, code string, result interface{}) error {
+	if code == "" {
+		return fmt.Errorf("empty login code")
+	}
+	value, err := s.consume(ctx, loginCodeKeyPrefix+code)
+	if err != nil {
+		return fmt.Errorf("invalid or expired login code: %w", err)
+	}
+	return json.Unmarshal([]byte(value), result)
+}
 
-	// Get and delete atomically using a transaction
-	var redirectURI string
+// consume gets and deletes key atomically using a transaction
+func (s *StateStore) consume(ctx context.Context, key string) (string, error) {
+	var value string
 	err := s.rdb.Watch(ctx, func(tx *redis.Tx) error {
 		val, err := tx.Get(ctx, key).Result()
 		if err == redis.Nil {
-			return fmt.Errorf("invalid or expired state")
+			return fmt.Errorf("not found")
 		}
 		if err != nil {
-			return fmt.Errorf("failed to get state: %w", err)
+			return err
 		}
-		redirectURI = val
+		value = val
 
-		// Delete the state to prevent reuse
+		// Delete the key to prevent reuse
 		_, err = tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 			pipe.Del(ctx, key)
 			return nil
 		})
 		return err
 	}, key)
-
-	if err != nil {
-		return "", err
-	}
-
-	return redirectURI, nil
+	return value, err
 }
@@ -117,3 +117,56 @@ func TestStateStore_GenerateState_Unique(t *testing.T) {
 		states[state] = true
 	}
 }
+
+func TestStateStore_ValidateStateData(t *testing.T) {
+	rdb, cleanup := setupTestRedis(t)
+	defer cleanup()
+
+	store := NewStateStore(rdb)
+	ctx := context.Background()
+
+	state, err := store.GenerateStateWithData(ctx, &StateData{RedirectURI: "http://localhost:3000/settings", LinkUserID: 42})
+	require.NoError(t, err)
+
+	data, err := store.ValidateStateData(ctx, state)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:3000/settings", data.RedirectURI)
+	assert.Equal(t, int64(42), data.LinkUserID)
+
+	// Plain redirect URIs stored by older versions are still accepted
+	require.NoError(t, rdb.Set(ctx, stateKeyPrefix+"legacy", "http://localhost:3000", stateTTL).Err())
+	data, err = store.ValidateStateData(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:3000", data.RedirectURI)
+	assert.Zero(t, data.LinkUserID)
+}
+
+func TestStateStore_LoginCode(t *testing.T) {
+	rdb, cleanup := setupTestRedis(t)
+	defer cleanup()
+
+	store := NewStateStore(rdb)
+	ctx := context.Background()
+
+	type result struct {
+		Token string `json:"token"`
+	}
+	code, err := store.GenerateLoginCode(ctx, &result{Token: "access"})
+	require.NoError(t, err)
+	assert.Len(t, code, 64)
+	ttl := rdb.TTL(ctx, loginCodeKeyPrefix+code).Val()
+	assert.True(t, ttl > 0 && ttl <= loginCodeTTL)
+
+	var got result
+	require.NoError(t, store.ConsumeLoginCode(ctx, code, &got))
+	assert.Equal(t, "access", got.Token)
+
+	// Codes can only be exchanged once
+	assert.Error(t, store.ConsumeLoginCode(ctx, code, &got))
+	assert.Error(t, store.ConsumeLoginCode(ctx, "", &got))
+
+	// A state cannot be exchanged as a login code
+	state, err := store.GenerateState(ctx, "http://localhost:3000")
+	require.NoError(t, err)
+	assert.Error(t, store.ConsumeLoginCode(ctx, state, &got))
+}
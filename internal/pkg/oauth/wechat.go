@@ -0,0 +1,146 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WechatEndpoint 微信开放平台的授权页和接口地址
+type WechatEndpoint struct {
+	AuthURL string // 扫码登录页
+	APIURL  string // sns 接口
+}
+
+// DefaultWechatEndpoint 微信开放平台的正式地址
+var DefaultWechatEndpoint = WechatEndpoint{
+	AuthURL: "https://open.weixin.qq.com/connect/qrconnect",
+	APIURL:  "https://api.weixin.qq.com",
+}
+
+// WechatToken 授权码换取的 access token
+type WechatToken struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	OpenID       string `json:"openid"`
+	Scope        string `json:"scope"`
+	UnionID      string `json:"unionid"`
+}
+
+type WechatUser struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	Nickname   string `json:"nickname"`
+	HeadImgURL string `json:"headimgurl"`
+}
+
+// wechatError 微信接口出错时返回 200 和 errcode
+type wechatError struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// WechatOAuth 微信网站应用扫码登录
+type WechatOAuth struct {
+	appID       string
+	appSecret   string
+	redirectURI string
+	endpoint    WechatEndpoint
+	client      *http.Client
+}
+
+func NewWechatOAuth(appID, appSecret, redirectURI string) *WechatOAuth {
+	return NewWechatOAuthWithEndpoint(appID, appSecret, redirectURI, DefaultWechatEndpoint)
+}
+
+// NewWechatOAuthWithEndpoint 使用指定的接口地址，用于测试
+func NewWechatOAuthWithEndpoint(appID, appSecret, redirectURI string, endpoint WechatEndpoint) *WechatOAuth {
+	return &WechatOAuth{
+		appID:       appID,
+		appSecret:   appSecret,
+		redirectURI: redirectURI,
+		endpoint:    endpoint,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetAuthURL 获取微信扫码登录 URL
+func (w *WechatOAuth) GetAuthURL(state string) string {
+	params := url.Values{
+		"appid":         {w.appID},
+		"redirect_uri":  {w.redirectURI},
+		"response_type": {"code"},
+		"scope":         {"snsapi_login"},
+		"state":         {state},
+	}
+	return w.endpoint.AuthURL + "?" + params.Encode() + "#wechat_redirect"
+}
+
+// Exchange 用授权码换取 access token 和 openid
+func (w *WechatOAuth) Exchange(ctx context.Context, code string) (*WechatToken, error) {
+	var token WechatToken
+	err := w.get(ctx, "/sns/oauth2/access_token", url.Values{
+		"appid":      {w.appID},
+		"secret":     {w.appSecret},
+		"code":       {code},
+		"grant_type": {"authorization_code"},
+	}, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if token.AccessToken == "" || token.OpenID == "" {
+		return nil, fmt.Errorf("failed to exchange code: empty access token or openid")
+	}
+	return &token, nil
+}
+
+// GetUser 获取微信用户信息
+func (w *WechatOAuth) GetUser(ctx context.Context, token *WechatToken) (*WechatUser, error) {
+	var user WechatUser
+	err := w.get(ctx, "/sns/userinfo", url.Values{
+		"access_token": {token.AccessToken},
+		"openid":       {token.OpenID},
+		"lang":         {"zh_CN"},
+	}, &user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	if user.OpenID == "" {
+		user.OpenID = token.OpenID
+	}
+	if user.UnionID == "" {
+		user.UnionID = token.UnionID
+	}
+	return &user, nil
+}
+
+func (w *WechatOAuth) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.endpoint.APIURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wechat api status %d", resp.StatusCode)
+	}
+
+	// 微信接口的 Content-Type 不一定是 JSON，直接按 JSON 解析
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	var apiErr wechatError
+	if err := json.Unmarshal(raw, &apiErr); err == nil && apiErr.ErrCode != 0 {
+		return fmt.Errorf("wechat api error %d: %s", apiErr.ErrCode, apiErr.ErrMsg)
+	}
+	return json.Unmarshal(raw, out)
+}
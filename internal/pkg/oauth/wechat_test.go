@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWechatStub 模拟微信 sns 接口，只接受授权码 good-code
+func newWechatStub(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "text/plain")
+		switch r.URL.Path {
+		case "/sns/oauth2/access_token":
+			assert.Equal(t, "app-id", q.Get("appid"))
+			assert.Equal(t, "app-secret", q.Get("secret"))
+			assert.Equal(t, "authorization_code", q.Get("grant_type"))
+			if q.Get("code") != "good-code" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 40029, "errmsg": "invalid code"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "wx-token", "expires_in": 7200, "openid": "openid-1", "unionid": "union-1",
+			})
+		case "/sns/userinfo":
+			assert.Equal(t, "wx-token", q.Get("access_token"))
+			assert.Equal(t, "openid-1", q.Get("openid"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"openid": "openid-1", "nickname": "微信用户", "headimgurl": "https://wx.example.com/head.png",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWechatOAuth_GetAuthURL(t *testing.T) {
+	oauth := NewWechatOAuth("app-id", "app-secret", "http://localhost/api/v1/auth/wechat/callback")
+
+	authURL := oauth.GetAuthURL("test-state")
+
+	require.True(t, strings.HasSuffix(authURL, "#wechat_redirect"))
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "open.weixin.qq.com", u.Host)
+	assert.Equal(t, "app-id", u.Query().Get("appid"))
+	assert.Equal(t, "snsapi_login", u.Query().Get("scope"))
+	assert.Equal(t, "test-state", u.Query().Get("state"))
+	assert.Equal(t, "http://localhost/api/v1/auth/wechat/callback", u.Query().Get("redirect_uri"))
+}
+
+func TestWechatOAuth_ExchangeAndGetUser(t *testing.T) {
+	server := newWechatStub(t)
+	oauth := NewWechatOAuthWithEndpoint("app-id", "app-secret", "", WechatEndpoint{APIURL: server.URL})
+	ctx := context.Background()
+
+	token, err := oauth.Exchange(ctx, "good-code")
+	require.NoError(t, err)
+	assert.Equal(t, "openid-1", token.OpenID)
+
+	user, err := oauth.GetUser(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "openid-1", user.OpenID)
+	assert.Equal(t, "微信用户", user.Nickname)
+	assert.Equal(t, "https://wx.example.com/head.png", user.HeadImgURL)
+	// userinfo 没有返回 unionid 时使用换取 token 时的 unionid
+	assert.Equal(t, "union-1", user.UnionID)
+
+	// 微信接口以 errcode 表示错误
+	_, err = oauth.Exchange(ctx, "bad-code")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "40029")
+}
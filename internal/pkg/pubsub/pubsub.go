@@ -11,6 +11,7 @@ import (
 
 const (
 	ChannelAnalysisProgress = "analysis_progress"
+	ChannelNotifications    = "notifications"
 )
 
 // ProgressMessage 进度消息
@@ -26,6 +27,13 @@ type ProgressMessage struct {
 	Error      string `json:"error,omitempty"`
 }
 
+// NotificationMessage 新的站内通知，由各实例转发给本机上该用户的 WebSocket 连接
+type NotificationMessage struct {
+	UserID       int64           `json:"user_id"`
+	UnreadCount  int64           `json:"unread_count"`
+	Notification json.RawMessage `json:"notification"`
+}
+
 // 进度阶段常量
 const (
 	StepCloning   = "cloning"
@@ -96,6 +104,15 @@ func (p *Publisher) PublishProgress(ctx context.Context, msg *ProgressMessage) e
 	return nil
 }
 
+// PublishNotification 发布站内通知
+func (p *Publisher) PublishNotification(ctx context.Context, msg *NotificationMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification message: %w", err)
+	}
+	return p.client.Publish(ctx, ChannelNotifications, data).Err()
+}
+
 // Subscriber Redis 订阅者
 type Subscriber struct {
 	client *redis.Client
@@ -131,3 +148,26 @@ func (s *Subscriber) Subscribe(ctx context.Context, handler func(*ProgressMessag
 		}
 	}
 }
+
+// SubscribeNotifications 订阅站内通知
+func (s *Subscriber) SubscribeNotifications(ctx context.Context, handler func(*NotificationMessage)) error {
+	pubsub := s.client.Subscribe(ctx, ChannelNotifications)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var notificationMsg NotificationMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &notificationMsg); err != nil {
+				continue // 忽略解析错误
+			}
+			handler(&notificationMsg)
+		}
+	}
+}
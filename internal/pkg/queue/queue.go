@@ -20,6 +20,7 @@ type JobMessage struct {
 	UserID      int64  `json:"user_id"`
 	SourceType  string `json:"source_type"`
 	RepoURL     string `json:"repo_url"`
+	Ref         string `json:"ref,omitempty"` // 分支、标签或提交，空表示默认分支
 	UploadID    string `json:"upload_id"`
 	StartFile   string `json:"start_file"`
 	StartStruct string `json:"start_struct"`
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	limiter := NewLimiter(client)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		ok, _, err := limiter.Allow(ctx, "resend:a@example.com", 3, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	ok, retryAfter, err := limiter.Allow(ctx, "resend:a@example.com", 3, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Greater(t, retryAfter, time.Duration(0))
+	assert.LessOrEqual(t, retryAfter, time.Minute)
+
+	// 不同 key 互不影响
+	ok, _, err = limiter.Allow(ctx, "resend:b@example.com", 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// 窗口结束后重新计数
+	mr.FastForward(time.Minute)
+	ok, _, err = limiter.Allow(ctx, "resend:a@example.com", 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
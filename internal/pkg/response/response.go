@@ -15,6 +15,7 @@ const (
 	CodeResourceNotFound = 1003
 	CodeQuotaExceeded    = 1004
 	CodeDuplicateAction  = 1005
+	CodeTooManyRequests  = 1006
 	CodeServerError      = 5000
 )
 
@@ -27,6 +28,7 @@ var codeMessages = map[int]string{
 	CodeResourceNotFound: "资源不存在",
 	CodeQuotaExceeded:    "配额不足",
 	CodeDuplicateAction:  "重复操作",
+	CodeTooManyRequests:  "请求过于频繁，请稍后再试",
 	CodeServerError:      "服务器内部错误",
 }
 
@@ -97,6 +99,18 @@ func ParamError(c *gin.Context, message string) {
 	Error(c, CodeParamError, message)
 }
 
+// ParamErrorWithData 参数错误，data 中附带详细错误（如字段级、行级错误列表）
+func ParamErrorWithData(c *gin.Context, message string, data interface{}) {
+	if message == "" {
+		message = codeMessages[CodeParamError]
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    CodeParamError,
+		Message: message,
+		Data:    data,
+	})
+}
+
 // AuthError 认证失败
 func AuthError(c *gin.Context, message string) {
 	if message == "" {
@@ -137,6 +151,14 @@ func DuplicateError(c *gin.Context, message string) {
 	Error(c, CodeDuplicateAction, message)
 }
 
+// TooManyRequestsError 请求过于频繁
+func TooManyRequestsError(c *gin.Context, message string) {
+	if message == "" {
+		message = codeMessages[CodeTooManyRequests]
+	}
+	Error(c, CodeTooManyRequests, message)
+}
+
 // ServerError 服务器错误
 func ServerError(c *gin.Context, message string) {
 	if message == "" {
@@ -188,6 +188,22 @@ func TestParamError(t *testing.T) {
 	}
 }
 
+func TestParamErrorWithData(t *testing.T) {
+	router := gin.New()
+	router.GET("/test", func(c *gin.Context) {
+		ParamErrorWithData(c, "", []map[string]interface{}{{"line": 3, "message": "bad"}})
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	resp := parseResponse(t, w)
+	assert.Equal(t, CodeParamError, resp.Code)
+	assert.Equal(t, "参数错误", resp.Message)
+	assert.Equal(t, []interface{}{map[string]interface{}{"line": float64(3), "message": "bad"}}, resp.Data)
+}
+
 func TestAuthError(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -368,6 +384,42 @@ func TestDuplicateError(t *testing.T) {
 	}
 }
 
+func TestTooManyRequestsError(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		wantMessage string
+	}{
+		{
+			name:        "with custom message",
+			message:     "请 60 秒后再试",
+			wantMessage: "请 60 秒后再试",
+		},
+		{
+			name:        "with empty message",
+			message:     "",
+			wantMessage: "请求过于频繁，请稍后再试",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/test", func(c *gin.Context) {
+				TooManyRequestsError(c, tt.message)
+			})
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			resp := parseResponse(t, w)
+			assert.Equal(t, CodeTooManyRequests, resp.Code)
+			assert.Equal(t, tt.wantMessage, resp.Message)
+		})
+	}
+}
+
 func TestServerError(t *testing.T) {
 	tests := []struct {
 		name        string
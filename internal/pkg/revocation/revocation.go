@@ -0,0 +1,33 @@
+// Package revocation 基于 Redis 的撤销列表，记录在自然过期前被提前撤销的 ID，多实例共享
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const keyPrefix = "revoked:"
+
+type List struct {
+	client *redis.Client
+}
+
+func NewList(client *redis.Client) *List {
+	return &List{client: client}
+}
+
+// Revoke 撤销 id，ttl 应不短于使用该 id 的凭证的剩余有效期，过期后记录自动删除
+func (l *List) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	return l.client.Set(ctx, keyPrefix+id, 1, ttl).Err()
+}
+
+// IsRevoked id 是否已被撤销
+func (l *List) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := l.client.Exists(ctx, keyPrefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
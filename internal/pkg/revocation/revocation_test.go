@@ -0,0 +1,38 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList_Revoke(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	list := NewList(client)
+	ctx := context.Background()
+
+	revoked, err := list.IsRevoked(ctx, "session-1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, list.Revoke(ctx, "session-1", time.Minute))
+	revoked, err = list.IsRevoked(ctx, "session-1")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	// 过期后自动删除
+	mr.FastForward(2 * time.Minute)
+	revoked, err = list.IsRevoked(ctx, "session-1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EncodingGzip 对象按 gzip 压缩存储（Content-Encoding: gzip）
+const EncodingGzip = "gzip"
+
+// Encoded 压缩后的对象内容
+type Encoded struct {
+	Data     []byte
+	Encoding string
+	RawSize  int
+	ETag     string // 按原始内容计算，与编码方式无关
+}
+
+// Compress gzip 压缩原始内容
+func Compress(raw []byte) (*Encoded, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Encoded{
+		Data:     buf.Bytes(),
+		Encoding: EncodingGzip,
+		RawSize:  len(raw),
+		ETag:     ContentETag(raw),
+	}, nil
+}
+
+// Decompress 按 encoding 还原原始内容，encoding 为空时原样返回
+func Decompress(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return data, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		defer r.Close()
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress: %w", err)
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// ContentETag 按内容计算强 ETag（带引号），用于 If-None-Match 比较
+func ContentETag(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// EncodedETag 按 encoding 编码后返回时使用的 ETag，如 "abc" -> "abc-gzip"
+// 同一内容的不同编码是不同的表示，强 ETag 不能相同
+func EncodedETag(etag, encoding string) string {
+	if encoding == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLocalBaseURL 本地存储签名 URL 的默认前缀，对应 GET /api/v1/files/*key
+const DefaultLocalBaseURL = "/api/v1/files"
+
+// Local 本地文件系统存储，签名 URL 由服务端的文件接口校验后返回文件内容
+type Local struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+func NewLocal(root, baseURL, secret string) *Local {
+	if baseURL == "" {
+		baseURL = DefaultLocalBaseURL
+	}
+	return &Local{
+		root:    root,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+	}
+}
+
+func (l *Local) Name() string {
+	return BackendLocal
+}
+
+func (l *Local) Put(ctx context.Context, key string, data []byte, opts PutOptions) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create dir: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// 顺手删除空的上级目录（如 diagrams/<id>/），非空时 Remove 会失败，忽略即可
+	if strings.Count(key, "/") > 1 {
+		os.Remove(filepath.Dir(p))
+	}
+	return nil
+}
+
+// SignedURL 返回 <baseURL>/<key>?expires=<unix>&signature=<hmac>
+func (l *Local) SignedURL(key string, expire time.Duration) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	if len(l.secret) == 0 {
+		return "", ErrNoSignSecret
+	}
+	expires := time.Now().Add(signedExpire(expire)).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", l.sign(key, expires))
+	return l.baseURL + "/" + escapeKey(key) + "?" + query.Encode(), nil
+}
+
+// VerifySignedURL 校验 SignedURL 生成的 expires 和 signature 参数
+func (l *Local) VerifySignedURL(key, expires, signature string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// 没有密钥时签名可以被任意计算，一律拒绝
+	if len(l.secret) == 0 {
+		return ErrInvalidSignURL
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignURL
+	}
+	if !hmac.Equal([]byte(l.sign(key, exp)), []byte(signature)) {
+		return ErrInvalidSignURL
+	}
+	return nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	objInfo := &ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+	}
+	name := key
+	if strings.HasSuffix(name, ".gz") {
+		objInfo.ContentEncoding = EncodingGzip
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	objInfo.ContentType = ContentType(filepath.Ext(name))
+	return objInfo, nil
+}
+
+// Path 返回 key 对应的本地文件路径
+func (l *Local) Path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(mac, "%s\n%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// escapeKey 按路径段转义 key，保留 /
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+// OSS 阿里云 OSS 存储
+type OSS struct {
+	client     *oss.Client
+	bucket     *oss.Bucket
+	bucketName string
+	cdnDomain  string
+}
+
+func NewOSS(cfg *config.OSSConfig) (*OSS, error) {
+	client, err := oss.New(cfg.Endpoint, cfg.AccessKeyID, cfg.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OSS client: %w", err)
+	}
+
+	bucket, err := client.Bucket(cfg.BucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket: %w", err)
+	}
+
+	return &OSS{
+		client:     client,
+		bucket:     bucket,
+		bucketName: cfg.BucketName,
+		cdnDomain:  strings.TrimRight(strings.TrimPrefix(strings.TrimPrefix(cfg.CDNDomain, "https://"), "http://"), "/"),
+	}, nil
+}
+
+func (o *OSS) Name() string {
+	return BackendOSS
+}
+
+func (o *OSS) Put(ctx context.Context, key string, data []byte, opts PutOptions) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	options := []oss.Option{oss.WithContext(ctx)}
+	if opts.ContentType != "" {
+		options = append(options, oss.ContentType(opts.ContentType))
+	}
+	if opts.ContentEncoding != "" {
+		options = append(options, oss.ContentEncoding(opts.ContentEncoding))
+	}
+	err := o.bucket.PutObject(key, bytes.NewReader(data), options...)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+func (o *OSS) Get(ctx context.Context, key string) ([]byte, error) {
+	body, err := o.bucket.GetObject(key, oss.WithContext(ctx))
+	if err != nil {
+		return nil, ossError("failed to download object", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object data: %w", err)
+	}
+	return data, nil
+}
+
+func (o *OSS) Delete(ctx context.Context, key string) error {
+	if err := o.bucket.DeleteObject(key, oss.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (o *OSS) SignedURL(key string, expire time.Duration) (string, error) {
+	signedURL, err := o.bucket.SignURL(key, oss.HTTPGet, int64(signedExpire(expire).Seconds()))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate signed URL: %w", err)
+	}
+
+	// SDK 默认生成 http:// URL，替换为 https://
+	return strings.Replace(signedURL, "http://", "https://", 1), nil
+}
+
+func (o *OSS) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	header, err := o.bucket.GetObjectDetailedMeta(key, oss.WithContext(ctx))
+	if err != nil {
+		return nil, ossError("failed to stat object", err)
+	}
+	return objectInfoFromHeader(key, header), nil
+}
+
+// PublicURL 返回 CDN 或 bucket 域名下的访问地址
+func (o *OSS) PublicURL(key string) string {
+	if o.cdnDomain != "" {
+		return fmt.Sprintf("https://%s/%s", o.cdnDomain, key)
+	}
+	return fmt.Sprintf("https://%s.%s/%s", o.bucketName, o.client.Config.Endpoint, key)
+}
+
+func ossError(msg string, err error) error {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// objectInfoFromHeader 从 HEAD 响应头解析对象元信息（OSS 与 S3 格式一致）
+func objectInfoFromHeader(key string, header http.Header) *ObjectInfo {
+	info := &ObjectInfo{
+		Key:             key,
+		ContentType:     header.Get("Content-Type"),
+		ContentEncoding: header.Get("Content-Encoding"),
+		ETag:            strings.Trim(header.Get("ETag"), `"`),
+	}
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if t, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.LastModified = t
+	}
+	return info
+}
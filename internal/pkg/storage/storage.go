@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+const (
+	BackendOSS   = "oss"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+
+	// DefaultSignedURLExpire 签名 URL 默认有效期
+	DefaultSignedURLExpire = time.Hour
+
+	maxRetries    = 3
+	baseRetryWait = 2 * time.Second
+)
+
+var (
+	ErrNotFound       = errors.New("object not found")
+	ErrInvalidKey     = errors.New("invalid object key")
+	ErrInvalidSignURL = errors.New("invalid or expired signed URL")
+	ErrNoSignSecret   = errors.New("local storage signing secret is not configured")
+)
+
+// Store 对象存储接口，key 为不以 / 开头的相对路径（如 diagrams/1/1700000000.json.gz）
+type Store interface {
+	// Name 返回后端名称（oss、s3、local），随 key 一起记录到数据库
+	Name() string
+	Put(ctx context.Context, key string, data []byte, opts PutOptions) error
+	// Get 读取对象内容，对象不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// SignedURL 生成带签名的临时下载地址，expire <= 0 时使用 DefaultSignedURLExpire
+	SignedURL(key string, expire time.Duration) (string, error)
+	// Stat 查询对象元信息，对象不存在时返回 ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+}
+
+// PublicURLer 可选接口：公共读 bucket 或 CDN 可以返回长期有效的访问地址（如头像）
+type PublicURLer interface {
+	PublicURL(key string) string
+}
+
+// PutOptions 写入对象时附带的元信息，通过签名 URL 下载时作为响应头返回
+// 本地存储不保存元信息，按 key 的扩展名推断（.gz 视为 gzip 编码）
+type PutOptions struct {
+	ContentType     string
+	ContentEncoding string
+}
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key             string
+	Size            int64
+	ContentType     string
+	ContentEncoding string
+	ETag            string
+	LastModified    time.Time
+}
+
+// New 根据配置创建主存储
+// storage.backend 为空时沿用旧行为：配置了 OSS 密钥则使用 OSS，否则使用本地存储
+func New(cfg *config.Config) (Store, error) {
+	backend := cfg.Storage.Backend
+	if backend == "" {
+		backend = BackendLocal
+		if cfg.OSS.Endpoint != "" && cfg.OSS.AccessKeyID != "" {
+			backend = BackendOSS
+		}
+	}
+
+	switch backend {
+	case BackendOSS:
+		return NewOSS(&cfg.OSS)
+	case BackendS3:
+		return NewS3(&cfg.Storage.S3)
+	case BackendLocal:
+		return NewLocalFromConfig(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// NewLocalFromConfig 按配置创建本地存储，目录默认为 upload.temp_dir，签名密钥默认为 jwt.secret
+// 只配置了非对称 JWT 密钥时 jwt.secret 可以为空，此时必须配置 storage.local.secret，
+// 否则任何人都能用空密钥伪造签名 URL
+func NewLocalFromConfig(cfg *config.Config) (*Local, error) {
+	dir := cfg.Storage.Local.Dir
+	if dir == "" {
+		dir = cfg.Upload.TempDir
+	}
+	secret := cfg.Storage.Local.Secret
+	if secret == "" {
+		secret = cfg.JWT.Secret
+	}
+	if secret == "" {
+		return nil, ErrNoSignSecret
+	}
+	return NewLocal(dir, cfg.Storage.Local.BaseURL, secret), nil
+}
+
+// Set 主存储加可选的降级存储
+// 主存储写入失败时降级写入 Fallback，后台再同步回主存储；读取时按数据库记录的后端名称选择 Store
+type Set struct {
+	Primary  Store
+	Fallback Store
+}
+
+// NewSet 创建存储集合，fallback 与 primary 为同一后端时忽略 fallback
+func NewSet(primary, fallback Store) *Set {
+	if primary != nil && fallback != nil && primary.Name() == fallback.Name() {
+		fallback = nil
+	}
+	return &Set{Primary: primary, Fallback: fallback}
+}
+
+// For 返回名称为 backend 的 Store，backend 为空时返回主存储，找不到时返回 nil
+func (s *Set) For(backend string) Store {
+	if s == nil {
+		return nil
+	}
+	if backend == "" || (s.Primary != nil && s.Primary.Name() == backend) {
+		return s.Primary
+	}
+	if s.Fallback != nil && s.Fallback.Name() == backend {
+		return s.Fallback
+	}
+	return nil
+}
+
+// PutWithRetry 带重试的写入，最多重试 3 次，指数退避
+func PutWithRetry(ctx context.Context, store Store, key string, data []byte, opts PutOptions) error {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		err := store.Put(ctx, key, data, opts)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if attempt < maxRetries {
+			wait := baseRetryWait * (1 << attempt) // 2s, 4s, 8s
+			log.Printf("Storage %s put attempt %d failed: %v, retrying in %v", store.Name(), attempt+1, err, wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	return fmt.Errorf("%s put failed after %d retries: %w", store.Name(), maxRetries+1, lastErr)
+}
+
+// DiagramKey 生成分析结果的存储 key，框图统一 gzip 压缩存储
+func DiagramKey(analysisID int64) string {
+	return fmt.Sprintf("diagrams/%d/%d.json.gz", analysisID, time.Now().UnixNano())
+}
+
+// AvatarKey 生成用户头像的存储 key
+func AvatarKey(userID int64, ext string) string {
+	return fmt.Sprintf("avatars/%d/%d%s", userID, time.Now().Unix(), ext)
+}
+
+// ContentType 根据扩展名获取 Content-Type
+func ContentType(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".json":
+		return "application/json"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// checkKey 拒绝空 key、绝对路径和包含 .. 的 key，防止本地存储越界
+func checkKey(key string) error {
+	if key == "" || len(key) > 1024 || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	if path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == ".." || elem == "." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+func signedExpire(expire time.Duration) time.Duration {
+	if expire <= 0 {
+		return DefaultSignedURLExpire
+	}
+	return expire
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+)
+
+func TestLocal_PutGetStatDelete(t *testing.T) {
+	root := t.TempDir()
+	store := NewLocal(root, "", "secret")
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "diagrams/1/100.json", []byte(`{"a":1}`), PutOptions{ContentType: "application/json"}))
+
+	data, err := store.Get(ctx, "diagrams/1/100.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(data))
+
+	info, err := store.Stat(ctx, "diagrams/1/100.json")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), info.Size)
+	assert.Equal(t, "application/json", info.ContentType)
+
+	require.NoError(t, store.Delete(ctx, "diagrams/1/100.json"))
+	_, err = store.Get(ctx, "diagrams/1/100.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Stat(ctx, "diagrams/1/100.json")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// 空的上级目录一并删除，重复删除不报错
+	_, err = os.Stat(filepath.Join(root, "diagrams", "1"))
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, store.Delete(ctx, "diagrams/1/100.json"))
+}
+
+func TestLocal_RejectsInvalidKeys(t *testing.T) {
+	store := NewLocal(t.TempDir(), "", "secret")
+	ctx := context.Background()
+
+	for _, key := range []string{"", "/etc/passwd", "../escape.json", "a/../../b", "a//b", "a\\b", "./a"} {
+		assert.ErrorIs(t, store.Put(ctx, key, []byte("x"), PutOptions{}), ErrInvalidKey, key)
+		_, err := store.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
+
+func TestLocal_SignedURL(t *testing.T) {
+	store := NewLocal(t.TempDir(), "/api/v1/files", "secret")
+
+	signed, err := store.SignedURL("diagrams/1/100.json", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "/api/v1/files/diagrams/1/100.json?"))
+
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.NoError(t, store.VerifySignedURL("diagrams/1/100.json", q.Get("expires"), q.Get("signature")))
+
+	// 换 key、篡改签名、换密钥或过期都应失败
+	assert.ErrorIs(t, store.VerifySignedURL("diagrams/2/100.json", q.Get("expires"), q.Get("signature")), ErrInvalidSignURL)
+	assert.ErrorIs(t, store.VerifySignedURL("diagrams/1/100.json", q.Get("expires"), "x"+q.Get("signature")[1:]), ErrInvalidSignURL)
+	other := NewLocal(t.TempDir(), "", "other")
+	assert.ErrorIs(t, other.VerifySignedURL("diagrams/1/100.json", q.Get("expires"), q.Get("signature")), ErrInvalidSignURL)
+
+	past := time.Now().Add(-time.Minute).Unix()
+	assert.ErrorIs(t, store.VerifySignedURL("diagrams/1/100.json", strconv.FormatInt(past, 10), store.sign("diagrams/1/100.json", past)), ErrInvalidSignURL)
+}
+
+func TestNewLocalFromConfig_Secret(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Upload.TempDir = t.TempDir()
+
+	// 只用非对称 JWT 密钥时 jwt.secret 为空，不能用空密钥签名
+	cfg.JWT.Secret = ""
+	_, err := NewLocalFromConfig(cfg)
+	assert.ErrorIs(t, err, ErrNoSignSecret)
+	_, err = New(cfg)
+	assert.ErrorIs(t, err, ErrNoSignSecret)
+
+	cfg.JWT.Secret = "jwt-secret"
+	store, err := NewLocalFromConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("jwt-secret"), store.secret)
+
+	cfg.JWT.Secret = ""
+	cfg.Storage.Local.Secret = "storage-secret"
+	store, err = NewLocalFromConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("storage-secret"), store.secret)
+
+	// 直接创建的空密钥存储既不签名也不接受任何签名
+	empty := NewLocal(t.TempDir(), "", "")
+	_, err = empty.SignedURL("diagrams/1/100.json", time.Minute)
+	assert.ErrorIs(t, err, ErrNoSignSecret)
+	exp := time.Now().Add(time.Minute).Unix()
+	assert.ErrorIs(t, empty.VerifySignedURL("diagrams/1/100.json", strconv.FormatInt(exp, 10), empty.sign("diagrams/1/100.json", exp)), ErrInvalidSignURL)
+}
+
+func TestNew_SelectsBackend(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Upload.TempDir = t.TempDir()
+	cfg.Storage.Local.Secret = "secret"
+
+	store, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, BackendLocal, store.Name())
+
+	cfg.Storage.Backend = BackendS3
+	cfg.Storage.S3 = config.S3StorageConfig{Endpoint: "http://127.0.0.1:9000", Bucket: "b"}
+	store, err = New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, BackendS3, store.Name())
+
+	cfg.Storage.Backend = "ftp"
+	_, err = New(cfg)
+	assert.Error(t, err)
+}
+
+func TestSet_For(t *testing.T) {
+	local := NewLocal(t.TempDir(), "", "secret")
+	s3, err := NewS3(&config.S3StorageConfig{Endpoint: "http://127.0.0.1:9000", Bucket: "b"})
+	require.NoError(t, err)
+
+	set := NewSet(s3, local)
+	assert.Equal(t, Store(s3), set.For(""))
+	assert.Equal(t, Store(s3), set.For(BackendS3))
+	assert.Equal(t, Store(local), set.For(BackendLocal))
+	assert.Nil(t, set.For(BackendOSS))
+
+	// 主存储就是本地存储时不再需要降级存储
+	set = NewSet(local, NewLocal(t.TempDir(), "", "secret"))
+	assert.Nil(t, set.Fallback)
+
+	var nilSet *Set
+	assert.Nil(t, nilSet.For(""))
+}
+
+func TestCompress_RoundTrip(t *testing.T) {
+	raw := []byte(strings.Repeat(`{"name":"Struct","fields":[]},`, 1000))
+
+	enc, err := Compress(raw)
+	require.NoError(t, err)
+	assert.Equal(t, EncodingGzip, enc.Encoding)
+	assert.Equal(t, len(raw), enc.RawSize)
+	assert.Less(t, len(enc.Data), len(raw)/10)
+	assert.Equal(t, ContentETag(raw), enc.ETag)
+
+	decoded, err := Decompress(enc.Data, enc.Encoding)
+	require.NoError(t, err)
+	assert.Equal(t, raw, decoded)
+
+	// 未压缩的旧数据原样返回
+	decoded, err = Decompress(raw, "")
+	require.NoError(t, err)
+	assert.Equal(t, raw, decoded)
+
+	_, err = Decompress(enc.Data, "br")
+	assert.Error(t, err)
+	_, err = Decompress(raw, EncodingGzip)
+	assert.Error(t, err)
+}
+
+func TestLocal_StatGzip(t *testing.T) {
+	store := NewLocal(t.TempDir(), "", "secret")
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "diagrams/1/1.json.gz", []byte("x"), PutOptions{ContentType: "application/json", ContentEncoding: EncodingGzip}))
+	info, err := store.Stat(ctx, "diagrams/1/1.json.gz")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", info.ContentType)
+	assert.Equal(t, EncodingGzip, info.ContentEncoding)
+}
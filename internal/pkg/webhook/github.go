@@ -0,0 +1,109 @@
+// Package webhook 处理 GitHub 推送事件的签名校验和解析
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GitHub webhook 请求头
+const (
+	HeaderGithubEvent     = "X-GitHub-Event"
+	HeaderGithubDelivery  = "X-GitHub-Delivery"
+	HeaderGithubSignature = "X-Hub-Signature-256"
+)
+
+// ErrInvalidSignature 签名缺失或与请求体不匹配
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign 计算 body 的 HMAC-SHA256 签名，格式与 X-Hub-Signature-256 相同：sha256=<hex>
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 用常量时间比较校验签名，secret 为空时一律拒绝
+func VerifySignature(secret, body []byte, signature string) error {
+	if len(secret) == 0 || !strings.HasPrefix(signature, "sha256=") {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(Sign(secret, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// PushEvent push 事件中用到的字段
+type PushEvent struct {
+	Ref        string `json:"ref"` // refs/heads/main 或 refs/tags/v1.0
+	Before     string `json:"before"`
+	After      string `json:"after"` // 推送后的提交 SHA
+	Deleted    bool   `json:"deleted"`
+	Repository struct {
+		FullName      string `json:"full_name"` // owner/repo
+		HTMLURL       string `json:"html_url"`
+		CloneURL      string `json:"clone_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Pusher struct {
+		Name string `json:"name"`
+	} `json:"pusher"`
+}
+
+// ParsePush 解析 push 事件
+func ParsePush(body []byte) (*PushEvent, error) {
+	var event PushEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse push event: %w", err)
+	}
+	if event.Repository.FullName == "" || event.Ref == "" {
+		return nil, errors.New("push event missing repository or ref")
+	}
+	return &event, nil
+}
+
+// RepositoryName 请求体中仓库的 owner/repo，push、ping 等仓库事件都带有 repository 字段
+// 用于在校验签名前找到可能对应的分析，解析失败时返回空
+func RepositoryName(body []byte) string {
+	var event struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return ""
+	}
+	return event.Repository.FullName
+}
+
+// Branch 推送的分支名，推送标签时返回空
+func (e *PushEvent) Branch() string {
+	branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// RepoPath 把仓库地址规范化为 host/owner/repo 形式用于比较
+// 支持 https://、git@host:owner/repo 和带 .git 后缀的写法，大小写不敏感
+func RepoPath(repoURL string) string {
+	s := strings.ToLower(strings.TrimSpace(repoURL))
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	if rest, ok := strings.CutPrefix(s, "git@"); ok {
+		s = strings.Replace(rest, ":", "/", 1)
+	}
+	if i := strings.Index(s, "@"); i >= 0 && i < strings.Index(s, "/") {
+		s = s[i+1:] // 去掉 user:token@
+	}
+	s = strings.TrimSuffix(strings.TrimRight(s, "/"), ".git")
+	return s
+}
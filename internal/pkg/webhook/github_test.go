@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("It's a Secret to Everybody")
+	body := []byte("Hello, World!")
+
+	// GitHub 文档中的示例
+	signature := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	assert.Equal(t, signature, Sign(secret, body))
+	assert.NoError(t, VerifySignature(secret, body, signature))
+
+	assert.ErrorIs(t, VerifySignature(secret, []byte("Hello, World?"), signature), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature([]byte("other"), body, signature), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature(secret, body, ""), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature(secret, body, "sha1=757107ea"), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature(nil, body, Sign(nil, body)), ErrInvalidSignature)
+}
+
+func TestParsePush(t *testing.T) {
+	event, err := ParsePush([]byte(`{"ref":"refs/heads/release/v2","after":"abc","repository":{"full_name":"Octo/Repo","default_branch":"main"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "release/v2", event.Branch())
+	assert.Equal(t, "main", event.Repository.DefaultBranch)
+
+	event, err = ParsePush([]byte(`{"ref":"refs/tags/v1.0","repository":{"full_name":"octo/repo"}}`))
+	require.NoError(t, err)
+	assert.Empty(t, event.Branch())
+
+	_, err = ParsePush([]byte(`{"zen":"Keep it logically awesome."}`))
+	assert.Error(t, err)
+	_, err = ParsePush([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestRepositoryName(t *testing.T) {
+	assert.Equal(t, "octo/repo", RepositoryName([]byte(`{"zen":"hi","repository":{"full_name":"octo/repo"}}`)))
+	assert.Empty(t, RepositoryName([]byte(`{"zen":"hi"}`)))
+	assert.Empty(t, RepositoryName([]byte(`not json`)))
+}
+
+func TestRepoPath(t *testing.T) {
+	for _, url := range []string{
+		"https://github.com/Octo/Repo",
+		"https://github.com/octo/repo.git",
+		"https://github.com/octo/repo/",
+		"http://token@github.com/octo/repo",
+		"git@github.com:octo/repo.git",
+		"ssh://git@github.com/octo/repo",
+	} {
+		assert.Equal(t, "github.com/octo/repo", RepoPath(url), url)
+	}
+	assert.NotEqual(t, RepoPath("https://github.com/octo/repo"), RepoPath("https://github.com/octo/repo2"))
+}
@@ -25,6 +25,15 @@ type Message struct {
 	Data interface{} `json:"data"`
 }
 
+// MessageTypeNotification 新的站内通知，Data 为 NotificationData
+const MessageTypeNotification = "notification"
+
+// NotificationData 站内通知消息的内容
+type NotificationData struct {
+	Notification interface{} `json:"notification"`
+	UnreadCount  int64       `json:"unread_count"`
+}
+
 func NewHub() *Hub {
 	return &Hub{
 		clients: make(map[int64]map[*Client]struct{}),
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type AccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenRepository(db *gorm.DB) *AccessTokenRepository {
+	return &AccessTokenRepository{db: db}
+}
+
+func (r *AccessTokenRepository) Create(token *model.AccessToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *AccessTokenRepository) GetByID(id int64) (*model.AccessToken, error) {
+	var token model.AccessToken
+	err := r.db.Where("id = ?", id).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *AccessTokenRepository) GetByTokenHash(hash string) (*model.AccessToken, error) {
+	var token model.AccessToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *AccessTokenRepository) ListByUserID(userID int64) ([]*model.AccessToken, error) {
+	var tokens []*model.AccessToken
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *AccessTokenRepository) CountByUserID(userID int64) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.AccessToken{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *AccessTokenRepository) Delete(id int64) error {
+	return r.db.Delete(&model.AccessToken{}, id).Error
+}
+
+// DeleteByUserID 删除用户的全部访问令牌
+func (r *AccessTokenRepository) DeleteByUserID(userID int64) error {
+	return r.db.Where("user_id = ?", userID).Delete(&model.AccessToken{}).Error
+}
+
+// TouchLastUsed 更新最后使用时间
+func (r *AccessTokenRepository) TouchLastUsed(id int64, now time.Time) error {
+	return r.db.Model(&model.AccessToken{}).Where("id = ?", id).Update("last_used_at", now).Error
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestAccessTokenRepository_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewAccessTokenRepository(db)
+	user := testutil.TestUser(t, db)
+
+	first := &model.AccessToken{UserID: user.ID, Name: "ci", Prefix: "agp_1", TokenHash: "hash-1",
+		Scopes: model.StringArray{model.ScopeAnalysesRead}}
+	second := &model.AccessToken{UserID: user.ID, Name: "deploy", Prefix: "agp_2", TokenHash: "hash-2"}
+	require.NoError(t, repo.Create(first))
+	require.NoError(t, repo.Create(second))
+
+	got, err := repo.GetByTokenHash("hash-1")
+	require.NoError(t, err)
+	assert.True(t, got.HasScope(model.ScopeAnalysesRead))
+	assert.False(t, got.HasScope(model.ScopeAnalysesWrite))
+	assert.Nil(t, got.LastUsedAt)
+
+	now := time.Now()
+	require.NoError(t, repo.TouchLastUsed(first.ID, now))
+	got, err = repo.GetByID(first.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+
+	// 按创建顺序倒序
+	tokens, err := repo.ListByUserID(user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, second.ID, tokens[0].ID)
+
+	require.NoError(t, repo.Delete(first.ID))
+	count, err := repo.CountByUserID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = repo.GetByTokenHash("hash-1")
+	assert.Error(t, err)
+}
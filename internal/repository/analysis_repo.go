@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/qs3c/anal_go_server/internal/model"
 )
@@ -107,23 +110,34 @@ func (r *AnalysisRepository) ListPublic(page, pageSize int, sortBy string, tags
 	return analyses, total, nil
 }
 
-// ListLocalDiagrams 查询所有 diagram_oss_url 以 local:// 开头的已完成分析
-func (r *AnalysisRepository) ListLocalDiagrams() ([]*model.Analysis, error) {
+// ListDiagramsByBackend 查询框图存放在指定存储后端的已完成分析
+func (r *AnalysisRepository) ListDiagramsByBackend(backend string) ([]*model.Analysis, error) {
 	var analyses []*model.Analysis
-	err := r.db.Where("diagram_oss_url LIKE ? AND status = ?", "local://%", "completed").Find(&analyses).Error
+	err := r.db.Where("diagram_backend = ? AND diagram_key <> '' AND status = ?", backend, "completed").Find(&analyses).Error
 	return analyses, err
 }
 
-// ListOSSMigratedDiagramIDs 查询已迁移到 OSS 的分析 ID 列表（diagram_oss_url 以 https:// 开头）
-func (r *AnalysisRepository) ListOSSMigratedDiagramIDs() ([]int64, error) {
+// ListRemoteDiagramIDs 查询框图已存放在远程存储（非 local）的分析 ID 列表
+func (r *AnalysisRepository) ListRemoteDiagramIDs() ([]int64, error) {
 	var ids []int64
 	err := r.db.Model(&model.Analysis{}).
-		Where("diagram_oss_url LIKE ?", "https://%").
+		Where("diagram_backend <> ? AND diagram_key <> ''", "local").
 		Pluck("id", &ids).Error
 	return ids, err
 }
 
 // IncrementViewCount 增加浏览数
+// ListWebhookCandidates 开启了推送触发、仓库地址包含 ownerRepo 的 GitHub 分析
+// LIKE 只做粗筛，调用方需要再按规范化后的地址精确比较
+func (r *AnalysisRepository) ListWebhookCandidates(ownerRepo string) ([]*model.Analysis, error) {
+	var analyses []*model.Analysis
+	err := r.db.Where("webhook_enabled = ? AND creation_type = ? AND source_type = ?", true, "ai", "github").
+		Where("LOWER(repo_url) LIKE ?", "%"+strings.ToLower(ownerRepo)+"%").
+		Order("id ASC").
+		Find(&analyses).Error
+	return analyses, err
+}
+
 func (r *AnalysisRepository) IncrementViewCount(id int64) error {
 	return r.db.Model(&model.Analysis{}).Where("id = ?", id).
 		Update("view_count", gorm.Expr("view_count + 1")).Error
@@ -146,3 +160,47 @@ func (r *AnalysisRepository) IncrementCommentCount(id int64, delta int) error {
 	return r.db.Model(&model.Analysis{}).Where("id = ?", id).
 		Update("comment_count", gorm.Expr("comment_count + ?", delta)).Error
 }
+
+// SaveMetrics 写入分析的架构指标，重新分析时覆盖旧值
+func (r *AnalysisRepository) SaveMetrics(metrics *model.AnalysisMetrics) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "analysis_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"struct_count", "connection_count", "package_count", "cycle_count",
+			"max_fan_in", "max_fan_out", "avg_fan_out", "max_instability", "details", "updated_at",
+		}),
+	}).Create(metrics).Error
+}
+
+// GetMetrics 获取分析的架构指标
+func (r *AnalysisRepository) GetMetrics(analysisID int64) (*model.AnalysisMetrics, error) {
+	var metrics model.AnalysisMetrics
+	err := r.db.Where("analysis_id = ?", analysisID).First(&metrics).Error
+	if err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// ListRules 获取分析的架构规则，按创建顺序
+func (r *AnalysisRepository) ListRules(analysisID int64) ([]*model.AnalysisRule, error) {
+	var rules []*model.AnalysisRule
+	err := r.db.Where("analysis_id = ?", analysisID).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// ReplaceRules 用新的规则列表替换分析原有的规则
+func (r *AnalysisRepository) ReplaceRules(analysisID int64, rules []*model.AnalysisRule) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("analysis_id = ?", analysisID).Delete(&model.AnalysisRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		for _, rule := range rules {
+			rule.AnalysisID = analysisID
+		}
+		return tx.Create(&rules).Error
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
 	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/testutil"
@@ -182,3 +183,62 @@ func TestAnalysisRepository_IncrementLikeCount(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, found.LikeCount)
 }
+
+func TestAnalysisRepository_SaveMetrics(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewAnalysisRepository(db)
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+
+	_, err := repo.GetMetrics(analysis.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	err = repo.SaveMetrics(&model.AnalysisMetrics{AnalysisID: analysis.ID, StructCount: 3, Details: model.JSONText(`{"cycles":[]}`)})
+	require.NoError(t, err)
+
+	// 重新分析时覆盖
+	err = repo.SaveMetrics(&model.AnalysisMetrics{AnalysisID: analysis.ID, StructCount: 5, CycleCount: 1, Details: model.JSONText(`{"cycles":[["A","B"]]}`)})
+	require.NoError(t, err)
+
+	found, err := repo.GetMetrics(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 5, found.StructCount)
+	assert.Equal(t, 1, found.CycleCount)
+	assert.JSONEq(t, `{"cycles":[["A","B"]]}`, string(found.Details))
+
+	var count int64
+	require.NoError(t, db.Model(&model.AnalysisMetrics{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestAnalysisRepository_ReplaceRules(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewAnalysisRepository(db)
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	other := testutil.TestAnalysis(t, db, user.ID)
+
+	require.NoError(t, repo.ReplaceRules(analysis.ID, []*model.AnalysisRule{{Type: "no_cycles"}, {Type: "max_fan_out", Max: 15}}))
+	require.NoError(t, repo.ReplaceRules(other.ID, []*model.AnalysisRule{{Type: "no_cycles"}}))
+
+	require.NoError(t, repo.ReplaceRules(analysis.ID, []*model.AnalysisRule{{Type: "no_dependency", FromPkg: "api", ToPkg: "repository"}}))
+	rules, err := repo.ListRules(analysis.ID)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "no_dependency", rules[0].Type)
+	assert.Equal(t, "repository", rules[0].ToPkg)
+
+	// 清空
+	require.NoError(t, repo.ReplaceRules(analysis.ID, nil))
+	rules, err = repo.ListRules(analysis.ID)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	rules, err = repo.ListRules(other.ID)
+	require.NoError(t, err)
+	assert.Len(t, rules, 1)
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type ChannelRepository struct {
+	db *gorm.DB
+}
+
+func NewChannelRepository(db *gorm.DB) *ChannelRepository {
+	return &ChannelRepository{db: db}
+}
+
+func (r *ChannelRepository) Create(channel *model.NotificationChannel) error {
+	return r.db.Create(channel).Error
+}
+
+func (r *ChannelRepository) GetByID(id int64) (*model.NotificationChannel, error) {
+	var channel model.NotificationChannel
+	err := r.db.Where("id = ?", id).First(&channel).Error
+	if err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r *ChannelRepository) Update(channel *model.NotificationChannel) error {
+	return r.db.Save(channel).Error
+}
+
+// Delete 删除渠道及其投递记录
+func (r *ChannelRepository) Delete(id int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("channel_id = ?", id).Delete(&model.NotificationDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.NotificationChannel{}, id).Error
+	})
+}
+
+func (r *ChannelRepository) ListByUserID(userID int64) ([]*model.NotificationChannel, error) {
+	var channels []*model.NotificationChannel
+	err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&channels).Error
+	return channels, err
+}
+
+// ListEnabled 用户已启用的渠道，是否订阅某个事件由调用方判断
+func (r *ChannelRepository) ListEnabled(userID int64) ([]*model.NotificationChannel, error) {
+	var channels []*model.NotificationChannel
+	err := r.db.Where("user_id = ? AND enabled = ?", userID, true).Order("id ASC").Find(&channels).Error
+	return channels, err
+}
+
+func (r *ChannelRepository) CreateDelivery(delivery *model.NotificationDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *ChannelRepository) UpdateDelivery(delivery *model.NotificationDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+// ListDueDeliveries 到了重试时间的投递
+func (r *ChannelRepository) ListDueDeliveries(now time.Time, limit int) ([]*model.NotificationDelivery, error) {
+	var deliveries []*model.NotificationDelivery
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", "pending", now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimDelivery 把到期的投递推后 lease 作为租约，返回是否由本次调用认领，避免多个实例重复发送
+func (r *ChannelRepository) ClaimDelivery(id int64, now time.Time, lease time.Duration) (bool, error) {
+	result := r.db.Model(&model.NotificationDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, "pending", now).
+		Update("next_attempt_at", now.Add(lease))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ListDeliveries 渠道最近的投递记录
+func (r *ChannelRepository) ListDeliveries(channelID int64, limit int) ([]*model.NotificationDelivery, error) {
+	var deliveries []*model.NotificationDelivery
+	err := r.db.Where("channel_id = ?", channelID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
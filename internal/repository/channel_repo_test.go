@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestChannelRepository_DueDeliveries(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewChannelRepository(db)
+	user := testutil.TestUser(t, db)
+	channel := &model.NotificationChannel{UserID: user.ID, Type: "webhook", URL: "https://example.com", Events: model.StringArray{"analysis.completed"}, Enabled: true}
+	require.NoError(t, repo.Create(channel))
+
+	now := time.Date(2026, 3, 14, 2, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	due := &model.NotificationDelivery{ChannelID: channel.ID, UserID: user.ID, Event: "analysis.completed", Status: "pending", NextAttemptAt: &past}
+	later := &model.NotificationDelivery{ChannelID: channel.ID, UserID: user.ID, Event: "analysis.completed", Status: "pending", NextAttemptAt: &future}
+	done := &model.NotificationDelivery{ChannelID: channel.ID, UserID: user.ID, Event: "analysis.completed", Status: "succeeded", NextAttemptAt: &past}
+	for _, d := range []*model.NotificationDelivery{due, later, done} {
+		require.NoError(t, repo.CreateDelivery(d))
+	}
+
+	deliveries, err := repo.ListDueDeliveries(now, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, due.ID, deliveries[0].ID)
+
+	// 同一次重试只能认领一次
+	claimed, err := repo.ClaimDelivery(due.ID, now, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = repo.ClaimDelivery(due.ID, now, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// 删除渠道时一并删除投递记录
+	require.NoError(t, repo.Delete(channel.ID))
+	deliveries, err = repo.ListDeliveries(channel.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
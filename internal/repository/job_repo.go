@@ -64,3 +64,12 @@ func (r *JobRepository) CancelByAnalysisID(analysisID int64) error {
 		Where("analysis_id = ? AND status IN ?", analysisID, []string{"queued", "processing"}).
 		Update("status", "cancelled").Error
 }
+
+// HasActiveJob 分析是否有排队中或运行中的任务
+func (r *JobRepository) HasActiveJob(analysisID int64) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.AnalysisJob{}).
+		Where("analysis_id = ? AND status IN ?", analysisID, []string{"queued", "processing"}).
+		Count(&count).Error
+	return count > 0, err
+}
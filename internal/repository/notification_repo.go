@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func (r *NotificationRepository) Create(notification *model.Notification) error {
+	return r.db.Create(notification).Error
+}
+
+// GetByIDWithActor 获取通知及触发用户信息
+func (r *NotificationRepository) GetByIDWithActor(id int64) (*model.Notification, error) {
+	var notification model.Notification
+	err := r.db.Preload("Actor").Where("id = ?", id).First(&notification).Error
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// ListByUserID 获取用户的通知列表，unreadOnly 为 true 时只返回未读
+func (r *NotificationRepository) ListByUserID(userID int64, page, pageSize int, unreadOnly bool) ([]*model.Notification, int64, error) {
+	var notifications []*model.Notification
+	var total int64
+
+	query := r.db.Model(&model.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Preload("Actor").Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&notifications).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+// Exists 用户是否已收到过同一触发者对同一分析的某类通知
+func (r *NotificationRepository) Exists(userID, actorID, analysisID int64, notificationType string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Notification{}).
+		Where("user_id = ? AND actor_id = ? AND analysis_id = ? AND type = ?", userID, actorID, analysisID, notificationType).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CountUnread 用户的未读通知数
+func (r *NotificationRepository) CountUnread(userID int64) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkRead 把用户的一条通知标记为已读，返回是否存在该通知
+func (r *NotificationRepository) MarkRead(userID, id int64, now time.Time) (bool, error) {
+	var notification model.Notification
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&notification).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if notification.ReadAt != nil {
+		return true, nil
+	}
+	return true, r.db.Model(&notification).Update("read_at", now).Error
+}
+
+// MarkAllRead 把用户的全部未读通知标记为已读，返回更新条数
+func (r *NotificationRepository) MarkAllRead(userID int64, now time.Time) (int64, error) {
+	result := r.db.Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", now)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type PasswordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create 保存新令牌，并使该用户之前未使用的令牌失效
+func (r *PasswordResetRepository) Create(token *model.PasswordResetToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *PasswordResetRepository) GetByTokenHash(hash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed 标记令牌已使用，令牌已被使用过时返回 false，保证同一令牌只能成功使用一次
+func (r *PasswordResetRepository) MarkUsed(id int64, now time.Time) (bool, error) {
+	result := r.db.Model(&model.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+// InvalidateByUserID 使用户所有未使用的令牌失效
+func (r *PasswordResetRepository) InvalidateByUserID(userID int64, now time.Time) error {
+	return r.db.Model(&model.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error
+}
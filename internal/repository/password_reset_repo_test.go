@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestPasswordResetRepository_SingleUse(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewPasswordResetRepository(db)
+	user := testutil.TestUser(t, db)
+	now := time.Now()
+
+	first := &model.PasswordResetToken{UserID: user.ID, TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	require.NoError(t, repo.Create(first))
+
+	// 新令牌使之前未使用的令牌失效
+	second := &model.PasswordResetToken{UserID: user.ID, TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	require.NoError(t, repo.Create(second))
+	got, err := repo.GetByTokenHash("hash-1")
+	require.NoError(t, err)
+	assert.NotNil(t, got.UsedAt)
+
+	// 同一令牌只能标记一次
+	marked, err := repo.MarkUsed(second.ID, now)
+	require.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = repo.MarkUsed(second.ID, now)
+	require.NoError(t, err)
+	assert.False(t, marked)
+}
+
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewUserRepository(db)
+	user := testutil.TestUser(t, db)
+
+	version, err := repo.GetTokenVersion(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	require.NoError(t, repo.UpdatePassword(user.ID, "new-hash"))
+	version, err = repo.GetTokenVersion(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	updated, err := repo.GetByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", *updated.PasswordHash)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func (r *RefreshTokenRepository) Create(token *model.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *RefreshTokenRepository) GetByTokenHash(hash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke 撤销一个令牌，令牌已被撤销时返回 false，保证同一令牌只能成功轮换一次
+func (r *RefreshTokenRepository) Revoke(id int64, now time.Time) (bool, error) {
+	result := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeSession 撤销会话中所有未撤销的令牌
+func (r *RefreshTokenRepository) RevokeSession(sessionID string, now time.Time) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error
+}
+
+// RevokeByUserID 撤销用户所有未撤销的令牌
+func (r *RefreshTokenRepository) RevokeByUserID(userID int64, now time.Time) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// DeleteExpiredByUserID 删除用户在 before 之前过期的令牌
+func (r *RefreshTokenRepository) DeleteExpiredByUserID(userID int64, before time.Time) error {
+	return r.db.Where("user_id = ? AND expires_at < ?", userID, before).Delete(&model.RefreshToken{}).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestRefreshTokenRepository_Revoke(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewRefreshTokenRepository(db)
+	user := testutil.TestUser(t, db)
+	now := time.Now()
+
+	first := &model.RefreshToken{UserID: user.ID, SessionID: "s1", TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)}
+	second := &model.RefreshToken{UserID: user.ID, SessionID: "s1", TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour)}
+	other := &model.RefreshToken{UserID: user.ID, SessionID: "s2", TokenHash: "hash-3", ExpiresAt: now.Add(time.Hour)}
+	expired := &model.RefreshToken{UserID: user.ID, SessionID: "s3", TokenHash: "hash-4", ExpiresAt: now.Add(-time.Hour)}
+	for _, token := range []*model.RefreshToken{first, second, other, expired} {
+		require.NoError(t, repo.Create(token))
+	}
+
+	// 同一令牌只能撤销一次
+	revoked, err := repo.Revoke(first.ID, now)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = repo.Revoke(first.ID, now)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	// 撤销会话不影响其它会话
+	require.NoError(t, repo.RevokeSession("s1", now))
+	got, err := repo.GetByTokenHash("hash-2")
+	require.NoError(t, err)
+	assert.NotNil(t, got.RevokedAt)
+	got, err = repo.GetByTokenHash("hash-3")
+	require.NoError(t, err)
+	assert.Nil(t, got.RevokedAt)
+
+	require.NoError(t, repo.DeleteExpiredByUserID(user.ID, now))
+	_, err = repo.GetByTokenHash("hash-4")
+	assert.Error(t, err)
+	_, err = repo.GetByTokenHash("hash-3")
+	assert.NoError(t, err)
+}
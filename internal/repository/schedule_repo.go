@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type ScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) Create(schedule *model.AnalysisSchedule) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *ScheduleRepository) GetByID(id int64) (*model.AnalysisSchedule, error) {
+	var schedule model.AnalysisSchedule
+	err := r.db.Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *ScheduleRepository) Update(schedule *model.AnalysisSchedule) error {
+	return r.db.Save(schedule).Error
+}
+
+func (r *ScheduleRepository) Delete(id int64) error {
+	return r.db.Delete(&model.AnalysisSchedule{}, id).Error
+}
+
+// ListByUserID 用户的全部定时任务，analysisID 不为 0 时只返回该分析的
+func (r *ScheduleRepository) ListByUserID(userID, analysisID int64) ([]*model.AnalysisSchedule, error) {
+	var schedules []*model.AnalysisSchedule
+	query := r.db.Where("user_id = ?", userID)
+	if analysisID != 0 {
+		query = query.Where("analysis_id = ?", analysisID)
+	}
+	err := query.Order("id ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// CountByAnalysisID 分析上的定时任务数
+func (r *ScheduleRepository) CountByAnalysisID(analysisID int64) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.AnalysisSchedule{}).Where("analysis_id = ?", analysisID).Count(&count).Error
+	return count, err
+}
+
+// ListDue 到期需要触发的定时任务
+func (r *ScheduleRepository) ListDue(now time.Time, limit int) ([]*model.AnalysisSchedule, error) {
+	var schedules []*model.AnalysisSchedule
+	err := r.db.Where("enabled = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").
+		Limit(limit).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// Claim 把到期的定时任务推进到下一次触发时间，返回是否由本次调用认领
+// 条件更新保证同一次触发只会被一个实例认领；next 为 nil 时停用（表达式不再触发）
+func (r *ScheduleRepository) Claim(id int64, now time.Time, next *time.Time) (bool, error) {
+	fields := map[string]interface{}{
+		"last_run_at": now,
+		"next_run_at": next,
+	}
+	if next == nil {
+		fields["enabled"] = false
+	}
+	result := r.db.Model(&model.AnalysisSchedule{}).
+		Where("id = ? AND enabled = ? AND next_run_at <= ?", id, true, now).
+		Updates(fields)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RecordRun 记录触发结果，lastError 为空表示成功创建了任务
+func (r *ScheduleRepository) RecordRun(id, jobID int64, lastError string) error {
+	fields := map[string]interface{}{"last_error": lastError}
+	if jobID != 0 {
+		fields["last_job_id"] = jobID
+	}
+	return r.db.Model(&model.AnalysisSchedule{}).Where("id = ?", id).Updates(fields).Error
+}
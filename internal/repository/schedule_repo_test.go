@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestScheduleRepository_ListDueAndClaim(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewScheduleRepository(db)
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+
+	now := time.Date(2026, 3, 14, 2, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	due := &model.AnalysisSchedule{AnalysisID: analysis.ID, UserID: user.ID, CronExpr: "0 2 * * *", Enabled: true, NextRunAt: &past}
+	later := &model.AnalysisSchedule{AnalysisID: analysis.ID, UserID: user.ID, CronExpr: "0 3 * * *", Enabled: true, NextRunAt: &future}
+	disabled := &model.AnalysisSchedule{AnalysisID: analysis.ID, UserID: user.ID, CronExpr: "0 1 * * *", Enabled: true, NextRunAt: &past}
+	for _, s := range []*model.AnalysisSchedule{due, later, disabled} {
+		require.NoError(t, repo.Create(s))
+	}
+	require.NoError(t, db.Model(disabled).Update("enabled", false).Error)
+
+	schedules, err := repo.ListDue(now, 10)
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, due.ID, schedules[0].ID)
+
+	// 同一次触发只能认领一次
+	next := now.Add(24 * time.Hour)
+	claimed, err := repo.Claim(due.ID, now, &next)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = repo.Claim(due.ID, now, &next)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	schedules, err = repo.ListDue(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	require.NoError(t, repo.RecordRun(due.ID, 42, ""))
+	found, err := repo.GetByID(due.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), found.LastJobID)
+	assert.True(t, found.NextRunAt.Equal(next))
+	assert.True(t, found.LastRunAt.Equal(now))
+
+	count, err := repo.CountByAnalysisID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
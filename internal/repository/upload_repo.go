@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type UploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+func (r *UploadRepository) Create(upload *model.Upload) error {
+	return r.db.Create(upload).Error
+}
+
+func (r *UploadRepository) GetByID(id string) (*model.Upload, error) {
+	var upload model.Upload
+	err := r.db.Where("id = ?", id).First(&upload).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// ExtendExpiry 延长上传文件的过期时间
+func (r *UploadRepository) ExtendExpiry(id string, expiresAt time.Time) error {
+	return r.db.Model(&model.Upload{}).Where("id = ?", id).Update("expires_at", expiresAt).Error
+}
+
+// MarkExpired 标记上传文件已过期（目录已删除）
+func (r *UploadRepository) MarkExpired(id string) error {
+	return r.db.Model(&model.Upload{}).Where("id = ?", id).Update("status", "expired").Error
+}
+
+// ListExpired 查询已到期但仍为 active 状态的上传记录
+func (r *UploadRepository) ListExpired(now time.Time, limit int) ([]*model.Upload, error) {
+	var uploads []*model.Upload
+	err := r.db.Where("status = ? AND expires_at < ?", "active", now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
+
+// ListActiveIDs 在给定 ID 中筛选出仍为 active 状态的上传记录
+func (r *UploadRepository) ListActiveIDs(ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var active []string
+	err := r.db.Model(&model.Upload{}).
+		Where("id IN ? AND status = ?", ids, "active").
+		Pluck("id", &active).Error
+	return active, err
+}
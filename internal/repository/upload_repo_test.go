@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func createTestUpload(t *testing.T, repo *UploadRepository, id string, userID int64, expiresAt time.Time) *model.Upload {
+	t.Helper()
+
+	upload := &model.Upload{
+		ID:        id,
+		UserID:    userID,
+		Filename:  id + ".zip",
+		Size:      1024,
+		Status:    "active",
+		ExpiresAt: expiresAt,
+	}
+	require.NoError(t, repo.Create(upload))
+	return upload
+}
+
+func TestUploadRepository_CreateAndGet(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewUploadRepository(db)
+	user := testutil.TestUser(t, db)
+	createTestUpload(t, repo, "upload-1", user.ID, time.Now().Add(time.Hour))
+
+	found, err := repo.GetByID("upload-1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.UserID)
+	assert.Equal(t, "active", found.Status)
+
+	_, err = repo.GetByID("missing")
+	assert.Error(t, err)
+}
+
+func TestUploadRepository_ListExpired(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewUploadRepository(db)
+	user := testutil.TestUser(t, db)
+	now := time.Now()
+	createTestUpload(t, repo, "expired-1", user.ID, now.Add(-2*time.Hour))
+	createTestUpload(t, repo, "expired-2", user.ID, now.Add(-time.Hour))
+	createTestUpload(t, repo, "active", user.ID, now.Add(time.Hour))
+	createTestUpload(t, repo, "already-cleaned", user.ID, now.Add(-3*time.Hour))
+	require.NoError(t, repo.MarkExpired("already-cleaned"))
+
+	uploads, err := repo.ListExpired(now, 10)
+	require.NoError(t, err)
+	require.Len(t, uploads, 2)
+	assert.Equal(t, "expired-1", uploads[0].ID)
+	assert.Equal(t, "expired-2", uploads[1].ID)
+
+	uploads, err = repo.ListExpired(now, 1)
+	require.NoError(t, err)
+	assert.Len(t, uploads, 1)
+}
+
+func TestUploadRepository_ExtendExpiry(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewUploadRepository(db)
+	user := testutil.TestUser(t, db)
+	createTestUpload(t, repo, "upload-1", user.ID, time.Now().Add(-time.Hour))
+
+	require.NoError(t, repo.ExtendExpiry("upload-1", time.Now().Add(time.Hour)))
+
+	uploads, err := repo.ListExpired(time.Now(), 10)
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+}
+
+func TestUploadRepository_ListActiveIDs(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := NewUploadRepository(db)
+	user := testutil.TestUser(t, db)
+	createTestUpload(t, repo, "a", user.ID, time.Now().Add(time.Hour))
+	createTestUpload(t, repo, "b", user.ID, time.Now().Add(time.Hour))
+	require.NoError(t, repo.MarkExpired("b"))
+
+	ids, err := repo.ListActiveIDs([]string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids)
+
+	ids, err = repo.ListActiveIDs(nil)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
@@ -56,6 +56,24 @@ func (r *UserRepository) GetByGithubID(githubID string) (*model.User, error) {
 	return &user, nil
 }
 
+func (r *UserRepository) GetByWechatOpenID(openID string) (*model.User, error) {
+	var user model.User
+	err := r.db.Where("wechat_openid = ?", openID).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetByOIDCSubject(subject string) (*model.User, error) {
+	var user model.User
+	err := r.db.Where("oidc_subject = ?", subject).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) GetByVerificationCode(code string) (*model.User, error) {
 	var user model.User
 	err := r.db.Where("verification_code = ?", code).First(&user).Error
@@ -108,3 +126,24 @@ func (r *UserRepository) ExistsByUsername(username string) (bool, error) {
 	err := r.db.Model(&model.User{}).Where("username = ?", username).Count(&count).Error
 	return count > 0, err
 }
+
+// GetTokenVersion 只查询 Token 版本，用于每次请求的认证检查
+func (r *UserRepository) GetTokenVersion(id int64) (int, error) {
+	var user model.User
+	err := r.db.Select("token_version").Where("id = ?", id).First(&user).Error
+	return user.TokenVersion, err
+}
+
+// UpdatePassword 更新密码并使 Token 版本加一，之前签发的 Token 全部失效
+func (r *UserRepository) UpdatePassword(id int64, passwordHash string) error {
+	return r.db.Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password_hash": passwordHash,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error
+}
+
+// IncrementTokenVersion 使 Token 版本加一，之前签发的 Token 全部失效
+func (r *UserRepository) IncrementTokenVersion(id int64) error {
+	return r.db.Model(&model.User{}).Where("id = ?", id).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
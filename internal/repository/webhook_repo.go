@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(delivery *model.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *WebhookRepository) Update(delivery *model.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+func (r *WebhookRepository) GetByDeliveryID(deliveryID string) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := r.db.Where("delivery_id = ?", deliveryID).First(&delivery).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ListByAnalysisID 分析最近的投递记录
+func (r *WebhookRepository) ListByAnalysisID(analysisID int64, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := r.db.Where("analysis_id = ?", analysisID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/repository"
+)
+
+var (
+	ErrAccessTokenNotFound     = errors.New("访问令牌不存在")
+	ErrAccessTokenPermission   = errors.New("无权操作此访问令牌")
+	ErrInvalidAccessTokenScope = errors.New("不支持的权限范围")
+	ErrAccessTokenLimitReached = errors.New("每个用户最多创建 20 个访问令牌")
+	ErrInvalidAccessToken      = errors.New("访问令牌无效或已过期")
+)
+
+const (
+	// maxAccessTokensPerUser 每个用户的访问令牌数上限
+	maxAccessTokensPerUser = 20
+	// accessTokenPrefixLen 保存用于辨认的令牌开头长度（含 agp_ 前缀）
+	accessTokenPrefixLen = 12
+	// accessTokenTouchInterval 最后使用时间的更新间隔，避免每个请求都写数据库
+	accessTokenTouchInterval = time.Minute
+)
+
+type AccessTokenService struct {
+	tokenRepo *repository.AccessTokenRepository
+	cfg       *config.Config
+}
+
+func NewAccessTokenService(tokenRepo *repository.AccessTokenRepository, cfg *config.Config) *AccessTokenService {
+	return &AccessTokenService{
+		tokenRepo: tokenRepo,
+		cfg:       cfg,
+	}
+}
+
+// Create 创建个人访问令牌，令牌明文只在响应中返回一次
+func (s *AccessTokenService) Create(userID int64, req *dto.CreateAccessTokenRequest) (*dto.AccessTokenItem, error) {
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.tokenRepo.CountByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAccessTokensPerUser {
+		return nil, ErrAccessTokenLimitReached
+	}
+
+	random, err := generateRandomCode(40)
+	if err != nil {
+		return nil, err
+	}
+	plain := model.AccessTokenPrefix + random
+
+	token := &model.AccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    plain[:accessTokenPrefixLen],
+		TokenHash: hashToken(plain),
+		Scopes:    model.StringArray(scopes),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+
+	item := toAccessTokenItem(token)
+	item.Token = plain
+	return item, nil
+}
+
+// List 用户的访问令牌，按创建时间倒序
+func (s *AccessTokenService) List(userID int64) ([]*dto.AccessTokenItem, error) {
+	tokens, err := s.tokenRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*dto.AccessTokenItem, len(tokens))
+	for i, token := range tokens {
+		items[i] = toAccessTokenItem(token)
+	}
+	return items, nil
+}
+
+// Delete 删除（吊销）访问令牌
+func (s *AccessTokenService) Delete(userID, tokenID int64) error {
+	token, err := s.tokenRepo.GetByID(tokenID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccessTokenNotFound
+		}
+		return err
+	}
+	if token.UserID != userID {
+		return ErrAccessTokenPermission
+	}
+	return s.tokenRepo.Delete(tokenID)
+}
+
+// VerifyAccessToken 校验个人访问令牌，返回所属用户和权限范围，并记录最后使用时间
+// 令牌不受 Token 版本控制，修改密码、重置密码和退出所有设备时由 AuthService 直接删除
+func (s *AccessTokenService) VerifyAccessToken(ctx context.Context, plain string) (int64, []string, error) {
+	if !strings.HasPrefix(plain, model.AccessTokenPrefix) {
+		return 0, nil, ErrInvalidAccessToken
+	}
+	token, err := s.tokenRepo.GetByTokenHash(hashToken(plain))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, ErrInvalidAccessToken
+		}
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return 0, nil, ErrInvalidAccessToken
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= accessTokenTouchInterval {
+		if err := s.tokenRepo.TouchLastUsed(token.ID, now); err != nil {
+			log.Printf("Failed to update access token %d last used time: %v", token.ID, err)
+		}
+	}
+	return token.UserID, []string(token.Scopes), nil
+}
+
+// normalizeScopes 校验并去重权限范围
+func normalizeScopes(scopes []string) ([]string, error) {
+	var result []string
+	for _, scope := range scopes {
+		supported := false
+		for _, known := range model.AccessTokenScopes {
+			if scope == known {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, ErrInvalidAccessTokenScope
+		}
+		duplicate := false
+		for _, s := range result {
+			if s == scope {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidAccessTokenScope
+	}
+	return result, nil
+}
+
+func toAccessTokenItem(token *model.AccessToken) *dto.AccessTokenItem {
+	scopes := []string(token.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	item := &dto.AccessTokenItem{
+		ID:        token.ID,
+		Name:      token.Name,
+		Prefix:    token.Prefix,
+		Scopes:    scopes,
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+	}
+	if token.ExpiresAt != nil {
+		item.ExpiresAt = token.ExpiresAt.Format(time.RFC3339)
+	}
+	if token.LastUsedAt != nil {
+		item.LastUsedAt = token.LastUsedAt.Format(time.RFC3339)
+	}
+	return item
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestAccessTokenService_CreateAndVerify(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	repo := repository.NewAccessTokenRepository(db)
+	service := NewAccessTokenService(repo, &config.Config{})
+	user := testutil.TestUser(t, db)
+	ctx := context.Background()
+
+	item, err := service.Create(user.ID, &dto.CreateAccessTokenRequest{
+		Name:          "ci",
+		Scopes:        []string{model.ScopeAnalysesRead, model.ScopeAnalysesWrite, model.ScopeAnalysesRead},
+		ExpiresInDays: 30,
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(item.Token, model.AccessTokenPrefix))
+	assert.Equal(t, item.Token[:len(item.Prefix)], item.Prefix)
+	assert.Equal(t, []string{model.ScopeAnalysesRead, model.ScopeAnalysesWrite}, item.Scopes)
+	assert.NotEmpty(t, item.ExpiresAt)
+
+	// 只保存哈希
+	stored, err := repo.GetByID(item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, hashToken(item.Token), stored.TokenHash)
+
+	userID, scopes, err := service.VerifyAccessToken(ctx, item.Token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+	assert.Equal(t, []string{model.ScopeAnalysesRead, model.ScopeAnalysesWrite}, scopes)
+
+	// 列表中不返回令牌明文，并记录了最后使用时间
+	items, err := service.List(user.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Empty(t, items[0].Token)
+	assert.NotEmpty(t, items[0].LastUsedAt)
+
+	_, _, err = service.VerifyAccessToken(ctx, item.Token+"x")
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	_, _, err = service.VerifyAccessToken(ctx, "not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+
+	// 吊销后不能再使用，其他用户不能吊销
+	other := testutil.TestUser(t, db)
+	assert.ErrorIs(t, service.Delete(other.ID, item.ID), ErrAccessTokenPermission)
+	require.NoError(t, service.Delete(user.ID, item.ID))
+	assert.ErrorIs(t, service.Delete(user.ID, item.ID), ErrAccessTokenNotFound)
+	_, _, err = service.VerifyAccessToken(ctx, item.Token)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+}
+
+func TestAccessTokenService_Expired(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewAccessTokenService(repository.NewAccessTokenRepository(db), &config.Config{})
+	user := testutil.TestUser(t, db)
+
+	item, err := service.Create(user.ID, &dto.CreateAccessTokenRequest{Name: "old", Scopes: []string{model.ScopeAnalysesRead}, ExpiresInDays: 1})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&model.AccessToken{}).Where("id = ?", item.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	_, _, err = service.VerifyAccessToken(context.Background(), item.Token)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+
+	// 不设置过期时间的令牌永不过期
+	forever, err := service.Create(user.ID, &dto.CreateAccessTokenRequest{Name: "forever", Scopes: []string{model.ScopeCommunityWrite}})
+	require.NoError(t, err)
+	assert.Empty(t, forever.ExpiresAt)
+	_, _, err = service.VerifyAccessToken(context.Background(), forever.Token)
+	assert.NoError(t, err)
+}
+
+func TestAccessTokenService_Create_Validation(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewAccessTokenService(repository.NewAccessTokenRepository(db), &config.Config{})
+	user := testutil.TestUser(t, db)
+
+	_, err := service.Create(user.ID, &dto.CreateAccessTokenRequest{Name: "admin", Scopes: []string{"admin"}})
+	assert.ErrorIs(t, err, ErrInvalidAccessTokenScope)
+
+	for i := 0; i < maxAccessTokensPerUser; i++ {
+		_, err := service.Create(user.ID, &dto.CreateAccessTokenRequest{Name: "ci", Scopes: []string{model.ScopeAnalysesRead}})
+		require.NoError(t, err)
+	}
+	_, err = service.Create(user.ID, &dto.CreateAccessTokenRequest{Name: "ci", Scopes: []string{model.ScopeAnalysesRead}})
+	assert.ErrorIs(t, err, ErrAccessTokenLimitReached)
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"log"
+	"regexp"
 	"strings"
 	"time"
 
@@ -15,8 +15,9 @@ import (
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
-	"github.com/qs3c/anal_go_server/internal/pkg/oss"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
 	"github.com/qs3c/anal_go_server/internal/pkg/queue"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
@@ -24,15 +25,23 @@ var (
 	ErrAnalysisNotFound    = errors.New("分析项目不存在")
 	ErrAnalysisPermission  = errors.New("无权操作此分析项目")
 	ErrAnalysisNotComplete = errors.New("分析尚未完成，无法分享")
+	ErrImportNotManual     = errors.New("只有手动创建的分析可以导入框图")
+	ErrAnalysisNotReady    = errors.New("分析尚未完成")
+	ErrRerunNotSupported   = errors.New("只有 GitHub 仓库的 AI 分析可以重新运行")
+	ErrAnalysisRunning     = errors.New("分析正在进行中")
+	ErrInvalidRef          = errors.New("无效的分支、标签或提交")
 )
 
+// refPattern git 引用允许的字符
+var refPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
 type AnalysisService struct {
 	analysisRepo  *repository.AnalysisRepository
 	jobRepo       *repository.JobRepository
 	userRepo      *repository.UserRepository
 	quotaService  *QuotaService
 	uploadService *UploadService
-	ossClient     *oss.Client
+	stores        *storage.Set
 	jobQueue      *queue.Queue
 	cfg           *config.Config
 }
@@ -43,7 +52,7 @@ func NewAnalysisService(
 	userRepo *repository.UserRepository,
 	quotaService *QuotaService,
 	uploadService *UploadService,
-	ossClient *oss.Client,
+	stores *storage.Set,
 	jobQueue *queue.Queue,
 	cfg *config.Config,
 ) *AnalysisService {
@@ -53,7 +62,7 @@ func NewAnalysisService(
 		userRepo:      userRepo,
 		quotaService:  quotaService,
 		uploadService: uploadService,
-		ossClient:     ossClient,
+		stores:        stores,
 		jobQueue:      jobQueue,
 		cfg:           cfg,
 	}
@@ -96,16 +105,30 @@ func (s *AnalysisService) Create(userID int64, req *dto.CreateAnalysisRequest) (
 			sourceType = "github" // default
 		}
 
-		if sourceType == "upload" {
+		if sourceType == "upload" || sourceType == "module" {
 			if req.UploadID == "" {
 				return nil, errors.New("upload_id 不能为空")
 			}
 			if s.uploadService != nil {
-				if _, err := s.uploadService.GetUploadPath(req.UploadID); err != nil {
+				if _, err := s.uploadService.GetUploadPath(userID, req.UploadID); err != nil {
+					return nil, err
+				}
+				// module 来源的 upload_id 来自 /upload/module，记录对应的 path@version
+				if sourceType == "module" {
+					upload, err := s.uploadService.GetUpload(userID, req.UploadID)
+					if err != nil {
+						return nil, err
+					}
+					if upload.Module == "" {
+						return nil, ErrUploadNotModule
+					}
+					analysis.RepoURL = upload.Module
+				}
+				if err := s.uploadService.ExtendUpload(req.UploadID); err != nil {
 					return nil, err
 				}
 			}
-			analysis.SourceType = "upload"
+			analysis.SourceType = sourceType
 			analysis.UploadID = req.UploadID
 			analysis.StartFile = req.StartFile
 		} else {
@@ -121,22 +144,19 @@ func (s *AnalysisService) Create(userID int64, req *dto.CreateAnalysisRequest) (
 		// 手动创建
 		analysis.Status = "draft"
 		if req.DiagramData != nil {
-			// 序列化并上传到 OSS
-			data, err := json.Marshal(req.DiagramData)
+			// 校验并升级到当前格式版本后写入对象存储
+			data, err := s.validateDiagram(user.SubscriptionLevel, req.DiagramData)
 			if err != nil {
 				return nil, err
 			}
-			if s.ossClient != nil {
+			if s.stores.For("") != nil {
 				// 先创建分析获取 ID
 				if err := s.analysisRepo.Create(analysis); err != nil {
 					return nil, err
 				}
-				ossURL, err := s.ossClient.UploadDiagram(analysis.ID, data)
-				if err != nil {
+				if err := s.putDiagram(analysis, data); err != nil {
 					return nil, err
 				}
-				analysis.DiagramOSSURL = ossURL
-				analysis.DiagramSize = len(data)
 				analysis.Status = "completed"
 				now := time.Now()
 				analysis.CompletedAt = &now
@@ -159,49 +179,144 @@ func (s *AnalysisService) Create(userID int64, req *dto.CreateAnalysisRequest) (
 
 	// 如果是 AI 分析，创建任务
 	if req.CreationType == "ai" {
-		if err := s.quotaService.UseQuota(userID); err != nil {
+		job, err := s.enqueue(analysis, "")
+		if err != nil {
+			// 分析已创建但任务没有进入队列，标记为失败，用户可以稍后重新运行
+			if uerr := s.analysisRepo.UpdateFields(analysis.ID, map[string]interface{}{
+				"status":        "failed",
+				"error_message": "创建分析任务失败",
+			}); uerr != nil {
+				log.Printf("Failed to mark analysis %d failed: %v", analysis.ID, uerr)
+			}
 			return nil, err
 		}
+		resp.JobID = job.ID
+	}
 
-		job := &model.AnalysisJob{
-			AnalysisID:  analysis.ID,
-			UserID:      userID,
-			RepoURL:     req.RepoURL,
-			StartStruct: req.StartStruct,
-			Depth:       req.AnalysisDepth,
-			ModelName:   req.ModelName,
-			Status:      "queued",
-		}
+	return resp, nil
+}
 
-		if err := s.jobRepo.Create(job); err != nil {
-			s.quotaService.RefundQuota(userID)
-			return nil, err
+// Rerun 重新运行 GitHub 仓库的 AI 分析，ref 为分支、标签或提交，空表示默认分支
+// 与创建分析一样检查并消耗配额，供定时任务和 webhook 触发
+func (s *AnalysisService) Rerun(userID, analysisID int64, ref string) (*dto.CreateAnalysisResponse, error) {
+	analysis, err := s.getOwned(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.CreationType != "ai" || analysis.SourceType != "github" {
+		return nil, ErrRerunNotSupported
+	}
+	if err := ValidateRef(ref); err != nil {
+		return nil, err
+	}
+
+	active, err := s.jobRepo.HasActiveJob(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, ErrAnalysisRunning
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	hasQuota, err := s.quotaService.CheckQuota(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasQuota {
+		return nil, ErrQuotaExceeded
+	}
+	// 订阅可能已降级，按当前等级重新检查
+	if err := s.quotaService.CheckDepth(user.SubscriptionLevel, analysis.AnalysisDepth); err != nil {
+		return nil, err
+	}
+	if err := s.quotaService.CheckModelPermission(user.SubscriptionLevel, analysis.ModelName); err != nil {
+		return nil, err
+	}
+
+	if err := s.analysisRepo.UpdateFields(analysisID, map[string]interface{}{
+		"status":        "pending",
+		"error_message": "",
+	}); err != nil {
+		return nil, err
+	}
+	job, err := s.enqueue(analysis, ref)
+	if err != nil {
+		// 恢复重新运行前的状态，analysis 中还是更新前的值
+		if uerr := s.analysisRepo.UpdateFields(analysisID, map[string]interface{}{
+			"status":        analysis.Status,
+			"error_message": analysis.ErrorMessage,
+		}); uerr != nil {
+			log.Printf("Failed to restore analysis %d status: %v", analysisID, uerr)
 		}
+		return nil, err
+	}
+	return &dto.CreateAnalysisResponse{AnalysisID: analysisID, JobID: job.ID}, nil
+}
 
-		resp.JobID = job.ID
+// enqueue 消耗配额，创建任务并加入 Redis 队列，失败时退还配额
+// 加入队列失败时任务标记为失败，避免 HasActiveJob 一直认为分析在运行
+func (s *AnalysisService) enqueue(analysis *model.Analysis, ref string) (*model.AnalysisJob, error) {
+	if err := s.quotaService.UseQuota(analysis.UserID); err != nil {
+		return nil, err
+	}
 
-		// 加入 Redis 队列
-		if s.jobQueue != nil {
-			jobMsg := &queue.JobMessage{
-				JobID:       job.ID,
-				AnalysisID:  analysis.ID,
-				UserID:      userID,
-				SourceType:  analysis.SourceType,
-				RepoURL:     req.RepoURL,
-				UploadID:    req.UploadID,
-				StartFile:   req.StartFile,
-				StartStruct: req.StartStruct,
-				Depth:       req.AnalysisDepth,
-				ModelName:   req.ModelName,
-			}
-			if err := s.jobQueue.Push(context.Background(), jobMsg); err != nil {
-				s.quotaService.RefundQuota(userID)
-				return nil, err
+	job := &model.AnalysisJob{
+		AnalysisID:  analysis.ID,
+		UserID:      analysis.UserID,
+		RepoURL:     analysis.RepoURL,
+		Ref:         ref,
+		StartStruct: analysis.StartStruct,
+		Depth:       analysis.AnalysisDepth,
+		ModelName:   analysis.ModelName,
+		Status:      "queued",
+	}
+
+	if err := s.jobRepo.Create(job); err != nil {
+		s.quotaService.RefundQuota(analysis.UserID)
+		return nil, err
+	}
+
+	// 加入 Redis 队列
+	if s.jobQueue != nil {
+		jobMsg := &queue.JobMessage{
+			JobID:       job.ID,
+			AnalysisID:  analysis.ID,
+			UserID:      analysis.UserID,
+			SourceType:  analysis.SourceType,
+			RepoURL:     analysis.RepoURL,
+			Ref:         ref,
+			UploadID:    analysis.UploadID,
+			StartFile:   analysis.StartFile,
+			StartStruct: analysis.StartStruct,
+			Depth:       analysis.AnalysisDepth,
+			ModelName:   analysis.ModelName,
+		}
+		if err := s.jobQueue.Push(context.Background(), jobMsg); err != nil {
+			s.quotaService.RefundQuota(analysis.UserID)
+			job.Status = "failed"
+			job.ErrorMessage = "加入任务队列失败"
+			if uerr := s.jobRepo.Update(job); uerr != nil {
+				log.Printf("Failed to mark job %d failed: %v", job.ID, uerr)
 			}
+			return nil, err
 		}
 	}
+	return job, nil
+}
 
-	return resp, nil
+// ValidateRef 检查 git 引用名，只允许常见的分支、标签和提交字符，不能以 - 开头
+func ValidateRef(ref string) error {
+	if ref == "" {
+		return nil
+	}
+	if len(ref) > 200 || strings.HasPrefix(ref, "-") || strings.Contains(ref, "..") || !refPattern.MatchString(ref) {
+		return ErrInvalidRef
+	}
+	return nil
 }
 
 // GetByID 获取分析详情
@@ -273,24 +388,62 @@ func (s *AnalysisService) Update(userID, analysisID int64, req *dto.UpdateAnalys
 		analysis.Description = *req.Description
 	}
 	if req.DiagramData != nil {
-		// 序列化并上传到 OSS
-		data, err := json.Marshal(req.DiagramData)
+		user, err := s.userRepo.GetByID(userID)
 		if err != nil {
 			return nil, err
 		}
-		if s.ossClient != nil {
-			ossURL, err := s.ossClient.UploadDiagram(analysis.ID, data)
-			if err != nil {
-				return nil, err
-			}
-			analysis.DiagramOSSURL = ossURL
-			analysis.DiagramSize = len(data)
-			if analysis.Status == "draft" {
-				analysis.Status = "completed"
-				now := time.Now()
-				analysis.CompletedAt = &now
-			}
+		// 校验并升级到当前格式版本后写入对象存储
+		data, err := s.validateDiagram(user.SubscriptionLevel, req.DiagramData)
+		if err != nil {
+			return nil, err
 		}
+		if err := s.saveDiagram(analysis, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.analysisRepo.Update(analysis); err != nil {
+		return nil, err
+	}
+
+	return s.buildAnalysisDetail(analysis), nil
+}
+
+// Import 把 Mermaid、PlantUML 或 Go 源码转换为框图，写入手动创建的分析
+func (s *AnalysisService) Import(userID, analysisID int64, req *dto.ImportDiagramRequest) (*dto.AnalysisDetail, error) {
+	analysis, err := s.analysisRepo.GetByID(analysisID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, err
+	}
+
+	if analysis.UserID != userID {
+		return nil, ErrAnalysisPermission
+	}
+	if analysis.CreationType != "manual" {
+		return nil, ErrImportNotManual
+	}
+
+	d, err := diagram.Import(req.Format, req.Source)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.validateDiagram(user.SubscriptionLevel, raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveDiagram(analysis, data); err != nil {
+		return nil, err
 	}
 
 	if err := s.analysisRepo.Update(analysis); err != nil {
@@ -300,6 +453,31 @@ func (s *AnalysisService) Update(userID, analysisID int64, req *dto.UpdateAnalys
 	return s.buildAnalysisDetail(analysis), nil
 }
 
+// validateDiagram 按订阅等级的限制校验框图并升级到当前格式版本，校验失败返回 diagram.ValidationError
+func (s *AnalysisService) validateDiagram(subscriptionLevel string, data []byte) ([]byte, error) {
+	var limits diagram.Limits
+	if s.quotaService != nil {
+		limits = s.quotaService.DiagramLimits(subscriptionLevel)
+	}
+	return diagram.ValidateJSON(data, limits)
+}
+
+// saveDiagram 写入手动编辑或导入的框图，草稿随之变为已完成
+func (s *AnalysisService) saveDiagram(analysis *model.Analysis, data []byte) error {
+	if s.stores.For("") == nil {
+		return nil
+	}
+	if err := s.putDiagram(analysis, data); err != nil {
+		return err
+	}
+	if analysis.Status == "draft" {
+		analysis.Status = "completed"
+		now := time.Now()
+		analysis.CompletedAt = &now
+	}
+	return nil
+}
+
 // Delete 删除分析
 func (s *AnalysisService) Delete(userID, analysisID int64) error {
 	analysis, err := s.analysisRepo.GetByID(analysisID)
@@ -317,10 +495,9 @@ func (s *AnalysisService) Delete(userID, analysisID int64) error {
 	// 取消进行中的任务
 	s.jobRepo.CancelByAnalysisID(analysisID)
 
-	// 删除 OSS 文件
-	if analysis.DiagramOSSURL != "" && s.ossClient != nil {
-		objectKey := s.ossClient.ExtractObjectKey(analysis.DiagramOSSURL)
-		s.ossClient.Delete(objectKey)
+	// 删除存储中的框图
+	if store := s.stores.For(analysis.DiagramBackend); store != nil && analysis.DiagramKey != "" {
+		store.Delete(context.Background(), analysis.DiagramKey)
 	}
 
 	return s.analysisRepo.Delete(analysisID)
@@ -399,6 +576,7 @@ func (s *AnalysisService) GetJobStatus(userID, analysisID int64) (*dto.JobStatus
 	resp := &dto.JobStatusResponse{
 		JobID:        job.ID,
 		AnalysisID:   job.AnalysisID,
+		Ref:          job.Ref,
 		Status:       job.Status,
 		CurrentStep:  job.CurrentStep,
 		ErrorMessage: job.ErrorMessage,
@@ -408,47 +586,86 @@ func (s *AnalysisService) GetJobStatus(userID, analysisID int64) (*dto.JobStatus
 		resp.StartedAt = job.StartedAt.Format(time.RFC3339)
 		resp.ElapsedSeconds = int(time.Since(*job.StartedAt).Seconds())
 	}
+	if len(job.RuleResult) > 0 {
+		resp.RuleResult = json.RawMessage(job.RuleResult)
+	}
 
 	return resp, nil
 }
 
-func (s *AnalysisService) buildAnalysisDetail(a *model.Analysis) *dto.AnalysisDetail {
-	// 处理 diagram URL
-	diagramURL := a.DiagramOSSURL
+// putDiagram 将框图压缩后写入主存储并记录 key，成功后删除旧的框图对象
+func (s *AnalysisService) putDiagram(analysis *model.Analysis, data []byte) error {
+	ctx := context.Background()
+	store := s.stores.For("")
+	enc, err := storage.Compress(data)
+	if err != nil {
+		return err
+	}
+	key := storage.DiagramKey(analysis.ID)
+	opts := storage.PutOptions{ContentType: "application/json", ContentEncoding: enc.Encoding}
+	if err := store.Put(ctx, key, enc.Data, opts); err != nil {
+		return err
+	}
 
-	if strings.HasPrefix(diagramURL, "local://") {
-		// 本地存储：走 API 代理
-		diagramURL = fmt.Sprintf("/api/v1/analyses/%d/diagram", a.ID)
-	} else if strings.HasPrefix(diagramURL, "https://") && s.ossClient != nil {
-		// OSS 存储：生成签名 URL，前端直接从 OSS 下载
-		objectKey := s.ossClient.ExtractObjectKey(diagramURL)
-		if signedURL, err := s.ossClient.GetSignedURL(objectKey); err == nil {
-			diagramURL = signedURL
+	if old := s.stores.For(analysis.DiagramBackend); old != nil && analysis.DiagramKey != "" {
+		old.Delete(ctx, analysis.DiagramKey)
+	}
+	analysis.DiagramKey = key
+	analysis.DiagramBackend = store.Name()
+	analysis.DiagramSize = enc.RawSize
+	analysis.DiagramStoredSize = len(enc.Data)
+	analysis.DiagramEncoding = enc.Encoding
+	analysis.DiagramETag = enc.ETag
+	analysis.DiagramSchemaVersion = diagram.SchemaVersion
+	return nil
+}
+
+// DiagramURL 生成框图的临时访问地址，前端直接从存储下载
+// 后端不可用或签名失败时退回到 API 代理地址
+func (s *AnalysisService) DiagramURL(a *model.Analysis) string {
+	return diagramURL(s.stores, a)
+}
+
+func diagramURL(stores *storage.Set, a *model.Analysis) string {
+	if a.DiagramKey == "" {
+		return ""
+	}
+	// 旧版本框图需要经过 API 升级格式，不能直接从存储下载
+	if a.DiagramSchemaVersion < diagram.SchemaVersion {
+		return fmt.Sprintf("/api/v1/analyses/%d/diagram", a.ID)
+	}
+	if store := stores.For(a.DiagramBackend); store != nil {
+		if signedURL, err := store.SignedURL(a.DiagramKey, storage.DefaultSignedURLExpire); err == nil {
+			return signedURL
 		}
 	}
+	return fmt.Sprintf("/api/v1/analyses/%d/diagram", a.ID)
+}
 
+func (s *AnalysisService) buildAnalysisDetail(a *model.Analysis) *dto.AnalysisDetail {
 	detail := &dto.AnalysisDetail{
-		ID:               a.ID,
-		Title:            a.Title,
-		Description:      a.Description,
-		CreationType:     a.CreationType,
-		RepoURL:          a.RepoURL,
-		StartStruct:      a.StartStruct,
-		AnalysisDepth:    a.AnalysisDepth,
-		ModelName:        a.ModelName,
-		DiagramOSSURL:    diagramURL,
-		DiagramSize:      a.DiagramSize,
-		Status:           a.Status,
-		ErrorMessage:     a.ErrorMessage,
-		IsPublic:         a.IsPublic,
-		ShareTitle:       a.ShareTitle,
-		ShareDescription: a.ShareDescription,
-		ViewCount:        a.ViewCount,
-		LikeCount:        a.LikeCount,
-		CommentCount:     a.CommentCount,
-		BookmarkCount:    a.BookmarkCount,
-		CreatedAt:        a.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:        a.UpdatedAt.Format(time.RFC3339),
+		ID:                a.ID,
+		Title:             a.Title,
+		Description:       a.Description,
+		CreationType:      a.CreationType,
+		RepoURL:           a.RepoURL,
+		StartStruct:       a.StartStruct,
+		AnalysisDepth:     a.AnalysisDepth,
+		ModelName:         a.ModelName,
+		DiagramOSSURL:     s.DiagramURL(a),
+		DiagramSize:       a.DiagramSize,
+		DiagramStoredSize: a.DiagramStoredSize,
+		Status:            a.Status,
+		ErrorMessage:      a.ErrorMessage,
+		IsPublic:          a.IsPublic,
+		ShareTitle:        a.ShareTitle,
+		ShareDescription:  a.ShareDescription,
+		ViewCount:         a.ViewCount,
+		LikeCount:         a.LikeCount,
+		CommentCount:      a.CommentCount,
+		BookmarkCount:     a.BookmarkCount,
+		CreatedAt:         a.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:         a.UpdatedAt.Format(time.RFC3339),
 	}
 
 	if a.Tags != nil {
@@ -461,8 +678,16 @@ func (s *AnalysisService) buildAnalysisDetail(a *model.Analysis) *dto.AnalysisDe
 		detail.CompletedAt = a.CompletedAt.Format(time.RFC3339)
 	}
 
-	// Check if diagram is stored locally (OSS upload failed)
-	if strings.HasPrefix(a.DiagramOSSURL, "local://") {
+	if a.Status == "completed" {
+		if metrics, err := s.analysisRepo.GetMetrics(a.ID); err == nil {
+			detail.Metrics = toMetricsDTO(metrics)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Analysis %d: failed to load metrics: %v", a.ID, err)
+		}
+	}
+
+	// 主存储写入失败时框图暂存在降级存储中
+	if s.stores != nil && s.stores.Fallback != nil && a.DiagramBackend == s.stores.Fallback.Name() {
 		detail.Warnings = append(detail.Warnings, "图表数据暂存本地，稍后将自动同步到云端")
 	}
 
@@ -483,9 +708,49 @@ func (s *AnalysisService) buildAnalysisDetail(a *model.Analysis) *dto.AnalysisDe
 	return detail
 }
 
-// GetDiagramData 获取图表数据
-// 支持本地存储和 OSS 存储
-func (s *AnalysisService) GetDiagramData(userID, analysisID int64) ([]byte, error) {
+func toMetricsDTO(m *model.AnalysisMetrics) *dto.Metrics {
+	metrics := &dto.Metrics{
+		StructCount:     m.StructCount,
+		ConnectionCount: m.ConnectionCount,
+		PackageCount:    m.PackageCount,
+		CycleCount:      m.CycleCount,
+		MaxFanIn:        m.MaxFanIn,
+		MaxFanOut:       m.MaxFanOut,
+		AvgFanOut:       m.AvgFanOut,
+		MaxInstability:  m.MaxInstability,
+		ComputedAt:      m.UpdatedAt.Format(time.RFC3339),
+	}
+	if json.Valid(m.Details) {
+		metrics.Details = json.RawMessage(m.Details)
+	}
+	return metrics
+}
+
+// Diagram 存储中的框图，Data 按 Encoding 编码（空表示未压缩）
+type Diagram struct {
+	Data        []byte
+	Encoding    string
+	ETag        string
+	NotModified bool // If-None-Match 命中，Data 为空
+}
+
+// Raw 返回解压后的原始 JSON
+func (d *Diagram) Raw() ([]byte, error) {
+	return storage.Decompress(d.Data, d.Encoding)
+}
+
+// GetDiagram 获取框图，ifNoneMatch 与 ETag 匹配时不读取存储，直接返回 NotModified
+// 按数据库记录的后端读取，兼容主存储和降级存储
+func (s *AnalysisService) GetDiagram(userID, analysisID int64, ifNoneMatch string) (*Diagram, error) {
+	analysis, err := s.getReadable(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	return s.readDiagram(analysis, ifNoneMatch)
+}
+
+// getReadable 获取分析，私有分析只能自己访问
+func (s *AnalysisService) getReadable(userID, analysisID int64) (*model.Analysis, error) {
 	analysis, err := s.analysisRepo.GetByID(analysisID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -494,35 +759,223 @@ func (s *AnalysisService) GetDiagramData(userID, analysisID int64) ([]byte, erro
 		return nil, err
 	}
 
-	// 验证权限（私有分析只能自己访问）
 	if !analysis.IsPublic && analysis.UserID != userID {
 		return nil, ErrAnalysisPermission
 	}
+	return analysis, nil
+}
+
+// getOwned 获取分析，只有创建者可以操作
+func (s *AnalysisService) getOwned(userID, analysisID int64) (*model.Analysis, error) {
+	analysis, err := s.analysisRepo.GetByID(analysisID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, err
+	}
+
+	if analysis.UserID != userID {
+		return nil, ErrAnalysisPermission
+	}
+	return analysis, nil
+}
 
-	if analysis.DiagramOSSURL == "" {
+func (s *AnalysisService) readDiagram(analysis *model.Analysis, ifNoneMatch string) (*Diagram, error) {
+	if analysis.DiagramKey == "" {
 		return nil, errors.New("diagram not available")
 	}
 
-	// 检查是否是本地存储
-	if strings.HasPrefix(analysis.DiagramOSSURL, "local://") {
-		// 本地存储：从文件读取
-		localPath := filepath.Join(s.cfg.Upload.TempDir, "diagrams", fmt.Sprintf("%d.json", analysisID))
-		data, err := os.ReadFile(localPath)
+	stored := &Diagram{Encoding: analysis.DiagramEncoding, ETag: analysis.DiagramETag}
+	current := analysis.DiagramSchemaVersion >= diagram.SchemaVersion
+	if current && stored.ETag != "" && etagMatches(ifNoneMatch, stored.ETag) {
+		stored.NotModified = true
+		return stored, nil
+	}
+
+	store := s.stores.For(analysis.DiagramBackend)
+	if store == nil {
+		return nil, fmt.Errorf("storage backend %q not configured", analysis.DiagramBackend)
+	}
+	var err error
+	stored.Data, err = store.Get(context.Background(), analysis.DiagramKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read diagram from %s: %w", store.Name(), err)
+	}
+
+	// 旧版本框图升级到当前格式后以未压缩形式返回，ETag 按升级后的内容现算
+	if !current || stored.ETag == "" {
+		raw, err := stored.Raw()
 		if err != nil {
-			return nil, fmt.Errorf("failed to read local diagram: %w", err)
+			return nil, err
+		}
+		if migrated, _, err := diagram.Migrate(raw); err != nil {
+			log.Printf("Analysis %d: failed to migrate diagram: %v", analysis.ID, err)
+		} else {
+			raw = migrated
+		}
+		stored = &Diagram{Data: raw, ETag: storage.ContentETag(raw)}
+		if etagMatches(ifNoneMatch, stored.ETag) {
+			return &Diagram{ETag: stored.ETag, NotModified: true}, nil
 		}
-		return data, nil
 	}
+	return stored, nil
+}
 
-	// OSS 存储：从 OSS 下载并返回
-	if s.ossClient != nil {
-		objectKey := s.ossClient.ExtractObjectKey(analysis.DiagramOSSURL)
-		data, err := s.ossClient.Download(objectKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to download diagram from OSS: %w", err)
+// GetDiagramData 获取解压后的图表数据
+func (s *AnalysisService) GetDiagramData(userID, analysisID int64) ([]byte, error) {
+	stored, err := s.GetDiagram(userID, analysisID, "")
+	if err != nil {
+		return nil, err
+	}
+	return stored.Raw()
+}
+
+// Export 将框图导出为 Mermaid、PlantUML、DOT 或 SVG，opts.Root 为空时以分析的起点结构体计算层级
+func (s *AnalysisService) Export(userID, analysisID int64, format string, opts diagram.Options) ([]byte, error) {
+	analysis, d, err := s.loadDiagram(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Root == "" {
+		opts.Root = analysis.StartStruct
+	}
+	return diagram.Export(d, format, opts)
+}
+
+// QueryDiagram 在框图上执行图查询（邻居、最短路径、子图、循环依赖），结果为可直接渲染的小框图
+func (s *AnalysisService) QueryDiagram(userID, analysisID int64, q diagram.Query) (*diagram.QueryResult, error) {
+	_, d, err := s.loadDiagram(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	return d.Query(q)
+}
+
+// loadDiagram 读取并解析有权限查看的分析的框图
+func (s *AnalysisService) loadDiagram(userID, analysisID int64) (*model.Analysis, *diagram.Diagram, error) {
+	analysis, err := s.getReadable(userID, analysisID)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := s.parseDiagram(analysis)
+	if err != nil {
+		return nil, nil, err
+	}
+	return analysis, d, nil
+}
+
+func (s *AnalysisService) parseDiagram(analysis *model.Analysis) (*diagram.Diagram, error) {
+	stored, err := s.readDiagram(analysis, "")
+	if err != nil {
+		return nil, err
+	}
+	raw, err := stored.Raw()
+	if err != nil {
+		return nil, err
+	}
+	return diagram.Parse(raw)
+}
+
+// GetRules 获取分析的架构规则
+func (s *AnalysisService) GetRules(userID, analysisID int64) ([]dto.RuleItem, error) {
+	if _, err := s.getOwned(userID, analysisID); err != nil {
+		return nil, err
+	}
+	rules, err := s.analysisRepo.ListRules(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	return toRuleItems(rules), nil
+}
+
+// UpdateRules 替换分析的架构规则，下次运行结束时按新规则检查
+func (s *AnalysisService) UpdateRules(userID, analysisID int64, req *dto.UpdateRulesRequest) ([]dto.RuleItem, error) {
+	if _, err := s.getOwned(userID, analysisID); err != nil {
+		return nil, err
+	}
+
+	rules := make([]*model.AnalysisRule, 0, len(req.Rules))
+	for i, item := range req.Rules {
+		rule := diagram.Rule{Type: item.Type, From: item.From, To: item.To, Max: item.Max}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
 		}
-		return data, nil
+		rules = append(rules, &model.AnalysisRule{Type: rule.Type, FromPkg: rule.From, ToPkg: rule.To, Max: rule.Max})
+	}
+	if err := s.analysisRepo.ReplaceRules(analysisID, rules); err != nil {
+		return nil, err
+	}
+	return toRuleItems(rules), nil
+}
+
+// CheckRules 用当前规则检查分析最新的框图，供 CI 根据结果决定是否通过
+func (s *AnalysisService) CheckRules(userID, analysisID int64) (*dto.RuleCheckResponse, error) {
+	analysis, err := s.getOwned(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.Status != "completed" {
+		return nil, ErrAnalysisNotReady
 	}
 
-	return nil, errors.New("OSS client not initialized")
+	rules, err := s.analysisRepo.ListRules(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.parseDiagram(analysis)
+	if err != nil {
+		return nil, err
+	}
+	result, err := diagram.Check(d, toDiagramRules(rules))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.RuleCheckResponse{
+		AnalysisID: analysisID,
+		Passed:     result.Passed,
+		Rules:      result.Rules,
+		Violations: make([]dto.RuleViolation, 0, len(result.Violations)),
+		CheckedAt:  time.Now().Format(time.RFC3339),
+	}
+	for _, v := range result.Violations {
+		resp.Violations = append(resp.Violations, dto.RuleViolation{Rule: v.Rule, Type: v.Type, Message: v.Message, Structs: v.Structs})
+	}
+	return resp, nil
+}
+
+// toDiagramRules 转换为 diagram.Check 使用的规则
+func toDiagramRules(rules []*model.AnalysisRule) []diagram.Rule {
+	result := make([]diagram.Rule, 0, len(rules))
+	for _, r := range rules {
+		result = append(result, diagram.Rule{Type: r.Type, From: r.FromPkg, To: r.ToPkg, Max: r.Max})
+	}
+	return result
+}
+
+func toRuleItems(rules []*model.AnalysisRule) []dto.RuleItem {
+	items := make([]dto.RuleItem, 0, len(rules))
+	for _, r := range rules {
+		items = append(items, dto.RuleItem{Type: r.Type, From: r.FromPkg, To: r.ToPkg, Max: r.Max})
+	}
+	return items
+}
+
+// etagMatches 按 If-None-Match 的弱比较规则判断 etag 是否命中（支持 * 和逗号分隔的列表）
+// 压缩返回时的 ETag（见 storage.EncodedETag）同样视为命中
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	encoded := storage.EncodedETag(etag, storage.EncodingGzip)
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag || candidate == encoded {
+			return true
+		}
+	}
+	return false
 }
@@ -1,13 +1,25 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
+	"github.com/qs3c/anal_go_server/internal/pkg/queue"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/testutil"
 )
@@ -175,6 +187,121 @@ func TestAnalysisService_Create_AI_DepthExceeded(t *testing.T) {
 	assert.Equal(t, ErrDepthExceeded, err)
 }
 
+func TestAnalysisService_Create_Upload_Ownership(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+			},
+		},
+		Models: []config.ModelConfig{
+			{Name: "gpt-3.5-turbo", RequiredLevel: "free"},
+		},
+		Upload: config.UploadConfig{TempDir: t.TempDir(), ExpireHours: 1},
+	}
+	quotaService := NewQuotaService(userRepo, cfg)
+	uploadService := NewUploadService(uploadRepo, cfg)
+	service := NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, uploadService, nil, nil, cfg)
+
+	owner := testutil.TestUser(t, db, testutil.WithEmail("owner@example.com"), testutil.WithUsername("owner"))
+	other := testutil.TestUser(t, db, testutil.WithEmail("other@example.com"), testutil.WithUsername("other"))
+
+	resp, err := uploadService.ParseZip(owner.ID, createTestZip(t, map[string]string{
+		"main.go": "package main\n\ntype App struct{}\n",
+	}), "app.zip")
+	require.NoError(t, err)
+
+	req := &dto.CreateAnalysisRequest{
+		Title:         "Upload Analysis",
+		CreationType:  "ai",
+		SourceType:    "upload",
+		UploadID:      resp.UploadID,
+		StartFile:     "main.go",
+		StartStruct:   "App",
+		AnalysisDepth: 3,
+		ModelName:     "gpt-3.5-turbo",
+	}
+
+	// 其他用户不能使用别人的上传
+	_, err = service.Create(other.ID, req)
+	assert.Equal(t, ErrUploadPermission, err)
+
+	created, err := service.Create(owner.ID, req)
+	require.NoError(t, err)
+	assert.NotZero(t, created.AnalysisID)
+
+	// 普通上传不能作为 module 来源
+	req.SourceType = "module"
+	_, err = service.Create(owner.ID, req)
+	assert.Equal(t, ErrUploadNotModule, err)
+
+	// 过期的上传不能再使用
+	req.SourceType = "upload"
+	require.NoError(t, uploadRepo.ExtendExpiry(resp.UploadID, time.Now().Add(-time.Minute)))
+	_, err = service.Create(owner.ID, req)
+	assert.Equal(t, ErrUploadNotFound, err)
+}
+
+func TestAnalysisService_Create_Module(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+			},
+		},
+		Models: []config.ModelConfig{
+			{Name: "gpt-3.5-turbo", RequiredLevel: "free"},
+		},
+		Upload: config.UploadConfig{TempDir: t.TempDir(), ExpireHours: 1},
+	}
+	quotaService := NewQuotaService(userRepo, cfg)
+	uploadService := NewUploadService(uploadRepo, cfg)
+	service := NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, uploadService, nil, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+
+	zipPath := createTestZip(t, map[string]string{
+		"example.com/lib@v1.2.3/lib.go": "package lib\n\ntype Client struct{}\n",
+	})
+	parsed, err := uploadService.parseArchive(user.ID, zipPath, "example.com/lib@v1.2.3.zip", "example.com/lib@v1.2.3")
+	require.NoError(t, err)
+
+	resp, err := service.Create(user.ID, &dto.CreateAnalysisRequest{
+		Title:         "Module Analysis",
+		CreationType:  "ai",
+		SourceType:    "module",
+		UploadID:      parsed.UploadID,
+		StartFile:     "example.com/lib@v1.2.3/lib.go",
+		StartStruct:   "Client",
+		AnalysisDepth: 3,
+		ModelName:     "gpt-3.5-turbo",
+	})
+	require.NoError(t, err)
+
+	analysis, err := analysisRepo.GetByID(resp.AnalysisID)
+	require.NoError(t, err)
+	assert.Equal(t, "module", analysis.SourceType)
+	assert.Equal(t, "example.com/lib@v1.2.3", analysis.RepoURL)
+
+	job, err := jobRepo.GetByID(resp.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/lib@v1.2.3", job.RepoURL)
+}
+
 func TestAnalysisService_GetByID_Success(t *testing.T) {
 	db := testutil.SetupTestDB(t)
 	defer testutil.CleanupTestDB(t, db)
@@ -293,6 +420,363 @@ func TestAnalysisService_Update_Success(t *testing.T) {
 	assert.Equal(t, "New description", detail.Description)
 }
 
+func TestAnalysisService_GetByID_Metrics(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	service := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, nil, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+
+	// 还没有计算指标
+	detail, err := service.GetByID(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.Nil(t, detail.Metrics)
+
+	require.NoError(t, analysisRepo.SaveMetrics(&model.AnalysisMetrics{
+		AnalysisID:  analysis.ID,
+		StructCount: 3,
+		CycleCount:  1,
+		MaxFanOut:   2,
+		AvgFanOut:   1.5,
+		Details:     model.JSONText(`{"cycles":[["A","B"]]}`),
+	}))
+
+	detail, err = service.GetByID(user.ID, analysis.ID)
+	require.NoError(t, err)
+	require.NotNil(t, detail.Metrics)
+	assert.Equal(t, 3, detail.Metrics.StructCount)
+	assert.Equal(t, 1, detail.Metrics.CycleCount)
+	assert.Equal(t, 1.5, detail.Metrics.AvgFanOut)
+	assert.JSONEq(t, `{"cycles":[["A","B"]]}`, string(detail.Metrics.Details))
+	assert.NotEmpty(t, detail.Metrics.ComputedAt)
+}
+
+func TestAnalysisService_DiagramStorage(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	quotaService := NewQuotaService(userRepo, cfg)
+	root := t.TempDir()
+	stores := storage.NewSet(storage.NewLocal(root, "", "secret"), nil)
+	service := NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, nil, stores, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+
+	// 写入时按键名排序重新序列化
+	diagramJSON := `{"connections":[],"structs":[],"version":2}`
+	_, err := service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	// 数据库记录 key 和后端，访问地址按需签名
+	saved, err := analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, storage.BackendLocal, saved.DiagramBackend)
+	assert.True(t, strings.HasPrefix(saved.DiagramKey, fmt.Sprintf("diagrams/%d/", analysis.ID)))
+	assert.Equal(t, storage.EncodingGzip, saved.DiagramEncoding)
+	assert.Equal(t, len(diagramJSON), saved.DiagramSize)
+	assert.NotZero(t, saved.DiagramStoredSize)
+	assert.Equal(t, storage.ContentETag([]byte(diagramJSON)), saved.DiagramETag)
+	assert.FileExists(t, filepath.Join(root, filepath.FromSlash(saved.DiagramKey)))
+
+	detail, err := service.GetByID(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(detail.DiagramOSSURL, "/api/v1/files/"+saved.DiagramKey+"?"))
+
+	data, err := service.GetDiagramData(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, diagramJSON, string(data))
+
+	// 再次更新时替换旧对象
+	_, err = service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(`{"version":2,"structs":[{"name":"A"}],"connections":[]}`)})
+	require.NoError(t, err)
+	updated, err := analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, saved.DiagramKey, updated.DiagramKey)
+	assert.NoFileExists(t, filepath.Join(root, filepath.FromSlash(saved.DiagramKey)))
+
+	require.NoError(t, service.Delete(user.ID, analysis.ID))
+	assert.NoFileExists(t, filepath.Join(root, filepath.FromSlash(updated.DiagramKey)))
+}
+
+func TestAnalysisService_GetDiagram_Legacy(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	local := storage.NewLocal(t.TempDir(), "", "secret")
+	service := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, storage.NewSet(local, nil), nil, cfg)
+
+	// 压缩存储之前的数据：未压缩，没有记录 ETag，格式为 v1
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	legacy := `{"nodes":[{"id":1,"label":"A"},{"id":2,"label":"B"}],"edges":[{"source":1,"target":2}]}`
+	require.NoError(t, local.Put(context.Background(), "diagrams/1.json", []byte(legacy), storage.PutOptions{}))
+	analysis.DiagramKey = "diagrams/1.json"
+	analysis.DiagramBackend = storage.BackendLocal
+	require.NoError(t, analysisRepo.Update(analysis))
+
+	// 旧版本不能直接从存储下载
+	detail, err := service.GetByID(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("/api/v1/analyses/%d/diagram", analysis.ID), detail.DiagramOSSURL)
+
+	stored, err := service.GetDiagram(user.ID, analysis.ID, "")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Encoding)
+	assert.JSONEq(t, `{"version":2,"structs":[{"id":"1","name":"A"},{"id":"2","name":"B"}],"connections":[{"from":"1","to":"2"}]}`, string(stored.Data))
+	assert.Equal(t, storage.ContentETag(stored.Data), stored.ETag)
+
+	stored, err = service.GetDiagram(user.ID, analysis.ID, stored.ETag)
+	require.NoError(t, err)
+	assert.True(t, stored.NotModified)
+	assert.Nil(t, stored.Data)
+}
+
+func TestAnalysisService_Update_InvalidDiagram(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3, MaxDiagramStructs: 2},
+				"pro":  {DailyQuota: 100, MaxDepth: 10},
+			},
+		},
+	}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	service := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+
+	// 字段级错误
+	_, err := service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{
+		DiagramData: json.RawMessage(`{"version":2,"structs":[{"name":"A","depth":-1}],"connections":[{"from":"A","to":"B"}]}`),
+	})
+	var errs diagram.ValidationError
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, diagram.ValidationError{{Field: "structs[0].depth", Message: "must be a non-negative integer"}}, errs)
+
+	_, err = service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{
+		DiagramData: json.RawMessage(`{"version":2,"structs":[{"name":"A"}],"connections":[{"from":"A","to":"B"}]}`),
+	})
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "connections[0].to", errs[0].Field)
+
+	// 超过 free 的结构体数量限制，pro 使用默认限制
+	three := json.RawMessage(`{"version":2,"structs":[{"name":"A"},{"name":"B"},{"name":"C"}],"connections":[]}`)
+	_, err = service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: three})
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "structs", errs[0].Field)
+
+	pro := testutil.TestUser(t, db, testutil.WithSubscription("pro", 100))
+	proAnalysis := testutil.TestAnalysis(t, db, pro.ID)
+	_, err = service.Update(pro.ID, proAnalysis.ID, &dto.UpdateAnalysisRequest{DiagramData: three})
+	require.NoError(t, err)
+
+	// 旧版本格式写入时升级
+	_, err = service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(`{"nodes":[{"id":"A"}],"edges":[]}`)})
+	require.NoError(t, err)
+	data, err := service.GetDiagramData(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2,"structs":[{"id":"A"}],"connections":[]}`, string(data))
+
+	// 手动创建同样校验
+	_, err = service.Create(user.ID, &dto.CreateAnalysisRequest{
+		Title:        "manual",
+		CreationType: "manual",
+		DiagramData:  json.RawMessage(`{"version":3,"structs":[],"connections":[]}`),
+	})
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "version", errs[0].Field)
+}
+
+func TestAnalysisService_Export(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	service := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	analysis.StartStruct = "B"
+	require.NoError(t, analysisRepo.Update(analysis))
+	diagramJSON := `{"structs":[{"name":"A"},{"name":"B","fields":[{"name":"C","type":"*C"}]},{"name":"C"}],"connections":[{"from":"A","to":"B"},{"from":"B","to":"C"}]}`
+	_, err := service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	// 默认以起点结构体计算深度：B 为第 0 层，A 不可达也作为起点
+	out, err := service.Export(user.ID, analysis.ID, diagram.FormatMermaid, diagram.Options{HideFields: true})
+	require.NoError(t, err)
+	assert.Equal(t, "classDiagram\n    class A\n    class B\n    class C\n    A --> B\n    B --> C\n", string(out))
+
+	out, err = service.Export(user.ID, analysis.ID, diagram.FormatDOT, diagram.Options{Root: "A", MaxDepth: 1})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"B" [label="{B|C: *C\l}"];`)
+	assert.NotContains(t, string(out), `"C"`)
+
+	_, err = service.Export(user.ID, analysis.ID, "pdf", diagram.Options{})
+	assert.ErrorIs(t, err, diagram.ErrUnsupportedFormat)
+
+	other := testutil.TestUser(t, db)
+	_, err = service.Export(other.ID, analysis.ID, diagram.FormatSVG, diagram.Options{})
+	assert.Equal(t, ErrAnalysisPermission, err)
+}
+
+func TestAnalysisService_QueryDiagram(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	service := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	diagramJSON := `{"structs":[{"name":"A"},{"name":"B"},{"name":"C"}],"connections":[{"from":"A","to":"B"},{"from":"B","to":"C"}]}`
+	_, err := service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	result, err := service.QueryDiagram(user.ID, analysis.ID, diagram.Query{Type: diagram.QueryPath, From: "A", To: "C"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B", "C"}, result.Path)
+	assert.Len(t, result.Connections, 2)
+
+	_, err = service.QueryDiagram(user.ID, analysis.ID, diagram.Query{Type: diagram.QueryNeighbors, Struct: "X"})
+	assert.ErrorIs(t, err, diagram.ErrStructNotFound)
+
+	// 私有分析不允许其他用户查询
+	other := testutil.TestUser(t, db)
+	_, err = service.QueryDiagram(other.ID, analysis.ID, diagram.Query{Type: diagram.QueryCycles})
+	assert.Equal(t, ErrAnalysisPermission, err)
+}
+
+func TestAnalysisService_Rules(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	service := NewAnalysisService(analysisRepo, jobRepo, userRepo, NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	diagramJSON := `{"structs":[{"name":"H","package":"api"},{"name":"S","package":"service"},{"name":"R","package":"repository"}],
+		"connections":[{"from":"H","to":"S"},{"from":"H","to":"R"},{"from":"S","to":"R"}]}`
+	_, err := service.Update(user.ID, analysis.ID, &dto.UpdateAnalysisRequest{DiagramData: json.RawMessage(diagramJSON)})
+	require.NoError(t, err)
+
+	// 没有规则时通过
+	result, err := service.CheckRules(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.Empty(t, result.Violations)
+
+	_, err = service.UpdateRules(user.ID, analysis.ID, &dto.UpdateRulesRequest{Rules: []dto.RuleItem{{Type: "max_fan_out"}}})
+	assert.ErrorIs(t, err, diagram.ErrInvalidRule)
+
+	rules, err := service.UpdateRules(user.ID, analysis.ID, &dto.UpdateRulesRequest{Rules: []dto.RuleItem{
+		{Type: "no_dependency", From: "api", To: "repository"},
+		{Type: "no_cycles"},
+	}})
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	rules, err = service.GetRules(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []dto.RuleItem{{Type: "no_dependency", From: "api", To: "repository"}, {Type: "no_cycles"}}, rules)
+
+	result, err = service.CheckRules(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Equal(t, 2, result.Rules)
+	assert.Equal(t, []dto.RuleViolation{{Rule: 0, Type: "no_dependency", Message: "H (api) depends on R (repository)", Structs: []string{"H", "R"}}}, result.Violations)
+
+	// 其他用户不能查看或修改规则
+	other := testutil.TestUser(t, db)
+	_, err = service.GetRules(other.ID, analysis.ID)
+	assert.Equal(t, ErrAnalysisPermission, err)
+	_, err = service.CheckRules(other.ID, analysis.ID)
+	assert.Equal(t, ErrAnalysisPermission, err)
+
+	// 运行结束时记录的检查结果随任务状态返回
+	require.NoError(t, jobRepo.Create(&model.AnalysisJob{
+		AnalysisID: analysis.ID, UserID: user.ID, Status: "completed",
+		RuleResult: model.JSONText(`{"passed":true,"rules":2,"violations":[]}`),
+	}))
+	status, err := service.GetJobStatus(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"passed":true,"rules":2,"violations":[]}`, string(status.RuleResult))
+
+	draft := testutil.TestAnalysis(t, db, user.ID, testutil.WithStatus("draft"))
+	_, err = service.CheckRules(user.ID, draft.ID)
+	assert.Equal(t, ErrAnalysisNotReady, err)
+}
+
+func TestAnalysisService_Import(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{}
+	stores := storage.NewSet(storage.NewLocal(t.TempDir(), "", "secret"), nil)
+	service := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, stores, nil, cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithStatus("draft"))
+
+	detail, err := service.Import(user.ID, analysis.ID, &dto.ImportDiagramRequest{
+		Format: diagram.FormatGo,
+		Source: "type A struct {\n\tB *B\n}\n\ntype B struct{}\n",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "completed", detail.Status)
+
+	data, err := service.GetDiagramData(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":2,"structs":[{"name":"A","fields":[{"name":"B","type":"*B"}]},{"name":"B"}],"connections":[{"from":"A","to":"B","type":"field"}]}`, string(data))
+
+	// 行级错误原样返回
+	_, err = service.Import(user.ID, analysis.ID, &dto.ImportDiagramRequest{Format: diagram.FormatMermaid, Source: "classDiagram\nA ==> B"})
+	var syntaxErrs diagram.SyntaxErrors
+	require.ErrorAs(t, err, &syntaxErrs)
+	assert.Equal(t, 2, syntaxErrs[0].Line)
+
+	// AI 分析不能导入
+	aiAnalysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithCreationType("ai"))
+	_, err = service.Import(user.ID, aiAnalysis.ID, &dto.ImportDiagramRequest{Format: diagram.FormatGo, Source: "type A struct{}"})
+	assert.Equal(t, ErrImportNotManual, err)
+
+	other := testutil.TestUser(t, db)
+	_, err = service.Import(other.ID, analysis.ID, &dto.ImportDiagramRequest{Format: diagram.FormatGo, Source: "type A struct{}"})
+	assert.Equal(t, ErrAnalysisPermission, err)
+}
+
 func TestAnalysisService_Update_NotFound(t *testing.T) {
 	service, cleanup := setupAnalysisService(t)
 	defer cleanup()
@@ -493,3 +977,120 @@ func TestAnalysisService_GetJobStatus_NoPermission(t *testing.T) {
 	_, err := service.GetJobStatus(user2.ID, analysis.ID)
 	assert.Equal(t, ErrAnalysisPermission, err)
 }
+
+func TestAnalysisService_Rerun(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+			},
+		},
+		Models: []config.ModelConfig{
+			{Name: "gpt-3.5-turbo", RequiredLevel: "free"},
+		},
+	}
+	quotaService := NewQuotaService(userRepo, cfg)
+	service := NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, nil, nil, nil, cfg)
+
+	user := testutil.TestUser(t, db, testutil.WithQuotaUsed(3))
+	created, err := service.Create(user.ID, &dto.CreateAnalysisRequest{
+		Title:         "AI Analysis",
+		CreationType:  "ai",
+		RepoURL:       "https://github.com/example/repo",
+		StartStruct:   "main.Config",
+		AnalysisDepth: 3,
+		ModelName:     "gpt-3.5-turbo",
+	})
+	require.NoError(t, err)
+
+	// 上一次任务还在排队
+	_, err = service.Rerun(user.ID, created.AnalysisID, "")
+	assert.ErrorIs(t, err, ErrAnalysisRunning)
+	require.NoError(t, jobRepo.UpdateStatus(created.JobID, "completed"))
+
+	_, err = service.Rerun(user.ID, created.AnalysisID, "--upload-pack=evil")
+	assert.ErrorIs(t, err, ErrInvalidRef)
+
+	resp, err := service.Rerun(user.ID, created.AnalysisID, "release/v1.2")
+	require.NoError(t, err)
+	assert.Equal(t, created.AnalysisID, resp.AnalysisID)
+	job, err := jobRepo.GetByID(resp.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, "release/v1.2", job.Ref)
+	assert.Equal(t, "main.Config", job.StartStruct)
+	assert.Equal(t, "queued", job.Status)
+	analysis, err := analysisRepo.GetByID(created.AnalysisID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", analysis.Status)
+
+	// 配额用完后不再创建任务
+	require.NoError(t, jobRepo.UpdateStatus(resp.JobID, "completed"))
+	_, err = service.Rerun(user.ID, created.AnalysisID, "")
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	other := testutil.TestUser(t, db)
+	_, err = service.Rerun(other.ID, created.AnalysisID, "")
+	assert.ErrorIs(t, err, ErrAnalysisPermission)
+
+	manual := testutil.TestAnalysis(t, db, user.ID)
+	_, err = service.Rerun(user.ID, manual.ID, "")
+	assert.ErrorIs(t, err, ErrRerunNotSupported)
+}
+
+func TestAnalysisService_Rerun_QueueFailure(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+			},
+		},
+		Models: []config.ModelConfig{
+			{Name: "gpt-3.5-turbo", RequiredLevel: "free"},
+		},
+	}
+	quotaService := NewQuotaService(userRepo, cfg)
+	service := NewAnalysisService(analysisRepo, jobRepo, userRepo, quotaService, nil, nil, queue.NewQueue(rdb, "test:jobs"), cfg)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithCreationType("ai"), testutil.WithStatus("failed"),
+		func(a *model.Analysis) {
+			a.SourceType = "github"
+			a.AnalysisDepth = 2
+			a.ModelName = "gpt-3.5-turbo"
+		})
+	require.NoError(t, analysisRepo.UpdateFields(analysis.ID, map[string]interface{}{"error_message": "clone failed"}))
+
+	// Redis 不可用时任务无法入队
+	mr.Close()
+	_, err = service.Rerun(user.ID, analysis.ID, "")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRerunNotSupported)
+
+	// 分析恢复原状态，任务不再算作运行中
+	restored, err := analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", restored.Status)
+	assert.Equal(t, "clone failed", restored.ErrorMessage)
+	job, err := jobRepo.GetByAnalysisID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", job.Status)
+	active, err := jobRepo.HasActiveJob(analysis.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+}
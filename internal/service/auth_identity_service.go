@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+)
+
+// 可绑定的登录方式
+const (
+	ProviderGithub = "github"
+	ProviderWechat = "wechat"
+	ProviderEmail  = "email"
+	ProviderOIDC   = "oidc"
+)
+
+var (
+	ErrUnsupportedProvider = errors.New("不支持的登录方式")
+	ErrIdentityLinked      = errors.New("已绑定该登录方式")
+	ErrIdentityInUse       = errors.New("该第三方账号已绑定其他用户")
+	ErrIdentityNotLinked   = errors.New("未绑定该登录方式")
+	ErrLastLoginMethod     = errors.New("至少需要保留一种登录方式")
+	ErrEmailMismatch       = errors.New("请使用账号已绑定的邮箱")
+	ErrOAuthEmailConflict  = errors.New("该邮箱已注册，请使用原账号登录后在账号设置中绑定")
+)
+
+// Identities 用户各登录方式的绑定状态
+func (s *AuthService) Identities(userID int64) ([]*dto.IdentityItem, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*dto.IdentityItem{
+		{Provider: ProviderGithub, Linked: user.GithubID != nil},
+		{Provider: ProviderWechat, Linked: user.WechatOpenID != nil},
+		{Provider: ProviderEmail, Linked: hasPasswordLogin(user)},
+	}
+	if user.Email != nil {
+		items[2].Detail = *user.Email
+	}
+	// 配置了 OIDC 或已绑定时才列出，detail 为身份提供方名称
+	if s.oidc != nil || user.OIDCSubject != nil {
+		item := &dto.IdentityItem{Provider: ProviderOIDC, Linked: user.OIDCSubject != nil}
+		if s.oidc != nil {
+			item.Detail = s.oidc.Name()
+		}
+		items = append(items, item)
+	}
+	methods := countLoginMethods(user)
+	for _, item := range items {
+		item.Removable = item.Linked && methods > 1
+	}
+	return items, nil
+}
+
+// LinkAuthURL 绑定第三方账号的授权 URL，state 中需要记录当前用户
+func (s *AuthService) LinkAuthURL(userID int64, provider, state string) (string, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return "", err
+	}
+	switch provider {
+	case ProviderGithub:
+		if user.GithubID != nil {
+			return "", ErrIdentityLinked
+		}
+		return s.githubOAuth.GetAuthURL(state), nil
+	case ProviderWechat:
+		if user.WechatOpenID != nil {
+			return "", ErrIdentityLinked
+		}
+		return s.wechatOAuth.GetAuthURL(state), nil
+	default:
+		return "", ErrUnsupportedProvider
+	}
+}
+
+// LinkOAuth 授权回调时把第三方账号绑定到 userID，第三方账号已属于其他用户时不合并
+func (s *AuthService) LinkOAuth(ctx context.Context, userID int64, provider, code string) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+
+	switch provider {
+	case ProviderGithub:
+		token, err := s.githubOAuth.Exchange(ctx, code)
+		if err != nil {
+			return fmt.Errorf("failed to exchange code: %w", err)
+		}
+		githubUser, err := s.githubOAuth.GetUser(ctx, token)
+		if err != nil {
+			return fmt.Errorf("failed to get github user: %w", err)
+		}
+		githubID := fmt.Sprintf("%d", githubUser.ID)
+		ownerID, err := identityOwner(s.userRepo.GetByGithubID(githubID))
+		if err != nil {
+			return err
+		}
+		if ownerID != 0 && ownerID != userID {
+			return ErrIdentityInUse
+		}
+		if user.GithubID != nil {
+			if *user.GithubID == githubID {
+				return nil
+			}
+			return ErrIdentityLinked
+		}
+		user.GithubID = &githubID
+	case ProviderWechat:
+		token, err := s.wechatOAuth.Exchange(ctx, code)
+		if err != nil {
+			return err
+		}
+		openID := token.OpenID
+		ownerID, err := identityOwner(s.userRepo.GetByWechatOpenID(openID))
+		if err != nil {
+			return err
+		}
+		if ownerID != 0 && ownerID != userID {
+			return ErrIdentityInUse
+		}
+		if user.WechatOpenID != nil {
+			if *user.WechatOpenID == openID {
+				return nil
+			}
+			return ErrIdentityLinked
+		}
+		user.WechatOpenID = &openID
+	default:
+		return ErrUnsupportedProvider
+	}
+
+	return s.userRepo.Update(user)
+}
+
+// LinkEmail 为第三方登录的账号设置邮箱密码登录
+// 账号已有邮箱时只设置密码；新邮箱不能被其他账号使用，并且需要验证后才能登录
+func (s *AuthService) LinkEmail(userID int64, req *dto.LinkEmailRequest) (*dto.LinkIdentityResponse, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash != nil {
+		return nil, ErrIdentityLinked
+	}
+
+	resp := &dto.LinkIdentityResponse{}
+	if user.Email != nil {
+		if !strings.EqualFold(*user.Email, req.Email) {
+			return nil, ErrEmailMismatch
+		}
+	} else {
+		exists, err := s.userRepo.ExistsByEmail(req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrEmailExists
+		}
+
+		code, err := generateRandomCode(32)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := time.Now().Add(verificationTTL)
+		user.Email = &req.Email
+		user.EmailVerified = false
+		user.VerificationCode = &code
+		user.VerificationExpiresAt = &expiresAt
+		resp.VerificationRequired = true
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	passwordStr := string(hashedPassword)
+	user.PasswordHash = &passwordStr
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	if resp.VerificationRequired {
+		s.sendVerification(user)
+	}
+	return resp, nil
+}
+
+// Unlink 解绑登录方式，解绑邮箱只移除密码、保留邮箱用于通知
+// 解绑后没有其它登录方式时拒绝
+func (s *AuthService) Unlink(userID int64, provider string) error {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+
+	switch provider {
+	case ProviderGithub:
+		if user.GithubID == nil {
+			return ErrIdentityNotLinked
+		}
+		user.GithubID = nil
+	case ProviderWechat:
+		if user.WechatOpenID == nil {
+			return ErrIdentityNotLinked
+		}
+		user.WechatOpenID = nil
+	case ProviderOIDC:
+		if user.OIDCSubject == nil {
+			return ErrIdentityNotLinked
+		}
+		user.OIDCSubject = nil
+	case ProviderEmail:
+		if !hasPasswordLogin(user) {
+			return ErrIdentityNotLinked
+		}
+		user.PasswordHash = nil
+	default:
+		return ErrUnsupportedProvider
+	}
+
+	if countLoginMethods(user) == 0 {
+		return ErrLastLoginMethod
+	}
+	return s.userRepo.Update(user)
+}
+
+// findUserByOAuthEmail 第三方账号未绑定用户时按邮箱查找已有用户
+// 只有第三方和本站的邮箱都已验证才绑定到已有用户，否则返回 ErrOAuthEmailConflict，
+// 避免通过未验证的邮箱接管他人账号，也不会因为邮箱重复创建第二个账号
+func (s *AuthService) findUserByOAuthEmail(email string, verified bool) (*model.User, error) {
+	if email == "" {
+		return nil, nil
+	}
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !verified || !user.EmailVerified {
+		return nil, ErrOAuthEmailConflict
+	}
+	return user, nil
+}
+
+func (s *AuthService) getUser(userID int64) (*model.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// identityOwner 第三方账号已绑定的用户 ID，未绑定时返回 0
+func identityOwner(owner *model.User, err error) (int64, error) {
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return owner.ID, nil
+}
+
+// hasPasswordLogin 是否可以用邮箱密码登录，邮箱未验证时可以重新发送验证邮件
+func hasPasswordLogin(user *model.User) bool {
+	return user.PasswordHash != nil && user.Email != nil
+}
+
+// countLoginMethods 用户可用的登录方式数量
+func countLoginMethods(user *model.User) int {
+	count := 0
+	if user.GithubID != nil {
+		count++
+	}
+	if user.WechatOpenID != nil {
+		count++
+	}
+	if user.OIDCSubject != nil {
+		count++
+	}
+	if hasPasswordLogin(user) {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func identityStates(t *testing.T, service *AuthService, userID int64) map[string]*dto.IdentityItem {
+	t.Helper()
+	items, err := service.Identities(userID)
+	require.NoError(t, err)
+	states := make(map[string]*dto.IdentityItem, len(items))
+	for _, item := range items {
+		states[item.Provider] = item
+	}
+	return states
+}
+
+func TestAuthService_LinkAndUnlink(t *testing.T) {
+	service, db, transport := setupAuthServiceWithMailer(t)
+	service.wechatOAuth = newWechatStub(t, "微信用户")
+	ctx := context.Background()
+
+	// 只有微信登录的用户不能解绑微信
+	resp, err := service.WechatCallback(ctx, "code-openid-0001")
+	require.NoError(t, err)
+	userID := resp.User.ID
+	states := identityStates(t, service, userID)
+	assert.True(t, states[ProviderWechat].Linked)
+	assert.False(t, states[ProviderWechat].Removable)
+	assert.False(t, states[ProviderEmail].Linked)
+	assert.ErrorIs(t, service.Unlink(userID, ProviderWechat), ErrLastLoginMethod)
+	assert.ErrorIs(t, service.Unlink(userID, ProviderGithub), ErrIdentityNotLinked)
+
+	// 已被其他账号使用的邮箱不能绑定
+	testutil.TestUser(t, db, testutil.WithEmail("taken@example.com"))
+	_, err = service.LinkEmail(userID, &dto.LinkEmailRequest{Email: "taken@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrEmailExists)
+
+	// 绑定新邮箱需要验证
+	linked, err := service.LinkEmail(userID, &dto.LinkEmailRequest{Email: "wx@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.True(t, linked.VerificationRequired)
+	require.Len(t, transport.Messages(), 1)
+	assert.Equal(t, "wx@example.com", transport.Messages()[0].To)
+	_, err = service.LinkEmail(userID, &dto.LinkEmailRequest{Email: "wx@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrIdentityLinked)
+
+	user, err := repository.NewUserRepository(db).GetByID(userID)
+	require.NoError(t, err)
+	assert.False(t, user.EmailVerified)
+	_, err = service.Login(&dto.LoginRequest{Email: "wx@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	_, err = service.VerifyEmail(*user.VerificationCode)
+	require.NoError(t, err)
+	_, err = service.Login(&dto.LoginRequest{Email: "wx@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	// 有两种登录方式后可以解绑其中一种，但不能全部解绑
+	states = identityStates(t, service, userID)
+	assert.Equal(t, "wx@example.com", states[ProviderEmail].Detail)
+	assert.True(t, states[ProviderWechat].Removable)
+	require.NoError(t, service.Unlink(userID, ProviderWechat))
+	assert.ErrorIs(t, service.Unlink(userID, ProviderEmail), ErrLastLoginMethod)
+
+	// 重新绑定微信，同一个微信账号不能绑定到其他用户
+	require.NoError(t, service.LinkOAuth(ctx, userID, ProviderWechat, "code-openid-0001"))
+	require.NoError(t, service.LinkOAuth(ctx, userID, ProviderWechat, "code-openid-0001"))
+	assert.ErrorIs(t, service.LinkOAuth(ctx, userID, ProviderWechat, "code-openid-0002"), ErrIdentityLinked)
+	other := testutil.TestUser(t, db)
+	assert.ErrorIs(t, service.LinkOAuth(ctx, other.ID, ProviderWechat, "code-openid-0001"), ErrIdentityInUse)
+
+	// 解绑邮箱只移除密码
+	require.NoError(t, service.Unlink(userID, ProviderEmail))
+	_, err = service.Login(&dto.LoginRequest{Email: "wx@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	states = identityStates(t, service, userID)
+	assert.False(t, states[ProviderEmail].Linked)
+	assert.Equal(t, "wx@example.com", states[ProviderEmail].Detail)
+
+	assert.ErrorIs(t, service.Unlink(userID, "qq"), ErrUnsupportedProvider)
+	_, err = service.LinkAuthURL(userID, ProviderWechat, "state")
+	assert.ErrorIs(t, err, ErrIdentityLinked)
+}
+
+func TestAuthService_LinkEmail_ExistingEmail(t *testing.T) {
+	service, db, transport := setupAuthServiceWithMailer(t)
+	githubID := "12345"
+	user := testutil.TestUser(t, db, testutil.WithEmail("gh@example.com"), func(u *model.User) {
+		u.GithubID = &githubID
+		u.PasswordHash = nil
+	})
+
+	// 账号已有邮箱时只能为该邮箱设置密码，不需要重新验证
+	_, err := service.LinkEmail(user.ID, &dto.LinkEmailRequest{Email: "other@example.com", Password: "password123"})
+	assert.ErrorIs(t, err, ErrEmailMismatch)
+	linked, err := service.LinkEmail(user.ID, &dto.LinkEmailRequest{Email: "GH@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.False(t, linked.VerificationRequired)
+	assert.Empty(t, transport.Messages())
+
+	_, err = service.Login(&dto.LoginRequest{Email: "gh@example.com", Password: "password123"})
+	require.NoError(t, err)
+}
+
+func TestAuthService_FindUserByOAuthEmail(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	verified := testutil.TestUser(t, db, testutil.WithEmail("verified@example.com"))
+	testutil.TestUser(t, db, testutil.WithEmail("pending@example.com"), func(u *model.User) { u.EmailVerified = false })
+
+	// 双方邮箱都已验证时绑定到已有用户
+	user, err := service.findUserByOAuthEmail("verified@example.com", true)
+	require.NoError(t, err)
+	assert.Equal(t, verified.ID, user.ID)
+
+	// 任意一方未验证都不绑定，也不创建重复账号
+	_, err = service.findUserByOAuthEmail("verified@example.com", false)
+	assert.ErrorIs(t, err, ErrOAuthEmailConflict)
+	_, err = service.findUserByOAuthEmail("pending@example.com", true)
+	assert.ErrorIs(t, err, ErrOAuthEmailConflict)
+
+	user, err = service.findUserByOAuthEmail("new@example.com", true)
+	require.NoError(t, err)
+	assert.Nil(t, user)
+	user, err = service.findUserByOAuthEmail("", false)
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
+)
+
+var (
+	ErrOIDCDisabled         = errors.New("未启用单点登录")
+	ErrOIDCNotProvisioned   = errors.New("该账号尚未开通，请联系管理员")
+	ErrOIDCDomainNotAllowed = errors.New("该邮箱域名不允许自动注册")
+)
+
+// OIDCEnabled 是否配置了 OIDC 登录
+func (s *AuthService) OIDCEnabled() bool {
+	return s.oidc != nil
+}
+
+// OIDCAuthURL 获取 OIDC 授权 URL，nonce 和 verifier 需要随 state 保存到回调时使用
+func (s *AuthService) OIDCAuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if s.oidc == nil {
+		return "", ErrOIDCDisabled
+	}
+	return s.oidc.AuthURL(ctx, state, nonce, verifier)
+}
+
+// OIDCCallback 处理 OIDC 登录回调
+// 先按 sub 查找用户，其次按双方都已验证的邮箱绑定已有用户，都没有时按配置自动开通
+func (s *AuthService) OIDCCallback(ctx context.Context, code, verifier, nonce string) (*dto.LoginResponse, error) {
+	if s.oidc == nil {
+		return nil, ErrOIDCDisabled
+	}
+
+	claims, err := s.oidc.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByOIDCSubject(claims.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if user == nil {
+		user, err = s.findUserByOAuthEmail(claims.Email, claims.EmailVerified)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			if user.OIDCSubject != nil {
+				return nil, ErrOAuthEmailConflict
+			}
+			user.OIDCSubject = &claims.Subject
+			if err := s.userRepo.Update(user); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if user == nil {
+		user, err = s.provisionOIDCUser(claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.createSession(user)
+}
+
+// provisionOIDCUser 自动开通 OIDC 用户
+// 配置了 allowed_domains 时只开通邮箱已验证且域名在列表中的用户
+func (s *AuthService) provisionOIDCUser(claims *oauth.OIDCClaims) (*model.User, error) {
+	cfg := s.cfg.OAuth.OIDC
+	if !cfg.AutoProvision {
+		return nil, ErrOIDCNotProvisioned
+	}
+	if len(cfg.AllowedDomains) > 0 && (!claims.EmailVerified || !emailDomainAllowed(claims.Email, cfg.AllowedDomains)) {
+		return nil, ErrOIDCDomainNotAllowed
+	}
+
+	// 用户名依次取 preferred_username、name、邮箱前缀，sub 可能很长，后缀取其哈希
+	suffix := hashToken(claims.Subject)[:8]
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Name
+	}
+	if username == "" {
+		username = claims.Email
+	}
+	if i := strings.Index(username, "@"); i >= 0 {
+		username = username[:i]
+	}
+	username = truncateRunes(username, 20)
+	if username == "" {
+		username = "oidc_" + suffix
+	}
+
+	user := &model.User{
+		Username:    username,
+		OIDCSubject: &claims.Subject,
+		AvatarURL:   claims.Picture,
+	}
+	if claims.Email != "" && claims.EmailVerified {
+		user.Email = &claims.Email
+	}
+	if err := s.createOAuthUser(user, username+"_"+suffix); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkOIDCAuthURL 绑定 OIDC 账号的授权 URL
+func (s *AuthService) LinkOIDCAuthURL(ctx context.Context, userID int64, state, nonce, verifier string) (string, error) {
+	if s.oidc == nil {
+		return "", ErrOIDCDisabled
+	}
+	user, err := s.getUser(userID)
+	if err != nil {
+		return "", err
+	}
+	if user.OIDCSubject != nil {
+		return "", ErrIdentityLinked
+	}
+	return s.oidc.AuthURL(ctx, state, nonce, verifier)
+}
+
+// LinkOIDC 授权回调时把 OIDC 账号绑定到 userID，OIDC 账号已属于其他用户时不合并
+func (s *AuthService) LinkOIDC(ctx context.Context, userID int64, code, verifier, nonce string) error {
+	if s.oidc == nil {
+		return ErrOIDCDisabled
+	}
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+
+	claims, err := s.oidc.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return err
+	}
+	ownerID, err := identityOwner(s.userRepo.GetByOIDCSubject(claims.Subject))
+	if err != nil {
+		return err
+	}
+	if ownerID != 0 && ownerID != userID {
+		return ErrIdentityInUse
+	}
+	if user.OIDCSubject != nil {
+		if *user.OIDCSubject == claims.Subject {
+			return nil
+		}
+		return ErrIdentityLinked
+	}
+	user.OIDCSubject = &claims.Subject
+	return s.userRepo.Update(user)
+}
+
+// emailDomainAllowed 邮箱域名是否在列表中，不区分大小写，不包含子域名
+func emailDomainAllowed(email string, domains []string) bool {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := email[i+1:]
+	for _, d := range domains {
+		if strings.EqualFold(domain, strings.TrimPrefix(strings.TrimSpace(d), "@")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+// useFakeOIDC 让 service 使用本地模拟的 IdP
+func useFakeOIDC(t *testing.T, service *AuthService, autoProvision bool, domains ...string) *testutil.FakeOIDC {
+	idp := testutil.NewFakeOIDC(t, "oidc-client")
+	service.cfg.OAuth.OIDC = config.OIDCConfig{
+		Name:           "Corp SSO",
+		Issuer:         idp.Issuer(),
+		ClientID:       "oidc-client",
+		ClientSecret:   "oidc-secret",
+		RedirectURI:    "http://localhost:8080/api/v1/auth/oidc/callback",
+		AutoProvision:  autoProvision,
+		AllowedDomains: domains,
+	}
+	service.oidc = oauth.NewOIDC(&service.cfg.OAuth.OIDC)
+	return idp
+}
+
+// oidcLogin 走完一次授权码流程，claims 为 IdP 签发的用户声明
+func oidcLogin(t *testing.T, service *AuthService, idp *testutil.FakeOIDC, claims jwt.MapClaims) (*dto.LoginResponse, error) {
+	t.Helper()
+	ctx := context.Background()
+	nonce, verifier := oauth.GenerateNonce(), oauth.GenerateVerifier()
+	authURL, err := service.OIDCAuthURL(ctx, "state", nonce, verifier)
+	require.NoError(t, err)
+	code, _ := idp.Authorize(t, authURL, claims)
+	return service.OIDCCallback(ctx, code, verifier, nonce)
+}
+
+func TestAuthService_OIDCDisabled(t *testing.T) {
+	service, cleanup := setupAuthService(t)
+	defer cleanup()
+
+	assert.False(t, service.OIDCEnabled())
+	_, err := service.OIDCAuthURL(context.Background(), "state", "nonce", "verifier")
+	assert.ErrorIs(t, err, ErrOIDCDisabled)
+	_, err = service.OIDCCallback(context.Background(), "code", "verifier", "nonce")
+	assert.ErrorIs(t, err, ErrOIDCDisabled)
+}
+
+func TestAuthService_OIDCCallback_AutoProvision(t *testing.T) {
+	service, cleanup := setupAuthService(t)
+	defer cleanup()
+	idp := useFakeOIDC(t, service, true, "corp.example.com")
+
+	// 域名不在列表中或邮箱未验证时不开通
+	_, err := oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-1", "email": "alice@other.example.com", "email_verified": true})
+	assert.ErrorIs(t, err, ErrOIDCDomainNotAllowed)
+	_, err = oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-1", "email": "alice@corp.example.com"})
+	assert.ErrorIs(t, err, ErrOIDCDomainNotAllowed)
+
+	resp, err := oidcLogin(t, service, idp, jwt.MapClaims{
+		"sub": "sub-1", "email": "alice@Corp.Example.com", "email_verified": true,
+		"preferred_username": "alice", "picture": "https://idp.example.com/alice.png",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.Equal(t, "alice", resp.User.Username)
+	assert.Equal(t, "https://idp.example.com/alice.png", resp.User.AvatarURL)
+
+	user, err := service.userRepo.GetByOIDCSubject("sub-1")
+	require.NoError(t, err)
+	assert.Equal(t, resp.User.ID, user.ID)
+	require.NotNil(t, user.Email)
+	assert.Equal(t, "alice@Corp.Example.com", *user.Email)
+	assert.Equal(t, 5, user.DailyQuota)
+
+	// 再次登录按 sub 找到同一个用户，即使邮箱已变化
+	again, err := oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-1", "email": "alice2@corp.example.com", "email_verified": true})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.User.ID)
+
+	// 用户名已被占用时加上 sub 哈希后缀
+	other, err := oidcLogin(t, service, idp, jwt.MapClaims{
+		"sub": "sub-2", "email": "alice.b@corp.example.com", "email_verified": true, "preferred_username": "alice",
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, user.ID, other.User.ID)
+	assert.Equal(t, "alice_"+hashToken("sub-2")[:8], other.User.Username)
+}
+
+func TestAuthService_OIDCCallback_NotProvisioned(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	idp := useFakeOIDC(t, service, false)
+
+	_, err := oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-1", "email": "new@example.com", "email_verified": true})
+	assert.ErrorIs(t, err, ErrOIDCNotProvisioned)
+
+	// 未开通自动注册时，邮箱双方都已验证的已有用户仍可登录并绑定
+	existing := testutil.TestUser(t, db, testutil.WithEmail("bob@example.com"))
+	resp, err := oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-2", "email": "bob@example.com", "email_verified": true})
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, resp.User.ID)
+	user, err := service.userRepo.GetByOIDCSubject("sub-2")
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, user.ID)
+
+	// IdP 未验证邮箱时不合并
+	testutil.TestUser(t, db, testutil.WithEmail("carol@example.com"))
+	_, err = oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-3", "email": "carol@example.com", "email_verified": false})
+	assert.ErrorIs(t, err, ErrOAuthEmailConflict)
+
+	// 已绑定其他 OIDC 账号的用户不合并
+	_, err = oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-4", "email": "bob@example.com", "email_verified": true})
+	assert.ErrorIs(t, err, ErrOAuthEmailConflict)
+}
+
+func TestAuthService_OIDCCallback_InvalidNonce(t *testing.T) {
+	service, cleanup := setupAuthService(t)
+	defer cleanup()
+	idp := useFakeOIDC(t, service, true)
+	ctx := context.Background()
+
+	verifier := oauth.GenerateVerifier()
+	authURL, err := service.OIDCAuthURL(ctx, "state", "nonce-1", verifier)
+	require.NoError(t, err)
+	code, _ := idp.Authorize(t, authURL, jwt.MapClaims{"sub": "sub-1"})
+
+	_, err = service.OIDCCallback(ctx, code, verifier, "nonce-2")
+	assert.ErrorIs(t, err, oauth.ErrInvalidIDToken)
+	_, err = service.userRepo.GetByOIDCSubject("sub-1")
+	assert.Error(t, err)
+}
+
+func TestAuthService_LinkOIDC(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	idp := useFakeOIDC(t, service, true)
+	ctx := context.Background()
+	user := testutil.TestUser(t, db)
+
+	link := func(userID int64, sub string) error {
+		nonce, verifier := oauth.GenerateNonce(), oauth.GenerateVerifier()
+		authURL, err := service.LinkOIDCAuthURL(ctx, userID, "state", nonce, verifier)
+		if err != nil {
+			return err
+		}
+		code, _ := idp.Authorize(t, authURL, jwt.MapClaims{"sub": sub})
+		return service.LinkOIDC(ctx, userID, code, verifier, nonce)
+	}
+
+	states := identityStates(t, service, user.ID)
+	assert.False(t, states[ProviderOIDC].Linked)
+	assert.Equal(t, "Corp SSO", states[ProviderOIDC].Detail)
+
+	require.NoError(t, link(user.ID, "sub-1"))
+	states = identityStates(t, service, user.ID)
+	assert.True(t, states[ProviderOIDC].Linked)
+	assert.True(t, states[ProviderOIDC].Removable)
+	assert.ErrorIs(t, link(user.ID, "sub-2"), ErrIdentityLinked)
+
+	// 已属于其他用户的 OIDC 账号不能绑定
+	other := testutil.TestUser(t, db)
+	assert.ErrorIs(t, link(other.ID, "sub-1"), ErrIdentityInUse)
+
+	// 绑定后可以用 OIDC 登录
+	resp, err := oidcLogin(t, service, idp, jwt.MapClaims{"sub": "sub-1"})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, resp.User.ID)
+
+	require.NoError(t, service.Unlink(user.ID, ProviderOIDC))
+	assert.ErrorIs(t, service.Unlink(user.ID, ProviderOIDC), ErrIdentityNotLinked)
+}
@@ -3,9 +3,13 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -14,35 +18,98 @@ import (
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/email"
 	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
 	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
+	"github.com/qs3c/anal_go_server/internal/pkg/ratelimit"
+	"github.com/qs3c/anal_go_server/internal/pkg/revocation"
 	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
 var (
-	ErrEmailExists        = errors.New("邮箱已被注册")
-	ErrUsernameExists     = errors.New("用户名已被使用")
-	ErrInvalidCredentials = errors.New("邮箱或密码错误")
-	ErrEmailNotVerified   = errors.New("邮箱尚未验证")
-	ErrInvalidVerifyCode  = errors.New("验证码无效或已过期")
-	ErrUserNotFound       = errors.New("用户不存在")
+	ErrEmailExists         = errors.New("邮箱已被注册")
+	ErrUsernameExists      = errors.New("用户名已被使用")
+	ErrInvalidCredentials  = errors.New("邮箱或密码错误")
+	ErrEmailNotVerified    = errors.New("邮箱尚未验证")
+	ErrInvalidVerifyCode   = errors.New("验证码无效或已过期")
+	ErrUserNotFound        = errors.New("用户不存在")
+	ErrTooManyRequests     = errors.New("请求过于频繁，请稍后再试")
+	ErrInvalidResetToken   = errors.New("重置链接无效或已过期")
+	ErrWrongPassword       = errors.New("原密码错误")
+	ErrPasswordNotSet      = errors.New("账号尚未设置密码，请通过找回密码设置")
+	ErrTokenRevoked        = errors.New("登录状态已失效，请重新登录")
+	ErrInvalidRefreshToken = errors.New("登录已过期，请重新登录")
 )
 
+// 发送验证邮件和重置邮件的限制：同一邮箱每分钟 1 次、每小时 5 次，同一 IP 每小时 20 次
+const (
+	mailPerMinute   = 1
+	mailPerHour     = 5
+	mailPerIPInHour = 20
+)
+
+// verificationTTL 邮箱验证码的有效期
+const verificationTTL = 24 * time.Hour
+
+// passwordResetTTL 密码重置令牌的有效期
+const passwordResetTTL = 30 * time.Minute
+
 type AuthService struct {
 	userRepo    *repository.UserRepository
+	resetRepo   *repository.PasswordResetRepository
+	refreshRepo *repository.RefreshTokenRepository
+	tokenRepo   *repository.AccessTokenRepository
+	mailer      *email.Service
+	limiter     *ratelimit.Limiter
+	revoked     *revocation.List
+	jwtKeys     *jwt.KeySet
 	cfg         *config.Config
 	githubOAuth *oauth.GithubOAuth
+	wechatOAuth *oauth.WechatOAuth
+	oidc        *oauth.OIDC
 }
 
-func NewAuthService(userRepo *repository.UserRepository, cfg *config.Config) *AuthService {
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	resetRepo *repository.PasswordResetRepository,
+	refreshRepo *repository.RefreshTokenRepository,
+	tokenRepo *repository.AccessTokenRepository,
+	mailer *email.Service,
+	limiter *ratelimit.Limiter,
+	revoked *revocation.List,
+	jwtKeys *jwt.KeySet,
+	cfg *config.Config,
+) *AuthService {
+	// 未提供密钥集时使用 jwt.secret 签名
+	if jwtKeys == nil {
+		jwtKeys = jwt.NewSecretKeySet(cfg.JWT.Secret)
+	}
+	// 配置了 issuer 才启用 OIDC 登录
+	var oidc *oauth.OIDC
+	if cfg.OAuth.OIDC.Issuer != "" {
+		oidc = oauth.NewOIDC(&cfg.OAuth.OIDC)
+	}
 	return &AuthService{
-		userRepo: userRepo,
-		cfg:      cfg,
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		refreshRepo: refreshRepo,
+		tokenRepo:   tokenRepo,
+		mailer:      mailer,
+		limiter:     limiter,
+		revoked:     revoked,
+		jwtKeys:     jwtKeys,
+		cfg:         cfg,
 		githubOAuth: oauth.NewGithubOAuth(
 			cfg.OAuth.Github.ClientID,
 			cfg.OAuth.Github.ClientSecret,
 			cfg.OAuth.Github.RedirectURI,
 		),
+		wechatOAuth: oauth.NewWechatOAuth(
+			cfg.OAuth.Wechat.AppID,
+			cfg.OAuth.Wechat.AppSecret,
+			cfg.OAuth.Wechat.RedirectURI,
+		),
+		oidc: oidc,
 	}
 }
 
@@ -79,7 +146,7 @@ func (s *AuthService) Register(req *dto.RegisterRequest) (*dto.RegisterResponse,
 	}
 
 	passwordStr := string(hashedPassword)
-	expiresAt := time.Now().Add(24 * time.Hour)
+	expiresAt := time.Now().Add(verificationTTL)
 	resetAt := time.Now().Add(24 * time.Hour).Truncate(24 * time.Hour)
 
 	user := &model.User{
@@ -97,14 +164,8 @@ func (s *AuthService) Register(req *dto.RegisterRequest) (*dto.RegisterResponse,
 		return nil, err
 	}
 
-	// TODO: 发送验证邮件
-	// 开发环境临时方案：自动验证邮箱
-	if s.cfg.Server.Mode == "debug" {
-		user.EmailVerified = true
-		if err := s.userRepo.Update(user); err != nil {
-			return nil, err
-		}
-	}
+	// 邮件通过发件箱异步发送，发送失败不影响注册，用户可以重新发送
+	s.sendVerification(user)
 
 	return &dto.RegisterResponse{
 		UserID: user.ID,
@@ -134,16 +195,7 @@ func (s *AuthService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
 		return nil, ErrInvalidCredentials
 	}
 
-	// 生成 Token
-	token, err := jwt.GenerateToken(user.ID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
-	if err != nil {
-		return nil, err
-	}
-
-	return &dto.LoginResponse{
-		Token: token,
-		User:  s.buildUserInfo(user),
-	}, nil
+	return s.createSession(user)
 }
 
 // VerifyEmail 验证邮箱
@@ -169,18 +221,373 @@ func (s *AuthService) VerifyEmail(code string) (*dto.LoginResponse, error) {
 		return nil, err
 	}
 
-	// 生成 Token
-	token, err := jwt.GenerateToken(user.ID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
+	return s.createSession(user)
+}
+
+// ResendVerification 重新生成验证码并发送验证邮件
+// 邮箱未注册或已验证时同样返回成功，避免泄露邮箱是否注册
+func (s *AuthService) ResendVerification(ctx context.Context, emailAddr, clientIP string) error {
+	emailAddr = strings.TrimSpace(emailAddr)
+	if err := s.checkMailLimit(ctx, "resend_verification", strings.ToLower(emailAddr), clientIP); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(emailAddr)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	code, err := generateRandomCode(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(verificationTTL)
+	user.VerificationCode = &code
+	user.VerificationExpiresAt = &expiresAt
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	s.sendVerification(user)
+	return nil
+}
+
+// checkMailLimit 按邮箱和 IP 限制 action 类邮件的发送频率，未配置限流器时不限制
+func (s *AuthService) checkMailLimit(ctx context.Context, action, emailAddr, clientIP string) error {
+	if s.limiter == nil {
+		return nil
+	}
+	rules := []struct {
+		key    string
+		limit  int
+		window time.Duration
+	}{
+		{action + ":minute:" + emailAddr, mailPerMinute, time.Minute},
+		{action + ":hour:" + emailAddr, mailPerHour, time.Hour},
+		{action + ":ip:" + clientIP, mailPerIPInHour, time.Hour},
+	}
+	for _, rule := range rules {
+		allowed, _, err := s.limiter.Allow(ctx, rule.key, rule.limit, rule.window)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrTooManyRequests
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) sendVerification(user *model.User) {
+	if s.mailer == nil || user.Email == nil || user.VerificationCode == nil {
+		return
+	}
+	if err := s.mailer.SendVerificationCode(*user.Email, *user.VerificationCode); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+}
+
+// ForgotPassword 生成密码重置令牌并发送重置邮件
+// 邮箱未注册时同样返回成功，避免泄露邮箱是否注册
+func (s *AuthService) ForgotPassword(ctx context.Context, emailAddr, clientIP string) error {
+	emailAddr = strings.TrimSpace(emailAddr)
+	if err := s.checkMailLimit(ctx, "forgot_password", strings.ToLower(emailAddr), clientIP); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(emailAddr)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// 邮件中只有明文令牌，数据库只保存哈希
+	token, err := generateRandomCode(64)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.resetRepo.Create(&model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(passwordResetTTL),
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+
+	if s.mailer != nil {
+		link := s.mailer.Link("/reset-password", url.Values{"token": {token}})
+		if err := s.mailer.SendPasswordReset(*user.Email, link); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，令牌只能使用一次，成功后已签发的 Token 全部失效
+func (s *AuthService) ResetPassword(token, newPassword string) error {
+	resetToken, err := s.resetRepo.GetByTokenHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	now := time.Now()
+	if resetToken.UsedAt != nil || now.After(resetToken.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	// 并发使用同一令牌时只有一个请求能标记成功
+	marked, err := s.resetRepo.MarkUsed(resetToken.ID, now)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrInvalidResetToken
+	}
+
+	return s.setPassword(resetToken.UserID, newPassword)
+}
+
+// ChangePassword 校验原密码后修改密码，所有设备上的登录全部失效，返回当前设备使用的新会话
+func (s *AuthService) ChangePassword(userID int64, req *dto.ChangePasswordRequest) (*dto.LoginResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.PasswordHash == nil {
+		return nil, ErrPasswordNotSet
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(*user.PasswordHash), []byte(req.OldPassword)); err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	if err := s.setPassword(userID, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	user, err = s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.createSession(user)
+}
+
+// setPassword 保存新密码、使 Token 版本加一，撤销所有刷新令牌和个人访问令牌并使未使用的重置令牌失效
+// 个人访问令牌不随 Token 版本失效，需要一并删除，否则泄露的令牌在重置密码后仍然可用
+func (s *AuthService) setPassword(userID int64, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(userID, string(hashedPassword)); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.refreshRepo.RevokeByUserID(userID, now); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+	return s.resetRepo.InvalidateByUserID(userID, now)
+}
+
+// Refresh 用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌随即失效
+// 已失效的刷新令牌被再次使用说明令牌可能已泄露，撤销整个会话
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*dto.LoginResponse, error) {
+	token, err := s.refreshRepo.GetByTokenHash(hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil {
+		if err := s.revokeSession(ctx, token.SessionID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if now.After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 并发使用同一令牌时只有一个请求能轮换成功
+	revoked, err := s.refreshRepo.Revoke(token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		if err := s.revokeSession(ctx, token.SessionID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return s.issueTokens(user, token.SessionID, now)
+}
+
+// Logout 退出刷新令牌所属的登录会话，该会话签发的访问令牌同时失效
+// 令牌无效时同样返回成功
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	token, err := s.refreshRepo.GetByTokenHash(hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.revokeSession(ctx, token.SessionID, time.Now())
+}
+
+// LogoutAll 退出用户在所有设备上的登录，个人访问令牌同时被吊销
+func (s *AuthService) LogoutAll(ctx context.Context, userID int64) error {
+	if err := s.userRepo.IncrementTokenVersion(userID); err != nil {
+		return err
+	}
+	if err := s.refreshRepo.RevokeByUserID(userID, time.Now()); err != nil {
+		return err
+	}
+	return s.tokenRepo.DeleteByUserID(userID)
+}
+
+// CheckToken 实现 middleware.TokenChecker
+// Token 版本与用户当前版本不一致或所属会话已退出时视为已失效
+func (s *AuthService) CheckToken(ctx context.Context, claims *jwt.Claims) error {
+	version, err := s.userRepo.GetTokenVersion(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenRevoked
+		}
+		return err
+	}
+	if version != claims.Version {
+		return ErrTokenRevoked
+	}
+
+	if claims.SessionID != "" && s.revoked != nil {
+		revoked, err := s.revoked.IsRevoked(ctx, sessionRevocationID(claims.SessionID))
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+	return nil
+}
+
+// JWKS 验证访问令牌的公钥
+func (s *AuthService) JWKS() *jwt.JWKS {
+	return s.jwtKeys.JWKS()
+}
+
+// createSession 开始新的登录会话，同时清理用户已过期的刷新令牌
+func (s *AuthService) createSession(user *model.User) (*dto.LoginResponse, error) {
+	sessionID, err := generateRandomCode(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.refreshRepo.DeleteExpiredByUserID(user.ID, now); err != nil {
+		log.Printf("Failed to delete expired refresh tokens of user %d: %v", user.ID, err)
+	}
+	return s.issueTokens(user, sessionID, now)
+}
+
+// issueTokens 在会话中签发访问令牌和刷新令牌
+func (s *AuthService) issueTokens(user *model.User, sessionID string, now time.Time) (*dto.LoginResponse, error) {
+	refreshToken, err := generateRandomCode(64)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshRepo.Create(&model.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(s.sessionTTL()),
+		CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.jwtKeys.Sign(&jwt.Claims{
+		UserID:    user.ID,
+		Version:   user.TokenVersion,
+		SessionID: sessionID,
+	}, s.accessTTL())
 	if err != nil {
 		return nil, err
 	}
 
 	return &dto.LoginResponse{
-		Token: token,
-		User:  s.buildUserInfo(user),
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL().Seconds()),
+		User:         s.buildUserInfo(user),
 	}, nil
 }
 
+// revokeSession 撤销会话的刷新令牌，并在访问令牌过期前把会话加入撤销列表
+func (s *AuthService) revokeSession(ctx context.Context, sessionID string, now time.Time) error {
+	if err := s.refreshRepo.RevokeSession(sessionID, now); err != nil {
+		return err
+	}
+	if s.revoked == nil {
+		return nil
+	}
+	return s.revoked.Revoke(ctx, sessionRevocationID(sessionID), s.accessTTL())
+}
+
+// accessTTL 访问令牌有效期，默认 15 分钟
+func (s *AuthService) accessTTL() time.Duration {
+	if s.cfg.JWT.AccessExpireMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(s.cfg.JWT.AccessExpireMinutes) * time.Minute
+}
+
+// sessionTTL 刷新令牌有效期，每次刷新后重新计算，默认 7 天
+func (s *AuthService) sessionTTL() time.Duration {
+	if s.cfg.JWT.ExpireHours <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(s.cfg.JWT.ExpireHours) * time.Hour
+}
+
+func sessionRevocationID(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// hashToken 令牌的 SHA-256，令牌本身是足够长的随机数，不需要加盐
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // GetUserByID 根据 ID 获取用户
 func (s *AuthService) GetUserByID(id int64) (*model.User, error) {
 	return s.userRepo.GetByID(id)
@@ -211,6 +618,21 @@ func generateRandomCode(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// AllowedRedirect 第三方登录后跳转的前端地址是否属于 oauth.allowed_redirect_origins，只比较 scheme、主机和端口
+func (s *AuthService) AllowedRedirect(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range s.cfg.OAuth.AllowedRedirectOrigins {
+		if origin == strings.ToLower(strings.TrimRight(allowed, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetGithubAuthURL 获取 GitHub 授权 URL
 func (s *AuthService) GetGithubAuthURL(state string) string {
 	return s.githubOAuth.GetAuthURL(state)
@@ -238,43 +660,114 @@ func (s *AuthService) GithubCallback(ctx context.Context, code string) (*dto.Log
 		return nil, err
 	}
 
+	// 邮箱已属于其他用户时，双方邮箱都已验证才绑定到该用户
+	if user == nil {
+		user, err = s.findUserByOAuthEmail(githubUser.Email, githubUser.EmailVerified)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			if user.GithubID != nil {
+				return nil, ErrOAuthEmailConflict
+			}
+			user.GithubID = &githubIDStr
+			if err := s.userRepo.Update(user); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	if user == nil {
 		// 创建新用户
-		resetAt := time.Now().Add(24 * time.Hour).Truncate(24 * time.Hour)
 		user = &model.User{
-			Username:          githubUser.Login,
-			GithubID:          &githubIDStr,
-			AvatarURL:         githubUser.AvatarURL,
-			SubscriptionLevel: "free",
-			DailyQuota:        s.cfg.Subscription.Levels["free"].DailyQuota,
-			QuotaResetAt:      &resetAt,
-			EmailVerified:     true, // OAuth 用户默认已验证
+			Username:  githubUser.Login,
+			GithubID:  &githubIDStr,
+			AvatarURL: githubUser.AvatarURL,
 		}
 
-		// 如果有邮箱，设置邮箱
-		if githubUser.Email != "" {
+		// 只保存 GitHub 已验证的邮箱，避免占用他人的邮箱
+		if githubUser.Email != "" && githubUser.EmailVerified {
 			user.Email = &githubUser.Email
 		}
 
-		// 确保用户名唯一
-		exists, _ := s.userRepo.ExistsByUsername(user.Username)
-		if exists {
-			user.Username = fmt.Sprintf("%s_%d", githubUser.Login, githubUser.ID)
+		if err := s.createOAuthUser(user, fmt.Sprintf("%s_%d", githubUser.Login, githubUser.ID)); err != nil {
+			return nil, err
 		}
+	}
 
-		if err := s.userRepo.Create(user); err != nil {
-			return nil, fmt.Errorf("failed to create user: %w", err)
-		}
+	return s.createSession(user)
+}
+
+// GetWechatAuthURL 获取微信扫码登录 URL
+func (s *AuthService) GetWechatAuthURL(state string) string {
+	return s.wechatOAuth.GetAuthURL(state)
+}
+
+// WechatCallback 处理微信扫码登录回调，按 openid 查找用户，不存在时创建
+func (s *AuthService) WechatCallback(ctx context.Context, code string) (*dto.LoginResponse, error) {
+	token, err := s.wechatOAuth.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
 	}
 
-	// 生成 JWT Token
-	jwtToken, err := jwt.GenerateToken(user.ID, s.cfg.JWT.Secret, s.cfg.JWT.ExpireHours)
+	wechatUser, err := s.wechatOAuth.GetUser(ctx, token)
 	if err != nil {
+		return nil, fmt.Errorf("failed to get wechat user: %w", err)
+	}
+
+	user, err := s.userRepo.GetByWechatOpenID(wechatUser.OpenID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 
-	return &dto.LoginResponse{
-		Token: jwtToken,
-		User:  s.buildUserInfo(user),
-	}, nil
+	if user == nil {
+		// 微信昵称可能为空或过长，用户名取昵称的前 20 个字符
+		suffix := wechatUser.OpenID
+		if len(suffix) > 8 {
+			suffix = suffix[len(suffix)-8:]
+		}
+		username := truncateRunes(wechatUser.Nickname, 20)
+		if username == "" {
+			username = "wechat_" + suffix
+		}
+
+		user = &model.User{
+			Username:     username,
+			WechatOpenID: &wechatUser.OpenID,
+			AvatarURL:    wechatUser.HeadImgURL,
+		}
+		if err := s.createOAuthUser(user, username+"_"+suffix); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.createSession(user)
+}
+
+// createOAuthUser 创建第三方登录的新用户，用户名已被占用时使用 fallbackUsername
+func (s *AuthService) createOAuthUser(user *model.User, fallbackUsername string) error {
+	resetAt := time.Now().Add(24 * time.Hour).Truncate(24 * time.Hour)
+	user.SubscriptionLevel = "free"
+	user.DailyQuota = s.cfg.Subscription.Levels["free"].DailyQuota
+	user.QuotaResetAt = &resetAt
+	user.EmailVerified = true // 第三方用户的邮箱已由第三方验证
+
+	// 确保用户名唯一
+	exists, _ := s.userRepo.ExistsByUsername(user.Username)
+	if exists {
+		user.Username = fallbackUsername
+	}
+
+	if err := s.userRepo.Create(user); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
 }
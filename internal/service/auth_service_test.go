@@ -1,14 +1,30 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/email"
+	"github.com/qs3c/anal_go_server/internal/pkg/jwt"
+	"github.com/qs3c/anal_go_server/internal/pkg/oauth"
+	"github.com/qs3c/anal_go_server/internal/pkg/ratelimit"
+	"github.com/qs3c/anal_go_server/internal/pkg/revocation"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/testutil"
 )
@@ -40,7 +56,7 @@ func setupAuthService(t *testing.T) (*AuthService, func()) {
 		},
 	}
 
-	service := NewAuthService(userRepo, cfg)
+	service := NewAuthService(userRepo, repository.NewPasswordResetRepository(db), repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
 
 	cleanup := func() {
 		testutil.CleanupTestDB(t, db)
@@ -163,7 +179,7 @@ func TestAuthService_VerifyEmail_Success(t *testing.T) {
 			Github: config.GithubOAuthConfig{},
 		},
 	}
-	service := NewAuthService(userRepo, cfg)
+	service := NewAuthService(userRepo, repository.NewPasswordResetRepository(db), repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
 
 	// Create user
 	user := testutil.TestUser(t, db)
@@ -199,7 +215,7 @@ func TestAuthService_GetUserByID(t *testing.T) {
 	cfg := &config.Config{
 		OAuth: config.OAuthConfig{Github: config.GithubOAuthConfig{}},
 	}
-	service := NewAuthService(userRepo, cfg)
+	service := NewAuthService(userRepo, repository.NewPasswordResetRepository(db), repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db), nil, nil, nil, nil, cfg)
 
 	user := testutil.TestUser(t, db, testutil.WithUsername("testuser"))
 
@@ -226,3 +242,356 @@ func TestAuthService_GetGithubAuthURL(t *testing.T) {
 	assert.Contains(t, url, "test-state")
 }
 
+func setupAuthServiceWithMailer(t *testing.T) (*AuthService, *gorm.DB, *email.MemoryTransport) {
+	t.Helper()
+
+	db := testutil.SetupTestDB(t)
+	t.Cleanup(func() { testutil.CleanupTestDB(t, db) })
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	userRepo := repository.NewUserRepository(db)
+	transport := email.NewMemoryTransport()
+	emailCfg := &config.EmailConfig{BaseURL: "https://app.example.com"}
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret-key-for-testing", ExpireHours: 24}}
+
+	service := NewAuthService(userRepo, repository.NewPasswordResetRepository(db), repository.NewRefreshTokenRepository(db), repository.NewAccessTokenRepository(db),
+		email.NewServiceWithTransport(emailCfg, transport), ratelimit.NewLimiter(client), revocation.NewList(client), nil, cfg)
+	return service, db, transport
+}
+
+func TestAuthService_Register_SendsVerification(t *testing.T) {
+	service, db, transport := setupAuthServiceWithMailer(t)
+	userRepo := repository.NewUserRepository(db)
+
+	_, err := service.Register(&dto.RegisterRequest{Email: "new@example.com", Username: "newuser", Password: "password123"})
+	require.NoError(t, err)
+
+	user, err := userRepo.GetByEmail("new@example.com")
+	require.NoError(t, err)
+	assert.False(t, user.EmailVerified)
+	require.NotNil(t, user.VerificationCode)
+
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "new@example.com", messages[0].To)
+	assert.Contains(t, messages[0].Body, *user.VerificationCode)
+	assert.Contains(t, messages[0].Body, "https://app.example.com/verify-email?code="+*user.VerificationCode)
+}
+
+func TestAuthService_ResendVerification(t *testing.T) {
+	service, db, transport := setupAuthServiceWithMailer(t)
+	userRepo := repository.NewUserRepository(db)
+	ctx := context.Background()
+
+	_, err := service.Register(&dto.RegisterRequest{Email: "new@example.com", Username: "newuser", Password: "password123"})
+	require.NoError(t, err)
+	before, err := userRepo.GetByEmail("new@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, service.ResendVerification(ctx, "new@example.com", "1.2.3.4"))
+	after, err := userRepo.GetByEmail("new@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, *before.VerificationCode, *after.VerificationCode)
+
+	messages := transport.Messages()
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[1].Body, *after.VerificationCode)
+
+	// 同一邮箱一分钟内只能重发一次，大小写不同视为同一邮箱
+	err = service.ResendVerification(ctx, "NEW@example.com", "5.6.7.8")
+	assert.Equal(t, ErrTooManyRequests, err)
+	assert.Len(t, transport.Messages(), 2)
+}
+
+func TestAuthService_ResendVerification_Silent(t *testing.T) {
+	service, db, transport := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+
+	testutil.TestUser(t, db, testutil.WithEmail("verified@example.com"))
+
+	// 未注册和已验证的邮箱同样返回成功，但不发送邮件
+	require.NoError(t, service.ResendVerification(ctx, "unknown@example.com", "1.2.3.4"))
+	require.NoError(t, service.ResendVerification(ctx, "verified@example.com", "1.2.3.4"))
+	assert.Empty(t, transport.Messages())
+}
+
+func TestAuthService_ResendVerification_IPLimit(t *testing.T) {
+	service, _, _ := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+
+	for i := 0; i < mailPerIPInHour; i++ {
+		require.NoError(t, service.ResendVerification(ctx, fmt.Sprintf("user%d@example.com", i), "1.2.3.4"))
+	}
+	err := service.ResendVerification(ctx, "another@example.com", "1.2.3.4")
+	assert.Equal(t, ErrTooManyRequests, err)
+	require.NoError(t, service.ResendVerification(ctx, "third@example.com", "5.6.7.8"))
+}
+
+func TestAuthService_ForgotAndResetPassword(t *testing.T) {
+	service, db, transport := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+	user := testutil.TestUser(t, db, testutil.WithEmail("reset@example.com"))
+
+	oldSession, err := service.createSession(user)
+	require.NoError(t, err)
+	oldClaims, err := jwt.ParseToken(oldSession.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	require.NoError(t, service.CheckToken(ctx, oldClaims))
+
+	// 未注册的邮箱不发送邮件
+	require.NoError(t, service.ForgotPassword(ctx, "unknown@example.com", "1.2.3.4"))
+	assert.Empty(t, transport.Messages())
+
+	require.NoError(t, service.ForgotPassword(ctx, "reset@example.com", "1.2.3.4"))
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	match := regexp.MustCompile(`https://app\.example\.com/reset-password\?token=([0-9a-f]+)`).FindStringSubmatch(messages[0].Body)
+	require.Len(t, match, 2)
+	token := match[1]
+
+	// 数据库中只保存哈希
+	var stored model.PasswordResetToken
+	require.NoError(t, db.Where("user_id = ?", user.ID).First(&stored).Error)
+	assert.NotEqual(t, token, stored.TokenHash)
+	assert.Equal(t, hashToken(token), stored.TokenHash)
+
+	require.NoError(t, service.ResetPassword(token, "newpassword123"))
+	assert.Equal(t, ErrInvalidResetToken, service.ResetPassword(token, "another123"))
+
+	// 旧 Token 失效，新密码可以登录
+	assert.Equal(t, ErrTokenRevoked, service.CheckToken(ctx, oldClaims))
+	resp, err := service.Login(&dto.LoginRequest{Email: "reset@example.com", Password: "newpassword123"})
+	require.NoError(t, err)
+	claims, err := jwt.ParseToken(resp.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	assert.NoError(t, service.CheckToken(ctx, claims))
+}
+
+func TestAuthService_ResetPassword_Expired(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	user := testutil.TestUser(t, db)
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Create(&model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken("expired-token"),
+		ExpiresAt: past,
+		CreatedAt: past.Add(-passwordResetTTL),
+	}).Error)
+
+	assert.Equal(t, ErrInvalidResetToken, service.ResetPassword("expired-token", "newpassword123"))
+	assert.Equal(t, ErrInvalidResetToken, service.ResetPassword("unknown-token", "newpassword123"))
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+
+	_, err := service.Register(&dto.RegisterRequest{Email: "change@example.com", Username: "changer", Password: "password123"})
+	require.NoError(t, err)
+	user, err := repository.NewUserRepository(db).GetByEmail("change@example.com")
+	require.NoError(t, err)
+	oldSession, err := service.createSession(user)
+	require.NoError(t, err)
+	oldClaims, err := jwt.ParseToken(oldSession.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+
+	_, err = service.ChangePassword(user.ID, &dto.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "newpassword123"})
+	assert.Equal(t, ErrWrongPassword, err)
+
+	resp, err := service.ChangePassword(user.ID, &dto.ChangePasswordRequest{OldPassword: "password123", NewPassword: "newpassword123"})
+	require.NoError(t, err)
+
+	// 旧 Token 失效，返回的新 Token 有效
+	assert.Equal(t, ErrTokenRevoked, service.CheckToken(ctx, oldClaims))
+	claims, err := jwt.ParseToken(resp.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	assert.NoError(t, service.CheckToken(ctx, claims))
+
+	// 没有密码的 OAuth 用户不能修改密码
+	oauthUser := testutil.TestUser(t, db, func(u *model.User) { u.PasswordHash = nil })
+	_, err = service.ChangePassword(oauthUser.ID, &dto.ChangePasswordRequest{OldPassword: "x", NewPassword: "newpassword123"})
+	assert.Equal(t, ErrPasswordNotSet, err)
+}
+
+func TestAuthService_RefreshRotation(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+	user := testutil.TestUser(t, db)
+
+	session, err := service.createSession(user)
+	require.NoError(t, err)
+	assert.NotEmpty(t, session.RefreshToken)
+	assert.Equal(t, int64(15*60), session.ExpiresIn)
+
+	// 刷新后签发新令牌，属于同一会话
+	refreshed, err := service.Refresh(ctx, session.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, session.RefreshToken, refreshed.RefreshToken)
+	oldClaims, err := jwt.ParseToken(session.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	newClaims, err := jwt.ParseToken(refreshed.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	assert.Equal(t, oldClaims.SessionID, newClaims.SessionID)
+	assert.NoError(t, service.CheckToken(ctx, newClaims))
+
+	// 旧刷新令牌被再次使用时撤销整个会话
+	_, err = service.Refresh(ctx, session.RefreshToken)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+	_, err = service.Refresh(ctx, refreshed.RefreshToken)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+	assert.Equal(t, ErrTokenRevoked, service.CheckToken(ctx, newClaims))
+
+	_, err = service.Refresh(ctx, "unknown")
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+	user := testutil.TestUser(t, db)
+
+	phone, err := service.createSession(user)
+	require.NoError(t, err)
+	laptop, err := service.createSession(user)
+	require.NoError(t, err)
+	phoneClaims, err := jwt.ParseToken(phone.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	laptopClaims, err := jwt.ParseToken(laptop.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+
+	// 退出一个设备不影响其它设备
+	require.NoError(t, service.Logout(ctx, phone.RefreshToken))
+	assert.Equal(t, ErrTokenRevoked, service.CheckToken(ctx, phoneClaims))
+	_, err = service.Refresh(ctx, phone.RefreshToken)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+	assert.NoError(t, service.CheckToken(ctx, laptopClaims))
+	require.NoError(t, service.Logout(ctx, "unknown"))
+
+	// 退出所有设备
+	require.NoError(t, service.LogoutAll(ctx, user.ID))
+	assert.Equal(t, ErrTokenRevoked, service.CheckToken(ctx, laptopClaims))
+	_, err = service.Refresh(ctx, laptop.RefreshToken)
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+
+	// 重新登录后可以正常使用
+	user, err = repository.NewUserRepository(db).GetByID(user.ID)
+	require.NoError(t, err)
+	session, err := service.createSession(user)
+	require.NoError(t, err)
+	claims, err := jwt.ParseToken(session.Token, "test-secret-key-for-testing")
+	require.NoError(t, err)
+	assert.NoError(t, service.CheckToken(ctx, claims))
+}
+
+func TestAuthService_RevokesAccessTokens(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	ctx := context.Background()
+	tokenService := NewAccessTokenService(repository.NewAccessTokenRepository(db), &config.Config{})
+
+	_, err := service.Register(&dto.RegisterRequest{Email: "pat@example.com", Username: "patuser", Password: "password123"})
+	require.NoError(t, err)
+	user, err := repository.NewUserRepository(db).GetByEmail("pat@example.com")
+	require.NoError(t, err)
+	other := testutil.TestUser(t, db)
+
+	create := func(userID int64) string {
+		item, err := tokenService.Create(userID, &dto.CreateAccessTokenRequest{Name: "ci", Scopes: []string{model.ScopeAnalysesRead}})
+		require.NoError(t, err)
+		_, _, err = tokenService.VerifyAccessToken(ctx, item.Token)
+		require.NoError(t, err)
+		return item.Token
+	}
+	otherToken := create(other.ID)
+
+	// 修改密码后旧的访问令牌失效
+	token := create(user.ID)
+	_, err = service.ChangePassword(user.ID, &dto.ChangePasswordRequest{OldPassword: "password123", NewPassword: "newpassword123"})
+	require.NoError(t, err)
+	_, _, err = tokenService.VerifyAccessToken(ctx, token)
+	assert.Equal(t, ErrInvalidAccessToken, err)
+
+	// 退出所有设备同样吊销访问令牌
+	token = create(user.ID)
+	require.NoError(t, service.LogoutAll(ctx, user.ID))
+	_, _, err = tokenService.VerifyAccessToken(ctx, token)
+	assert.Equal(t, ErrInvalidAccessToken, err)
+	tokens, err := tokenService.List(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	// 不影响其他用户
+	_, _, err = tokenService.VerifyAccessToken(ctx, otherToken)
+	assert.NoError(t, err)
+}
+
+func TestAuthService_Refresh_Expired(t *testing.T) {
+	service, db, _ := setupAuthServiceWithMailer(t)
+	user := testutil.TestUser(t, db)
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Create(&model.RefreshToken{
+		UserID:    user.ID,
+		SessionID: "expired-session",
+		TokenHash: hashToken("expired-token"),
+		ExpiresAt: past,
+		CreatedAt: past.Add(-time.Hour),
+	}).Error)
+
+	_, err := service.Refresh(context.Background(), "expired-token")
+	assert.Equal(t, ErrInvalidRefreshToken, err)
+}
+
+// newWechatStub 模拟微信 sns 接口，授权码 code-<openid> 换取对应用户
+func newWechatStub(t *testing.T, nickname string) *oauth.WechatOAuth {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/sns/oauth2/access_token":
+			openID := strings.TrimPrefix(q.Get("code"), "code-")
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token-" + openID, "openid": openID})
+		case "/sns/userinfo":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"openid": q.Get("openid"), "nickname": nickname, "headimgurl": "https://wx.example.com/head.png",
+			})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return oauth.NewWechatOAuthWithEndpoint("app-id", "app-secret", "", oauth.WechatEndpoint{APIURL: server.URL})
+}
+
+func TestAuthService_WechatCallback(t *testing.T) {
+	service, cleanup := setupAuthService(t)
+	defer cleanup()
+	service.wechatOAuth = newWechatStub(t, "微信用户")
+	ctx := context.Background()
+
+	// 首次扫码创建用户
+	resp, err := service.WechatCallback(ctx, "code-openid-0001")
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Token)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.Equal(t, "微信用户", resp.User.Username)
+	assert.Equal(t, "https://wx.example.com/head.png", resp.User.AvatarURL)
+
+	user, err := service.userRepo.GetByWechatOpenID("openid-0001")
+	require.NoError(t, err)
+	assert.Equal(t, resp.User.ID, user.ID)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, 5, user.DailyQuota)
+
+	// 再次扫码登录同一个用户
+	again, err := service.WechatCallback(ctx, "code-openid-0001")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.User.ID)
+
+	// 昵称已被占用时加上 openid 后缀
+	other, err := service.WechatCallback(ctx, "code-openid-0002")
+	require.NoError(t, err)
+	assert.NotEqual(t, user.ID, other.User.ID)
+	assert.Equal(t, "微信用户_nid-0002", other.User.Username)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/notify"
+	"github.com/qs3c/anal_go_server/internal/repository"
+)
+
+var (
+	ErrChannelNotFound     = errors.New("通知渠道不存在")
+	ErrChannelPermission   = errors.New("无权操作此通知渠道")
+	ErrInvalidChannelURL   = errors.New("webhook 地址必须是 http 或 https 链接")
+	ErrInvalidChannelEvent = errors.New("不支持的通知事件")
+	ErrChannelLimitReached = errors.New("每个用户最多创建 10 个通知渠道")
+	ErrChannelEmailMissing = errors.New("请先绑定邮箱")
+)
+
+const (
+	// maxChannelsPerUser 每个用户的通知渠道数上限
+	maxChannelsPerUser = 10
+	// channelDeliveryLimit 投递记录列表返回的条数
+	channelDeliveryLimit = 50
+)
+
+type ChannelService struct {
+	channelRepo *repository.ChannelRepository
+	userRepo    *repository.UserRepository
+	cfg         *config.Config
+}
+
+func NewChannelService(
+	channelRepo *repository.ChannelRepository,
+	userRepo *repository.UserRepository,
+	cfg *config.Config,
+) *ChannelService {
+	return &ChannelService{
+		channelRepo: channelRepo,
+		userRepo:    userRepo,
+		cfg:         cfg,
+	}
+}
+
+// Create 创建通知渠道，webhook 渠道生成签名密钥并在响应中返回一次
+func (s *ChannelService) Create(userID int64, req *dto.CreateChannelRequest) (*dto.ChannelItem, error) {
+	channels, err := s.channelRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) >= maxChannelsPerUser {
+		return nil, ErrChannelLimitReached
+	}
+
+	channel := &model.NotificationChannel{
+		UserID:  userID,
+		Type:    req.Type,
+		Name:    req.Name,
+		Events:  model.StringArray(req.Events),
+		Enabled: req.Enabled == nil || *req.Enabled,
+	}
+	if err := validateChannelEvents(channel.Events); err != nil {
+		return nil, err
+	}
+
+	switch channel.Type {
+	case notify.ChannelWebhook:
+		if err := validateChannelURL(req.URL); err != nil {
+			return nil, err
+		}
+		channel.URL = req.URL
+		if channel.Secret, err = generateRandomCode(64); err != nil {
+			return nil, err
+		}
+	case notify.ChannelEmail:
+		user, err := s.userRepo.GetByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Email == nil || *user.Email == "" {
+			return nil, ErrChannelEmailMissing
+		}
+	}
+
+	enabled := channel.Enabled
+	if err := s.channelRepo.Create(channel); err != nil {
+		return nil, err
+	}
+	// gorm 创建时零值字段使用数据库默认值，停用需要单独写入
+	if !enabled {
+		channel.Enabled = false
+		if err := s.channelRepo.Update(channel); err != nil {
+			return nil, err
+		}
+	}
+
+	item := toChannelItem(channel)
+	item.Secret = channel.Secret
+	return item, nil
+}
+
+// List 用户的通知渠道
+func (s *ChannelService) List(userID int64) ([]*dto.ChannelItem, error) {
+	channels, err := s.channelRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*dto.ChannelItem, len(channels))
+	for i, channel := range channels {
+		items[i] = toChannelItem(channel)
+	}
+	return items, nil
+}
+
+// Update 修改名称、地址、订阅事件或启用状态，rotate_secret 为 true 时重新生成签名密钥
+func (s *ChannelService) Update(userID, channelID int64, req *dto.UpdateChannelRequest) (*dto.ChannelItem, error) {
+	channel, err := s.getOwned(userID, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		channel.Name = *req.Name
+	}
+	if req.URL != nil && channel.Type == notify.ChannelWebhook {
+		if err := validateChannelURL(*req.URL); err != nil {
+			return nil, err
+		}
+		channel.URL = *req.URL
+	}
+	if req.Events != nil {
+		if len(req.Events) == 0 {
+			return nil, ErrInvalidChannelEvent
+		}
+		if err := validateChannelEvents(req.Events); err != nil {
+			return nil, err
+		}
+		channel.Events = model.StringArray(req.Events)
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+	rotated := req.RotateSecret && channel.Type == notify.ChannelWebhook
+	if rotated {
+		if channel.Secret, err = generateRandomCode(64); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.channelRepo.Update(channel); err != nil {
+		return nil, err
+	}
+
+	item := toChannelItem(channel)
+	if rotated {
+		item.Secret = channel.Secret
+	}
+	return item, nil
+}
+
+// Delete 删除通知渠道及其投递记录
+func (s *ChannelService) Delete(userID, channelID int64) error {
+	if _, err := s.getOwned(userID, channelID); err != nil {
+		return err
+	}
+	return s.channelRepo.Delete(channelID)
+}
+
+// ListDeliveries 渠道最近的投递记录
+func (s *ChannelService) ListDeliveries(userID, channelID int64) ([]*dto.ChannelDeliveryItem, error) {
+	if _, err := s.getOwned(userID, channelID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.channelRepo.ListDeliveries(channelID, channelDeliveryLimit)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*dto.ChannelDeliveryItem, len(deliveries))
+	for i, d := range deliveries {
+		items[i] = &dto.ChannelDeliveryItem{
+			ID:             d.ID,
+			Event:          d.Event,
+			AnalysisID:     d.AnalysisID,
+			JobID:          d.JobID,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			ResponseStatus: d.ResponseStatus,
+			LastError:      d.LastError,
+			CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+		}
+		if d.NextAttemptAt != nil {
+			items[i].NextAttemptAt = d.NextAttemptAt.Format(time.RFC3339)
+		}
+	}
+	return items, nil
+}
+
+func (s *ChannelService) getOwned(userID, channelID int64) (*model.NotificationChannel, error) {
+	channel, err := s.channelRepo.GetByID(channelID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChannelNotFound
+		}
+		return nil, err
+	}
+	if channel.UserID != userID {
+		return nil, ErrChannelPermission
+	}
+	return channel, nil
+}
+
+func validateChannelURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidChannelURL
+	}
+	return nil
+}
+
+func validateChannelEvents(events []string) error {
+	for _, e := range events {
+		supported := false
+		for _, known := range notify.Events {
+			if e == known {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return ErrInvalidChannelEvent
+		}
+	}
+	return nil
+}
+
+func toChannelItem(channel *model.NotificationChannel) *dto.ChannelItem {
+	events := []string(channel.Events)
+	if events == nil {
+		events = []string{}
+	}
+	return &dto.ChannelItem{
+		ID:        channel.ID,
+		Type:      channel.Type,
+		Name:      channel.Name,
+		URL:       channel.URL,
+		Events:    events,
+		Enabled:   channel.Enabled,
+		CreatedAt: channel.CreatedAt.Format(time.RFC3339),
+	}
+}
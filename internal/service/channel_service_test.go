@@ -0,0 +1,98 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/notify"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestChannelService_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewChannelService(repository.NewChannelRepository(db), repository.NewUserRepository(db), &config.Config{})
+	user := testutil.TestUser(t, db)
+
+	item, err := service.Create(user.ID, &dto.CreateChannelRequest{
+		Type:   notify.ChannelWebhook,
+		URL:    "https://hooks.example.com/anal",
+		Events: []string{notify.EventAnalysisCompleted},
+	})
+	require.NoError(t, err)
+	assert.True(t, item.Enabled)
+	assert.Len(t, item.Secret, 64)
+	secret := item.Secret
+
+	// 列表中不返回密钥
+	items, err := service.List(user.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Empty(t, items[0].Secret)
+
+	disabled := false
+	updated, err := service.Update(user.ID, item.ID, &dto.UpdateChannelRequest{
+		Events:       []string{notify.EventAnalysisCompleted, notify.EventAnalysisFailed},
+		Enabled:      &disabled,
+		RotateSecret: true,
+	})
+	require.NoError(t, err)
+	assert.False(t, updated.Enabled)
+	assert.Len(t, updated.Events, 2)
+	assert.NotEqual(t, secret, updated.Secret)
+
+	other := testutil.TestUser(t, db)
+	_, err = service.Update(other.ID, item.ID, &dto.UpdateChannelRequest{Enabled: &disabled})
+	assert.ErrorIs(t, err, ErrChannelPermission)
+	assert.ErrorIs(t, service.Delete(other.ID, item.ID), ErrChannelPermission)
+
+	require.NoError(t, service.Delete(user.ID, item.ID))
+	_, err = service.ListDeliveries(user.ID, item.ID)
+	assert.ErrorIs(t, err, ErrChannelNotFound)
+}
+
+func TestChannelService_Create_Validation(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewChannelService(repository.NewChannelRepository(db), repository.NewUserRepository(db), &config.Config{})
+	user := testutil.TestUser(t, db)
+
+	_, err := service.Create(user.ID, &dto.CreateChannelRequest{
+		Type: notify.ChannelWebhook, URL: "ftp://example.com", Events: []string{notify.EventAnalysisCompleted},
+	})
+	assert.ErrorIs(t, err, ErrInvalidChannelURL)
+
+	_, err = service.Create(user.ID, &dto.CreateChannelRequest{
+		Type: notify.ChannelWebhook, URL: "https://example.com", Events: []string{"analysis.deleted"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidChannelEvent)
+
+	// 没有绑定邮箱时不能创建邮件渠道
+	noEmail := testutil.TestUser(t, db, func(u *model.User) { u.Email = nil })
+	_, err = service.Create(noEmail.ID, &dto.CreateChannelRequest{Type: notify.ChannelEmail, Events: []string{notify.EventAnalysisFailed}})
+	assert.ErrorIs(t, err, ErrChannelEmailMissing)
+
+	withEmail := testutil.TestUser(t, db, testutil.WithEmail("me@example.com"))
+	item, err := service.Create(withEmail.ID, &dto.CreateChannelRequest{Type: notify.ChannelEmail, Events: []string{notify.EventAnalysisFailed}})
+	require.NoError(t, err)
+	assert.Empty(t, item.Secret)
+
+	for i := 0; i < maxChannelsPerUser; i++ {
+		_, err = service.Create(user.ID, &dto.CreateChannelRequest{
+			Type: notify.ChannelWebhook, URL: "https://example.com", Events: []string{notify.EventAnalysisCompleted},
+		})
+		require.NoError(t, err)
+	}
+	_, err = service.Create(user.ID, &dto.CreateChannelRequest{
+		Type: notify.ChannelWebhook, URL: "https://example.com", Events: []string{notify.EventAnalysisCompleted},
+	})
+	assert.ErrorIs(t, err, ErrChannelLimitReached)
+}
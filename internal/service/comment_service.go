@@ -23,6 +23,7 @@ type CommentService struct {
 	commentRepo  *repository.CommentRepository
 	analysisRepo *repository.AnalysisRepository
 	userRepo     *repository.UserRepository
+	notification *NotificationService
 	cfg          *config.Config
 }
 
@@ -30,12 +31,14 @@ func NewCommentService(
 	commentRepo *repository.CommentRepository,
 	analysisRepo *repository.AnalysisRepository,
 	userRepo *repository.UserRepository,
+	notification *NotificationService,
 	cfg *config.Config,
 ) *CommentService {
 	return &CommentService{
 		commentRepo:  commentRepo,
 		analysisRepo: analysisRepo,
 		userRepo:     userRepo,
+		notification: notification,
 		cfg:          cfg,
 	}
 }
@@ -56,6 +59,7 @@ func (s *CommentService) Create(userID, analysisID int64, req *dto.CreateComment
 	}
 
 	// 如果是回复，验证父评论
+	var replyTo *model.Comment
 	if req.ParentID != nil {
 		parent, err := s.commentRepo.GetByID(*req.ParentID)
 		if err != nil {
@@ -74,6 +78,7 @@ func (s *CommentService) Create(userID, analysisID int64, req *dto.CreateComment
 		if parent.ParentID != nil {
 			req.ParentID = parent.ParentID
 		}
+		replyTo = parent
 	}
 
 	// 获取用户信息
@@ -97,6 +102,8 @@ func (s *CommentService) Create(userID, analysisID int64, req *dto.CreateComment
 	// 增加评论数
 	s.analysisRepo.IncrementCommentCount(analysisID, 1)
 
+	s.notifyComment(analysis, comment, replyTo)
+
 	return &dto.CommentItem{
 		ID:       comment.ID,
 		ParentID: comment.ParentID,
@@ -110,6 +117,36 @@ func (s *CommentService) Create(userID, analysisID int64, req *dto.CreateComment
 	}, nil
 }
 
+// notifyComment 通知被回复的评论作者和分析作者，同一个人只通知一次
+func (s *CommentService) notifyComment(analysis *model.Analysis, comment *model.Comment, replyTo *model.Comment) {
+	if s.notification == nil {
+		return
+	}
+	if replyTo != nil {
+		s.notification.Notify(&model.Notification{
+			UserID:     replyTo.UserID,
+			ActorID:    comment.UserID,
+			Type:       model.NotificationReply,
+			AnalysisID: analysis.ID,
+			CommentID:  comment.ID,
+			Title:      analysis.Title,
+			Content:    comment.Content,
+		})
+		if replyTo.UserID == analysis.UserID {
+			return
+		}
+	}
+	s.notification.Notify(&model.Notification{
+		UserID:     analysis.UserID,
+		ActorID:    comment.UserID,
+		Type:       model.NotificationComment,
+		AnalysisID: analysis.ID,
+		CommentID:  comment.ID,
+		Title:      analysis.Title,
+		Content:    comment.Content,
+	})
+}
+
 // Delete 删除评论
 func (s *CommentService) Delete(userID, commentID int64) error {
 	comment, err := s.commentRepo.GetByID(commentID)
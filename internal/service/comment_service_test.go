@@ -22,7 +22,7 @@ func setupCommentService(t *testing.T) (*CommentService, func()) {
 
 	cfg := &config.Config{}
 
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, cfg)
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, cfg)
 
 	cleanup := func() {
 		testutil.CleanupTestDB(t, db)
@@ -38,7 +38,7 @@ func TestCommentService_Create_Success(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db, testutil.WithUsername("commenter"))
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -62,7 +62,7 @@ func TestCommentService_Create_Reply(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -100,7 +100,7 @@ func TestCommentService_Create_AnalysisNotPublic(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(false))
@@ -120,7 +120,7 @@ func TestCommentService_Create_ParentNotFound(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -142,7 +142,7 @@ func TestCommentService_Delete_Success(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -171,7 +171,7 @@ func TestCommentService_Delete_NoPermission(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user1 := testutil.TestUser(t, db)
 	user2 := testutil.TestUser(t, db)
@@ -190,7 +190,7 @@ func TestCommentService_Delete_WithReplies(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -213,7 +213,7 @@ func TestCommentService_ListByAnalysisID_Success(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -236,7 +236,7 @@ func TestCommentService_ListByAnalysisID_WithReplies(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -268,7 +268,7 @@ func TestCommentService_ListByAnalysisID_NotPublic(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(false))
@@ -284,7 +284,7 @@ func TestCommentService_ListByAnalysisID_Empty(t *testing.T) {
 	commentRepo := repository.NewCommentRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	service := NewCommentService(commentRepo, analysisRepo, userRepo, &config.Config{})
+	service := NewCommentService(commentRepo, analysisRepo, userRepo, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -2,7 +2,6 @@ package service
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
@@ -11,6 +10,7 @@ import (
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
@@ -25,17 +25,23 @@ var (
 type CommunityService struct {
 	analysisRepo    *repository.AnalysisRepository
 	interactionRepo *repository.InteractionRepository
+	stores          *storage.Set
+	notification    *NotificationService
 	cfg             *config.Config
 }
 
 func NewCommunityService(
 	analysisRepo *repository.AnalysisRepository,
 	interactionRepo *repository.InteractionRepository,
+	stores *storage.Set,
+	notification *NotificationService,
 	cfg *config.Config,
 ) *CommunityService {
 	return &CommunityService{
 		analysisRepo:    analysisRepo,
 		interactionRepo: interactionRepo,
+		stores:          stores,
+		notification:    notification,
 		cfg:             cfg,
 	}
 }
@@ -138,6 +144,17 @@ func (s *CommunityService) Like(userID, analysisID int64) (*dto.LikeResponse, er
 	// 增加点赞数
 	s.analysisRepo.IncrementLikeCount(analysisID, 1)
 
+	// 通知分析作者
+	if s.notification != nil {
+		s.notification.Notify(&model.Notification{
+			UserID:     analysis.UserID,
+			ActorID:    userID,
+			Type:       model.NotificationLike,
+			AnalysisID: analysis.ID,
+			Title:      analysis.Title,
+		})
+	}
+
 	return &dto.LikeResponse{
 		Liked:     true,
 		LikeCount: analysis.LikeCount + 1,
@@ -302,17 +319,11 @@ func (s *CommunityService) buildCommunityItem(a *model.Analysis) *dto.CommunityA
 }
 
 func (s *CommunityService) buildCommunityDetail(a *model.Analysis) *dto.CommunityAnalysisDetail {
-	// 处理本地存储的 URL，转换为 API 端点
-	diagramURL := a.DiagramOSSURL
-	if strings.HasPrefix(diagramURL, "local://") {
-		diagramURL = fmt.Sprintf("/api/v1/analyses/%d/diagram", a.ID)
-	}
-
 	detail := &dto.CommunityAnalysisDetail{
 		ID:               a.ID,
 		ShareTitle:       a.ShareTitle,
 		ShareDescription: a.ShareDescription,
-		DiagramOSSURL:    diagramURL,
+		DiagramOSSURL:    diagramURL(s.stores, a),
 		CreationType:     a.CreationType,
 		RepoURL:          a.RepoURL,
 		ViewCount:        a.ViewCount,
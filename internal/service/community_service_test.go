@@ -20,7 +20,7 @@ func setupCommunityService(t *testing.T) (*CommunityService, *repository.Analysi
 
 	cfg := &config.Config{}
 
-	service := NewCommunityService(analysisRepo, interactionRepo, cfg)
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, cfg)
 
 	cleanup := func() {
 		testutil.CleanupTestDB(t, db)
@@ -35,7 +35,7 @@ func TestCommunityService_ListPublicAnalyses(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 
@@ -66,7 +66,7 @@ func TestCommunityService_GetPublicAnalysis_Success(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db, testutil.WithUsername("author"))
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true), testutil.WithTitle("Public Analysis"))
@@ -92,7 +92,7 @@ func TestCommunityService_GetPublicAnalysis_NotPublic(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(false))
@@ -107,7 +107,7 @@ func TestCommunityService_GetPublicAnalysis_WithUserInteraction(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -129,7 +129,7 @@ func TestCommunityService_Like_Success(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -146,7 +146,7 @@ func TestCommunityService_Like_Idempotent(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -167,7 +167,7 @@ func TestCommunityService_Like_NotPublic(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(false))
@@ -182,7 +182,7 @@ func TestCommunityService_Unlike_Success(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -204,7 +204,7 @@ func TestCommunityService_Unlike_NotLiked(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -221,7 +221,7 @@ func TestCommunityService_Bookmark_Success(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -238,7 +238,7 @@ func TestCommunityService_Bookmark_NotPublic(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(false))
@@ -253,7 +253,7 @@ func TestCommunityService_Unbookmark_Success(t *testing.T) {
 
 	analysisRepo := repository.NewAnalysisRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
-	service := NewCommunityService(analysisRepo, interactionRepo, &config.Config{})
+	service := NewCommunityService(analysisRepo, interactionRepo, nil, nil, &config.Config{})
 
 	user := testutil.TestUser(t, db)
 	analysis := testutil.TestAnalysis(t, db, user.ID, testutil.WithPublic(true))
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/pubsub"
+	"github.com/qs3c/anal_go_server/internal/repository"
+)
+
+var ErrNotificationNotFound = errors.New("通知不存在")
+
+// notificationContentLimit 通知中保存的评论摘要或失败原因的最大字符数
+const notificationContentLimit = 200
+
+type NotificationService struct {
+	notificationRepo *repository.NotificationRepository
+	publisher        *pubsub.Publisher
+	cfg              *config.Config
+}
+
+func NewNotificationService(
+	notificationRepo *repository.NotificationRepository,
+	publisher *pubsub.Publisher,
+	cfg *config.Config,
+) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		publisher:        publisher,
+		cfg:              cfg,
+	}
+}
+
+// Notify 保存通知并实时推送给接收者，用户对自己的操作不产生通知
+// 同一用户对同一分析的点赞只通知一次，反复取消再点赞不会重复通知
+// 通知失败不影响触发它的业务操作，只记录日志
+func (s *NotificationService) Notify(notification *model.Notification) {
+	if notification.UserID == 0 || notification.UserID == notification.ActorID {
+		return
+	}
+	if notification.Type == model.NotificationLike {
+		exists, err := s.notificationRepo.Exists(notification.UserID, notification.ActorID, notification.AnalysisID, notification.Type)
+		if err != nil {
+			log.Printf("Notify: failed to check like notification for user %d: %v", notification.UserID, err)
+			return
+		}
+		if exists {
+			return
+		}
+	}
+	if r := []rune(notification.Content); len(r) > notificationContentLimit {
+		notification.Content = string(r[:notificationContentLimit])
+	}
+	if err := s.notificationRepo.Create(notification); err != nil {
+		log.Printf("Notify: failed to create notification for user %d: %v", notification.UserID, err)
+		return
+	}
+	if s.publisher == nil {
+		return
+	}
+
+	// 重新读取以带上触发用户信息
+	created, err := s.notificationRepo.GetByIDWithActor(notification.ID)
+	if err != nil {
+		log.Printf("Notify: failed to load notification %d: %v", notification.ID, err)
+		return
+	}
+	data, err := json.Marshal(toNotificationItem(created))
+	if err != nil {
+		return
+	}
+	unread, err := s.notificationRepo.CountUnread(notification.UserID)
+	if err != nil {
+		log.Printf("Notify: failed to count unread for user %d: %v", notification.UserID, err)
+	}
+	err = s.publisher.PublishNotification(context.Background(), &pubsub.NotificationMessage{
+		UserID:       notification.UserID,
+		UnreadCount:  unread,
+		Notification: data,
+	})
+	if err != nil {
+		log.Printf("Notify: failed to publish notification %d: %v", notification.ID, err)
+	}
+}
+
+// List 获取通知列表及未读数
+func (s *NotificationService) List(userID int64, page, pageSize int, unreadOnly bool) (*dto.NotificationListResponse, error) {
+	notifications, total, err := s.notificationRepo.ListByUserID(userID, page, pageSize, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*dto.NotificationItem, len(notifications))
+	for i, n := range notifications {
+		items[i] = toNotificationItem(n)
+	}
+	return &dto.NotificationListResponse{
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+		UnreadCount: unread,
+		Items:       items,
+	}, nil
+}
+
+// UnreadCount 获取未读通知数
+func (s *NotificationService) UnreadCount(userID int64) (*dto.UnreadCountResponse, error) {
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.UnreadCountResponse{UnreadCount: unread}, nil
+}
+
+// MarkRead 标记一条通知为已读，返回剩余未读数
+func (s *NotificationService) MarkRead(userID, notificationID int64) (*dto.UnreadCountResponse, error) {
+	found, err := s.notificationRepo.MarkRead(userID, notificationID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotificationNotFound
+	}
+	return s.UnreadCount(userID)
+}
+
+// MarkAllRead 标记全部通知为已读
+func (s *NotificationService) MarkAllRead(userID int64) (*dto.UnreadCountResponse, error) {
+	if _, err := s.notificationRepo.MarkAllRead(userID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &dto.UnreadCountResponse{UnreadCount: 0}, nil
+}
+
+func toNotificationItem(n *model.Notification) *dto.NotificationItem {
+	item := &dto.NotificationItem{
+		ID:         n.ID,
+		Type:       n.Type,
+		AnalysisID: n.AnalysisID,
+		CommentID:  n.CommentID,
+		JobID:      n.JobID,
+		Title:      n.Title,
+		Content:    n.Content,
+		Read:       n.ReadAt != nil,
+		CreatedAt:  n.CreatedAt.Format(time.RFC3339),
+	}
+	if n.Actor != nil {
+		item.Actor = &dto.CommentUser{
+			ID:        n.Actor.ID,
+			Username:  n.Actor.Username,
+			AvatarURL: n.Actor.AvatarURL,
+		}
+	}
+	return item
+}
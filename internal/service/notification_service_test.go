@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/pubsub"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestNotificationService_CommentFanOut(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	notificationService := NewNotificationService(repository.NewNotificationRepository(db), nil, &config.Config{})
+	commentService := NewCommentService(repository.NewCommentRepository(db), repository.NewAnalysisRepository(db),
+		repository.NewUserRepository(db), notificationService, &config.Config{})
+
+	owner := testutil.TestUser(t, db)
+	alice := testutil.TestUser(t, db)
+	bob := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, owner.ID, testutil.WithPublic(true), testutil.WithTitle("demo"))
+
+	// 作者评论自己的分析不产生通知
+	_, err := commentService.Create(owner.ID, analysis.ID, &dto.CreateCommentRequest{Content: "自己的评论"})
+	require.NoError(t, err)
+	resp, err := notificationService.List(owner.ID, 1, 20, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), resp.Total)
+
+	comment, err := commentService.Create(alice.ID, analysis.ID, &dto.CreateCommentRequest{Content: "写得不错"})
+	require.NoError(t, err)
+	resp, err = notificationService.List(owner.ID, 1, 20, false)
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, model.NotificationComment, resp.Items[0].Type)
+	assert.Equal(t, alice.ID, resp.Items[0].Actor.ID)
+	assert.Equal(t, "demo", resp.Items[0].Title)
+	assert.Equal(t, "写得不错", resp.Items[0].Content)
+	assert.Equal(t, int64(1), resp.UnreadCount)
+
+	// 回复通知被回复者和分析作者
+	_, err = commentService.Create(bob.ID, analysis.ID, &dto.CreateCommentRequest{Content: "同意", ParentID: &comment.ID})
+	require.NoError(t, err)
+	resp, err = notificationService.List(alice.ID, 1, 20, false)
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, model.NotificationReply, resp.Items[0].Type)
+	resp, err = notificationService.List(owner.ID, 1, 20, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.UnreadCount)
+
+	// 作者回复别人时只通知被回复者
+	_, err = commentService.Create(owner.ID, analysis.ID, &dto.CreateCommentRequest{Content: "谢谢", ParentID: &comment.ID})
+	require.NoError(t, err)
+	resp, err = notificationService.List(alice.ID, 1, 20, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.UnreadCount)
+	resp, err = notificationService.List(owner.ID, 1, 20, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.UnreadCount)
+}
+
+func TestNotificationService_Like(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	notificationService := NewNotificationService(repository.NewNotificationRepository(db), nil, &config.Config{})
+	communityService := NewCommunityService(repository.NewAnalysisRepository(db), repository.NewInteractionRepository(db),
+		nil, notificationService, &config.Config{})
+
+	owner := testutil.TestUser(t, db)
+	fan := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, owner.ID, testutil.WithPublic(true))
+
+	_, err := communityService.Like(fan.ID, analysis.ID)
+	require.NoError(t, err)
+	// 重复点赞是幂等的，不再通知
+	_, err = communityService.Like(fan.ID, analysis.ID)
+	require.NoError(t, err)
+	// 取消后再点赞也不再通知
+	for i := 0; i < 3; i++ {
+		_, err = communityService.Unlike(fan.ID, analysis.ID)
+		require.NoError(t, err)
+		_, err = communityService.Like(fan.ID, analysis.ID)
+		require.NoError(t, err)
+	}
+
+	resp, err := notificationService.List(owner.ID, 1, 20, false)
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, model.NotificationLike, resp.Items[0].Type)
+	assert.Equal(t, fan.ID, resp.Items[0].Actor.ID)
+}
+
+func TestNotificationService_MarkRead(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewNotificationService(repository.NewNotificationRepository(db), nil, &config.Config{})
+	user := testutil.TestUser(t, db)
+	other := testutil.TestUser(t, db)
+
+	for i := 0; i < 3; i++ {
+		service.Notify(&model.Notification{UserID: user.ID, Type: model.NotificationAnalysisCompleted, AnalysisID: int64(i + 1)})
+	}
+	resp, err := service.List(user.ID, 1, 20, false)
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 3)
+
+	unread, err := service.MarkRead(user.ID, resp.Items[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), unread.UnreadCount)
+
+	// 重复标记不报错，不能标记别人的通知
+	_, err = service.MarkRead(user.ID, resp.Items[0].ID)
+	require.NoError(t, err)
+	_, err = service.MarkRead(other.ID, resp.Items[1].ID)
+	assert.ErrorIs(t, err, ErrNotificationNotFound)
+
+	resp, err = service.List(user.ID, 1, 20, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.Total)
+
+	_, err = service.MarkAllRead(user.ID)
+	require.NoError(t, err)
+	count, err := service.UnreadCount(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count.UnreadCount)
+}
+
+func TestNotificationService_Publish(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewNotificationService(repository.NewNotificationRepository(db), pubsub.NewPublisher(client), &config.Config{})
+	user := testutil.TestUser(t, db)
+	actor := testutil.TestUser(t, db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan *pubsub.NotificationMessage, 1)
+	go pubsub.NewSubscriber(client).SubscribeNotifications(ctx, func(msg *pubsub.NotificationMessage) {
+		received <- msg
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	service.Notify(&model.Notification{UserID: user.ID, ActorID: actor.ID, Type: model.NotificationLike, AnalysisID: 1})
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, user.ID, msg.UserID)
+		assert.Equal(t, int64(1), msg.UnreadCount)
+		var item dto.NotificationItem
+		require.NoError(t, json.Unmarshal(msg.Notification, &item))
+		assert.Equal(t, model.NotificationLike, item.Type)
+		assert.Equal(t, actor.Username, item.Actor.Username)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for notification")
+	}
+}
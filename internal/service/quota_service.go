@@ -6,6 +6,7 @@ import (
 
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
 	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
@@ -112,6 +113,37 @@ func (s *QuotaService) GetMaxDepth(subscriptionLevel string) int {
 	return level.MaxDepth
 }
 
+// 订阅等级未配置框图限制时使用的默认值
+const (
+	defaultDiagramSizeKB      = 1024
+	defaultDiagramStructs     = 300
+	defaultDiagramConnections = 1000
+)
+
+// DiagramLimits 获取手动编辑/导入框图的大小和节点、连接数量限制
+func (s *QuotaService) DiagramLimits(subscriptionLevel string) diagram.Limits {
+	level, ok := s.cfg.Subscription.Levels[subscriptionLevel]
+	if !ok {
+		level = s.cfg.Subscription.Levels["free"]
+	}
+
+	limits := diagram.Limits{
+		MaxBytes:       level.MaxDiagramSizeKB * 1024,
+		MaxStructs:     level.MaxDiagramStructs,
+		MaxConnections: level.MaxDiagramConnections,
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = defaultDiagramSizeKB * 1024
+	}
+	if limits.MaxStructs <= 0 {
+		limits.MaxStructs = defaultDiagramStructs
+	}
+	if limits.MaxConnections <= 0 {
+		limits.MaxConnections = defaultDiagramConnections
+	}
+	return limits
+}
+
 func (s *QuotaService) resetUserQuota(userID int64) error {
 	nextReset := time.Now().Add(24 * time.Hour).Truncate(24 * time.Hour)
 	return s.userRepo.ResetQuota(userID, nextReset)
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
 	"github.com/qs3c/anal_go_server/internal/repository"
 	"github.com/qs3c/anal_go_server/internal/testutil"
 )
@@ -241,3 +242,19 @@ func TestQuotaService_GetQuotaInfo(t *testing.T) {
 	assert.Equal(t, 3, info.DailyRemain)
 	assert.Equal(t, 3, info.MaxDepth)
 }
+
+func TestQuotaService_DiagramLimits(t *testing.T) {
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+				"pro":  {DailyQuota: 100, MaxDepth: 10, MaxDiagramSizeKB: 16384, MaxDiagramStructs: 8000, MaxDiagramConnections: 30000},
+			},
+		},
+	}
+	service := NewQuotaService(nil, cfg)
+
+	assert.Equal(t, diagram.Limits{MaxBytes: 16384 * 1024, MaxStructs: 8000, MaxConnections: 30000}, service.DiagramLimits("pro"))
+	// 未配置时使用默认限制
+	assert.Equal(t, diagram.Limits{MaxBytes: 1024 * 1024, MaxStructs: 300, MaxConnections: 1000}, service.DiagramLimits("unknown"))
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/cronexpr"
+	"github.com/qs3c/anal_go_server/internal/repository"
+)
+
+var (
+	ErrScheduleNotFound     = errors.New("定时任务不存在")
+	ErrSchedulePermission   = errors.New("无权操作此定时任务")
+	ErrInvalidCron          = errors.New("无效的 cron 表达式")
+	ErrScheduleTooFrequent  = errors.New("定时任务的间隔不能小于 1 小时")
+	ErrScheduleLimitReached = errors.New("每个分析最多创建 5 个定时任务")
+)
+
+const (
+	// minScheduleInterval 相邻两次触发的最小间隔，避免误配置的表达式耗尽配额
+	minScheduleInterval = time.Hour
+	// maxSchedulesPerAnalysis 每个分析的定时任务数上限
+	maxSchedulesPerAnalysis = 5
+)
+
+type ScheduleService struct {
+	scheduleRepo *repository.ScheduleRepository
+	analysisRepo *repository.AnalysisRepository
+	cfg          *config.Config
+}
+
+func NewScheduleService(
+	scheduleRepo *repository.ScheduleRepository,
+	analysisRepo *repository.AnalysisRepository,
+	cfg *config.Config,
+) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo: scheduleRepo,
+		analysisRepo: analysisRepo,
+		cfg:          cfg,
+	}
+}
+
+// Create 为 GitHub 仓库的 AI 分析创建定时任务
+func (s *ScheduleService) Create(userID int64, req *dto.CreateScheduleRequest) (*dto.ScheduleItem, error) {
+	analysis, err := s.analysisRepo.GetByID(req.AnalysisID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, err
+	}
+	if analysis.UserID != userID {
+		return nil, ErrAnalysisPermission
+	}
+	if analysis.CreationType != "ai" || analysis.SourceType != "github" {
+		return nil, ErrRerunNotSupported
+	}
+
+	count, err := s.scheduleRepo.CountByAnalysisID(analysis.ID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxSchedulesPerAnalysis {
+		return nil, ErrScheduleLimitReached
+	}
+
+	schedule := &model.AnalysisSchedule{
+		AnalysisID: analysis.ID,
+		UserID:     userID,
+		CronExpr:   req.Cron,
+		Ref:        req.Ref,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if err := s.prepare(schedule); err != nil {
+		return nil, err
+	}
+	enabled := schedule.Enabled
+	if err := s.scheduleRepo.Create(schedule); err != nil {
+		return nil, err
+	}
+	// gorm 创建时零值字段使用数据库默认值，停用需要单独写入
+	if !enabled {
+		schedule.Enabled = false
+		if err := s.scheduleRepo.Update(schedule); err != nil {
+			return nil, err
+		}
+	}
+	return toScheduleItem(schedule), nil
+}
+
+// List 用户的定时任务，analysisID 不为 0 时只返回该分析的
+func (s *ScheduleService) List(userID, analysisID int64) ([]*dto.ScheduleItem, error) {
+	schedules, err := s.scheduleRepo.ListByUserID(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*dto.ScheduleItem, len(schedules))
+	for i, schedule := range schedules {
+		items[i] = toScheduleItem(schedule)
+	}
+	return items, nil
+}
+
+// Get 获取定时任务
+func (s *ScheduleService) Get(userID, scheduleID int64) (*dto.ScheduleItem, error) {
+	schedule, err := s.getOwned(userID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return toScheduleItem(schedule), nil
+}
+
+// Update 修改表达式、ref 或启用状态，并重新计算下次触发时间
+func (s *ScheduleService) Update(userID, scheduleID int64, req *dto.UpdateScheduleRequest) (*dto.ScheduleItem, error) {
+	schedule, err := s.getOwned(userID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Cron != nil {
+		schedule.CronExpr = *req.Cron
+	}
+	if req.Ref != nil {
+		schedule.Ref = *req.Ref
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := s.prepare(schedule); err != nil {
+		return nil, err
+	}
+	if err := s.scheduleRepo.Update(schedule); err != nil {
+		return nil, err
+	}
+	return toScheduleItem(schedule), nil
+}
+
+// Delete 删除定时任务
+func (s *ScheduleService) Delete(userID, scheduleID int64) error {
+	if _, err := s.getOwned(userID, scheduleID); err != nil {
+		return err
+	}
+	return s.scheduleRepo.Delete(scheduleID)
+}
+
+func (s *ScheduleService) getOwned(userID, scheduleID int64) (*model.AnalysisSchedule, error) {
+	schedule, err := s.scheduleRepo.GetByID(scheduleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	if schedule.UserID != userID {
+		return nil, ErrSchedulePermission
+	}
+	return schedule, nil
+}
+
+// prepare 校验表达式和 ref，计算下次触发时间，停用时清空
+func (s *ScheduleService) prepare(schedule *model.AnalysisSchedule) error {
+	if err := ValidateRef(schedule.Ref); err != nil {
+		return err
+	}
+	next, err := NextScheduleRun(schedule.CronExpr, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+// NextScheduleRun 校验 cron 表达式并返回 now 之后的下一次触发时间
+// 表达式不合法、永不触发或间隔小于 minScheduleInterval 时返回错误
+func NextScheduleRun(expr string, now time.Time) (time.Time, error) {
+	sched, err := cronexpr.Parse(expr)
+	if err != nil {
+		return time.Time{}, ErrInvalidCron
+	}
+	next := sched.Next(now)
+	if next.IsZero() {
+		return time.Time{}, ErrInvalidCron
+	}
+	// 检查接下来的若干次触发，覆盖 "0,30 * * * *" 这类不均匀的表达式
+	prev := next
+	for i := 0; i < 24; i++ {
+		t := sched.Next(prev)
+		if t.IsZero() {
+			break
+		}
+		if t.Sub(prev) < minScheduleInterval {
+			return time.Time{}, ErrScheduleTooFrequent
+		}
+		prev = t
+	}
+	return next, nil
+}
+
+func toScheduleItem(schedule *model.AnalysisSchedule) *dto.ScheduleItem {
+	item := &dto.ScheduleItem{
+		ID:         schedule.ID,
+		AnalysisID: schedule.AnalysisID,
+		Cron:       schedule.CronExpr,
+		Ref:        schedule.Ref,
+		Enabled:    schedule.Enabled,
+		LastJobID:  schedule.LastJobID,
+		LastError:  schedule.LastError,
+		CreatedAt:  schedule.CreatedAt.Format(time.RFC3339),
+	}
+	if schedule.LastRunAt != nil {
+		item.LastRunAt = schedule.LastRunAt.Format(time.RFC3339)
+	}
+	if schedule.NextRunAt != nil {
+		item.NextRunAt = schedule.NextRunAt.Format(time.RFC3339)
+	}
+	return item
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func TestScheduleService_CRUD(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewScheduleService(repository.NewScheduleRepository(db), repository.NewAnalysisRepository(db), &config.Config{})
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, func(a *model.Analysis) {
+		a.CreationType = "ai"
+		a.SourceType = "github"
+		a.RepoURL = "https://github.com/example/repo"
+	})
+
+	item, err := service.Create(user.ID, &dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "0 2 * * *", Ref: "main"})
+	require.NoError(t, err)
+	assert.True(t, item.Enabled)
+	next, err := time.Parse(time.RFC3339, item.NextRunAt)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.Hour())
+	assert.True(t, next.After(time.Now()))
+
+	disabled := false
+	item2, err := service.Create(user.ID, &dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "@weekly", Enabled: &disabled})
+	require.NoError(t, err)
+	assert.False(t, item2.Enabled)
+	assert.Empty(t, item2.NextRunAt)
+
+	items, err := service.List(user.ID, analysis.ID)
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	// 启用时计算下次触发时间
+	enabled := true
+	cron := "@daily"
+	updated, err := service.Update(user.ID, item2.ID, &dto.UpdateScheduleRequest{Cron: &cron, Enabled: &enabled})
+	require.NoError(t, err)
+	assert.Equal(t, "@daily", updated.Cron)
+	assert.NotEmpty(t, updated.NextRunAt)
+
+	other := testutil.TestUser(t, db)
+	_, err = service.Get(other.ID, item.ID)
+	assert.ErrorIs(t, err, ErrSchedulePermission)
+
+	require.NoError(t, service.Delete(user.ID, item.ID))
+	_, err = service.Get(user.ID, item.ID)
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+}
+
+func TestScheduleService_Create_Invalid(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	service := NewScheduleService(repository.NewScheduleRepository(db), repository.NewAnalysisRepository(db), &config.Config{})
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, func(a *model.Analysis) {
+		a.CreationType = "ai"
+		a.SourceType = "github"
+	})
+	manual := testutil.TestAnalysis(t, db, user.ID)
+
+	tests := []struct {
+		req  dto.CreateScheduleRequest
+		want error
+	}{
+		{dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "every night"}, ErrInvalidCron},
+		{dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "0 0 30 2 *"}, ErrInvalidCron},
+		{dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "*/10 * * * *"}, ErrScheduleTooFrequent},
+		{dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "0,30 * * * *"}, ErrScheduleTooFrequent},
+		{dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "@daily", Ref: "-x"}, ErrInvalidRef},
+		{dto.CreateScheduleRequest{AnalysisID: manual.ID, Cron: "@daily"}, ErrRerunNotSupported},
+		{dto.CreateScheduleRequest{AnalysisID: 99999, Cron: "@daily"}, ErrAnalysisNotFound},
+	}
+	for _, tt := range tests {
+		_, err := service.Create(user.ID, &tt.req)
+		assert.ErrorIs(t, err, tt.want, tt.req.Cron)
+	}
+
+	for i := 0; i < maxSchedulesPerAnalysis; i++ {
+		_, err := service.Create(user.ID, &dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "@daily"})
+		require.NoError(t, err)
+	}
+	_, err := service.Create(user.ID, &dto.CreateScheduleRequest{AnalysisID: analysis.ID, Cron: "@daily"})
+	assert.ErrorIs(t, err, ErrScheduleLimitReached)
+}
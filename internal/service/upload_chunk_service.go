@@ -0,0 +1,327 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+)
+
+var (
+	ErrChunkSessionNotFound = fmt.Errorf("分片上传会话不存在或已过期")
+	ErrChunkInvalid         = fmt.Errorf("分片编号或大小无效")
+	ErrChunkChecksum        = fmt.Errorf("分片校验失败")
+	ErrChunksIncomplete     = fmt.Errorf("分片尚未全部上传")
+	ErrFileChecksum         = fmt.Errorf("文件校验失败")
+	ErrChunkCompleting      = fmt.Errorf("分片正在合并，请勿重复提交")
+)
+
+const (
+	// ChunkSessionDir 分片上传会话所在的子目录（位于 Upload.TempDir 下）
+	ChunkSessionDir = "chunks"
+
+	defaultChunkSize = 5 << 20 // 默认分片大小 5MB
+
+	chunkSessionFile = "session.json"
+	chunkPartSuffix  = ".part"
+	chunkAssembled   = "assembled"
+	chunkCompleting  = "completing" // 合并期间存在的标记文件，保证同一会话只合并一次
+)
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// chunkSession 分片上传会话元数据，保存在会话目录的 session.json 中
+type chunkSession struct {
+	UserID     int64     `json:"user_id"`
+	Filename   string    `json:"filename"`
+	TotalSize  int64     `json:"total_size"`
+	ChunkSize  int64     `json:"chunk_size"`
+	TotalParts int       `json:"total_parts"`
+	SHA256     string    `json:"sha256,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	lastActive time.Time // 会话目录的修改时间，即最后一次上传分片的时间
+}
+
+// partSize 返回第 n 个分片（从 1 开始）应有的大小
+func (cs *chunkSession) partSize(n int) int64 {
+	if n < cs.TotalParts {
+		return cs.ChunkSize
+	}
+	return cs.TotalSize - int64(cs.TotalParts-1)*cs.ChunkSize
+}
+
+// InitChunkedUpload 创建分片上传会话
+func (s *UploadService) InitChunkedUpload(userID int64, req *dto.InitChunkedUploadRequest) (*dto.ChunkedUploadStatus, error) {
+	if req.TotalSize > s.cfg.Upload.MaxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	sessionID, err := generateUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := s.chunkSize()
+	session := &chunkSession{
+		UserID:     userID,
+		Filename:   req.Filename,
+		TotalSize:  req.TotalSize,
+		ChunkSize:  chunkSize,
+		TotalParts: int((req.TotalSize + chunkSize - 1) / chunkSize),
+		SHA256:     strings.ToLower(req.SHA256),
+		CreatedAt:  time.Now(),
+		lastActive: time.Now(),
+	}
+
+	dir := s.chunkSessionPath(sessionID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, chunkSessionFile), data, 0644); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return s.chunkStatus(sessionID, session, nil), nil
+}
+
+// UploadChunk 上传第 partNumber 个分片（从 1 开始），checksum 为分片内容的 SHA-256（十六进制）
+// 同一分片可重复上传，后一次覆盖前一次，便于断点续传
+func (s *UploadService) UploadChunk(userID int64, sessionID string, partNumber int, checksum string, r io.Reader) error {
+	session, err := s.loadChunkSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if partNumber < 1 || partNumber > session.TotalParts {
+		return ErrChunkInvalid
+	}
+
+	dir := s.chunkSessionPath(sessionID)
+	// 合并期间不再接收分片，避免改动正在拼接的文件
+	if _, err := os.Stat(filepath.Join(dir, chunkCompleting)); err == nil {
+		return ErrChunkCompleting
+	}
+	tmp, err := os.CreateTemp(dir, "incoming-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// 多读一个字节，用于发现超长分片
+	expected := session.partSize(partNumber)
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, expected+1))
+	if err != nil {
+		return err
+	}
+	if written != expected {
+		return ErrChunkInvalid
+	}
+	if !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), checksum) {
+		return ErrChunkChecksum
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, strconv.Itoa(partNumber)+chunkPartSuffix))
+}
+
+// GetChunkedUpload 查询分片上传会话及已接收的分片
+func (s *UploadService) GetChunkedUpload(userID int64, sessionID string) (*dto.ChunkedUploadStatus, error) {
+	session, err := s.loadChunkSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	received, err := s.receivedParts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.chunkStatus(sessionID, session, received), nil
+}
+
+// CompleteChunkedUpload 合并全部分片，并交给 ParseZip 解析
+func (s *UploadService) CompleteChunkedUpload(userID int64, sessionID string) (*dto.ParseUploadResponse, error) {
+	session, err := s.loadChunkSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	received, err := s.receivedParts(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(received) != session.TotalParts {
+		return nil, ErrChunksIncomplete
+	}
+
+	// 先创建标记文件占用会话，并发的合并请求只有一个能继续，失败时删除标记以便重试
+	dir := s.chunkSessionPath(sessionID)
+	marker, err := os.OpenFile(filepath.Join(dir, chunkCompleting), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrExist):
+			return nil, ErrChunkCompleting
+		case errors.Is(err, os.ErrNotExist):
+			// 另一个请求已经合并完成并删除了会话目录
+			return nil, ErrChunkSessionNotFound
+		}
+		return nil, err
+	}
+	marker.Close()
+	release := func() {
+		os.Remove(filepath.Join(dir, chunkAssembled))
+		os.Remove(filepath.Join(dir, chunkCompleting))
+	}
+
+	assembledPath := filepath.Join(dir, chunkAssembled)
+	if err := s.assembleChunks(dir, session, assembledPath); err != nil {
+		release()
+		return nil, err
+	}
+
+	resp, err := s.ParseZip(userID, assembledPath, session.Filename)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	os.RemoveAll(dir)
+	return resp, nil
+}
+
+// assembleChunks 按顺序拼接分片并校验整体大小和哈希
+func (s *UploadService) assembleChunks(dir string, session *chunkSession, destPath string) error {
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	hash := sha256.New()
+	w := io.MultiWriter(dest, hash)
+	var total int64
+	for n := 1; n <= session.TotalParts; n++ {
+		part, err := os.Open(filepath.Join(dir, strconv.Itoa(n)+chunkPartSuffix))
+		if err != nil {
+			return err
+		}
+		written, err := io.Copy(w, part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+		total += written
+	}
+
+	if total != session.TotalSize {
+		return ErrChunkInvalid
+	}
+	if session.SHA256 != "" && hex.EncodeToString(hash.Sum(nil)) != session.SHA256 {
+		return ErrFileChecksum
+	}
+	return dest.Close()
+}
+
+// loadChunkSession 读取会话元数据，会话不存在、已过期或不属于该用户时统一返回 ErrChunkSessionNotFound
+func (s *UploadService) loadChunkSession(userID int64, sessionID string) (*chunkSession, error) {
+	if !uploadIDPattern.MatchString(sessionID) {
+		return nil, ErrChunkSessionNotFound
+	}
+
+	dir := s.chunkSessionPath(sessionID)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, ErrChunkSessionNotFound
+	}
+	// 过期时间从最后一次上传分片算起，与 cron 清理的判断保持一致
+	if time.Since(info.ModTime()) > s.uploadExpire() {
+		return nil, ErrChunkSessionNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, chunkSessionFile))
+	if err != nil {
+		return nil, ErrChunkSessionNotFound
+	}
+	var session chunkSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, ErrChunkSessionNotFound
+	}
+	if session.UserID != userID {
+		return nil, ErrChunkSessionNotFound
+	}
+	session.lastActive = info.ModTime()
+
+	return &session, nil
+}
+
+// receivedParts 列出已接收的分片编号（升序）
+func (s *UploadService) receivedParts(sessionID string) ([]int, error) {
+	entries, err := os.ReadDir(s.chunkSessionPath(sessionID))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, chunkPartSuffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(name, chunkPartSuffix))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, n)
+	}
+	sort.Ints(parts)
+	return parts, nil
+}
+
+func (s *UploadService) chunkStatus(sessionID string, session *chunkSession, received []int) *dto.ChunkedUploadStatus {
+	if received == nil {
+		received = []int{}
+	}
+	return &dto.ChunkedUploadStatus{
+		SessionID:     sessionID,
+		Filename:      session.Filename,
+		TotalSize:     session.TotalSize,
+		ChunkSize:     session.ChunkSize,
+		TotalParts:    session.TotalParts,
+		ReceivedParts: received,
+		ExpiresAt:     session.lastActive.Add(s.uploadExpire()).Format(time.RFC3339),
+	}
+}
+
+func (s *UploadService) chunkSessionPath(sessionID string) string {
+	return filepath.Join(s.cfg.Upload.TempDir, ChunkSessionDir, sessionID)
+}
+
+func (s *UploadService) chunkSize() int64 {
+	if s.cfg.Upload.ChunkSize > 0 {
+		return s.cfg.Upload.ChunkSize
+	}
+	return defaultChunkSize
+}
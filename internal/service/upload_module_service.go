@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/goproxy"
+)
+
+var (
+	ErrInvalidModule   = fmt.Errorf("模块路径或版本无效，格式如 github.com/foo/bar@v1.2.3")
+	ErrModuleNotFound  = fmt.Errorf("模块版本不存在")
+	ErrModuleChecksum  = fmt.Errorf("模块校验和不匹配")
+	ErrModuleDownload  = fmt.Errorf("模块下载失败")
+	ErrUploadNotModule = fmt.Errorf("该上传不是从模块代理下载的")
+)
+
+const defaultGoProxyTimeout = 120 // 默认下载超时（秒）
+
+// ImportModule 从 GOPROXY 下载模块 zip，校验 go.sum 哈希后按上传流程解析
+func (s *UploadService) ImportModule(ctx context.Context, userID int64, req *dto.ImportModuleRequest) (*dto.ParseUploadResponse, error) {
+	path, version, err := goproxy.ParseModuleRef(req.Module)
+	if err != nil {
+		return nil, ErrInvalidModule
+	}
+
+	tempFile, err := os.CreateTemp("", "module-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	if _, err := s.goproxy.DownloadZip(ctx, path, version, tempFile, s.cfg.Upload.MaxSize); err != nil {
+		switch {
+		case errors.Is(err, goproxy.ErrInvalidModule):
+			return nil, ErrInvalidModule
+		case errors.Is(err, goproxy.ErrNotFound):
+			return nil, ErrModuleNotFound
+		case errors.Is(err, goproxy.ErrTooLarge):
+			return nil, ErrFileTooLarge
+		default:
+			return nil, fmt.Errorf("%w: %v", ErrModuleDownload, err)
+		}
+	}
+	if err := tempFile.Close(); err != nil {
+		return nil, err
+	}
+
+	// 计算哈希前按解压限制检查，避免恶意代理返回的压缩炸弹在校验阶段耗尽资源
+	sum, err := goproxy.VerifyZip(tempFile.Name(), req.Sum, s.archiveLimits())
+	if err != nil {
+		if errors.Is(err, goproxy.ErrChecksumMismatch) {
+			return nil, ErrModuleChecksum
+		}
+		return nil, archiveError(err)
+	}
+
+	module := path + "@" + version
+	resp, err := s.parseArchive(userID, tempFile.Name(), module+".zip", module)
+	if err != nil {
+		return nil, err
+	}
+	resp.ModuleSum = sum
+	return resp, nil
+}
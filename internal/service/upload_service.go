@@ -1,9 +1,10 @@
 package service
 
 import (
-	"archive/zip"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -14,41 +15,86 @@ import (
 	"strings"
 	"time"
 
+	"gorm.io/gorm"
+
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/archive"
+	"github.com/qs3c/anal_go_server/internal/pkg/goproxy"
+	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
 var (
-	ErrInvalidZip     = fmt.Errorf("ZIP 文件损坏或无法解压")
-	ErrNoGoFiles      = fmt.Errorf("未找到 Go 源文件")
-	ErrFileTooLarge   = fmt.Errorf("文件过大")
-	ErrInvalidFormat  = fmt.Errorf("仅支持 ZIP 格式")
-	ErrUploadNotFound = fmt.Errorf("上传文件不存在或已过期")
+	ErrInvalidArchive   = fmt.Errorf("压缩包损坏或无法解压")
+	ErrNoGoFiles        = fmt.Errorf("未找到 Go 源文件")
+	ErrFileTooLarge     = fmt.Errorf("文件过大")
+	ErrInvalidFormat    = fmt.Errorf("仅支持 ZIP、TAR、TAR.GZ 格式")
+	ErrUnsafeArchive    = fmt.Errorf("压缩包包含非法路径、链接或设备文件")
+	ErrArchiveTooLarge  = fmt.Errorf("压缩包解压后体积过大")
+	ErrArchiveTooMany   = fmt.Errorf("压缩包内文件数量过多")
+	ErrArchiveTooDeep   = fmt.Errorf("压缩包目录层级过深")
+	ErrArchiveBomb      = fmt.Errorf("压缩包压缩比异常，疑似压缩炸弹")
+	ErrUploadNotFound   = fmt.Errorf("上传文件不存在或已过期")
+	ErrUploadPermission = fmt.Errorf("无权使用此上传文件")
+)
+
+const (
+	defaultMaxEntrySize        = 20 << 20  // 单个文件解压后默认上限 20MB
+	defaultMaxExtractedSize    = 512 << 20 // 解压后总大小默认上限 512MB
+	defaultMaxEntries          = 50000     // 默认条目数量上限
+	defaultMaxDepth            = 32        // 默认目录层级上限
+	defaultMaxCompressionRatio = 100       // 默认压缩比上限
+
+	// maxSkippedReport 响应中最多列出的跳过条目数，完整数量见 SkippedCount
+	maxSkippedReport = 100
 )
 
 type UploadService struct {
-	cfg *config.Config
+	uploadRepo *repository.UploadRepository
+	goproxy    *goproxy.Client
+	cfg        *config.Config
 }
 
-func NewUploadService(cfg *config.Config) *UploadService {
-	return &UploadService{cfg: cfg}
+func NewUploadService(uploadRepo *repository.UploadRepository, cfg *config.Config) *UploadService {
+	timeout := cfg.GoProxy.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultGoProxyTimeout
+	}
+	return &UploadService{
+		uploadRepo: uploadRepo,
+		goproxy:    goproxy.NewClient(cfg.GoProxy.URL, time.Duration(timeout)*time.Second),
+		cfg:        cfg,
+	}
 }
 
-// ParseZip 解析 ZIP 文件，提取 Go 文件和结构体信息
-func (s *UploadService) ParseZip(zipPath string) (*dto.ParseUploadResponse, error) {
+// ParseZip 解析上传的压缩包（ZIP / TAR / TAR.GZ，按文件内容识别），提取 Go 文件和结构体信息
+// 解析成功后记录上传者、大小、哈希和过期时间
+func (s *UploadService) ParseZip(userID int64, zipPath, filename string) (*dto.ParseUploadResponse, error) {
+	return s.parseArchive(userID, zipPath, filename, "")
+}
+
+// parseArchive 解压并解析压缩包，module 非空表示来自 GOPROXY 下载
+func (s *UploadService) parseArchive(userID int64, zipPath, filename, module string) (*dto.ParseUploadResponse, error) {
 	uploadID, err := generateUploadID()
 	if err != nil {
 		return nil, err
 	}
 
+	size, hash, err := fileDigest(zipPath)
+	if err != nil {
+		return nil, err
+	}
+
 	extractDir := filepath.Join(s.cfg.Upload.TempDir, uploadID)
 	if err := os.MkdirAll(extractDir, 0755); err != nil {
 		return nil, err
 	}
 
-	if err := s.extractZip(zipPath, extractDir); err != nil {
+	extracted, err := s.extractArchive(zipPath, extractDir)
+	if err != nil {
 		os.RemoveAll(extractDir)
-		return nil, ErrInvalidZip
+		return nil, err
 	}
 
 	files, err := s.scanGoFiles(extractDir)
@@ -67,19 +113,82 @@ func (s *UploadService) ParseZip(zipPath string) (*dto.ParseUploadResponse, erro
 		totalStructs += len(f.Structs)
 	}
 
-	expiresAt := time.Now().Add(time.Duration(s.cfg.Upload.ExpireHours) * time.Hour)
+	expiresAt := time.Now().Add(s.uploadExpire())
+
+	if s.uploadRepo != nil {
+		upload := &model.Upload{
+			ID:            uploadID,
+			UserID:        userID,
+			Filename:      filename,
+			Size:          size,
+			ExtractedSize: extracted.Bytes,
+			SHA256:        hash,
+			Module:        module,
+			Status:        "active",
+			ExpiresAt:     expiresAt,
+		}
+		if err := s.uploadRepo.Create(upload); err != nil {
+			os.RemoveAll(extractDir)
+			return nil, err
+		}
+	}
 
-	return &dto.ParseUploadResponse{
+	resp := &dto.ParseUploadResponse{
 		UploadID:     uploadID,
+		Module:       module,
 		ExpiresAt:    expiresAt.Format(time.RFC3339),
 		Files:        files,
 		TotalFiles:   len(files),
 		TotalStructs: totalStructs,
-	}, nil
+		SkippedCount: len(extracted.Skipped),
+	}
+	for i, skipped := range extracted.Skipped {
+		if i >= maxSkippedReport {
+			break
+		}
+		resp.Skipped = append(resp.Skipped, dto.SkippedFile{
+			Path:   skipped.Path,
+			Reason: skipped.Reason,
+		})
+	}
+
+	return resp, nil
 }
 
-// GetUploadPath 获取上传文件的路径
-func (s *UploadService) GetUploadPath(uploadID string) (string, error) {
+// GetUpload 获取上传记录，只有上传者本人可以使用，已过期的记录视为不存在
+func (s *UploadService) GetUpload(userID int64, uploadID string) (*model.Upload, error) {
+	if s.uploadRepo == nil || !uploadIDPattern.MatchString(uploadID) {
+		return nil, ErrUploadNotFound
+	}
+
+	upload, err := s.uploadRepo.GetByID(uploadID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if upload.UserID != userID {
+		return nil, ErrUploadPermission
+	}
+	if upload.Status != "active" || time.Now().After(upload.ExpiresAt) {
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// GetUploadPath 获取上传文件的路径，只有上传者本人可以使用
+func (s *UploadService) GetUploadPath(userID int64, uploadID string) (string, error) {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return "", ErrUploadNotFound
+	}
+
+	if s.uploadRepo != nil {
+		if _, err := s.GetUpload(userID, uploadID); err != nil {
+			return "", err
+		}
+	}
+
 	path := filepath.Join(s.cfg.Upload.TempDir, uploadID)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return "", ErrUploadNotFound
@@ -87,54 +196,95 @@ func (s *UploadService) GetUploadPath(uploadID string) (string, error) {
 	return path, nil
 }
 
+// ExtendUpload 延长上传文件的有效期，避免任务排队期间文件被清理
+func (s *UploadService) ExtendUpload(uploadID string) error {
+	if s.uploadRepo == nil {
+		return nil
+	}
+	return s.uploadRepo.ExtendExpiry(uploadID, time.Now().Add(s.uploadExpire()))
+}
+
 // CleanupUpload 清理上传的文件
 func (s *UploadService) CleanupUpload(uploadID string) error {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return ErrUploadNotFound
+	}
 	path := filepath.Join(s.cfg.Upload.TempDir, uploadID)
-	return os.RemoveAll(path)
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if s.uploadRepo != nil {
+		return s.uploadRepo.MarkExpired(uploadID)
+	}
+	return nil
 }
 
-func (s *UploadService) extractZip(zipPath, destDir string) error {
-	r, err := zip.OpenReader(zipPath)
+// extractArchive 解压压缩包，并把底层错误转换为面向用户的错误
+func (s *UploadService) extractArchive(archivePath, destDir string) (*archive.Result, error) {
+	result, err := archive.Extract(archivePath, destDir, s.archiveLimits())
 	if err != nil {
-		return err
+		return nil, archiveError(err)
 	}
-	defer r.Close()
-
-	for _, f := range r.File {
-		destPath := filepath.Join(destDir, f.Name)
-		// Security: prevent zip slip attack
-		if !strings.HasPrefix(destPath, filepath.Clean(destDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("invalid file path: %s", f.Name)
-		}
-
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(destPath, 0755)
-			continue
-		}
-
-		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-			return err
-		}
+	return result, nil
+}
 
-		destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return err
-		}
+// archiveError 把 archive 包的错误转换为面向用户的错误
+func archiveError(err error) error {
+	switch {
+	case errors.Is(err, archive.ErrUnsupportedFormat):
+		return ErrInvalidFormat
+	case errors.Is(err, archive.ErrUnsafePath),
+		errors.Is(err, archive.ErrSymlink),
+		errors.Is(err, archive.ErrSpecialFile):
+		return ErrUnsafeArchive
+	case errors.Is(err, archive.ErrEntryTooLarge), errors.Is(err, archive.ErrTooLarge):
+		return ErrArchiveTooLarge
+	case errors.Is(err, archive.ErrTooManyEntries):
+		return ErrArchiveTooMany
+	case errors.Is(err, archive.ErrTooDeep):
+		return ErrArchiveTooDeep
+	case errors.Is(err, archive.ErrCompressionRatio):
+		return ErrArchiveBomb
+	default:
+		return ErrInvalidArchive
+	}
+}
 
-		srcFile, err := f.Open()
-		if err != nil {
-			destFile.Close()
-			return err
-		}
+// archiveLimits 读取解压限制，未配置的项使用默认值
+func (s *UploadService) archiveLimits() archive.Limits {
+	cfg := s.cfg.Upload
+	limits := archive.Limits{
+		MaxEntrySize:        cfg.MaxEntrySize,
+		MaxTotalSize:        cfg.MaxExtractedSize,
+		MaxEntries:          cfg.MaxEntries,
+		MaxDepth:            cfg.MaxDepth,
+		MaxCompressionRatio: cfg.MaxCompressionRatio,
+	}
+	if limits.MaxEntrySize <= 0 {
+		limits.MaxEntrySize = defaultMaxEntrySize
+	}
+	if limits.MaxTotalSize <= 0 {
+		limits.MaxTotalSize = defaultMaxExtractedSize
+	}
+	if limits.MaxEntries <= 0 {
+		limits.MaxEntries = defaultMaxEntries
+	}
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = defaultMaxDepth
+	}
+	if limits.MaxCompressionRatio <= 0 {
+		limits.MaxCompressionRatio = defaultMaxCompressionRatio
+	}
+	return limits
+}
 
-		_, err = io.Copy(destFile, srcFile)
-		srcFile.Close()
-		destFile.Close()
-		if err != nil {
-			return err
-		}
+// uploadExpire 上传文件的有效期，未配置时为 1 小时
+func (s *UploadService) uploadExpire() time.Duration {
+	hours := s.cfg.Upload.ExpireHours
+	if hours <= 0 {
+		hours = 1
 	}
-	return nil
+	return time.Duration(hours) * time.Hour
 }
 
 func (s *UploadService) scanGoFiles(rootDir string) ([]dto.GoFileInfo, error) {
@@ -192,6 +342,22 @@ func (s *UploadService) parseStructs(filePath string) ([]string, error) {
 	return structs, nil
 }
 
+// fileDigest 计算文件大小和 SHA-256
+func fileDigest(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func generateUploadID() (string, error) {
 	bytes := make([]byte, 16)
 	if _, err := rand.Read(bytes); err != nil {
@@ -1,13 +1,30 @@
 package service
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/archive"
+	"github.com/qs3c/anal_go_server/internal/pkg/goproxy"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
 )
 
 func TestNewUploadService(t *testing.T) {
@@ -20,7 +37,7 @@ func TestNewUploadService(t *testing.T) {
 		},
 	}
 
-	svc := NewUploadService(cfg)
+	svc := NewUploadService(nil, cfg)
 	assert.NotNil(t, svc)
 }
 
@@ -33,7 +50,7 @@ func TestUploadService_ParseZip(t *testing.T) {
 			AllowedExtensions: []string{".zip"},
 		},
 	}
-	svc := NewUploadService(cfg)
+	svc := NewUploadService(nil, cfg)
 
 	// 创建测试 ZIP 文件
 	zipPath := createTestZip(t, map[string]string{
@@ -61,7 +78,7 @@ type UserProfile struct {
 `,
 	})
 
-	result, err := svc.ParseZip(zipPath)
+	result, err := svc.ParseZip(1, zipPath, "test.zip")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, result.UploadID)
 	assert.Equal(t, 2, result.TotalFiles)
@@ -77,6 +94,425 @@ type UserProfile struct {
 	assert.ElementsMatch(t, []string{"User", "UserProfile"}, fileMap["internal/model/user.go"])
 }
 
+func TestUploadService_ParseZip_TarGz(t *testing.T) {
+	cfg := &config.Config{
+		Upload: config.UploadConfig{
+			TempDir:     t.TempDir(),
+			ExpireHours: 1,
+		},
+	}
+	svc := NewUploadService(nil, cfg)
+
+	// 扩展名与内容无关，按内容识别
+	archivePath := createTestTarGz(t, "upload.bin", map[string]string{
+		"project/go.mod":  "module example.com/project",
+		"project/main.go": "package main\n\ntype App struct {\n\tName string\n}\n",
+	})
+
+	result, err := svc.ParseZip(1, archivePath, "project.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TotalFiles)
+	assert.Equal(t, "project/main.go", result.Files[0].Path)
+	assert.Equal(t, []string{"App"}, result.Files[0].Structs)
+}
+
+func TestUploadService_ParseZip_InvalidFormat(t *testing.T) {
+	cfg := &config.Config{Upload: config.UploadConfig{TempDir: t.TempDir()}}
+	svc := NewUploadService(nil, cfg)
+
+	path := filepath.Join(t.TempDir(), "test.zip")
+	if err := os.WriteFile(path, []byte("definitely not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := svc.ParseZip(1, path, "test.txt")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestUploadService_ParseZip_UnsafeArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{Upload: config.UploadConfig{TempDir: tempDir}}
+	svc := NewUploadService(nil, cfg)
+
+	zipPath := createTestZip(t, map[string]string{
+		"../escape.go": "package main\n\ntype Evil struct{}\n",
+	})
+
+	_, err := svc.ParseZip(1, zipPath, "test.zip")
+	assert.ErrorIs(t, err, ErrUnsafeArchive)
+
+	// 解压失败后临时目录应被清理
+	entries, _ := os.ReadDir(tempDir)
+	assert.Empty(t, entries)
+}
+
+func TestUploadService_ParseZip_TooLarge(t *testing.T) {
+	cfg := &config.Config{
+		Upload: config.UploadConfig{
+			TempDir:      t.TempDir(),
+			MaxEntrySize: 16,
+		},
+	}
+	svc := NewUploadService(nil, cfg)
+
+	zipPath := createTestZip(t, map[string]string{
+		"main.go": "package main\n\ntype App struct{}\n",
+	})
+
+	_, err := svc.ParseZip(1, zipPath, "test.zip")
+	assert.ErrorIs(t, err, ErrArchiveTooLarge)
+}
+
+func TestUploadService_ParseZip_ReportsSkipped(t *testing.T) {
+	cfg := &config.Config{Upload: config.UploadConfig{TempDir: t.TempDir()}}
+	svc := NewUploadService(nil, cfg)
+
+	zipPath := createTestZip(t, map[string]string{
+		"main.go":                     "package main\n\ntype App struct{}\n",
+		"vendor/example.com/lib/a.go": "package lib\n\ntype Lib struct{}\n",
+		".git/HEAD":                   "ref: refs/heads/main",
+	})
+
+	result, err := svc.ParseZip(1, zipPath, "test.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.TotalFiles)
+	assert.Equal(t, 1, result.TotalStructs)
+	assert.Equal(t, 2, result.SkippedCount)
+	assert.ElementsMatch(t, []dto.SkippedFile{
+		{Path: "vendor/", Reason: "vendor"},
+		{Path: ".git/", Reason: "vcs"},
+	}, result.Skipped)
+}
+
+// createTestTarGz 创建测试用 tar.gz 文件
+func TestUploadService_ParseZip_RecordsUpload(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	uploadRepo := repository.NewUploadRepository(db)
+	cfg := &config.Config{Upload: config.UploadConfig{TempDir: t.TempDir(), ExpireHours: 2}}
+	svc := NewUploadService(uploadRepo, cfg)
+
+	zipPath := createTestZip(t, map[string]string{
+		"main.go": "package main\n\ntype App struct{}\n",
+	})
+	data, err := os.ReadFile(zipPath)
+	assert.NoError(t, err)
+
+	result, err := svc.ParseZip(7, zipPath, "app.zip")
+	assert.NoError(t, err)
+
+	upload, err := uploadRepo.GetByID(result.UploadID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), upload.UserID)
+	assert.Equal(t, "app.zip", upload.Filename)
+	assert.Equal(t, int64(len(data)), upload.Size)
+	assert.Equal(t, chunkChecksum(data), upload.SHA256)
+	assert.Equal(t, "active", upload.Status)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), upload.ExpiresAt, time.Minute)
+
+	path, err := svc.GetUploadPath(7, result.UploadID)
+	assert.NoError(t, err)
+	assert.DirExists(t, path)
+
+	_, err = svc.GetUploadPath(8, result.UploadID)
+	assert.ErrorIs(t, err, ErrUploadPermission)
+
+	_, err = svc.GetUploadPath(7, "../"+result.UploadID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	// 清理后记录标记为 expired，不能再使用
+	assert.NoError(t, svc.CleanupUpload(result.UploadID))
+	assert.NoDirExists(t, path)
+	_, err = svc.GetUploadPath(7, result.UploadID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestUploadService_ImportModule(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	zipPath := createTestZip(t, map[string]string{
+		"example.com/lib@v1.2.3/go.mod": "module example.com/lib\n",
+		"example.com/lib@v1.2.3/lib.go": "package lib\n\ntype Client struct{}\n",
+	})
+	data, err := os.ReadFile(zipPath)
+	assert.NoError(t, err)
+	sum, err := goproxy.HashZip(zipPath, archive.Limits{})
+	assert.NoError(t, err)
+
+	// 本地 GOPROXY 桩
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.com/lib/@v/v1.2.3.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer proxy.Close()
+
+	uploadRepo := repository.NewUploadRepository(db)
+	cfg := &config.Config{
+		Upload:  config.UploadConfig{MaxSize: 104857600, TempDir: t.TempDir(), ExpireHours: 1},
+		GoProxy: config.GoProxyConfig{URL: proxy.URL, TimeoutSeconds: 5},
+	}
+	svc := NewUploadService(uploadRepo, cfg)
+	ctx := context.Background()
+
+	result, err := svc.ImportModule(ctx, 1, &dto.ImportModuleRequest{Module: "example.com/lib@v1.2.3", Sum: sum})
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com/lib@v1.2.3", result.Module)
+	assert.Equal(t, sum, result.ModuleSum)
+	assert.Equal(t, 1, result.TotalFiles)
+	assert.Equal(t, "example.com/lib@v1.2.3/lib.go", result.Files[0].Path)
+
+	upload, err := svc.GetUpload(1, result.UploadID)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com/lib@v1.2.3", upload.Module)
+
+	_, err = svc.ImportModule(ctx, 1, &dto.ImportModuleRequest{
+		Module: "example.com/lib@v1.2.3",
+		Sum:    "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	})
+	assert.ErrorIs(t, err, ErrModuleChecksum)
+
+	_, err = svc.ImportModule(ctx, 1, &dto.ImportModuleRequest{Module: "example.com/lib@v9.9.9", Sum: sum})
+	assert.ErrorIs(t, err, ErrModuleNotFound)
+
+	_, err = svc.ImportModule(ctx, 1, &dto.ImportModuleRequest{Module: "example.com/lib@latest", Sum: sum})
+	assert.ErrorIs(t, err, ErrInvalidModule)
+
+	cfg.Upload.MaxSize = 10
+	_, err = svc.ImportModule(ctx, 1, &dto.ImportModuleRequest{Module: "example.com/lib@v1.2.3", Sum: sum})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+}
+
+func TestUploadService_ChunkedUpload(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{
+		Upload: config.UploadConfig{
+			MaxSize:     104857600,
+			ChunkSize:   100,
+			TempDir:     tempDir,
+			ExpireHours: 1,
+		},
+	}
+	svc := NewUploadService(nil, cfg)
+
+	data, err := os.ReadFile(createTestZip(t, map[string]string{
+		"main.go": "package main\n\ntype App struct {\n\tName string\n}\n",
+	}))
+	assert.NoError(t, err)
+	fileSum := sha256.Sum256(data)
+
+	status, err := svc.InitChunkedUpload(1, &dto.InitChunkedUploadRequest{
+		Filename:  "project.zip",
+		TotalSize: int64(len(data)),
+		SHA256:    hex.EncodeToString(fileSum[:]),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), status.ChunkSize)
+	assert.Equal(t, (len(data)+99)/100, status.TotalParts)
+	assert.Empty(t, status.ReceivedParts)
+
+	chunks := splitChunks(data, 100)
+
+	// 倒序上传，模拟断点续传时分片乱序到达
+	for i := len(chunks) - 1; i >= 1; i-- {
+		err := svc.UploadChunk(1, status.SessionID, i+1, chunkChecksum(chunks[i]), bytes.NewReader(chunks[i]))
+		assert.NoError(t, err)
+	}
+
+	_, err = svc.CompleteChunkedUpload(1, status.SessionID)
+	assert.ErrorIs(t, err, ErrChunksIncomplete)
+
+	got, err := svc.GetChunkedUpload(1, status.SessionID)
+	assert.NoError(t, err)
+	assert.Len(t, got.ReceivedParts, len(chunks)-1)
+	assert.NotContains(t, got.ReceivedParts, 1)
+
+	err = svc.UploadChunk(1, status.SessionID, 1, chunkChecksum(chunks[0]), bytes.NewReader(chunks[0]))
+	assert.NoError(t, err)
+
+	resp, err := svc.CompleteChunkedUpload(1, status.SessionID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, resp.TotalFiles)
+	assert.Equal(t, 1, resp.TotalStructs)
+
+	// 合并后会话目录被删除
+	_, err = os.Stat(filepath.Join(tempDir, ChunkSessionDir, status.SessionID))
+	assert.True(t, os.IsNotExist(err))
+	_, err = svc.GetChunkedUpload(1, status.SessionID)
+	assert.ErrorIs(t, err, ErrChunkSessionNotFound)
+}
+
+func TestUploadService_UploadChunk_Invalid(t *testing.T) {
+	cfg := &config.Config{
+		Upload: config.UploadConfig{MaxSize: 1000, ChunkSize: 10, TempDir: t.TempDir(), ExpireHours: 1},
+	}
+	svc := NewUploadService(nil, cfg)
+
+	status, err := svc.InitChunkedUpload(1, &dto.InitChunkedUploadRequest{Filename: "a.zip", TotalSize: 25})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, status.TotalParts)
+
+	chunk := []byte("0123456789")
+
+	// 校验值不匹配
+	err = svc.UploadChunk(1, status.SessionID, 1, chunkChecksum([]byte("other")), bytes.NewReader(chunk))
+	assert.ErrorIs(t, err, ErrChunkChecksum)
+
+	// 分片编号越界
+	err = svc.UploadChunk(1, status.SessionID, 4, chunkChecksum(chunk), bytes.NewReader(chunk))
+	assert.ErrorIs(t, err, ErrChunkInvalid)
+
+	// 最后一个分片应为 5 字节
+	err = svc.UploadChunk(1, status.SessionID, 3, chunkChecksum(chunk), bytes.NewReader(chunk))
+	assert.ErrorIs(t, err, ErrChunkInvalid)
+
+	// 其他用户无法访问该会话
+	err = svc.UploadChunk(2, status.SessionID, 1, chunkChecksum(chunk), bytes.NewReader(chunk))
+	assert.ErrorIs(t, err, ErrChunkSessionNotFound)
+
+	// 非法会话 ID
+	_, err = svc.GetChunkedUpload(1, "../../etc")
+	assert.ErrorIs(t, err, ErrChunkSessionNotFound)
+
+	got, err := svc.GetChunkedUpload(1, status.SessionID)
+	assert.NoError(t, err)
+	assert.Empty(t, got.ReceivedParts)
+}
+
+func TestUploadService_InitChunkedUpload_TooLarge(t *testing.T) {
+	cfg := &config.Config{Upload: config.UploadConfig{MaxSize: 100, TempDir: t.TempDir()}}
+	svc := NewUploadService(nil, cfg)
+
+	_, err := svc.InitChunkedUpload(1, &dto.InitChunkedUploadRequest{Filename: "a.zip", TotalSize: 101})
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+}
+
+func TestUploadService_CompleteChunkedUpload_FileChecksum(t *testing.T) {
+	cfg := &config.Config{
+		Upload: config.UploadConfig{MaxSize: 1000, ChunkSize: 10, TempDir: t.TempDir(), ExpireHours: 1},
+	}
+	svc := NewUploadService(nil, cfg)
+
+	data := []byte("not the expected file")
+	status, err := svc.InitChunkedUpload(1, &dto.InitChunkedUploadRequest{
+		Filename:  "a.zip",
+		TotalSize: int64(len(data)),
+		SHA256:    chunkChecksum([]byte("something else")),
+	})
+	assert.NoError(t, err)
+
+	for i, chunk := range splitChunks(data, 10) {
+		assert.NoError(t, svc.UploadChunk(1, status.SessionID, i+1, chunkChecksum(chunk), bytes.NewReader(chunk)))
+	}
+
+	_, err = svc.CompleteChunkedUpload(1, status.SessionID)
+	assert.ErrorIs(t, err, ErrFileChecksum)
+	// 失败后释放会话，可以再次提交
+	_, err = svc.CompleteChunkedUpload(1, status.SessionID)
+	assert.ErrorIs(t, err, ErrFileChecksum)
+}
+
+func TestUploadService_CompleteChunkedUpload_Concurrent(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := &config.Config{
+		Upload: config.UploadConfig{MaxSize: 104857600, ChunkSize: 100, TempDir: tempDir, ExpireHours: 1},
+	}
+	svc := NewUploadService(nil, cfg)
+
+	data, err := os.ReadFile(createTestZip(t, map[string]string{
+		"main.go": "package main\n\ntype App struct {\n\tName string\n}\n",
+	}))
+	require.NoError(t, err)
+	status, err := svc.InitChunkedUpload(1, &dto.InitChunkedUploadRequest{Filename: "project.zip", TotalSize: int64(len(data))})
+	require.NoError(t, err)
+	for i, chunk := range splitChunks(data, 100) {
+		require.NoError(t, svc.UploadChunk(1, status.SessionID, i+1, chunkChecksum(chunk), bytes.NewReader(chunk)))
+	}
+
+	// 合并进行中时拒绝重复提交和继续上传分片
+	marker := filepath.Join(tempDir, ChunkSessionDir, status.SessionID, chunkCompleting)
+	require.NoError(t, os.WriteFile(marker, nil, 0644))
+	_, err = svc.CompleteChunkedUpload(1, status.SessionID)
+	assert.ErrorIs(t, err, ErrChunkCompleting)
+	chunk := data[:100]
+	assert.ErrorIs(t, svc.UploadChunk(1, status.SessionID, 1, chunkChecksum(chunk), bytes.NewReader(chunk)), ErrChunkCompleting)
+	require.NoError(t, os.Remove(marker))
+
+	// 并发提交只有一个成功
+	const callers = 5
+	errs := make(chan error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.CompleteChunkedUpload(1, status.SessionID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrChunkCompleting) || errors.Is(err, ErrChunkSessionNotFound), err)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func splitChunks(data []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := size
+		if len(data) < n {
+			n = len(data)
+		}
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+func chunkChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func createTestTarGz(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for fileName, content := range files {
+		hdr := &tar.Header{Name: fileName, Mode: 0644, Size: int64(len(content))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+
+	return path
+}
+
 // createTestZip 创建测试用 ZIP 文件
 func createTestZip(t *testing.T, files map[string]string) string {
 	t.Helper()
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"io"
 	"path/filepath"
@@ -11,21 +12,21 @@ import (
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model"
 	"github.com/qs3c/anal_go_server/internal/model/dto"
-	"github.com/qs3c/anal_go_server/internal/pkg/oss"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
 type UserService struct {
-	userRepo  *repository.UserRepository
-	ossClient *oss.Client
-	cfg       *config.Config
+	userRepo *repository.UserRepository
+	store    storage.Store
+	cfg      *config.Config
 }
 
-func NewUserService(userRepo *repository.UserRepository, ossClient *oss.Client, cfg *config.Config) *UserService {
+func NewUserService(userRepo *repository.UserRepository, store storage.Store, cfg *config.Config) *UserService {
 	return &UserService{
-		userRepo:  userRepo,
-		ossClient: ossClient,
-		cfg:       cfg,
+		userRepo: userRepo,
+		store:    store,
+		cfg:      cfg,
 	}
 }
 
@@ -82,10 +83,11 @@ func (s *UserService) UpdateAvatar(userID int64, avatarURL string) error {
 	})
 }
 
-// UploadAvatar 上传用户头像到 OSS
+// UploadAvatar 上传用户头像到对象存储，头像需要长期有效的公开地址
 func (s *UserService) UploadAvatar(userID int64, file io.Reader, filename string) (string, error) {
-	if s.ossClient == nil {
-		return "", errors.New("OSS 客户端未配置")
+	publisher, ok := s.store.(storage.PublicURLer)
+	if s.store == nil || !ok {
+		return "", errors.New("对象存储未配置或不支持公开访问")
 	}
 
 	// 读取文件内容
@@ -100,9 +102,12 @@ func (s *UserService) UploadAvatar(userID int64, file io.Reader, filename string
 		ext = ".jpg"
 	}
 
-	// 上传到 OSS
-	avatarURL, err := s.ossClient.UploadAvatar(userID, data, ext)
-	if err != nil {
+	key := storage.AvatarKey(userID, ext)
+	avatarURL := publisher.PublicURL(key)
+	if avatarURL == "" {
+		return "", errors.New("对象存储未配置公开访问地址")
+	}
+	if err := s.store.Put(context.Background(), key, data, storage.PutOptions{ContentType: storage.ContentType(ext)}); err != nil {
 		return "", err
 	}
 
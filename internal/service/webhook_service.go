@@ -0,0 +1,271 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/webhook"
+	"github.com/qs3c/anal_go_server/internal/repository"
+)
+
+var (
+	ErrWebhookSignature  = errors.New("webhook 签名校验失败")
+	ErrWebhookDeliveryID = errors.New("缺少投递ID")
+)
+
+// 投递处理结果
+const (
+	DeliveryProcessing = "processing"
+	DeliveryProcessed  = "processed"
+	DeliveryIgnored    = "ignored"
+	DeliveryFailed     = "failed"
+)
+
+const (
+	// GithubWebhookPath GitHub 仓库 webhook 的 Payload URL 路径
+	GithubWebhookPath = "/api/v1/webhooks/github"
+	// recentDeliveries 分析设置中展示的投递记录数
+	recentDeliveries = 20
+)
+
+type WebhookService struct {
+	deliveryRepo    *repository.WebhookRepository
+	analysisRepo    *repository.AnalysisRepository
+	analysisService *AnalysisService
+}
+
+func NewWebhookService(
+	deliveryRepo *repository.WebhookRepository,
+	analysisRepo *repository.AnalysisRepository,
+	analysisService *AnalysisService,
+) *WebhookService {
+	return &WebhookService{
+		deliveryRepo:    deliveryRepo,
+		analysisRepo:    analysisRepo,
+		analysisService: analysisService,
+	}
+}
+
+// HandleGithub 处理 GitHub 投递：用仓库对应分析各自的密钥校验签名，按投递ID去重，
+// push 事件推送到监听的分支时，以推送后的提交重新运行签名匹配的分析
+func (s *WebhookService) HandleGithub(deliveryID, event, signature string, body []byte) (*dto.WebhookDeliveryItem, error) {
+	analysis, err := s.signedAnalysis(body, signature)
+	if err != nil {
+		return nil, err
+	}
+	if deliveryID == "" {
+		return nil, ErrWebhookDeliveryID
+	}
+
+	// 先写入投递记录占位，唯一索引保证并发的重复投递只处理一次
+	if existing, err := s.deliveryRepo.GetByDeliveryID(deliveryID); err == nil {
+		return duplicateDelivery(existing, analysis.ID)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	delivery := &model.WebhookDelivery{DeliveryID: deliveryID, AnalysisID: analysis.ID, Event: event, Status: DeliveryProcessing}
+	if err := s.deliveryRepo.Create(delivery); err != nil {
+		if existing, getErr := s.deliveryRepo.GetByDeliveryID(deliveryID); getErr == nil {
+			return duplicateDelivery(existing, analysis.ID)
+		}
+		return nil, err
+	}
+
+	results := s.process(delivery, analysis, event, body)
+	if len(results) > 0 {
+		data, err := json.Marshal(results)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Results = model.JSONText(data)
+	}
+	if r := []rune(delivery.Message); len(r) > 500 {
+		delivery.Message = string(r[:500])
+	}
+	if err := s.deliveryRepo.Update(delivery); err != nil {
+		return nil, err
+	}
+	return toDeliveryItem(delivery, false), nil
+}
+
+// signedAnalysis 找到请求体中的仓库对应、并且签名与其密钥匹配的分析
+// 每个分析有独立的密钥，只有持有该分析密钥的人才能触发它
+func (s *WebhookService) signedAnalysis(body []byte, signature string) (*model.Analysis, error) {
+	repo := webhook.RepositoryName(body)
+	if repo == "" {
+		return nil, ErrWebhookSignature
+	}
+	candidates, err := s.analysisRepo.ListWebhookCandidates(repo)
+	if err != nil {
+		return nil, err
+	}
+	suffix := "/" + strings.ToLower(repo)
+	for _, analysis := range candidates {
+		if !strings.HasSuffix(webhook.RepoPath(analysis.RepoURL), suffix) {
+			continue
+		}
+		if webhook.VerifySignature([]byte(analysis.WebhookSecret), body, signature) == nil {
+			return analysis, nil
+		}
+	}
+	return nil, ErrWebhookSignature
+}
+
+// duplicateDelivery 重复投递返回已有记录，投递ID属于其他分析时拒绝
+func duplicateDelivery(existing *model.WebhookDelivery, analysisID int64) (*dto.WebhookDeliveryItem, error) {
+	if existing.AnalysisID != analysisID {
+		return nil, ErrWebhookDeliveryID
+	}
+	return toDeliveryItem(existing, true), nil
+}
+
+// process 处理投递内容，结果写入 delivery 的状态字段，返回分析的触发结果
+func (s *WebhookService) process(delivery *model.WebhookDelivery, analysis *model.Analysis, event string, body []byte) []dto.WebhookTriggerResult {
+	ignore := func(message string) []dto.WebhookTriggerResult {
+		delivery.Status = DeliveryIgnored
+		delivery.Message = message
+		return nil
+	}
+
+	switch event {
+	case "ping":
+		return ignore("ping")
+	case "push":
+	default:
+		return ignore(fmt.Sprintf("不处理 %s 事件", event))
+	}
+
+	push, err := webhook.ParsePush(body)
+	if err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Message = err.Error()
+		return nil
+	}
+	delivery.Repository = webhook.RepoPath(push.Repository.HTMLURL)
+	if push.Repository.HTMLURL == "" {
+		delivery.Repository = webhook.RepoPath("github.com/" + push.Repository.FullName)
+	}
+	delivery.Ref = push.Ref
+	delivery.Commit = push.After
+
+	branch := push.Branch()
+	if branch == "" {
+		return ignore("不是分支推送")
+	}
+	if push.Deleted || strings.Trim(push.After, "0") == "" {
+		return ignore("分支已删除")
+	}
+
+	if webhook.RepoPath(analysis.RepoURL) != delivery.Repository {
+		return ignore("仓库与分析不匹配")
+	}
+	watch := analysis.WebhookBranch
+	if watch == "" {
+		watch = push.Repository.DefaultBranch
+	}
+	if branch != watch {
+		return ignore(fmt.Sprintf("不是监听的分支 %s", watch))
+	}
+
+	// 结果会返回给 GitHub，失败原因只记录日志，不带出内部错误
+	result := dto.WebhookTriggerResult{AnalysisID: analysis.ID}
+	resp, err := s.analysisService.Rerun(analysis.UserID, analysis.ID, push.After)
+	if err != nil {
+		log.Printf("Webhook %s: failed to rerun analysis %d: %v", delivery.DeliveryID, analysis.ID, err)
+		result.Error = "触发失败"
+		delivery.Status = DeliveryFailed
+		delivery.Message = "触发失败"
+		return []dto.WebhookTriggerResult{result}
+	}
+
+	result.JobID = resp.JobID
+	delivery.Status = DeliveryProcessed
+	delivery.Message = "已触发分析"
+	return []dto.WebhookTriggerResult{result}
+}
+
+// GetSettings 获取分析的推送触发设置、签名密钥和最近的投递记录
+func (s *WebhookService) GetSettings(userID, analysisID int64) (*dto.WebhookSettings, error) {
+	analysis, err := s.analysisService.getOwned(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &dto.WebhookSettings{
+		AnalysisID: analysis.ID,
+		Enabled:    analysis.WebhookEnabled,
+		Branch:     analysis.WebhookBranch,
+		URL:        GithubWebhookPath,
+		Secret:     analysis.WebhookSecret,
+		Deliveries: []*dto.WebhookDeliveryItem{},
+	}
+	deliveries, err := s.deliveryRepo.ListByAnalysisID(analysis.ID, recentDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deliveries {
+		settings.Deliveries = append(settings.Deliveries, toDeliveryItem(d, false))
+	}
+	return settings, nil
+}
+
+// UpdateSettings 开启或关闭推送触发，只支持 GitHub 仓库的 AI 分析
+// 首次开启或要求重新生成时为分析生成签名密钥
+func (s *WebhookService) UpdateSettings(userID, analysisID int64, req *dto.UpdateWebhookRequest) (*dto.WebhookSettings, error) {
+	analysis, err := s.analysisService.getOwned(userID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.CreationType != "ai" || analysis.SourceType != "github" {
+		return nil, ErrRerunNotSupported
+	}
+	if err := ValidateRef(req.Branch); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{
+		"webhook_enabled": req.Enabled,
+		"webhook_branch":  req.Branch,
+	}
+	if (req.Enabled && analysis.WebhookSecret == "") || req.RotateSecret {
+		secret, err := generateRandomCode(32)
+		if err != nil {
+			return nil, err
+		}
+		fields["webhook_secret"] = secret
+	}
+	if err := s.analysisRepo.UpdateFields(analysisID, fields); err != nil {
+		return nil, err
+	}
+	return s.GetSettings(userID, analysisID)
+}
+
+func toDeliveryItem(delivery *model.WebhookDelivery, duplicate bool) *dto.WebhookDeliveryItem {
+	item := &dto.WebhookDeliveryItem{
+		ID:         delivery.ID,
+		DeliveryID: delivery.DeliveryID,
+		Event:      delivery.Event,
+		Repository: delivery.Repository,
+		Ref:        delivery.Ref,
+		Commit:     delivery.Commit,
+		Status:     delivery.Status,
+		Message:    delivery.Message,
+		Results:    []dto.WebhookTriggerResult{},
+		Duplicate:  duplicate,
+		CreatedAt:  delivery.CreatedAt.Format(time.RFC3339),
+	}
+	if len(delivery.Results) > 0 {
+		if err := json.Unmarshal(delivery.Results, &item.Results); err != nil {
+			log.Printf("Webhook delivery %d: invalid results: %v", delivery.ID, err)
+		}
+	}
+	return item
+}
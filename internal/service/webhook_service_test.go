@@ -0,0 +1,198 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/model/dto"
+	"github.com/qs3c/anal_go_server/internal/pkg/webhook"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
+)
+
+func pushPayload(repo, branch, commit string) []byte {
+	return []byte(fmt.Sprintf(`{"ref":"refs/heads/%s","after":"%s","repository":{"full_name":"%s","html_url":"https://github.com/%s","default_branch":"main"}}`,
+		branch, commit, repo, repo))
+}
+
+func TestWebhookService_HandleGithub(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	cfg := &config.Config{
+		Subscription: config.SubscriptionConfig{
+			Levels: map[string]config.SubscriptionLevel{
+				"free": {DailyQuota: 5, MaxDepth: 3},
+			},
+		},
+		Models: []config.ModelConfig{{Name: "gpt-3.5-turbo", RequiredLevel: "free"}},
+	}
+	analysisRepo := repository.NewAnalysisRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	analysisService := NewAnalysisService(analysisRepo, jobRepo, userRepo, NewQuotaService(userRepo, cfg), nil, nil, nil, cfg)
+	service := NewWebhookService(repository.NewWebhookRepository(db), analysisRepo, analysisService)
+
+	user := testutil.TestUser(t, db)
+	newAnalysis := func(userID int64, repoURL, branch string) *model.Analysis {
+		analysis := testutil.TestAnalysis(t, db, userID, func(a *model.Analysis) {
+			a.CreationType = "ai"
+			a.SourceType = "github"
+			a.RepoURL = repoURL
+			a.AnalysisDepth = 2
+			a.ModelName = "gpt-3.5-turbo"
+		})
+		settings, err := service.UpdateSettings(userID, analysis.ID, &dto.UpdateWebhookRequest{Enabled: true, Branch: branch})
+		require.NoError(t, err)
+		analysis.WebhookSecret = settings.Secret
+		return analysis
+	}
+	onMain := newAnalysis(user.ID, "https://github.com/octo/repo.git", "")
+	onDev := newAnalysis(user.ID, "https://github.com/Octo/Repo", "dev")
+	disabled := newAnalysis(user.ID, "https://github.com/octo/repo", "")
+	_, err := service.UpdateSettings(user.ID, disabled.ID, &dto.UpdateWebhookRequest{Enabled: false})
+	require.NoError(t, err)
+	otherRepo := newAnalysis(user.ID, "https://github.com/octo/repo2", "")
+	assert.Len(t, onMain.WebhookSecret, 32)
+	assert.NotEqual(t, onMain.WebhookSecret, onDev.WebhookSecret)
+
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	body := pushPayload("Octo/Repo", "main", commit)
+	handle := func(analysis *model.Analysis, deliveryID, event string, body []byte) (*dto.WebhookDeliveryItem, error) {
+		return service.HandleGithub(deliveryID, event, webhook.Sign([]byte(analysis.WebhookSecret), body), body)
+	}
+
+	_, err = service.HandleGithub("d-1", "push", "sha256=bad", body)
+	assert.ErrorIs(t, err, ErrWebhookSignature)
+	_, err = handle(onMain, "", "push", body)
+	assert.ErrorIs(t, err, ErrWebhookDeliveryID)
+
+	// 关闭了推送触发的分析、其他仓库分析的密钥都不能用于这个仓库
+	_, err = handle(disabled, "d-1", "push", body)
+	assert.ErrorIs(t, err, ErrWebhookSignature)
+	_, err = handle(otherRepo, "d-1", "push", body)
+	assert.ErrorIs(t, err, ErrWebhookSignature)
+
+	// 只触发签名匹配的分析
+	item, err := handle(onMain, "d-1", "push", body)
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryProcessed, item.Status)
+	assert.Equal(t, "已触发分析", item.Message)
+	assert.Equal(t, "github.com/octo/repo", item.Repository)
+	assert.Equal(t, commit, item.Commit)
+	require.Len(t, item.Results, 1)
+	assert.Equal(t, onMain.ID, item.Results[0].AnalysisID)
+	job, err := jobRepo.GetByID(item.Results[0].JobID)
+	require.NoError(t, err)
+	assert.Equal(t, commit, job.Ref)
+
+	// 重复投递不再处理，其他分析不能冒用已有的投递ID
+	item, err = handle(onMain, "d-1", "push", body)
+	require.NoError(t, err)
+	assert.True(t, item.Duplicate)
+	assert.Len(t, item.Results, 1)
+	jobs, err := jobRepo.GetPendingJobs(10)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	_, err = handle(onDev, "d-1", "push", body)
+	assert.ErrorIs(t, err, ErrWebhookDeliveryID)
+
+	// 推送到其他分支时忽略
+	item, err = handle(onDev, "d-2", "push", body)
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryIgnored, item.Status)
+
+	item, err = handle(onDev, "d-3", "push", pushPayload("octo/repo", "dev", commit))
+	require.NoError(t, err)
+	require.Len(t, item.Results, 1)
+	assert.Equal(t, onDev.ID, item.Results[0].AnalysisID)
+
+	// 上一次运行还在进行时记录失败，不返回内部错误信息
+	item, err = handle(onDev, "d-4", "push", pushPayload("octo/repo", "dev", commit))
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryFailed, item.Status)
+	assert.Equal(t, "触发失败", item.Message)
+	assert.Equal(t, "触发失败", item.Results[0].Error)
+
+	for id, tc := range map[string]struct {
+		event string
+		body  []byte
+		want  string
+	}{
+		"d-ping":   {"ping", []byte(`{"zen":"hi","repository":{"full_name":"octo/repo"}}`), DeliveryIgnored},
+		"d-issue":  {"issues", []byte(`{"repository":{"full_name":"octo/repo"}}`), DeliveryIgnored},
+		"d-tag":    {"push", []byte(`{"ref":"refs/tags/v1","after":"abc","repository":{"full_name":"octo/repo"}}`), DeliveryIgnored},
+		"d-delete": {"push", pushPayload("octo/repo", "main", "0000000000000000000000000000000000000000"), DeliveryIgnored},
+		"d-bad":    {"push", []byte(`{"repository":{"full_name":"octo/repo"}}`), DeliveryFailed},
+	} {
+		item, err := handle(onMain, id, tc.event, tc.body)
+		require.NoError(t, err, id)
+		assert.Equal(t, tc.want, item.Status, id)
+		assert.Empty(t, item.Results, id)
+	}
+
+	// 投递记录只对签名匹配的分析可见
+	settings, err := service.GetSettings(user.ID, onMain.ID)
+	require.NoError(t, err)
+	assert.True(t, settings.Enabled)
+	assert.Equal(t, GithubWebhookPath, settings.URL)
+	assert.Equal(t, onMain.WebhookSecret, settings.Secret)
+	assert.Len(t, settings.Deliveries, 6)
+
+	// 其他用户指向同一仓库的分析看不到这些投递
+	other := testutil.TestUser(t, db)
+	copycat := newAnalysis(other.ID, "https://github.com/octo/repo", "")
+	settings, err = service.GetSettings(other.ID, copycat.ID)
+	require.NoError(t, err)
+	assert.Empty(t, settings.Deliveries)
+}
+
+func TestWebhookService_UpdateSettings(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	cfg := &config.Config{}
+	analysisRepo := repository.NewAnalysisRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	analysisService := NewAnalysisService(analysisRepo, repository.NewJobRepository(db), userRepo, NewQuotaService(userRepo, cfg), nil, nil, nil, cfg)
+	service := NewWebhookService(repository.NewWebhookRepository(db), analysisRepo, analysisService)
+
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID, func(a *model.Analysis) {
+		a.CreationType = "ai"
+		a.SourceType = "github"
+		a.RepoURL = "https://github.com/octo/repo"
+	})
+
+	settings, err := service.UpdateSettings(user.ID, analysis.ID, &dto.UpdateWebhookRequest{Enabled: true, Branch: "release"})
+	require.NoError(t, err)
+	assert.True(t, settings.Enabled)
+	assert.Equal(t, "release", settings.Branch)
+	secret := settings.Secret
+	assert.NotEmpty(t, secret)
+
+	// 关闭后保留密钥，重新生成时替换
+	settings, err = service.UpdateSettings(user.ID, analysis.ID, &dto.UpdateWebhookRequest{Enabled: false})
+	require.NoError(t, err)
+	assert.False(t, settings.Enabled)
+	assert.Equal(t, secret, settings.Secret)
+	settings, err = service.UpdateSettings(user.ID, analysis.ID, &dto.UpdateWebhookRequest{Enabled: true, RotateSecret: true})
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, settings.Secret)
+
+	_, err = service.UpdateSettings(user.ID, analysis.ID, &dto.UpdateWebhookRequest{Enabled: true, Branch: "-x"})
+	assert.ErrorIs(t, err, ErrInvalidRef)
+
+	manual := testutil.TestAnalysis(t, db, user.ID)
+	_, err = service.UpdateSettings(user.ID, manual.ID, &dto.UpdateWebhookRequest{Enabled: true})
+	assert.ErrorIs(t, err, ErrRerunNotSupported)
+
+	other := testutil.TestUser(t, db)
+	_, err = service.GetSettings(other.ID, analysis.ID)
+	assert.ErrorIs(t, err, ErrAnalysisPermission)
+}
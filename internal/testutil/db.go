@@ -31,6 +31,17 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 		&model.Comment{},
 		&model.Interaction{},
 		&model.Subscription{},
+		&model.Upload{},
+		&model.AnalysisMetrics{},
+		&model.AnalysisRule{},
+		&model.AnalysisSchedule{},
+		&model.WebhookDelivery{},
+		&model.NotificationChannel{},
+		&model.NotificationDelivery{},
+		&model.Notification{},
+		&model.PasswordResetToken{},
+		&model.RefreshToken{},
+		&model.AccessToken{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
@@ -83,6 +94,7 @@ func TruncateTables(t *testing.T, db *gorm.DB) {
 		"analysis_jobs",
 		"analyses",
 		"subscriptions",
+		"uploads",
 		"users",
 	}
 
@@ -0,0 +1,206 @@
+package testutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FakeOIDC 本地模拟的 OpenID Connect 身份提供方，提供发现文档、JWKS 和 token 端点
+type FakeOIDC struct {
+	Server   *httptest.Server
+	ClientID string
+
+	mu    sync.Mutex
+	key   *rsa.PrivateKey
+	kid   string
+	codes map[string]fakeOIDCCode
+}
+
+type fakeOIDCCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// NewFakeOIDC 启动模拟 IdP，测试结束时自动关闭
+func NewFakeOIDC(t *testing.T, clientID string) *FakeOIDC {
+	t.Helper()
+
+	f := &FakeOIDC{ClientID: clientID, codes: make(map[string]fakeOIDCCode)}
+	f.RotateKey(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 f.Issuer(),
+			"authorization_endpoint": f.Issuer() + "/authorize",
+			"token_endpoint":         f.Issuer() + "/token",
+			"jwks_uri":               f.Issuer() + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": f.kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", f.handleToken)
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Issuer IdP 的 issuer 地址
+func (f *FakeOIDC) Issuer() string {
+	return f.Server.URL
+}
+
+// RotateKey 更换签名密钥和 kid
+func (f *FakeOIDC) RotateKey(t *testing.T) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.key = key
+	f.kid = randomHex(8)
+}
+
+// Authorize 模拟用户在 IdP 登录并同意授权，返回回调中的 code 和 state
+// claims 写入签发的 ID Token，可覆盖默认的 iss、aud、exp 等
+func (f *FakeOIDC) Authorize(t *testing.T, authURL string, claims jwt.MapClaims) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Failed to parse auth url: %v", err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != f.ClientID || q.Get("response_type") != "code" {
+		t.Fatalf("Unexpected auth request: %s", authURL)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("Auth request without PKCE: %s", authURL)
+	}
+
+	merged := f.defaultClaims()
+	merged["nonce"] = q.Get("nonce")
+	for k, v := range claims {
+		merged[k] = v
+	}
+
+	code = randomHex(16)
+	f.mu.Lock()
+	f.codes[code] = fakeOIDCCode{challenge: q.Get("code_challenge"), claims: merged}
+	f.mu.Unlock()
+	return code, q.Get("state")
+}
+
+// SignIDToken 用当前密钥签发 ID Token，claims 覆盖默认声明
+func (f *FakeOIDC) SignIDToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	merged := f.defaultClaims()
+	for k, v := range claims {
+		merged[k] = v
+	}
+	signed, err := f.sign(merged)
+	if err != nil {
+		t.Fatalf("Failed to sign id token: %v", err)
+	}
+	return signed
+}
+
+func (f *FakeOIDC) defaultClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss": f.Issuer(),
+		"aud": f.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func (f *FakeOIDC) sign(claims jwt.MapClaims) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = f.kid
+	return token.SignedString(f.key)
+}
+
+func (f *FakeOIDC) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.Form.Get("client_id")
+	}
+	if clientID != f.ClientID {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	f.mu.Lock()
+	entry, ok := f.codes[r.Form.Get("code")]
+	delete(f.codes, r.Form.Get("code"))
+	f.mu.Unlock()
+
+	// 校验 PKCE：S256(code_verifier) 必须等于授权请求中的 code_challenge
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != entry.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := f.sign(entry.claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomHex(16),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
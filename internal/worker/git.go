@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -32,6 +33,13 @@ func classifyCloneError(output string, err error) *CloneError {
 	lower := strings.ToLower(output + " " + err.Error())
 
 	switch {
+	case strings.Contains(lower, "remote branch") ||
+		strings.Contains(lower, "couldn't find remote ref") ||
+		strings.Contains(lower, "not our ref"):
+		return &CloneError{
+			UserMessage: "指定的分支、标签或提交不存在",
+			RawError:    fmt.Errorf("%w, output: %s", err, output),
+		}
 	case strings.Contains(lower, "repository not found") ||
 		strings.Contains(lower, "not found"):
 		return &CloneError{
@@ -73,11 +81,12 @@ func classifyCloneError(output string, err error) *CloneError {
 
 // isTransient 判断克隆错误是否为暂时性错误（值得重试）
 func isTransient(ce *CloneError) bool {
-	// 仓库不存在、权限拒绝、仓库为空 → 不重试
+	// 仓库不存在、权限拒绝、仓库为空、引用无效 → 不重试
 	nonTransient := []string{
 		"仓库不存在",
 		"仓库访问被拒绝",
 		"仓库为空",
+		"分支、标签或提交",
 	}
 	for _, s := range nonTransient {
 		if strings.Contains(ce.UserMessage, s) {
@@ -87,8 +96,21 @@ func isTransient(ce *CloneError) bool {
 	return true
 }
 
-// CloneRepo 浅克隆仓库到指定目录，支持超时控制
+// CloneRepo 浅克隆仓库默认分支到指定目录，支持超时控制
 func CloneRepo(ctx context.Context, repoURL, destDir string, timeoutSeconds int) *CloneError {
+	return CloneRepoAt(ctx, repoURL, "", destDir, timeoutSeconds)
+}
+
+// CloneRepoAt 浅克隆仓库的指定引用，ref 为空时克隆默认分支
+// 完整的 40 位提交 SHA 通过 fetch 单个提交获取，其他视为分支或标签
+func CloneRepoAt(ctx context.Context, repoURL, ref, destDir string, timeoutSeconds int) *CloneError {
+	if strings.HasPrefix(ref, "-") {
+		return &CloneError{
+			UserMessage: "无效的分支、标签或提交",
+			RawError:    fmt.Errorf("invalid ref %q", ref),
+		}
+	}
+
 	// 确保目标目录不存在
 	if _, err := os.Stat(destDir); err == nil {
 		if err := os.RemoveAll(destDir); err != nil {
@@ -116,21 +138,40 @@ func CloneRepo(ctx context.Context, repoURL, destDir string, timeoutSeconds int)
 	defer cancel()
 
 	// 执行浅克隆
-	cmd := exec.CommandContext(cloneCtx, "git", "clone", "--depth", "1", repoURL, destDir)
-	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var commands [][]string
+	switch {
+	case ref == "":
+		commands = [][]string{{"clone", "--depth", "1", "--", repoURL, destDir}}
+	case commitSHA.MatchString(ref):
+		commands = [][]string{
+			{"init", "--quiet", destDir},
+			{"-C", destDir, "fetch", "--depth", "1", "--", repoURL, ref},
+			{"-C", destDir, "checkout", "--quiet", "FETCH_HEAD"},
+		}
+	default:
+		commands = [][]string{{"clone", "--depth", "1", "--branch", ref, "--", repoURL, destDir}}
+	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// 克隆失败，清理残留目录
-		os.RemoveAll(destDir)
-		return classifyCloneError(string(output), err)
+	for _, args := range commands {
+		cmd := exec.CommandContext(cloneCtx, "git", args...)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			// 克隆失败，清理残留目录
+			os.RemoveAll(destDir)
+			return classifyCloneError(string(output), err)
+		}
 	}
 
 	return nil
 }
 
+// commitSHA 完整的提交 SHA
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
 // CloneRepoWithRetry 带重试的克隆，指数退避，非暂时性错误不重试
-func CloneRepoWithRetry(ctx context.Context, repoURL, destDir string, timeoutSeconds, maxRetries int) error {
+func CloneRepoWithRetry(ctx context.Context, repoURL, ref, destDir string, timeoutSeconds, maxRetries int) error {
 	if maxRetries <= 0 {
 		maxRetries = 2
 	}
@@ -150,7 +191,7 @@ func CloneRepoWithRetry(ctx context.Context, repoURL, destDir string, timeoutSec
 			}
 		}
 
-		lastErr = CloneRepo(ctx, repoURL, destDir, timeoutSeconds)
+		lastErr = CloneRepoAt(ctx, repoURL, ref, destDir, timeoutSeconds)
 		if lastErr == nil {
 			return nil
 		}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"encoding/json"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
+)
+
+// computeMetrics 根据框图 JSON 计算架构指标
+func computeMetrics(analysisID int64, diagramJSON []byte) (*model.AnalysisMetrics, error) {
+	d, err := diagram.Parse(diagramJSON)
+	if err != nil {
+		return nil, err
+	}
+	m := diagram.ComputeMetrics(d)
+	details, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &model.AnalysisMetrics{
+		AnalysisID:      analysisID,
+		StructCount:     m.StructCount,
+		ConnectionCount: m.ConnectionCount,
+		PackageCount:    m.PackageCount,
+		CycleCount:      len(m.Cycles),
+		MaxFanIn:        m.MaxFanIn,
+		MaxFanOut:       m.MaxFanOut,
+		AvgFanOut:       m.AvgFanOut,
+		Details:         model.JSONText(details),
+	}
+	for _, p := range m.Packages {
+		metrics.MaxInstability = max(metrics.MaxInstability, p.Instability)
+	}
+	return metrics, nil
+}
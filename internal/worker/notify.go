@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/notify"
+)
+
+// notifyTimeout 单次事件通知（含首次 webhook 尝试）的最长耗时，失败的投递由重试任务接手
+const notifyTimeout = 30 * time.Second
+
+// notify 异步通知分析结果（站内通知和用户配置的渠道），不阻塞任务处理
+func (p *Processor) notify(eventType string, job *model.AnalysisJob, errMsg string) {
+	if p.notifier == nil && p.inbox == nil {
+		return
+	}
+
+	event := &notify.Event{
+		Type:       eventType,
+		UserID:     job.UserID,
+		AnalysisID: job.AnalysisID,
+		JobID:      job.ID,
+		Error:      errMsg,
+		OccurredAt: time.Now(),
+	}
+	if analysis, err := p.analysisRepo.GetByID(job.AnalysisID); err == nil {
+		event.Title = analysis.Title
+	}
+
+	go func() {
+		if p.inbox != nil {
+			notificationType := model.NotificationAnalysisCompleted
+			if eventType == notify.EventAnalysisFailed {
+				notificationType = model.NotificationAnalysisFailed
+			}
+			p.inbox.Notify(&model.Notification{
+				UserID:     event.UserID,
+				Type:       notificationType,
+				AnalysisID: event.AnalysisID,
+				JobID:      event.JobID,
+				Title:      event.Title,
+				Content:    event.Error,
+			})
+		}
+
+		if p.notifier != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := p.notifier.Notify(ctx, event); err != nil {
+				log.Printf("Job %d: failed to send notifications: %v", job.ID, err)
+			}
+		}
+	}()
+}
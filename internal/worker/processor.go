@@ -13,18 +13,23 @@ import (
 
 	"github.com/qs3c/anal_go_server/config"
 	"github.com/qs3c/anal_go_server/internal/model"
-	"github.com/qs3c/anal_go_server/internal/pkg/oss"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
+	"github.com/qs3c/anal_go_server/internal/pkg/notify"
 	"github.com/qs3c/anal_go_server/internal/pkg/pubsub"
 	"github.com/qs3c/anal_go_server/internal/pkg/queue"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/service"
 )
 
 // Processor 任务处理器
 type Processor struct {
 	jobRepo      *repository.JobRepository
 	analysisRepo *repository.AnalysisRepository
-	ossClient    *oss.Client
+	stores       *storage.Set
 	publisher    *pubsub.Publisher
+	notifier     *notify.Notifier
+	inbox        *service.NotificationService
 	cfg          *config.Config
 }
 
@@ -32,15 +37,19 @@ type Processor struct {
 func NewProcessor(
 	jobRepo *repository.JobRepository,
 	analysisRepo *repository.AnalysisRepository,
-	ossClient *oss.Client,
+	stores *storage.Set,
 	publisher *pubsub.Publisher,
+	notifier *notify.Notifier,
+	inbox *service.NotificationService,
 	cfg *config.Config,
 ) *Processor {
 	return &Processor{
 		jobRepo:      jobRepo,
 		analysisRepo: analysisRepo,
-		ossClient:    ossClient,
+		stores:       stores,
 		publisher:    publisher,
+		notifier:     notifier,
+		inbox:        inbox,
 		cfg:          cfg,
 	}
 }
@@ -83,6 +92,7 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 		p.jobRepo.Update(job)
 		p.analysisRepo.UpdateStatus(job.AnalysisID, "failed")
 		publishProgress(step, "failed", errMsg)
+		p.notify(notify.EventAnalysisFailed, job, errMsg)
 		return err
 	}
 
@@ -90,8 +100,8 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 	var projectPath string
 	var needCleanup bool
 
-	if msg.SourceType == "upload" {
-		// Upload mode: use already uploaded files
+	if msg.SourceType == "upload" || msg.SourceType == "module" {
+		// Upload / module mode: use already uploaded (or proxy-downloaded) files
 		uploadRoot := filepath.Join(p.cfg.Upload.TempDir, msg.UploadID)
 		if _, err := os.Stat(uploadRoot); os.IsNotExist(err) {
 			return handleError(pubsub.StepCloning, fmt.Errorf("上传文件不存在或已过期"))
@@ -113,7 +123,7 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 		projectPath = GetTempDir(job.ID)
 		needCleanup = true
 
-		log.Printf("Job %d: cloning repo %s", job.ID, msg.RepoURL)
+		log.Printf("Job %d: cloning repo %s (ref %q)", job.ID, msg.RepoURL, msg.Ref)
 		job.CurrentStep = "正在克隆仓库"
 		p.jobRepo.Update(job)
 		publishProgress(pubsub.StepCloning, "processing", "")
@@ -122,7 +132,7 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 			return handleError(pubsub.StepCloning, err)
 		}
 
-		if err := CloneRepoWithRetry(ctx, msg.RepoURL, projectPath,
+		if err := CloneRepoWithRetry(ctx, msg.RepoURL, msg.Ref, projectPath,
 			p.cfg.Clone.TimeoutSeconds, p.cfg.Clone.MaxRetries); err != nil {
 			return handleError(pubsub.StepCloning, err)
 		}
@@ -181,25 +191,21 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 		return handleError(pubsub.StepUploading, fmt.Errorf("failed to generate visualizer JSON: %w", err))
 	}
 
-	// 上传到 OSS（带重试），失败则降级到本地存储
-	var diagramURL string
-	if p.ossClient != nil {
-		diagramURL, err = p.ossClient.UploadDiagramWithRetry(job.AnalysisID, []byte(visualizerJSON))
-		if err != nil {
-			// OSS 重试全部失败，降级到本地存储
-			log.Printf("Job %d: OSS upload failed after retries: %v, falling back to local storage", job.ID, err)
-			diagramURL, err = p.saveLocalDiagram(job.AnalysisID, []byte(visualizerJSON))
-			if err != nil {
-				return handleError(pubsub.StepUploading, fmt.Errorf("failed to save diagram locally: %w", err))
-			}
-		}
-	} else {
-		// 无 OSS 客户端，直接本地存储
-		diagramURL, err = p.saveLocalDiagram(job.AnalysisID, []byte(visualizerJSON))
-		if err != nil {
-			return handleError(pubsub.StepUploading, fmt.Errorf("failed to save diagram locally: %w", err))
-		}
-		log.Printf("Job %d: saved diagram locally (OSS not configured)", job.ID)
+	// 统一升级为当前版本的框图格式
+	diagramJSON, _, err := diagram.Migrate([]byte(visualizerJSON))
+	if err != nil {
+		return handleError(pubsub.StepUploading, fmt.Errorf("failed to migrate diagram: %w", err))
+	}
+
+	// 压缩后写入主存储（带重试），失败则降级到本地存储，由 Reuploader 稍后同步
+	stored, err := storage.Compress(diagramJSON)
+	if err != nil {
+		return handleError(pubsub.StepUploading, fmt.Errorf("failed to compress diagram: %w", err))
+	}
+	diagramKey := storage.DiagramKey(job.AnalysisID)
+	diagramBackend, err := p.putDiagram(ctx, diagramKey, stored)
+	if err != nil {
+		return handleError(pubsub.StepUploading, err)
 	}
 
 	// Step 5: 更新数据库
@@ -211,29 +217,40 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 		return handleError(pubsub.StepDone, fmt.Errorf("failed to get analysis: %w", err))
 	}
 
-	analysis.Status = "completed"
-	analysis.DiagramOSSURL = diagramURL
-	analysis.DiagramSize = result.TotalStructs // 记录结构体数量
-
 	// 如果描述为空，根据分析结果自动生成
 	if analysis.Description == "" {
 		analysis.Description = generateDescription(analysis, result)
 	}
 
-	if err := p.analysisRepo.Update(analysis); err != nil {
+	if err := p.saveDiagram(ctx, analysis, diagramKey, diagramBackend, stored); err != nil {
 		return handleError(pubsub.StepDone, fmt.Errorf("failed to update analysis: %w", err))
 	}
 
+	// 计算架构指标，失败不影响分析结果
+	if metrics, err := computeMetrics(analysis.ID, diagramJSON); err != nil {
+		log.Printf("Job %d: failed to compute metrics: %v", job.ID, err)
+	} else if err := p.analysisRepo.SaveMetrics(metrics); err != nil {
+		log.Printf("Job %d: failed to save metrics: %v", job.ID, err)
+	}
+
+	// 检查架构规则，违规明细记录在任务结果中
+	ruleResult, err := p.checkRules(analysis.ID, diagramJSON)
+	if err != nil {
+		log.Printf("Job %d: failed to check rules: %v", job.ID, err)
+	}
+
 	// 更新 Job
 	job.Status = "completed"
 	job.CurrentStep = "分析完成"
 	completedAt := time.Now()
 	job.CompletedAt = &completedAt
 	job.ElapsedSeconds = int(completedAt.Sub(*job.StartedAt).Seconds())
+	job.RuleResult = ruleResult
 	p.jobRepo.Update(job)
 
 	// 推送完成消息
 	publishProgress(pubsub.StepDone, "completed", "")
+	p.notify(notify.EventAnalysisCompleted, job, "")
 
 	log.Printf("Job %d: completed in %d seconds, found %d structs, %d deps",
 		job.ID, job.ElapsedSeconds, result.TotalStructs, result.TotalDeps)
@@ -241,17 +258,54 @@ func (p *Processor) Process(ctx context.Context, msg *queue.JobMessage) error {
 	return nil
 }
 
-// saveLocalDiagram 保存 diagram 到本地文件，返回 local:// URL
-func (p *Processor) saveLocalDiagram(analysisID int64, data []byte) (string, error) {
-	localDir := filepath.Join(p.cfg.Upload.TempDir, "diagrams")
-	if err := os.MkdirAll(localDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create diagram dir: %w", err)
+// putDiagram 写入主存储，失败时写入降级存储，返回实际写入的后端名称
+func (p *Processor) putDiagram(ctx context.Context, key string, stored *storage.Encoded) (string, error) {
+	primary := p.stores.For("")
+	if primary == nil {
+		return "", fmt.Errorf("storage not configured")
+	}
+
+	opts := storage.PutOptions{ContentType: "application/json", ContentEncoding: stored.Encoding}
+	err := storage.PutWithRetry(ctx, primary, key, stored.Data, opts)
+	if err == nil {
+		return primary.Name(), nil
+	}
+	if p.stores.Fallback == nil {
+		return "", fmt.Errorf("failed to save diagram: %w", err)
+	}
+
+	log.Printf("Storage %s put failed after retries: %v, falling back to %s", primary.Name(), err, p.stores.Fallback.Name())
+	if err := p.stores.Fallback.Put(ctx, key, stored.Data, opts); err != nil {
+		return "", fmt.Errorf("failed to save diagram to fallback storage: %w", err)
+	}
+	return p.stores.Fallback.Name(), nil
+}
+
+// saveDiagram 把新框图记录到分析上并标记完成，保存成功后删除重新分析前的旧框图对象
+// 每次分析都会生成新的 key，不删除的话每次重新分析都会遗留一个对象
+func (p *Processor) saveDiagram(ctx context.Context, analysis *model.Analysis, key, backend string, stored *storage.Encoded) error {
+	oldKey, oldBackend := analysis.DiagramKey, analysis.DiagramBackend
+
+	analysis.Status = "completed"
+	analysis.DiagramKey = key
+	analysis.DiagramBackend = backend
+	analysis.DiagramSize = stored.RawSize
+	analysis.DiagramStoredSize = len(stored.Data)
+	analysis.DiagramEncoding = stored.Encoding
+	analysis.DiagramETag = stored.ETag
+	analysis.DiagramSchemaVersion = diagram.SchemaVersion
+	if err := p.analysisRepo.Update(analysis); err != nil {
+		return err
 	}
-	localPath := filepath.Join(localDir, fmt.Sprintf("%d.json", analysisID))
-	if err := os.WriteFile(localPath, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to write diagram file: %w", err)
+
+	if oldKey != "" && oldKey != key {
+		if old := p.stores.For(oldBackend); old != nil {
+			if err := old.Delete(ctx, oldKey); err != nil {
+				log.Printf("Analysis %d: failed to delete old diagram %s: %v", analysis.ID, oldKey, err)
+			}
+		}
 	}
-	return fmt.Sprintf("local://%d", analysisID), nil
+	return nil
 }
 
 // getModelConfig 根据模型名获取提供商和 API Key
@@ -270,7 +324,9 @@ func generateDescription(analysis *model.Analysis, result *analyzer.Result) stri
 	var parts []string
 
 	// 来源信息
-	if analysis.RepoURL != "" {
+	if analysis.SourceType == "module" {
+		parts = append(parts, fmt.Sprintf("分析模块 %s", analysis.RepoURL))
+	} else if analysis.RepoURL != "" {
 		// 提取仓库名
 		repoName := analysis.RepoURL
 		if idx := len(repoName) - 1; idx > 0 {
@@ -1,11 +1,19 @@
 package worker
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/qs3c/anal_go_server/config"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
+	"github.com/qs3c/anal_go_server/internal/repository"
+	"github.com/qs3c/anal_go_server/internal/testutil"
 )
 
 func TestNewProcessor(t *testing.T) {
@@ -13,7 +21,7 @@ func TestNewProcessor(t *testing.T) {
 
 	// Test that NewProcessor doesn't panic with nil dependencies
 	// In production, dependencies would be properly initialized
-	processor := NewProcessor(nil, nil, nil, nil, cfg)
+	processor := NewProcessor(nil, nil, nil, nil, nil, nil, cfg)
 
 	assert.NotNil(t, processor)
 	assert.Equal(t, cfg, processor.cfg)
@@ -184,12 +192,12 @@ func TestNewProcessor_WithAllNilDeps(t *testing.T) {
 		},
 	}
 
-	processor := NewProcessor(nil, nil, nil, nil, cfg)
+	processor := NewProcessor(nil, nil, nil, nil, nil, nil, cfg)
 
 	assert.NotNil(t, processor)
 	assert.Nil(t, processor.jobRepo)
 	assert.Nil(t, processor.analysisRepo)
-	assert.Nil(t, processor.ossClient)
+	assert.Nil(t, processor.stores)
 	assert.Nil(t, processor.publisher)
 	assert.Equal(t, cfg, processor.cfg)
 
@@ -214,3 +222,46 @@ func TestProcessor_GetModelConfig_FirstMatchWins(t *testing.T) {
 	assert.Equal(t, "first", provider)
 	assert.Equal(t, "key1", apiKey)
 }
+
+func TestProcessor_SaveDiagram_DeletesOldObject(t *testing.T) {
+	db := testutil.SetupTestDB(t)
+	defer testutil.CleanupTestDB(t, db)
+
+	root := t.TempDir()
+	stores := storage.NewSet(storage.NewLocal(root, "", "secret"), nil)
+	analysisRepo := repository.NewAnalysisRepository(db)
+	processor := NewProcessor(nil, analysisRepo, stores, nil, nil, nil, &config.Config{})
+	user := testutil.TestUser(t, db)
+	analysis := testutil.TestAnalysis(t, db, user.ID)
+	ctx := context.Background()
+
+	// 模拟两次分析：每次写入新 key 后保存到分析上
+	var keys []string
+	for i := 0; i < 2; i++ {
+		stored, err := storage.Compress([]byte(`{"nodes":[]}`))
+		require.NoError(t, err)
+		key := storage.DiagramKey(analysis.ID)
+		backend, err := processor.putDiagram(ctx, key, stored)
+		require.NoError(t, err)
+
+		current, err := analysisRepo.GetByID(analysis.ID)
+		require.NoError(t, err)
+		require.NoError(t, processor.saveDiagram(ctx, current, key, backend, stored))
+		keys = append(keys, key)
+	}
+	require.NotEqual(t, keys[0], keys[1])
+
+	// 只保留最新的框图对象
+	entries, err := os.ReadDir(filepath.Join(root, "diagrams", fmt.Sprint(analysis.ID)))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	_, err = stores.Primary.Stat(ctx, keys[0])
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = stores.Primary.Stat(ctx, keys[1])
+	assert.NoError(t, err)
+
+	saved, err := analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, keys[1], saved.DiagramKey)
+	assert.Equal(t, "completed", saved.Status)
+}
@@ -2,35 +2,32 @@ package worker
 
 import (
 	"context"
-	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/qs3c/anal_go_server/config"
-	"github.com/qs3c/anal_go_server/internal/pkg/oss"
+	"github.com/qs3c/anal_go_server/internal/pkg/storage"
 	"github.com/qs3c/anal_go_server/internal/repository"
 )
 
 const reuploadInterval = 5 * time.Minute
 
-// Reuploader 后台异步重传本地 diagram 到 OSS
+// Reuploader 后台异步将降级存储中的 diagram 同步到主存储
 type Reuploader struct {
 	analysisRepo *repository.AnalysisRepository
-	ossClient    *oss.Client
+	stores       *storage.Set
 	cfg          *config.Config
 }
 
 // NewReuploader 创建重传器
 func NewReuploader(
 	analysisRepo *repository.AnalysisRepository,
-	ossClient *oss.Client,
+	stores *storage.Set,
 	cfg *config.Config,
 ) *Reuploader {
 	return &Reuploader{
 		analysisRepo: analysisRepo,
-		ossClient:    ossClient,
+		stores:       stores,
 		cfg:          cfg,
 	}
 }
@@ -38,7 +35,7 @@ func NewReuploader(
 // Start 启动后台重传循环
 func (r *Reuploader) Start(ctx context.Context) {
 	// 启动后先执行一次
-	r.run()
+	r.run(ctx)
 
 	ticker := time.NewTicker(reuploadInterval)
 	defer ticker.Stop()
@@ -49,15 +46,20 @@ func (r *Reuploader) Start(ctx context.Context) {
 			log.Println("Reuploader stopped")
 			return
 		case <-ticker.C:
-			r.run()
+			r.run(ctx)
 		}
 	}
 }
 
-func (r *Reuploader) run() {
-	analyses, err := r.analysisRepo.ListLocalDiagrams()
+func (r *Reuploader) run(ctx context.Context) {
+	primary, fallback := r.stores.Primary, r.stores.Fallback
+	if primary == nil || fallback == nil {
+		return
+	}
+
+	analyses, err := r.analysisRepo.ListDiagramsByBackend(fallback.Name())
 	if err != nil {
-		log.Printf("Reuploader: failed to query local diagrams: %v", err)
+		log.Printf("Reuploader: failed to query %s diagrams: %v", fallback.Name(), err)
 		return
 	}
 
@@ -65,31 +67,31 @@ func (r *Reuploader) run() {
 		return
 	}
 
-	log.Printf("Reuploader: found %d local diagrams to re-upload", len(analyses))
+	log.Printf("Reuploader: found %d %s diagrams to re-upload", len(analyses), fallback.Name())
 
 	for _, a := range analyses {
-		localPath := filepath.Join(r.cfg.Upload.TempDir, "diagrams", fmt.Sprintf("%d.json", a.ID))
-		data, err := os.ReadFile(localPath)
+		data, err := fallback.Get(ctx, a.DiagramKey)
 		if err != nil {
-			log.Printf("Reuploader: failed to read local diagram %d: %v", a.ID, err)
+			log.Printf("Reuploader: failed to read diagram %d: %v", a.ID, err)
 			continue
 		}
 
-		ossURL, err := r.ossClient.UploadDiagramWithRetry(a.ID, data)
-		if err != nil {
+		// 沿用原 key，数据库只需改后端名称
+		opts := storage.PutOptions{ContentType: "application/json", ContentEncoding: a.DiagramEncoding}
+		if err := storage.PutWithRetry(ctx, primary, a.DiagramKey, data, opts); err != nil {
 			log.Printf("Reuploader: failed to re-upload diagram %d: %v", a.ID, err)
 			continue
 		}
 
 		// 更新 DB
-		a.DiagramOSSURL = ossURL
+		a.DiagramBackend = primary.Name()
 		if err := r.analysisRepo.Update(a); err != nil {
 			log.Printf("Reuploader: failed to update DB for diagram %d: %v", a.ID, err)
 			continue
 		}
 
-		// 删除本地文件
-		os.Remove(localPath)
-		log.Printf("Reuploader: successfully re-uploaded diagram %d to OSS", a.ID)
+		// 删除降级存储中的文件
+		fallback.Delete(ctx, a.DiagramKey)
+		log.Printf("Reuploader: successfully re-uploaded diagram %d to %s", a.ID, primary.Name())
 	}
 }
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"encoding/json"
+
+	"github.com/qs3c/anal_go_server/internal/model"
+	"github.com/qs3c/anal_go_server/internal/pkg/diagram"
+)
+
+// checkRules 用分析上的架构规则检查本次生成的框图，没有规则时返回 nil
+func (p *Processor) checkRules(analysisID int64, diagramJSON []byte) (model.JSONText, error) {
+	rules, err := p.analysisRepo.ListRules(analysisID)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	d, err := diagram.Parse(diagramJSON)
+	if err != nil {
+		return nil, err
+	}
+	checkRules := make([]diagram.Rule, 0, len(rules))
+	for _, r := range rules {
+		checkRules = append(checkRules, diagram.Rule{Type: r.Type, From: r.FromPkg, To: r.ToPkg, Max: r.Max})
+	}
+	result, err := diagram.Check(d, checkRules)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return model.JSONText(data), nil
+}
@@ -0,0 +1,15 @@
+CREATE TABLE uploads (
+    id VARCHAR(64) PRIMARY KEY COMMENT '上传ID，同时是解压目录名',
+    user_id BIGINT NOT NULL COMMENT '上传者',
+    filename VARCHAR(255) COMMENT '原始文件名',
+    size BIGINT NOT NULL COMMENT '压缩包大小（字节）',
+    extracted_size BIGINT DEFAULT 0 COMMENT '解压后大小（字节）',
+    sha256 VARCHAR(64) COMMENT '压缩包 SHA-256',
+    status ENUM('active', 'expired') DEFAULT 'active',
+    expires_at DATETIME NOT NULL COMMENT '过期时间',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_id (user_id),
+    INDEX idx_status_expires (status, expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='上传文件表';
@@ -0,0 +1,2 @@
+ALTER TABLE uploads
+DROP COLUMN module;
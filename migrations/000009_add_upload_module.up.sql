@@ -0,0 +1,4 @@
+-- Record the module path@version for uploads downloaded from a Go module proxy
+
+ALTER TABLE uploads
+ADD COLUMN module VARCHAR(300) COMMENT '模块 path@version，仅 GOPROXY 下载' AFTER sha256;
@@ -0,0 +1,3 @@
+ALTER TABLE analyses
+DROP COLUMN diagram_backend,
+DROP COLUMN diagram_key;
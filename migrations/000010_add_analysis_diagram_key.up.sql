@@ -0,0 +1,17 @@
+-- Record diagram storage keys instead of full URLs
+
+ALTER TABLE analyses
+ADD COLUMN diagram_key VARCHAR(500) COMMENT '框图对象存储 key' AFTER diagram_oss_url,
+ADD COLUMN diagram_backend VARCHAR(20) COMMENT '框图所在存储后端：oss、s3、local' AFTER diagram_key;
+
+-- 本地降级存储的旧数据：local://<id> 对应 <temp_dir>/diagrams/<id>.json
+UPDATE analyses
+SET diagram_key = CONCAT('diagrams/', id, '.json'), diagram_backend = 'local'
+WHERE diagram_oss_url LIKE 'local://%';
+
+-- OSS 旧数据：https://<host>/<key>
+UPDATE analyses
+SET diagram_key = SUBSTRING(diagram_oss_url, LOCATE('/', diagram_oss_url, 9) + 1), diagram_backend = 'oss'
+WHERE diagram_oss_url LIKE 'https://%';
+
+-- diagram_oss_url 暂时保留以便回滚，不再写入
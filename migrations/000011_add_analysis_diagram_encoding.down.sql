@@ -0,0 +1,4 @@
+ALTER TABLE analyses
+DROP COLUMN diagram_etag,
+DROP COLUMN diagram_encoding,
+DROP COLUMN diagram_stored_size;
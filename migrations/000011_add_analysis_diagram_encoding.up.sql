@@ -0,0 +1,6 @@
+-- Store diagrams compressed and keep raw/stored sizes and an ETag for conditional requests
+
+ALTER TABLE analyses
+ADD COLUMN diagram_stored_size INT COMMENT '框图实际存储大小（压缩后，字节）' AFTER diagram_size,
+ADD COLUMN diagram_encoding VARCHAR(20) COMMENT '框图存储编码：gzip，空表示未压缩' AFTER diagram_stored_size,
+ADD COLUMN diagram_etag VARCHAR(64) COMMENT '按原始内容计算的 ETag' AFTER diagram_encoding;
@@ -0,0 +1,2 @@
+ALTER TABLE analyses
+DROP COLUMN diagram_schema_version;
@@ -0,0 +1,4 @@
+-- Record the diagram JSON schema version; older versions are upgraded on read
+
+ALTER TABLE analyses
+ADD COLUMN diagram_schema_version INT NOT NULL DEFAULT 0 COMMENT '框图格式版本，0 表示未记录版本的旧数据' AFTER diagram_etag;
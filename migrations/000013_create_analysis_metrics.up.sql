@@ -0,0 +1,19 @@
+CREATE TABLE analysis_metrics (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    analysis_id BIGINT NOT NULL COMMENT '分析ID',
+    struct_count INT NOT NULL DEFAULT 0 COMMENT '结构体数',
+    connection_count INT NOT NULL DEFAULT 0 COMMENT '去重后的依赖数',
+    package_count INT NOT NULL DEFAULT 0 COMMENT '包数',
+    cycle_count INT NOT NULL DEFAULT 0 COMMENT '循环依赖组数',
+    max_fan_in INT NOT NULL DEFAULT 0 COMMENT '最大扇入',
+    max_fan_out INT NOT NULL DEFAULT 0 COMMENT '最大扇出',
+    avg_fan_out DOUBLE NOT NULL DEFAULT 0 COMMENT '平均扇出',
+    max_instability DOUBLE NOT NULL DEFAULT 0 COMMENT '包不稳定度最大值',
+    details JSON COMMENT '各结构体、包的指标明细',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (analysis_id) REFERENCES analyses(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_analysis_id (analysis_id),
+    INDEX idx_cycle_count (cycle_count),
+    INDEX idx_max_fan_out (max_fan_out)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分析架构指标表';
@@ -0,0 +1,4 @@
+ALTER TABLE analysis_jobs
+DROP COLUMN rule_result;
+
+DROP TABLE IF EXISTS analysis_rules;
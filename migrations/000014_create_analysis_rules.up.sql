@@ -0,0 +1,14 @@
+CREATE TABLE analysis_rules (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    analysis_id BIGINT NOT NULL COMMENT '分析ID',
+    type ENUM('no_dependency', 'no_cycles', 'max_fan_out', 'max_fan_in') NOT NULL COMMENT '规则类型',
+    from_pkg VARCHAR(200) COMMENT 'no_dependency 的依赖方包名，支持通配符',
+    to_pkg VARCHAR(200) COMMENT 'no_dependency 的被依赖方包名，支持通配符',
+    max INT COMMENT 'max_fan_out / max_fan_in 的上限',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (analysis_id) REFERENCES analyses(id) ON DELETE CASCADE,
+    INDEX idx_analysis_id (analysis_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='架构规则表';
+
+ALTER TABLE analysis_jobs
+ADD COLUMN rule_result JSON COMMENT '架构规则检查结果' AFTER elapsed_seconds;
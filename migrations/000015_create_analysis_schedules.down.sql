@@ -0,0 +1,4 @@
+ALTER TABLE analysis_jobs
+DROP COLUMN ref;
+
+DROP TABLE IF EXISTS analysis_schedules;
@@ -0,0 +1,22 @@
+CREATE TABLE analysis_schedules (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    analysis_id BIGINT NOT NULL COMMENT '分析ID',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    cron_expr VARCHAR(100) NOT NULL COMMENT 'cron 表达式（UTC）',
+    ref VARCHAR(200) COMMENT '分支、标签或提交，空表示默认分支',
+    enabled BOOLEAN DEFAULT TRUE COMMENT '是否启用',
+    last_run_at DATETIME COMMENT '上次触发时间',
+    next_run_at DATETIME COMMENT '下次触发时间，停用时为空',
+    last_job_id BIGINT COMMENT '上次创建的任务ID',
+    last_error VARCHAR(500) COMMENT '上次触发失败的原因',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (analysis_id) REFERENCES analyses(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_analysis_id (analysis_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_next_run_at (next_run_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分析定时任务表';
+
+ALTER TABLE analysis_jobs
+ADD COLUMN ref VARCHAR(200) COMMENT '克隆的分支、标签或提交' AFTER repo_url;
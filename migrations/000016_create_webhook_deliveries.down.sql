@@ -0,0 +1,6 @@
+ALTER TABLE analyses
+DROP INDEX idx_webhook_enabled,
+DROP COLUMN webhook_branch,
+DROP COLUMN webhook_enabled;
+
+DROP TABLE IF EXISTS webhook_deliveries;
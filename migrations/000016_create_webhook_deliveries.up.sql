@@ -0,0 +1,20 @@
+CREATE TABLE webhook_deliveries (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    delivery_id VARCHAR(100) NOT NULL COMMENT '投递ID（X-GitHub-Delivery）',
+    event VARCHAR(50) NOT NULL COMMENT '事件类型',
+    repository VARCHAR(300) COMMENT '规范化后的仓库路径 host/owner/repo',
+    ref VARCHAR(200) COMMENT '推送的引用',
+    `commit` VARCHAR(64) COMMENT '推送后的提交 SHA',
+    status ENUM('processing', 'processed', 'ignored', 'failed') NOT NULL COMMENT '处理结果',
+    message VARCHAR(500) COMMENT '处理说明',
+    results JSON COMMENT '每个匹配分析的触发结果',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_delivery_id (delivery_id),
+    INDEX idx_repository (repository),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='webhook 投递记录表';
+
+ALTER TABLE analyses
+ADD COLUMN webhook_enabled BOOLEAN DEFAULT FALSE COMMENT '推送时自动重新分析' AFTER start_file,
+ADD COLUMN webhook_branch VARCHAR(200) COMMENT '监听的分支，空表示默认分支' AFTER webhook_enabled,
+ADD INDEX idx_webhook_enabled (webhook_enabled);
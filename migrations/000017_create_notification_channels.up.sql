@@ -0,0 +1,37 @@
+CREATE TABLE notification_channels (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    type ENUM('webhook', 'email') NOT NULL COMMENT '渠道类型',
+    name VARCHAR(100) COMMENT '名称',
+    url VARCHAR(500) COMMENT 'webhook 地址',
+    secret VARCHAR(64) COMMENT 'webhook 签名密钥',
+    events JSON COMMENT '订阅的事件类型',
+    enabled BOOLEAN DEFAULT TRUE COMMENT '是否启用',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='通知渠道表';
+
+CREATE TABLE notification_deliveries (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    channel_id BIGINT NOT NULL COMMENT '渠道ID',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    event VARCHAR(50) NOT NULL COMMENT '事件类型',
+    analysis_id BIGINT COMMENT '分析ID',
+    job_id BIGINT COMMENT '任务ID',
+    payload JSON COMMENT '投递内容',
+    status ENUM('pending', 'succeeded', 'failed') NOT NULL COMMENT '投递状态',
+    attempts INT DEFAULT 0 COMMENT '已尝试次数',
+    next_attempt_at DATETIME COMMENT '下次重试时间',
+    response_status INT COMMENT 'webhook 最后一次的 HTTP 状态码',
+    last_error VARCHAR(500) COMMENT '最后一次失败原因',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (channel_id) REFERENCES notification_channels(id) ON DELETE CASCADE,
+    INDEX idx_channel_id (channel_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_status (status),
+    INDEX idx_next_attempt_at (next_attempt_at),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='通知投递记录表';
@@ -0,0 +1,16 @@
+CREATE TABLE notifications (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL COMMENT '接收通知的用户ID',
+    actor_id BIGINT COMMENT '触发通知的用户ID，任务事件为空',
+    type VARCHAR(30) NOT NULL COMMENT '通知类型: comment, reply, like, analysis_completed, analysis_failed',
+    analysis_id BIGINT COMMENT '分析ID',
+    comment_id BIGINT COMMENT '评论ID',
+    job_id BIGINT COMMENT '任务ID',
+    title VARCHAR(200) COMMENT '分析标题快照',
+    content VARCHAR(500) COMMENT '评论内容摘要或失败原因',
+    read_at DATETIME COMMENT '已读时间',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_read (user_id, read_at),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='站内通知表';
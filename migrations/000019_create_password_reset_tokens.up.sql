@@ -0,0 +1,14 @@
+ALTER TABLE users
+ADD COLUMN token_version INT DEFAULT 0 COMMENT 'Token 版本，修改密码时加一使已签发的 Token 失效' AFTER verification_expires_at;
+
+CREATE TABLE password_reset_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    token_hash VARCHAR(64) NOT NULL COMMENT '重置令牌的 SHA-256',
+    expires_at DATETIME NOT NULL COMMENT '过期时间',
+    used_at DATETIME COMMENT '使用时间，使用后或被新令牌取代时设置',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_token_hash (token_hash),
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='密码重置令牌表';
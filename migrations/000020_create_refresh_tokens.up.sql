@@ -0,0 +1,13 @@
+CREATE TABLE refresh_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    session_id VARCHAR(32) NOT NULL COMMENT '登录会话ID，轮换产生的令牌共享',
+    token_hash VARCHAR(64) NOT NULL COMMENT '刷新令牌的 SHA-256',
+    expires_at DATETIME NOT NULL COMMENT '过期时间',
+    revoked_at DATETIME COMMENT '撤销时间，轮换或退出登录时设置',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_token_hash (token_hash),
+    INDEX idx_user_id (user_id),
+    INDEX idx_session_id (session_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='刷新令牌表';
@@ -0,0 +1,14 @@
+CREATE TABLE access_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    name VARCHAR(100) NOT NULL COMMENT '名称',
+    prefix VARCHAR(16) NOT NULL COMMENT '令牌开头几位，用于辨认',
+    token_hash VARCHAR(64) NOT NULL COMMENT '令牌的 SHA-256',
+    scopes JSON COMMENT '权限范围',
+    expires_at DATETIME COMMENT '过期时间，为空表示永不过期',
+    last_used_at DATETIME COMMENT '最后使用时间',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_token_hash (token_hash),
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='个人访问令牌表';
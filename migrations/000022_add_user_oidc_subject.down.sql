@@ -0,0 +1,3 @@
+ALTER TABLE users
+DROP INDEX idx_oidc_subject,
+DROP COLUMN oidc_subject;
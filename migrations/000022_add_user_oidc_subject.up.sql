@@ -0,0 +1,3 @@
+ALTER TABLE users
+ADD COLUMN oidc_subject VARCHAR(255) COMMENT 'OIDC 身份提供方的 sub' AFTER wechat_openid,
+ADD UNIQUE INDEX idx_oidc_subject (oidc_subject);
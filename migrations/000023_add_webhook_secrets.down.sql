@@ -0,0 +1,6 @@
+ALTER TABLE webhook_deliveries
+DROP INDEX idx_analysis_id,
+DROP COLUMN analysis_id;
+
+ALTER TABLE analyses
+DROP COLUMN webhook_secret;
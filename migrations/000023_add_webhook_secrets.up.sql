@@ -0,0 +1,6 @@
+ALTER TABLE analyses
+ADD COLUMN webhook_secret VARCHAR(64) COMMENT 'GitHub webhook 签名密钥，开启推送触发时生成' AFTER webhook_branch;
+
+ALTER TABLE webhook_deliveries
+ADD COLUMN analysis_id BIGINT COMMENT '签名匹配的分析ID' AFTER delivery_id,
+ADD INDEX idx_analysis_id (analysis_id);